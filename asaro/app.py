@@ -38,6 +38,44 @@ class GetPlaylistRequestSchema(Schema):
         return data
 
 
+class GetTrackRequestSchema(Schema):
+    url = fields.Url(required=True)
+
+    @post_load
+    def transform_url(self, data, **kwargs):
+        match = re.match(
+            pattern="^https:\/\/music\.youtube\.com\/watch\?v=([a-zA-Z0-9-_]+)",
+            string=data["url"],
+        )
+        if match is None:
+            raise ValidationError(
+                "Invalid track URL. Check that it follows the format https://music.youtube.com/watch?v=",
+                field_name="url",
+            )
+
+        data["url"] = match.group(1)
+        return data
+
+
+class GetAlbumRequestSchema(Schema):
+    url = fields.Url(required=True)
+
+    @post_load
+    def transform_url(self, data, **kwargs):
+        match = re.match(
+            pattern="^https:\/\/music\.youtube\.com\/browse\/(MPREb_[a-zA-Z0-9-_]+)$",
+            string=data["url"],
+        )
+        if match is None:
+            raise ValidationError(
+                "Invalid album URL. Check that it follows the format https://music.youtube.com/browse/",
+                field_name="url",
+            )
+
+        data["url"] = match.group(1)
+        return data
+
+
 class CreatePlaylistRequestSchema(Schema):
     title = fields.Str(required=True)
     description = fields.Str(load_default=None)
@@ -48,6 +86,10 @@ class CreatePlaylistRequestSchema(Schema):
     )
 
 
+class SaveTracksRequestSchema(Schema):
+    track_ids = fields.List(fields.Str(required=True), required=True)
+
+
 class SearchTrackRequestSchema(Schema):
     q = fields.Str(required=True)
     search_filter = fields.Str(
@@ -63,7 +105,38 @@ class SearchTrackRequestSchema(Schema):
     ignore_spelling = fields.Bool(load_default=False)
 
 
-class TrackResponseSchema(Schema):
+class SearchAlbumRequestSchema(Schema):
+    q = fields.Str(required=True)
+    limit = fields.Int(strict=True, load_default=5)
+
+
+def largest_thumbnail_url(thumbnails):
+    """Returns the URL of the largest thumbnail, ytmusicapi sorts thumbnails by size in ascending order."""
+    if not thumbnails:
+        return None
+
+    return thumbnails[-1]["url"]
+
+
+class TrackMetadataSchema(Schema):
+    """Optional track metadata shared by playlist tracks and search results."""
+
+    album = fields.Raw(required=False, allow_none=True)
+    duration_seconds = fields.Int(required=False, allow_none=True)
+    isExplicit = fields.Bool(required=False, allow_none=True)
+    thumbnails = fields.List(fields.Raw(), required=False, allow_none=True)
+
+    @post_dump
+    def transform_metadata(self, data, **kwargs):
+        album = data.pop("album", None)
+        # album tracks only have the title of their album.
+        data["album"] = album["name"] if isinstance(album, dict) else album
+        data["explicit"] = bool(data.pop("isExplicit", False))
+        data["thumbnail_url"] = largest_thumbnail_url(data.pop("thumbnails", None))
+        return data
+
+
+class TrackResponseSchema(TrackMetadataSchema):
     videoId = fields.Str(required=True)
     title = fields.Str(required=True)
     artists = fields.List(fields.Raw(), required=True)
@@ -79,15 +152,58 @@ class PlaylistResponseSchema(Schema):
     id = fields.Str(required=True)
     title = fields.Str(required=True)
     description = fields.Str(required=False)
+    author = fields.Raw(required=False, allow_none=True)
+    thumbnails = fields.List(fields.Raw(), required=False, allow_none=True)
+    trackCount = fields.Int(required=False, allow_none=True)
     tracks = fields.List(fields.Nested(TrackResponseSchema(unknown=EXCLUDE)), required=True)
 
     @post_dump
     def transform_data(self, data, **kwargs):
+        author = data.pop("author", None)
         data["identifier"] = data.pop("id")
+        data["author"] = author["name"] if author else None
+        data["thumbnail_url"] = largest_thumbnail_url(data.pop("thumbnails", None))
+        data["track_count"] = data.pop("trackCount", None)
+        return data
+
+
+class SongResponseSchema(Schema):
+    videoId = fields.Str(required=True)
+    title = fields.Str(required=True)
+    author = fields.Str(required=True)
+    lengthSeconds = fields.Str(required=False, allow_none=True)
+    thumbnail = fields.Raw(required=False, allow_none=True)
+
+    @post_dump
+    def transform_data(self, data, **kwargs):
+        thumbnail = data.pop("thumbnail", None)
+        length_seconds = data.pop("lengthSeconds", None)
+        data["identifier"] = data.pop("videoId")
+        data["artists"] = [data.pop("author")]
+        data["duration_seconds"] = int(length_seconds) if length_seconds else None
+        data["thumbnail_url"] = largest_thumbnail_url(thumbnail["thumbnails"] if thumbnail else None)
+        return data
+
+
+class AlbumResponseSchema(Schema):
+    browseId = fields.Str(required=True)
+    title = fields.Str(required=True)
+    artists = fields.List(fields.Raw(), required=False, allow_none=True)
+    year = fields.Str(required=False, allow_none=True)
+    thumbnails = fields.List(fields.Raw(), required=False, allow_none=True)
+    trackCount = fields.Int(required=False, allow_none=True)
+    tracks = fields.List(fields.Nested(TrackResponseSchema(unknown=EXCLUDE)), required=False)
+
+    @post_dump
+    def transform_data(self, data, **kwargs):
+        data["identifier"] = data.pop("browseId")
+        data["artists"] = [x["name"] for x in data.pop("artists", None) or []]
+        data["thumbnail_url"] = largest_thumbnail_url(data.pop("thumbnails", None))
+        data["track_count"] = data.pop("trackCount", None)
         return data
 
 
-class SearchTrackResponseSchema(Schema):
+class SearchTrackResponseSchema(TrackMetadataSchema):
     category = fields.Str(required=True)
     resultType = fields.Str(required=True)
     videoId = fields.Str(required=True)
@@ -204,6 +320,58 @@ def create_playlist(payload):
     return {"data": f"https://music.youtube.com/playlist?list={result}"}
 
 
+@application.post("/tracks")
+@validate_request(GetTrackRequestSchema())
+@cache.cached()
+def fetch_track(payload):
+    song_schema = SongResponseSchema(unknown=EXCLUDE)
+    result = ytmusic.get_song(videoId=payload["url"])
+
+    return {"data": song_schema.dump(result["videoDetails"])}
+
+
+@application.post("/albums")
+@validate_request(GetAlbumRequestSchema())
+@cache.cached()
+def fetch_album(payload):
+    album_schema = AlbumResponseSchema(unknown=EXCLUDE)
+    result = ytmusic.get_album(browseId=payload["url"])
+    result["browseId"] = payload["url"]
+
+    return {"data": album_schema.dump(result)}
+
+
+@application.post("/albums/search")
+@validate_request(SearchAlbumRequestSchema())
+@cache.cached(timeout=43200)
+def search_album(payload):
+    search_schema = AlbumResponseSchema(unknown=EXCLUDE, many=True)
+    results = ytmusic.search(query=payload["q"], filter="albums", limit=payload["limit"])
+
+    return {"data": search_schema.dump(results)}
+
+
+@application.get("/library/tracks")
+@requires_auth
+def fetch_liked_songs():
+    track_schema = TrackResponseSchema(unknown=EXCLUDE, many=True)
+    result = ytmusic.get_liked_songs(limit=None)
+
+    return {"data": track_schema.dump(result["tracks"])}
+
+
+@application.put("/library/tracks")
+@requires_auth
+@validate_request(SaveTracksRequestSchema())
+def save_tracks(payload):
+    for track_id in payload["track_ids"]:
+        result = ytmusic.rate_song(videoId=track_id, rating="LIKE")
+        if result is None:
+            return {"message": "TrackRatingError", "errors": [f"Track {track_id} could not be liked"]}, 500
+
+    return {"data": len(payload["track_ids"])}
+
+
 @application.post("/tracks/search")
 @validate_request(SearchTrackRequestSchema())
 @cache.cached(timeout=43200)
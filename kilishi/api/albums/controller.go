@@ -0,0 +1,82 @@
+package albums
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/prettyirrelevant/kilishi/api/database"
+	"github.com/prettyirrelevant/kilishi/api/presenter"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/aggregator"
+	"github.com/prettyirrelevant/kilishi/utils"
+)
+
+// AlbumLink is the equivalent of a resolved album on another platform.
+type AlbumLink struct {
+	// Album is nil when the album could not be found, Error then holds the reason.
+	Album *utils.Album `json:"album"`
+	Error string       `json:"error,omitempty"`
+}
+
+// ResolveAlbumResponse is the album a link points at and its equivalents, keyed by the platform they are on.
+type ResolveAlbumResponse struct {
+	Source utils.Album                                      `json:"source"`
+	Links  map[aggregator.MusicStreamingPlatform]*AlbumLink `json:"links"`
+}
+
+// ResolveAlbumController fetches the album a link points at and searches for it on every other platform that supports albums.
+// A platform the album could not be found on is reported in its link instead of failing the request.
+func ResolveAlbumController(ag *aggregator.MusicStreamingPlatformsAggregator, _ *database.Database) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var queryParams ResolveAlbumRequest
+
+		err := c.QueryParser(&queryParams)
+		if err != nil {
+			return c.
+				Status(http.StatusBadRequest).
+				JSON(presenter.ErrorResponse("validation error", err.Error()))
+		}
+
+		queryParams.DetectPlatform()
+		if ok, errors := queryParams.Validate(); !ok {
+			return c.
+				Status(http.StatusUnprocessableEntity).
+				JSON(presenter.ErrorResponse("validation error", errors...))
+		}
+
+		source, err := ag.GetAlbum(c.UserContext(), queryParams.Platform, strings.TrimSpace(queryParams.URL))
+		if err != nil {
+			return presenter.PlatformErrorResponse(c, "error retrieving album", err)
+		}
+
+		response := ResolveAlbumResponse{Source: source, Links: make(map[aggregator.MusicStreamingPlatform]*AlbumLink)}
+		var wg sync.WaitGroup
+
+		// the links are created before the searches start, so every goroutine writes to its own link without a lock.
+		for _, factory := range ag.SupportedPlatforms() {
+			if factory.Name == queryParams.Platform || !factory.Capabilities.Albums {
+				continue
+			}
+
+			link := &AlbumLink{}
+			response.Links[factory.Name] = link
+
+			wg.Add(1)
+			go func(destination aggregator.MusicStreamingPlatform) {
+				defer wg.Done()
+
+				match, _err := ag.FindAlbum(c.UserContext(), source, destination, queryParams.MinConfidence)
+				if _err != nil {
+					link.Error = _err.Error()
+					return
+				}
+				link.Album = &match
+			}(factory.Name)
+		}
+		wg.Wait()
+
+		return c.Status(http.StatusOK).JSON(presenter.SuccessResponse("album resolved successfully", response))
+	}
+}
@@ -1,8 +1,9 @@
 package auth
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
@@ -10,13 +11,81 @@ import (
 
 	"github.com/prettyirrelevant/kilishi/api/database"
 	"github.com/prettyirrelevant/kilishi/api/presenter"
+	"github.com/prettyirrelevant/kilishi/api/users"
 	"github.com/prettyirrelevant/kilishi/streaming_platforms/aggregator"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
 	"github.com/prettyirrelevant/kilishi/utils"
 )
 
-// SpotifyOauthCallbackController handles Spotify OAuth callback requests.
-func SpotifyOauthCallbackController(ag *aggregator.MusicStreamingPlatformsAggregator, db *database.Database) fiber.Handler {
+// LoginController starts the authorization of a platform by redirecting to its authorization page with a signed, single-use state.
+// The account is connected to the signed in user, if any, once the callback is received.
+// The state is bound to the caller through a cookie, so the callback must come from the same browser.
+// Clients that follow the link themselves can pass `redirect=false` to receive it instead, it only works in the browser that received the cookie.
+func LoginController(ag *aggregator.MusicStreamingPlatformsAggregator, db *database.Database) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		platform := aggregator.MusicStreamingPlatform(c.Params("platform"))
+		if !isAvailable(ag, platform, func(caps registry.Capabilities) bool { return caps.Oauth }) {
+			return c.
+				Status(http.StatusNotFound).
+				JSON(presenter.ErrorResponse(fmt.Sprintf("oauth is not available for %s", platform)))
+		}
+
+		var userID string
+		if user, ok := users.CurrentUser(c); ok {
+			userID = user.ID
+		}
+
+		// the verifier is kept with the state, platforms that do not use PKCE ignore it.
+		codeVerifier, err := newCodeVerifier()
+		if err != nil {
+			return c.
+				Status(http.StatusInternalServerError).
+				JSON(presenter.ErrorResponse("unable to generate state parameter", err.Error()))
+		}
+
+		state, nonce, err := newStateParameter(c.UserContext(), db, ag.Config, database.OauthState{Platform: platform, UserID: userID, CodeVerifier: codeVerifier})
+		if err != nil {
+			return c.
+				Status(http.StatusInternalServerError).
+				JSON(presenter.ErrorResponse("unable to generate state parameter", err.Error()))
+		}
+
+		authorizationURL, err := ag.GetAuthorizationURL(platform, state, codeChallenge(codeVerifier))
+		if err != nil {
+			return c.
+				Status(http.StatusInternalServerError).
+				JSON(presenter.ErrorResponse("unable to generate authorization url", err.Error()))
+		}
+
+		c.Cookie(&fiber.Cookie{
+			Name:     stateCookieName(platform),
+			Value:    stateBinding(nonce),
+			Path:     "/",
+			Expires:  time.Now().Add(stateParameterLifetime),
+			Secure:   c.Protocol() == "https",
+			HTTPOnly: true,
+			// the callback is a top-level navigation from the platform, which lax cookies are sent with.
+			SameSite: fiber.CookieSameSiteLaxMode,
+		})
+
+		if c.Query("redirect") == "false" {
+			return c.Status(http.StatusOK).JSON(presenter.SuccessResponse("authorization url generated successfully", authorizationURL))
+		}
+		return c.Redirect(authorizationURL, http.StatusFound)
+	}
+}
+
+// OauthCallbackController handles OAuth callback requests for every platform that supports it.
+// The state must have been issued by LoginController for the same platform to the same browser, and can only be used once.
+func OauthCallbackController(ag *aggregator.MusicStreamingPlatformsAggregator, db *database.Database) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		platform := aggregator.MusicStreamingPlatform(c.Params("platform"))
+		if !isAvailable(ag, platform, func(caps registry.Capabilities) bool { return caps.Oauth }) {
+			return c.
+				Status(http.StatusNotFound).
+				JSON(presenter.ErrorResponse(fmt.Sprintf("oauth is not available for %s", platform)))
+		}
+
 		state := c.Query("state")
 		code := c.Query("code")
 		if state == "" || code == "" {
@@ -25,111 +94,192 @@ func SpotifyOauthCallbackController(ag *aggregator.MusicStreamingPlatformsAggreg
 				JSON(presenter.ErrorResponse("missing required query parameters: state and/or code"))
 		}
 
-		stateParamDecrypted, err := utils.Decrypt(state, ag.Config.SecretKey, ag.Config.InitializationVector)
+		binding := c.Cookies(stateCookieName(platform))
+		c.ClearCookie(stateCookieName(platform))
+
+		oauthState, err := validateStateParameter(c.UserContext(), db, ag.Config, state, binding, platform)
 		if err != nil {
 			return c.
 				Status(http.StatusUnprocessableEntity).
-				JSON(presenter.ErrorResponse("invalid state parameter provided", err.Error()))
+				JSON(presenter.ErrorResponse("invalid/expired state parameter provided", err.Error()))
 		}
 
-		// It takes the format of unixTimeInSecs:streamingPlatform
-		stateParamSlice := strings.Split(stateParamDecrypted, ":")
-		if len(stateParamSlice) < 2 {
+		return connectAccount(c, ag, db, platform, code, oauthState)
+	}
+}
+
+// ConnectTokenController connects the account of platforms that issue user tokens to the client directly, e.g. Apple Music through MusicKit, so there is no callback to receive.
+// The account is connected to the signed in user, if any.
+func ConnectTokenController(ag *aggregator.MusicStreamingPlatformsAggregator, db *database.Database) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		platform := aggregator.MusicStreamingPlatform(c.Params("platform"))
+		if !isAvailable(ag, platform, func(caps registry.Capabilities) bool { return caps.ClientToken }) {
 			return c.
-				Status(http.StatusUnprocessableEntity).
-				JSON(presenter.ErrorResponse("invalid state parameter provided", err.Error()))
+				Status(http.StatusNotFound).
+				JSON(presenter.ErrorResponse(fmt.Sprintf("connecting with a token is not available for %s", platform)))
 		}
 
-		stateParamTime, err := strconv.Atoi(stateParamSlice[0])
+		var requestBody ConnectTokenRequest
+
+		err := c.BodyParser(&requestBody)
 		if err != nil {
 			return c.
-				Status(http.StatusUnprocessableEntity).
-				JSON(presenter.ErrorResponse("invalid state parameter provided", err.Error()))
+				Status(http.StatusBadRequest).
+				JSON(presenter.ErrorResponse("validation error", err.Error()))
 		}
 
-		if stateParamSlice[1] != string(aggregator.Spotify) || time.Now().Unix()-int64(stateParamTime) >= 60 {
+		if ok, errors := requestBody.Validate(); !ok {
 			return c.
 				Status(http.StatusUnprocessableEntity).
-				JSON(presenter.ErrorResponse("invalid/expired state parameter provided", err.Error()))
+				JSON(presenter.ErrorResponse("validation error", errors...))
+		}
+
+		var userID string
+		if user, ok := users.CurrentUser(c); ok {
+			userID = user.ID
 		}
 
-		oauthCredentials, err := ag.Spotify.GetAuthorizationCode(c.Query("code"))
+		return connectAccount(c, ag, db, platform, strings.TrimSpace(requestBody.Token), database.OauthState{Platform: platform, UserID: userID})
+	}
+}
+
+// connectAccount exchanges the authorization code for credentials and stores them for the user who started the authorization.
+// When nobody was signed in, the caller is signed in as the user the account was connected to before, or as a new user.
+func connectAccount(c *fiber.Ctx, ag *aggregator.MusicStreamingPlatformsAggregator, db *database.Database, platform aggregator.MusicStreamingPlatform, code string, oauthState database.OauthState) error {
+	streamingPlatform, err := ag.GetStreamingPlatform(platform)
+	if err != nil {
+		return presenter.PlatformErrorResponse(c, "unable to retrieve authorization code", err)
+	}
+
+	oauthCredentials, err := ag.GetAuthorizationCode(c.UserContext(), platform, code, oauthState.CodeVerifier)
+	if err != nil {
+		return presenter.PlatformErrorResponse(c, "unable to retrieve authorization code", err)
+	}
+
+	// platforms that cannot tell whose account it is cannot be used to sign back in, they are only connected.
+	var account utils.Account
+	if _, ok := streamingPlatform.(registry.AccountGetter); ok {
+		account, err = ag.GetAccount(c.UserContext(), platform, oauthCredentials.AccessToken)
 		if err != nil {
-			return c.
-				Status(http.StatusInternalServerError).
-				JSON(presenter.ErrorResponse("unable to retrieve authorization code", err.Error()))
+			return presenter.PlatformErrorResponse(c, "unable to retrieve account", err)
 		}
+	}
 
-		err = db.SetOauthCredentials(aggregator.Spotify, oauthCredentials)
+	// the user is taken from the state rather than the session, so the credentials go to whoever started the authorization.
+	var user database.User
+	signedIn := oauthState.UserID != ""
+	if signedIn {
+		user, err = db.GetUser(c.UserContext(), oauthState.UserID)
 		if err != nil {
 			return c.
 				Status(http.StatusInternalServerError).
-				JSON(presenter.ErrorResponse("unable to store authorization code in database", err.Error()))
+				JSON(presenter.ErrorResponse("unable to retrieve user", err.Error()))
 		}
+	}
 
-		return c.
-			Status(http.StatusOK).
-			JSON(presenter.SuccessResponse("spotify token saved successfully", nil))
+	if account.ID != "" {
+		owner, _err := db.GetUserByAccount(c.UserContext(), platform, account.ID)
+		switch {
+		case errors.Is(_err, database.ErrUserNotFound):
+			// the account has not been connected before.
+		case _err != nil:
+			return c.
+				Status(http.StatusInternalServerError).
+				JSON(presenter.ErrorResponse("unable to retrieve user", _err.Error()))
+		case !signedIn:
+			user, signedIn = owner, true
+		case owner.ID != user.ID:
+			return c.
+				Status(http.StatusConflict).
+				JSON(presenter.ErrorResponse(fmt.Sprintf("this %s account is connected to another user", platform)))
+		}
 	}
-}
 
-// DeezerOauthCallbackController handles Deezer OAuth callback requests.
-func DeezerOauthCallbackController(ag *aggregator.MusicStreamingPlatformsAggregator, db *database.Database) fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		oauthCredentials, err := ag.Deezer.GetAuthorizationCode(c.Query("code"))
+	var response users.SessionResponse
+	if !signedIn {
+		user, err = db.CreateUser(c.UserContext(), account.Name)
 		if err != nil {
 			return c.
 				Status(http.StatusInternalServerError).
-				JSON(presenter.ErrorResponse("unable to retrieve authorization code", err.Error()))
+				JSON(presenter.ErrorResponse("unable to create user", err.Error()))
 		}
-
-		err = db.SetOauthCredentials(aggregator.Deezer, oauthCredentials)
+	}
+	if current, ok := users.CurrentUser(c); !ok || current.ID != user.ID {
+		response.Token, err = users.StartSession(c, db, user, ag.Config.SessionLifetime)
 		if err != nil {
 			return c.
 				Status(http.StatusInternalServerError).
-				JSON(presenter.ErrorResponse("unable to store authorization code in database", err.Error()))
+				JSON(presenter.ErrorResponse("unable to start session", err.Error()))
 		}
+	}
+	response.User = user
 
+	err = db.SetOauthCredentials(c.UserContext(), user.ID, platform, oauthCredentials)
+	if err != nil {
 		return c.
-			Status(http.StatusOK).
-			JSON(presenter.SuccessResponse("deezer token saved successfully", nil))
+			Status(http.StatusInternalServerError).
+			JSON(presenter.ErrorResponse("unable to store authorization code in database", err.Error()))
 	}
+
+	if account.ID != "" {
+		if err = db.LinkAccount(c.UserContext(), user.ID, platform, account.ID); err != nil {
+			return c.
+				Status(http.StatusInternalServerError).
+				JSON(presenter.ErrorResponse("unable to link account to user", err.Error()))
+		}
+	}
+
+	return c.
+		Status(http.StatusOK).
+		JSON(presenter.SuccessResponse(fmt.Sprintf("%s token saved successfully", platform), response))
 }
 
-func SpotifyRefreshAccessTokenController(ag *aggregator.MusicStreamingPlatformsAggregator, db *database.Database) fiber.Handler {
+// RefreshAccessTokenController refreshes the access token the signed in user stored for platforms that support it.
+func RefreshAccessTokenController(ag *aggregator.MusicStreamingPlatformsAggregator, db *database.Database) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		credentialsInDB, err := db.GetDBOauthCredentials(aggregator.Spotify)
-		if err != nil {
+		user, ok := users.CurrentUser(c)
+		if !ok {
 			return c.
-				Status(http.StatusInternalServerError).
-				JSON(presenter.ErrorResponse("unable to retrieve db credentials", err.Error()))
+				Status(http.StatusUnauthorized).
+				JSON(presenter.ErrorResponse("you are not signed in"))
 		}
 
-		var oldCredentials utils.OauthCredentials
-		err = oldCredentials.FromDB(credentialsInDB.Credentials)
-		if err != nil {
+		platform := aggregator.MusicStreamingPlatform(c.Params("platform"))
+		if streamingPlatform, err := ag.GetStreamingPlatform(platform); err != nil {
+			return presenter.PlatformErrorResponse(c, "error refreshing access token", err)
+		} else if _, isRefresher := streamingPlatform.(registry.OauthTokenRefresher); !isRefresher {
 			return c.
-				Status(http.StatusInternalServerError).
-				JSON(presenter.ErrorResponse("unable to parse db credentials", err.Error()))
+				Status(http.StatusNotFound).
+				JSON(presenter.ErrorResponse(fmt.Sprintf("%s does not support refreshing access tokens", platform)))
 		}
 
-		newCredentials, err := ag.Spotify.RefreshAccessToken(oldCredentials)
+		_, err := db.GetDBOauthCredentials(c.UserContext(), user.ID, platform)
+		if errors.Is(err, database.ErrOauthCredentialsNotFound) {
+			return c.
+				Status(http.StatusNotFound).
+				JSON(presenter.ErrorResponse(fmt.Sprintf("%s is not connected to your account", platform)))
+		}
 		if err != nil {
 			return c.
 				Status(http.StatusInternalServerError).
-				JSON(presenter.ErrorResponse("unable refresh access token", err.Error()))
+				JSON(presenter.ErrorResponse("unable to retrieve db credentials", err.Error()))
 		}
 
-		newCredentials.RefreshToken = oldCredentials.RefreshToken
-		err = db.SetOauthCredentials(aggregator.Spotify, newCredentials)
+		// access tokens are refreshed in the background before they expire, this forces it.
+		_, err = users.ForceRefreshCredentials(c.UserContext(), ag, db, user.ID, platform, "")
+		if errors.Is(err, users.ErrNoAccessToken) {
+			return c.
+				Status(http.StatusUnauthorized).
+				JSON(presenter.ErrorResponse("unable refresh access token", err.Error()))
+		}
 		if err != nil {
 			return c.
 				Status(http.StatusInternalServerError).
-				JSON(presenter.ErrorResponse("unable to store newly updated access token", err.Error()))
+				JSON(presenter.ErrorResponse("unable refresh access token", err.Error()))
 		}
 
 		return c.
 			Status(http.StatusOK).
-			JSON(presenter.SuccessResponse("spotify access token refreshed", nil))
+			JSON(presenter.SuccessResponse(fmt.Sprintf("%s access token refreshed", platform), nil))
 	}
 }
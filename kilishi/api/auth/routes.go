@@ -8,7 +8,8 @@ import (
 )
 
 func RouterV1(router fiber.Router, aggregatorService *aggregator.MusicStreamingPlatformsAggregator, db *database.Database) {
-	router.Get("/v1/auth/deezer/callback", DeezerOauthCallbackController(aggregatorService, db))
-	router.Get("/v1/auth/spotify/callback", SpotifyOauthCallbackController(aggregatorService, db))
-	router.Post("/v1/auth/spotify/refresh", SpotifyRefreshAccessTokenController(aggregatorService, db))
+	router.Get("/v1/auth/:platform/login", LoginController(aggregatorService, db))
+	router.Get("/v1/auth/:platform/callback", OauthCallbackController(aggregatorService, db))
+	router.Post("/v1/auth/:platform/token", ConnectTokenController(aggregatorService, db))
+	router.Post("/v1/auth/:platform/refresh", RefreshAccessTokenController(aggregatorService, db))
 }
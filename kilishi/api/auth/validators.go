@@ -1 +1,150 @@
 package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prettyirrelevant/kilishi/api/database"
+	"github.com/prettyirrelevant/kilishi/api/validation"
+	"github.com/prettyirrelevant/kilishi/config"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/aggregator"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+)
+
+// stateParameterLifetime is how long an oauth state parameter remains valid.
+const stateParameterLifetime = 10 * time.Minute
+
+var (
+	errInvalidStateParameter = errors.New("state parameter is malformed or was issued for another platform")
+	errExpiredStateParameter = errors.New("state parameter has expired or has already been used")
+	errUnboundStateParameter = errors.New("state parameter was issued to another browser")
+)
+
+// ConnectTokenRequest is a struct that represents the request body for the ConnectTokenController function.
+type ConnectTokenRequest struct {
+	Token string `json:"token"`
+}
+
+func (r *ConnectTokenRequest) Validate() (bool, []string) {
+	var foundErrors []string
+
+	err := validation.String(r.Token, "`token` is required.")
+	if err != nil {
+		foundErrors = append(foundErrors, err.Error())
+	}
+	if len(foundErrors) > 0 {
+		return false, foundErrors
+	}
+
+	return true, foundErrors
+}
+
+// isAvailable reports whether the platform has the capability and was initialised by the aggregator.
+// Platforms left out because they are not configured are unknown to clients, `/v1/platforms` does not list them either.
+func isAvailable(ag *aggregator.MusicStreamingPlatformsAggregator, platform registry.MusicStreamingPlatform, supports func(registry.Capabilities) bool) bool {
+	if factory, ok := registry.Get(platform); !ok || !supports(factory.Capabilities) {
+		return false
+	}
+
+	_, err := ag.GetStreamingPlatform(platform)
+	return !errors.Is(err, aggregator.ErrUnsupportedPlatform)
+}
+
+// stateCookieName returns the cookie that binds the authorization of the platform to the browser that started it.
+func stateCookieName(platform registry.MusicStreamingPlatform) string {
+	return fmt.Sprintf("kilishi_oauth_state_%s", platform)
+}
+
+// stateBinding is the value of the state cookie, a hash of the nonce so the cookie alone cannot be turned into a state parameter.
+func stateBinding(nonce string) string {
+	hash := sha256.Sum256([]byte(nonce))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// newCodeVerifier returns a random PKCE code verifier, 43 characters long as the minimum allowed.
+// https://datatracker.ietf.org/doc/html/rfc7636#section-4.1
+func newCodeVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("auth: code verifier generation failed due to %s", err.Error())
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// codeChallenge returns the S256 PKCE challenge of the code verifier.
+func codeChallenge(codeVerifier string) string {
+	hash := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// newStateParameter records a single-use nonce for the authorization and returns the state parameter to send to the platform along with the nonce.
+// It takes the format of base64(platform:nonce:expiresAtUnix).signature, the signature is an HMAC of the payload keyed with `SECRET_KEY`, or one of `PREVIOUS_SECRET_KEYS` when it is verified.
+func newStateParameter(ctx context.Context, db *database.Database, cfg *config.Config, oauthState database.OauthState) (string, string, error) {
+	nonce, err := db.CreateOauthState(ctx, oauthState, stateParameterLifetime)
+	if err != nil {
+		return "", "", err
+	}
+
+	payload := fmt.Sprintf("%s:%s:%d", oauthState.Platform, nonce, time.Now().Add(stateParameterLifetime).Unix())
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + cfg.Keyring().Sign(payload), nonce, nil
+}
+
+// validateStateParameter checks the signature of an oauth state parameter, that it was issued for the given platform and has not expired,
+// and that the binding from the state cookie matches its nonce, then consumes the nonce so it cannot be used again.
+// It returns the authorization the state was issued for.
+func validateStateParameter(ctx context.Context, db *database.Database, cfg *config.Config, state, binding string, platform registry.MusicStreamingPlatform) (database.OauthState, error) {
+	encodedPayload, signature, found := strings.Cut(state, ".")
+	if !found {
+		return database.OauthState{}, errInvalidStateParameter
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return database.OauthState{}, errInvalidStateParameter
+	}
+
+	if !cfg.Keyring().VerifySignature(string(payload), signature) {
+		return database.OauthState{}, errInvalidStateParameter
+	}
+
+	stateParamSlice := strings.Split(string(payload), ":")
+	if len(stateParamSlice) != 3 || stateParamSlice[0] != string(platform) {
+		return database.OauthState{}, errInvalidStateParameter
+	}
+
+	expiresAt, err := strconv.ParseInt(stateParamSlice[2], 10, 64)
+	if err != nil {
+		return database.OauthState{}, errInvalidStateParameter
+	}
+	if time.Now().Unix() >= expiresAt {
+		return database.OauthState{}, errExpiredStateParameter
+	}
+
+	// a state only completes the authorization in the browser it was issued to, so a link started by someone else cannot connect their account.
+	if subtle.ConstantTimeCompare([]byte(stateBinding(stateParamSlice[1])), []byte(binding)) != 1 {
+		return database.OauthState{}, errUnboundStateParameter
+	}
+
+	oauthState, err := db.ConsumeOauthState(ctx, stateParamSlice[1])
+	if errors.Is(err, database.ErrOauthStateNotFound) {
+		return database.OauthState{}, errExpiredStateParameter
+	}
+	if err != nil {
+		return database.OauthState{}, err
+	}
+	// the signature already covers the platform, this guards against a nonce recorded for another one.
+	if oauthState.Platform != platform {
+		return database.OauthState{}, errInvalidStateParameter
+	}
+
+	return oauthState, nil
+}
@@ -0,0 +1,230 @@
+package conversions
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/prettyirrelevant/kilishi/api/database"
+	"github.com/prettyirrelevant/kilishi/api/presenter"
+	"github.com/prettyirrelevant/kilishi/api/users"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/aggregator"
+)
+
+// keepAliveInterval is how often a comment is sent on an idle event stream so proxies do not close it.
+const keepAliveInterval = 15 * time.Second
+
+// CreateConversionController starts a conversion job and responds with it straight away.
+// The job fetches the source playlist, album or library, matches its tracks and creates the playlist on the destination in the background.
+// The tracks, or the album, can instead be saved to the library of the user on the destination.
+func CreateConversionController(ag *aggregator.MusicStreamingPlatformsAggregator, db *database.Database) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var requestBody CreateConversionRequest
+
+		err := c.BodyParser(&requestBody)
+		if err != nil {
+			return c.
+				Status(http.StatusBadRequest).
+				JSON(presenter.ErrorResponse("validation error", err.Error()))
+		}
+
+		requestBody.DetectPlatform()
+		if ok, errors := requestBody.Validate(); !ok {
+			return c.
+				Status(http.StatusUnprocessableEntity).
+				JSON(presenter.ErrorResponse("validation error", errors...))
+		}
+
+		// the access token is resolved now so a missing one is reported to the caller instead of failing the job.
+		providedAccessToken := strings.TrimSpace(requestBody.AccessToken)
+		accessToken, err := users.AccessToken(c, ag, db, requestBody.DestinationPlatform, providedAccessToken)
+		if errors.Is(err, users.ErrNoAccessToken) {
+			return c.
+				Status(http.StatusUnauthorized).
+				JSON(presenter.ErrorResponse("access token required", err.Error()))
+		}
+		if err != nil {
+			return presenter.PlatformErrorResponse(c, "error fetching access token", err)
+		}
+
+		var sourceOwner, sourceAccessToken string
+		if requestBody.SourceType == database.ConversionSourceLibrary {
+			providedSourceAccessToken := strings.TrimSpace(requestBody.SourceAccessToken)
+			sourceOwner = users.CredentialsOwner(c, ag, requestBody.SourcePlatform, providedSourceAccessToken)
+			sourceAccessToken, err = users.AccessToken(c, ag, db, requestBody.SourcePlatform, providedSourceAccessToken)
+			if errors.Is(err, users.ErrNoAccessToken) {
+				return c.
+					Status(http.StatusUnauthorized).
+					JSON(presenter.ErrorResponse("access token required", err.Error()))
+			}
+			if err != nil {
+				return presenter.PlatformErrorResponse(c, "error fetching source access token", err)
+			}
+		}
+
+		id, err := newConversionID()
+		if err != nil {
+			return c.
+				Status(http.StatusInternalServerError).
+				JSON(presenter.ErrorResponse("error creating conversion", err.Error()))
+		}
+
+		job := database.ConversionJob{
+			ID:                  id,
+			Status:              database.ConversionPending,
+			SourceType:          requestBody.SourceType,
+			SourceURL:           strings.TrimSpace(requestBody.SourceURL),
+			SourcePlatform:      requestBody.SourcePlatform,
+			DestinationPlatform: requestBody.DestinationPlatform,
+			Target:              requestBody.Target,
+			MinConfidence:       requestBody.MinConfidence,
+			Tracks:              []database.ConversionTrackResult{},
+			CreatedAt:           time.Now().Unix(),
+		}
+		if err = db.SaveConversionJob(c.UserContext(), job); err != nil {
+			return c.
+				Status(http.StatusInternalServerError).
+				JSON(presenter.ErrorResponse("error creating conversion", err.Error()))
+		}
+
+		// the job must outlive the request, so it gets its own deadline instead of the request's context.
+		owner := users.CredentialsOwner(c, ag, requestBody.DestinationPlatform, providedAccessToken)
+		ctx, cancel := context.WithTimeout(context.Background(), ag.Config.ConversionTimeout)
+		go func() {
+			defer cancel()
+			newConversion(ag, db, job, owner, accessToken, sourceOwner, sourceAccessToken).run(ctx)
+		}()
+
+		return c.Status(http.StatusAccepted).JSON(presenter.SuccessResponse("conversion started successfully", job))
+	}
+}
+
+// GetConversionController returns the status of a conversion job, including the result of every track matched so far.
+func GetConversionController(_ *aggregator.MusicStreamingPlatformsAggregator, db *database.Database) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		job, err := db.GetConversionJob(c.UserContext(), c.Params("id"))
+		if errors.Is(err, database.ErrConversionJobNotFound) {
+			return c.
+				Status(http.StatusNotFound).
+				JSON(presenter.ErrorResponse("conversion not found"))
+		}
+		if err != nil {
+			return c.
+				Status(http.StatusInternalServerError).
+				JSON(presenter.ErrorResponse("error retrieving conversion", err.Error()))
+		}
+
+		return c.Status(http.StatusOK).JSON(presenter.SuccessResponse("conversion retrieved successfully", job))
+	}
+}
+
+// StreamConversionEventsController streams the events of a conversion job as Server-Sent Events.
+// Events that already happened are replayed first, so clients can connect at any point and resume with `Last-Event-ID`.
+// The stream ends after the job completes or fails.
+func StreamConversionEventsController(ag *aggregator.MusicStreamingPlatformsAggregator, db *database.Database) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		jobID := c.Params("id")
+		if _, err := db.GetConversionJob(c.UserContext(), jobID); err != nil {
+			if errors.Is(err, database.ErrConversionJobNotFound) {
+				return c.
+					Status(http.StatusNotFound).
+					JSON(presenter.ErrorResponse("conversion not found"))
+			}
+			return c.
+				Status(http.StatusInternalServerError).
+				JSON(presenter.ErrorResponse("error retrieving conversion", err.Error()))
+		}
+
+		var nextEventID int64
+		if lastEventID := c.Get("Last-Event-ID"); lastEventID != "" {
+			id, err := strconv.ParseInt(lastEventID, 10, 64)
+			if err != nil || id < 0 {
+				return c.
+					Status(http.StatusBadRequest).
+					JSON(presenter.ErrorResponse("validation error", "`Last-Event-ID` must be the id of an event"))
+			}
+			nextEventID = id + 1
+		}
+
+		// the stream is written after the handler returns, so it cannot use the request's context.
+		ctx, cancel := context.WithTimeout(context.Background(), ag.Config.ConversionTimeout)
+		pubsub, err := db.SubscribeConversionEvents(ctx, jobID)
+		if err != nil {
+			cancel()
+			return c.
+				Status(http.StatusInternalServerError).
+				JSON(presenter.ErrorResponse("error subscribing to conversion events", err.Error()))
+		}
+
+		c.Set(fiber.HeaderContentType, "text/event-stream")
+		c.Set(fiber.HeaderCacheControl, "no-cache")
+		c.Set(fiber.HeaderConnection, "keep-alive")
+		c.Set("X-Accel-Buffering", "no")
+
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			defer cancel()
+			defer func() { _ = pubsub.Close() }()
+
+			notifications := pubsub.Channel()
+			keepAlive := time.NewTicker(keepAliveInterval)
+			defer keepAlive.Stop()
+
+			for {
+				events, _err := db.GetConversionEvents(ctx, jobID, nextEventID)
+				if _err != nil {
+					log.Printf("conversions: events of job %s could not be streamed due to %s", jobID, _err.Error())
+					return
+				}
+
+				for _, event := range events {
+					// a failed write means the client has disconnected.
+					if _err = writeServerSentEvent(w, nextEventID, event); _err != nil {
+						return
+					}
+					nextEventID++
+
+					if event.Type.IsFinal() {
+						return
+					}
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-notifications:
+				case <-keepAlive.C:
+					if _, _err = w.WriteString(": keep-alive\n\n"); _err != nil {
+						return
+					}
+					if _err = w.Flush(); _err != nil {
+						return
+					}
+				}
+			}
+		})
+
+		return nil
+	}
+}
+
+// writeServerSentEvent writes the event in the `text/event-stream` format and flushes it to the client.
+func writeServerSentEvent(w *bufio.Writer, id int64, event database.ConversionEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if _, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, event.Type, data); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,14 @@
+package conversions
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/prettyirrelevant/kilishi/api/database"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/aggregator"
+)
+
+func RouterV1(router fiber.Router, aggregatorService *aggregator.MusicStreamingPlatformsAggregator, db *database.Database) {
+	router.Post("/v1/conversions", CreateConversionController(aggregatorService, db))
+	router.Get("/v1/conversions/:id", GetConversionController(aggregatorService, db))
+	router.Get("/v1/conversions/:id/events", StreamConversionEventsController(aggregatorService, db))
+}
@@ -0,0 +1,379 @@
+package conversions
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prettyirrelevant/kilishi/api/database"
+	"github.com/prettyirrelevant/kilishi/api/users"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/aggregator"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/matching"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+	"github.com/prettyirrelevant/kilishi/utils"
+)
+
+const (
+	// saveTimeout bounds the final save of a job, it cannot use the job's context since that may have expired.
+	saveTimeout = 10 * time.Second
+	// minimumAlbumConfidence is the confidence an album match needs before its tracks are used or it is saved to a library,
+	// a wrong album would otherwise mismatch every one of its tracks.
+	minimumAlbumConfidence = 0.8
+	// resultsPerSave is the number of track results after which a job that is matching its tracks is saved,
+	// so the results found so far can be retrieved before every track is matched.
+	resultsPerSave = 25
+)
+
+// conversion runs a conversion job, it saves its progress after every stage and publishes an event for every step.
+type conversion struct {
+	ag  *aggregator.MusicStreamingPlatformsAggregator
+	db  *database.Database
+	job database.ConversionJob
+	// userID is the user whose stored credentials accessToken belongs to, it is empty when the access token was provided.
+	userID      string
+	accessToken string
+	// sourceAccessToken is only set when the job converts the library of the user on the source platform,
+	// sourceUserID is then the user whose stored credentials it belongs to, see userID.
+	sourceAccessToken string
+	sourceUserID      string
+	// album is the source album, it is only set when the job converts an album.
+	album *utils.Album
+}
+
+func newConversion(ag *aggregator.MusicStreamingPlatformsAggregator, db *database.Database, job database.ConversionJob, userID, accessToken, sourceUserID, sourceAccessToken string) *conversion {
+	return &conversion{ag: ag, db: db, job: job, userID: userID, accessToken: accessToken, sourceUserID: sourceUserID, sourceAccessToken: sourceAccessToken}
+}
+
+// run converts the playlist, album or library and records whether the job completed or failed.
+func (c *conversion) run(ctx context.Context) {
+	finalEvent := database.ConversionEvent{Type: database.ConversionEventCompleted}
+	if err := c.execute(ctx); err != nil {
+		c.job.Status = database.ConversionFailed
+		c.job.Error = err.Error()
+		finalEvent = database.ConversionEvent{Type: database.ConversionEventFailed, Error: err.Error()}
+	} else {
+		c.job.Status = database.ConversionCompleted
+		finalEvent.MatchedTracks = c.job.MatchedTracks
+		finalEvent.PlaylistURL = c.job.PlaylistURL
+	}
+
+	saveCtx, cancel := context.WithTimeout(context.Background(), saveTimeout)
+	defer cancel()
+
+	// the job is saved before the final event is published, so subscribers that fetch it afterwards see its final state.
+	if err := c.db.SaveConversionJob(saveCtx, c.job); err != nil {
+		log.Printf("conversions: final save of job %s failed due to %s", c.job.ID, err.Error())
+	}
+	c.publish(saveCtx, finalEvent)
+}
+
+func (c *conversion) execute(ctx context.Context) error {
+	source, err := c.ag.GetStreamingPlatform(c.job.SourcePlatform)
+	if err != nil {
+		return err
+	}
+	destination, err := c.ag.GetStreamingPlatform(c.job.DestinationPlatform)
+	if err != nil {
+		return err
+	}
+
+	if err := c.setStatus(ctx, database.ConversionFetching); err != nil {
+		return err
+	}
+	playlist, err := c.fetchSource(ctx, source)
+	if err != nil {
+		return err
+	}
+
+	c.job.Title = playlist.Title
+	c.job.Description = playlist.Description
+	c.job.Tracks = make([]database.ConversionTrackResult, len(playlist.Tracks))
+	for i, track := range playlist.Tracks {
+		c.job.Tracks[i].Source = track
+	}
+	if err = c.setStatus(ctx, database.ConversionMatching); err != nil {
+		return err
+	}
+	c.publish(ctx, database.ConversionEvent{Type: database.ConversionEventPlaylistFetched, Title: playlist.Title, TrackCount: len(playlist.Tracks)})
+
+	if c.album != nil && c.job.Target == database.ConversionTargetLibrary {
+		return c.saveAlbum(ctx)
+	}
+
+	// the tracks of an album are first looked for on the album it matches, which is faster and more accurate
+	// than searching for every track on its own.
+	var albumTracks []utils.Track
+	if c.album != nil {
+		albumTracks = c.matchAlbumTracks(ctx)
+	}
+
+	matches := c.matchTracks(ctx, albumTracks)
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		return errors.New("none of the tracks in the playlist were found on the destination")
+	}
+
+	if err = c.setStatus(ctx, database.ConversionCreating); err != nil {
+		return err
+	}
+	progressCtx := registry.WithProgress(ctx, func(added, total int) {
+		c.publish(ctx, database.ConversionEvent{Type: database.ConversionEventTracksAdded, Added: added, Total: total})
+	})
+	// the job may have been running long enough for the access token to expire, it is refreshed and retried once if it is rejected.
+	if c.job.Target == database.ConversionTargetLibrary {
+		err = users.RetryOnUnauthorized(ctx, c.ag, c.db, c.userID, c.job.DestinationPlatform, c.accessToken, func(accessToken string) error {
+			return c.ag.SaveTracks(progressCtx, c.job.DestinationPlatform, matches, accessToken)
+		})
+		if err != nil {
+			return fmt.Errorf("error saving tracks: %w", err)
+		}
+		return nil
+	}
+
+	var playlistURL string
+	err = users.RetryOnUnauthorized(ctx, c.ag, c.db, c.userID, c.job.DestinationPlatform, c.accessToken, func(accessToken string) (_err error) {
+		playlistURL, _err = destination.CreatePlaylist(progressCtx, utils.Playlist{Title: playlist.Title, Description: playlist.Description, Tracks: matches}, accessToken)
+		return _err
+	})
+	if err != nil {
+		return fmt.Errorf("error creating playlist: %s", err.Error())
+	}
+
+	c.job.PlaylistURL = playlistURL
+	return nil
+}
+
+// fetchSource retrieves the playlist to convert, an album or a library is converted as a playlist of its tracks.
+func (c *conversion) fetchSource(ctx context.Context, source registry.MusicStreamingPlatformInterface) (utils.Playlist, error) {
+	switch c.job.SourceType {
+	case database.ConversionSourceAlbum:
+		album, err := c.ag.GetAlbum(ctx, c.job.SourcePlatform, c.job.SourceURL)
+		if err != nil {
+			return utils.Playlist{}, fmt.Errorf("error retrieving album: %s", err.Error())
+		}
+		c.album = &album
+
+		return utils.Playlist{
+			Title:       album.Title,
+			Description: fmt.Sprintf("%s by %s", album.Title, strings.Join(album.Artists, ", ")),
+			Tracks:      album.Tracks,
+		}, nil
+	case database.ConversionSourceLibrary:
+		var tracks []utils.Track
+		err := users.RetryOnUnauthorized(ctx, c.ag, c.db, c.sourceUserID, c.job.SourcePlatform, c.sourceAccessToken, func(accessToken string) (_err error) {
+			tracks, _err = c.ag.GetSavedTracks(ctx, c.job.SourcePlatform, accessToken)
+			return _err
+		})
+		if err != nil {
+			return utils.Playlist{}, fmt.Errorf("error retrieving saved tracks: %w", err)
+		}
+
+		factory, _ := registry.Get(c.job.SourcePlatform)
+		return utils.Playlist{
+			Title:       "Liked Songs",
+			Description: fmt.Sprintf("Liked songs from %s", factory.DisplayName),
+			Tracks:      tracks,
+		}, nil
+	case database.ConversionSourcePlaylist:
+		playlist, err := source.GetPlaylist(ctx, c.job.SourceURL)
+		if err != nil {
+			return utils.Playlist{}, fmt.Errorf("error retrieving playlist: %s", err.Error())
+		}
+		return playlist, nil
+	}
+
+	return utils.Playlist{}, fmt.Errorf("%s is not a supported source type", c.job.SourceType)
+}
+
+// findAlbum searches for the source album on the destination, the match is recorded on the job.
+func (c *conversion) findAlbum(ctx context.Context) (utils.Album, error) {
+	minConfidence := c.job.MinConfidence
+	if minConfidence < minimumAlbumConfidence {
+		minConfidence = minimumAlbumConfidence
+	}
+
+	match, err := c.ag.FindAlbum(ctx, *c.album, c.job.DestinationPlatform, minConfidence)
+	if err != nil {
+		return utils.Album{}, err
+	}
+
+	c.job.AlbumMatch = &match
+	c.publish(ctx, database.ConversionEvent{Type: database.ConversionEventAlbumMatched, Album: &match})
+	return match, nil
+}
+
+// saveAlbum saves the album the source album matches to the library of the user.
+func (c *conversion) saveAlbum(ctx context.Context) error {
+	match, err := c.findAlbum(ctx)
+	if err != nil {
+		return fmt.Errorf("error matching album: %s", err.Error())
+	}
+
+	if err = c.setStatus(ctx, database.ConversionCreating); err != nil {
+		return err
+	}
+	err = users.RetryOnUnauthorized(ctx, c.ag, c.db, c.userID, c.job.DestinationPlatform, c.accessToken, func(accessToken string) error {
+		return c.ag.SaveAlbum(ctx, c.job.DestinationPlatform, match, accessToken)
+	})
+	if err != nil {
+		return fmt.Errorf("error saving album: %w", err)
+	}
+
+	c.job.PlaylistURL = match.URL
+	return nil
+}
+
+// matchAlbumTracks returns the tracks of the album the source album matches on the destination.
+// Nothing is returned when the destination has no good enough match, the tracks are then searched for one by one.
+func (c *conversion) matchAlbumTracks(ctx context.Context) []utils.Track {
+	if factory, ok := registry.Get(c.job.DestinationPlatform); !ok || !factory.Capabilities.Albums {
+		return nil
+	}
+
+	match, err := c.findAlbum(ctx)
+	if err != nil {
+		return nil
+	}
+
+	album, err := c.ag.GetAlbum(ctx, c.job.DestinationPlatform, match.URL)
+	if err != nil {
+		log.Printf("conversions: tracks of album %s of job %s could not be retrieved due to %s", match.URL, c.job.ID, err.Error())
+		return nil
+	}
+
+	return album.Tracks
+}
+
+// matchTracks searches for every track of the job on the destination and returns the matches in playlist order.
+// A track is first compared with the album tracks, if any, and only searched for when none of them is a good enough match.
+// At most `MAXIMUM_CONCURRENT_LOOKUPS` tracks are matched at the same time, and the job is saved after every batch of results.
+func (c *conversion) matchTracks(ctx context.Context, albumTracks []utils.Track) []utils.Track {
+	type trackResult struct {
+		index int
+		match *utils.Track
+		err   error
+	}
+
+	numOfWorkers := c.ag.Config.MaximumConcurrentLookups
+	if numOfWorkers < 1 {
+		numOfWorkers = 1
+	}
+
+	indexes := make(chan int)
+	go func() {
+		defer close(indexes)
+		for i := range c.job.Tracks {
+			select {
+			case indexes <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// the workers only read the source tracks, the results are recorded on the job by this goroutine alone.
+	results := make(chan trackResult)
+	var wg sync.WaitGroup
+	for i := 0; i < numOfWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				match, err := c.matchTrack(ctx, c.job.Tracks[index].Source, albumTracks)
+				results <- trackResult{index: index, match: match, err: err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	numOfResults := 0
+	c.job.MatchedTracks = 0
+	for result := range results {
+		entry := &c.job.Tracks[result.index]
+		if result.err != nil {
+			entry.Error = result.err.Error()
+			c.publish(ctx, database.ConversionEvent{Type: database.ConversionEventTrackUnmatched, Position: result.index + 1, Track: entry})
+		} else {
+			entry.Match = result.match
+			c.job.MatchedTracks++
+			c.publish(ctx, database.ConversionEvent{Type: database.ConversionEventTrackMatched, Position: result.index + 1, Track: entry})
+		}
+
+		// a failed save is only logged, the job is saved again with the next batch and once it finishes.
+		if numOfResults++; numOfResults%resultsPerSave == 0 {
+			if err := c.db.SaveConversionJob(ctx, c.job); err != nil {
+				log.Printf("conversions: progress of job %s was not saved due to %s", c.job.ID, err.Error())
+			}
+		}
+	}
+
+	var matches []utils.Track
+	for _, result := range c.job.Tracks {
+		if result.Match != nil {
+			matches = append(matches, *result.Match)
+		}
+	}
+
+	return matches
+}
+
+// matchTrack returns the album track that matches the source track or, when none does, the best match found on the destination.
+func (c *conversion) matchTrack(ctx context.Context, source utils.Track, albumTracks []utils.Track) (*utils.Track, error) {
+	if match, ok := c.matchAlbumTrack(source, albumTracks); ok {
+		return &match, nil
+	}
+
+	match, err := c.ag.FindRetrievedTrack(ctx, c.userID, c.job.SourcePlatform, source, c.job.DestinationPlatform, c.job.MinConfidence)
+	if err != nil {
+		return nil, err
+	}
+	return &match, nil
+}
+
+// matchAlbumTrack returns the album track that best matches the source track, if it is a good enough match.
+func (c *conversion) matchAlbumTrack(source utils.Track, albumTracks []utils.Track) (utils.Track, bool) {
+	if len(albumTracks) == 0 {
+		return utils.Track{}, false
+	}
+
+	best := matching.Rank(source, albumTracks)[0]
+	if best.Confidence < minimumAlbumConfidence || best.Confidence < c.job.MinConfidence {
+		return utils.Track{}, false
+	}
+
+	best.MatchStrategy = utils.AlbumMatch
+	return best, true
+}
+
+// publish records an event of the job, a failure is only logged since the job itself can carry on.
+func (c *conversion) publish(ctx context.Context, event database.ConversionEvent) {
+	if err := c.db.PublishConversionEvent(ctx, c.job.ID, event); err != nil {
+		log.Printf("conversions: %s event of job %s was not published due to %s", event.Type, c.job.ID, err.Error())
+	}
+}
+
+func (c *conversion) setStatus(ctx context.Context, status database.ConversionStatus) error {
+	c.job.Status = status
+	return c.db.SaveConversionJob(ctx, c.job)
+}
+
+// newConversionID returns a random identifier for a conversion job.
+func newConversionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("conversions: id generation failed due to %s", err.Error())
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,115 @@
+package conversions
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prettyirrelevant/kilishi/api/database"
+	"github.com/prettyirrelevant/kilishi/api/validation"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/aggregator"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+)
+
+// CreateConversionRequest is a struct that represents the request body for the CreateConversionController function.
+// `source_type` only needs to be provided to convert the library of the user, it is detected from `source_url` otherwise.
+// `source_platform` is optional when converting a link, it is detected from `source_url` when omitted.
+// `target` defaults to `playlist`, `library` saves the tracks, or the album, to the library of the user instead.
+type CreateConversionRequest struct {
+	SourceType          database.ConversionSource         `json:"source_type"`
+	SourceURL           string                            `json:"source_url"`
+	SourcePlatform      aggregator.MusicStreamingPlatform `json:"source_platform"`
+	DestinationPlatform aggregator.MusicStreamingPlatform `json:"destination_platform"`
+	Target              database.ConversionTarget         `json:"target"`
+	AccessToken         string                            `json:"access_token"`
+	// SourceAccessToken is used to read the library of the user on the source platform.
+	SourceAccessToken string `json:"source_access_token"`
+	// MinConfidence is the confidence from 0 to 1 below which a track is reported as not found.
+	MinConfidence float64 `json:"min_confidence"`
+}
+
+// DetectPlatform fills in the source platform from the source URL when the caller did not provide one,
+// along with whether the source URL is a playlist or an album.
+func (c *CreateConversionRequest) DetectPlatform() {
+	if strings.TrimSpace(string(c.Target)) == "" {
+		c.Target = database.ConversionTargetPlaylist
+	}
+	if c.SourceType != "" && c.SourceType != database.ConversionSourcePlaylist && c.SourceType != database.ConversionSourceAlbum {
+		return
+	}
+
+	c.SourceType = database.ConversionSourcePlaylist
+	platform, kind, ok := aggregator.DetectPlatform(c.SourceURL)
+	if !ok || (kind != registry.PlaylistURL && kind != registry.AlbumURL) {
+		return
+	}
+	if strings.TrimSpace(string(c.SourcePlatform)) == "" {
+		c.SourcePlatform = platform
+	}
+	if platform == c.SourcePlatform && kind == registry.AlbumURL {
+		c.SourceType = database.ConversionSourceAlbum
+	}
+}
+
+func (c *CreateConversionRequest) Validate() (bool, []string) {
+	var foundErrors []string
+	var err error
+
+	if c.SourceType != database.ConversionSourceLibrary {
+		err = validation.String(c.SourceURL, "`source_url` is required.")
+		if err != nil {
+			foundErrors = append(foundErrors, err.Error())
+		}
+	}
+	if strings.TrimSpace(string(c.SourcePlatform)) == "" {
+		foundErrors = append(foundErrors, "`source_platform` could not be detected from `source_url`, please provide it.")
+	} else {
+		err = validateSourcePlatform(c.SourcePlatform, c.SourceType)
+		if err != nil {
+			foundErrors = append(foundErrors, err.Error())
+		}
+	}
+	err = validateTarget(c.DestinationPlatform, c.Target, c.SourceType)
+	if err != nil {
+		foundErrors = append(foundErrors, err.Error())
+	}
+	if c.SourcePlatform == c.DestinationPlatform {
+		foundErrors = append(foundErrors, "`source_platform` and `destination_platform` cannot be the same.")
+	}
+	if c.MinConfidence < 0 || c.MinConfidence > 1 {
+		foundErrors = append(foundErrors, "min_confidence must be between 0 and 1")
+	}
+	if len(foundErrors) > 0 {
+		return false, foundErrors
+	}
+
+	return true, foundErrors
+}
+
+// validateSourcePlatform checks that the platform can retrieve the source of the conversion.
+func validateSourcePlatform(m aggregator.MusicStreamingPlatform, source database.ConversionSource) error {
+	switch source {
+	case database.ConversionSourcePlaylist:
+		return validation.StreamingPlatform(m, fmt.Sprintf("%s is not a supported streaming platform", m), func(c registry.Capabilities) bool { return c.GetPlaylist })
+	case database.ConversionSourceAlbum:
+		return validation.StreamingPlatform(m, fmt.Sprintf("%s does not support albums", m), func(c registry.Capabilities) bool { return c.Albums })
+	case database.ConversionSourceLibrary:
+		return validation.StreamingPlatform(m, fmt.Sprintf("%s does not support libraries", m), func(c registry.Capabilities) bool { return c.Library })
+	default:
+		return fmt.Errorf("`source_type` must be one of `%s`, `%s` or `%s`", database.ConversionSourcePlaylist, database.ConversionSourceAlbum, database.ConversionSourceLibrary)
+	}
+}
+
+// validateTarget checks that the destination platform can create the target of the conversion.
+func validateTarget(m aggregator.MusicStreamingPlatform, target database.ConversionTarget, source database.ConversionSource) error {
+	switch target {
+	case database.ConversionTargetPlaylist:
+		return validation.StreamingPlatform(m, fmt.Sprintf("%s does not support playlist creation", m), func(c registry.Capabilities) bool { return c.CreatePlaylist })
+	case database.ConversionTargetLibrary:
+		if source == database.ConversionSourceAlbum {
+			return validation.StreamingPlatform(m, fmt.Sprintf("%s does not support saving albums", m), func(c registry.Capabilities) bool { return c.SaveAlbum })
+		}
+		return validation.StreamingPlatform(m, fmt.Sprintf("%s does not support libraries", m), func(c registry.Capabilities) bool { return c.Library })
+	default:
+		return fmt.Errorf("`target` must be either `%s` or `%s`", database.ConversionTargetPlaylist, database.ConversionTargetLibrary)
+	}
+}
@@ -2,25 +2,56 @@ package database
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 
-	"github.com/prettyirrelevant/kilishi/streaming_platforms/aggregator"
+	"github.com/prettyirrelevant/kilishi/config"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/matching"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
 	"github.com/prettyirrelevant/kilishi/utils"
 )
 
-var ctx = context.Background()
+// conversionJobTTL is how long a conversion job is kept after it was last updated.
+const conversionJobTTL = 7 * 24 * time.Hour
+
+var (
+	// ErrConversionJobNotFound is returned when a conversion job does not exist or has expired.
+	ErrConversionJobNotFound = errors.New("database: conversion job not found")
+	// ErrMatchOverrideNotFound is returned when there is no match override for a track.
+	ErrMatchOverrideNotFound = errors.New("database: match override not found")
+	// ErrOauthCredentialsNotFound is returned when a user has not connected a platform.
+	ErrOauthCredentialsNotFound = errors.New("database: oauth credentials not found")
+	// ErrUserNotFound is returned when a user does not exist.
+	ErrUserNotFound = errors.New("database: user not found")
+	// ErrSessionNotFound is returned when a session does not exist or has expired.
+	ErrSessionNotFound = errors.New("database: session not found")
+	// ErrOauthStateNotFound is returned when an oauth state has expired or has already been used.
+	ErrOauthStateNotFound = errors.New("database: oauth state not found")
+)
+
+// oauthExpiriesKey is a sorted set of the stored credentials that can be refreshed, scored by the unix time their access token expires at.
+const oauthExpiriesKey = "oauth_cred_expiries"
 
 // Database represents a connection to a Redis instance.
+// The keyring encrypts the OAuth credentials stored in it.
 type Database struct {
-	client *redis.Client
+	client  *redis.Client
+	keyring *utils.Keyring
 }
 
-// New creates a new Database struct and connects to a MongoDB database using the provided URL.
-func New(databaseURL string) (*Database, error) {
-	opts, err := redis.ParseURL(databaseURL)
+// New creates a new Database struct and connects to the Redis database in the configuration.
+func New(ctx context.Context, cfg *config.Config) (*Database, error) {
+	opts, err := redis.ParseURL(cfg.DatabaseURL)
 	if err != nil {
 		return &Database{}, fmt.Errorf("database: url parse failed due to  %s", err.Error())
 	}
@@ -30,30 +61,47 @@ func New(databaseURL string) (*Database, error) {
 		return &Database{}, fmt.Errorf("database: ping failed due to %s", status.Err().Error())
 	}
 
-	return &Database{client}, nil
+	return &Database{client: client, keyring: cfg.Keyring()}, nil
 }
 
-// GetDBOauthCredentials retrieves the OAuth credentials for a given music streaming platform from the database.
-func (d *Database) GetDBOauthCredentials(platform aggregator.MusicStreamingPlatform) (OauthCredentialsInDB, error) {
+// GetDBOauthCredentials retrieves the OAuth credentials a user connected for a music streaming platform from the database.
+// It returns ErrOauthCredentialsNotFound when the user has not connected the platform.
+func (d *Database) GetDBOauthCredentials(ctx context.Context, userID string, platform registry.MusicStreamingPlatform) (OauthCredentialsInDB, error) {
 	var dbCredentials OauthCredentialsInDB
-	var hashKey = fmt.Sprintf("oauth_cred:%s", platform)
+	var hashKey = oauthCredentialsKey(userID, platform)
 
 	err := d.client.HGetAll(ctx, hashKey).Scan(&dbCredentials)
 	if err != nil {
 		return dbCredentials, fmt.Errorf("database: credentials fetch failed for %s due to %s", platform, err.Error())
 	}
+	if len(dbCredentials.Credentials) == 0 {
+		return dbCredentials, ErrOauthCredentialsNotFound
+	}
+
+	credentials, err := d.decryptOauthCredentials(hashKey, string(dbCredentials.Credentials), dbCredentials.EncryptionKey)
+	if err != nil {
+		return dbCredentials, fmt.Errorf("database: credentials decryption failed for %s due to %s", platform, err.Error())
+	}
+
+	dbCredentials.Credentials = credentials
 	return dbCredentials, nil
 }
 
-// SetOauthCredentials saves the OAuth credentials for a given music streaming platform in the database.
-func (d *Database) SetOauthCredentials(platform aggregator.MusicStreamingPlatform, credentials utils.OauthCredentials) error {
-	var hashKey = fmt.Sprintf("oauth_cred:%s", platform)
+// SetOauthCredentials encrypts and saves the OAuth credentials a user connected for a music streaming platform in the database.
+func (d *Database) SetOauthCredentials(ctx context.Context, userID string, platform registry.MusicStreamingPlatform, credentials utils.OauthCredentials) error {
+	var hashKey = oauthCredentialsKey(userID, platform)
 
 	bytesCredentials, err := credentials.ToBytes()
 	if err != nil {
 		return fmt.Errorf("database: credentials conversion to bytes failed for %s due to %s", platform, err.Error())
 	}
 
+	// the credentials are bound to their key, so they cannot be swapped with those of another user or platform.
+	encryptionKey, encryptedCredentials, err := d.keyring.SealEnvelope(bytesCredentials, []byte(hashKey))
+	if err != nil {
+		return fmt.Errorf("database: credentials encryption failed for %s due to %s", platform, err.Error())
+	}
+
 	count, err := d.client.Exists(ctx, hashKey).Result()
 	if err != nil {
 		return fmt.Errorf("database: credentials existence check failed for %s due to %s", platform, err.Error())
@@ -61,8 +109,12 @@ func (d *Database) SetOauthCredentials(platform aggregator.MusicStreamingPlatfor
 
 	if count == 1 {
 		_, err = d.client.Pipelined(ctx, func(p redis.Pipeliner) error {
-			p.HSet(ctx, hashKey, "credentials", bytesCredentials)
+			p.HSet(ctx, hashKey, "credentials", encryptedCredentials)
+			p.HSet(ctx, hashKey, "encryption_key", encryptionKey)
+			p.HSet(ctx, hashKey, "needs_reauthorization", false)
+			p.HSet(ctx, hashKey, "refresh_error", "")
 			p.HSet(ctx, hashKey, "updated_at", time.Now().Unix())
+			scheduleOauthRefresh(ctx, p, userID, platform, credentials)
 			return nil
 		})
 		if err != nil {
@@ -74,10 +126,14 @@ func (d *Database) SetOauthCredentials(platform aggregator.MusicStreamingPlatfor
 
 	now := time.Now().Unix()
 	_, err = d.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		p.HSet(ctx, hashKey, "user_id", userID)
 		p.HSet(ctx, hashKey, "platform", string(platform))
-		p.HSet(ctx, hashKey, "credentials", bytesCredentials)
+		p.HSet(ctx, hashKey, "credentials", encryptedCredentials)
+		p.HSet(ctx, hashKey, "encryption_key", encryptionKey)
 		p.HSet(ctx, hashKey, "created_at", now)
 		p.HSet(ctx, hashKey, "updated_at", now)
+		p.SAdd(ctx, userPlatformsKey(userID), string(platform))
+		scheduleOauthRefresh(ctx, p, userID, platform, credentials)
 		return nil
 	})
 	if err != nil {
@@ -86,3 +142,816 @@ func (d *Database) SetOauthCredentials(platform aggregator.MusicStreamingPlatfor
 
 	return nil
 }
+
+// decryptOauthCredentials decrypts the credentials stored at the hash key with their encryption key.
+// Credentials stored as plain JSON before they were encrypted are still read until MigrateOauthCredentials has encrypted them.
+func (d *Database) decryptOauthCredentials(hashKey, ciphertext, encryptionKey string) ([]byte, error) {
+	if encryptionKey != "" {
+		return d.keyring.OpenEnvelope(encryptionKey, ciphertext, []byte(hashKey))
+	}
+
+	if !json.Valid([]byte(ciphertext)) {
+		return nil, utils.ErrMalformedCiphertext
+	}
+	return []byte(ciphertext), nil
+}
+
+// MigrateOauthCredentials encrypts the stored credentials that do not have their own encryption key yet, it returns how many were.
+// It only has to be run once, after upgrading from a version that stored them in plain JSON.
+func (d *Database) MigrateOauthCredentials(ctx context.Context) (int, error) {
+	return d.updateOauthCredentials(ctx, func(hashKey, ciphertext, encryptionKey string) (map[string]any, error) {
+		if encryptionKey != "" {
+			return nil, nil
+		}
+
+		credentials, err := d.decryptOauthCredentials(hashKey, ciphertext, "")
+		if err != nil {
+			return nil, err
+		}
+		encryptionKey, ciphertext, err = d.keyring.SealEnvelope(credentials, []byte(hashKey))
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]any{"credentials": ciphertext, "encryption_key": encryptionKey}, nil
+	})
+}
+
+// RotateOauthCredentialsKeys encrypts the encryption keys of the stored credentials with `SECRET_KEY`, it returns how many were.
+// It is run after `SECRET_KEY` is rotated, the previous key can be removed from `PREVIOUS_SECRET_KEYS` once it succeeds.
+func (d *Database) RotateOauthCredentialsKeys(ctx context.Context) (int, error) {
+	return d.updateOauthCredentials(ctx, func(hashKey, _, encryptionKey string) (map[string]any, error) {
+		if encryptionKey == "" || d.keyring.IsCurrent(encryptionKey) {
+			return nil, nil
+		}
+
+		encryptionKey, err := d.keyring.RewrapKey(encryptionKey, []byte(hashKey))
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]any{"encryption_key": encryptionKey}, nil
+	})
+}
+
+// updateOauthCredentials calls update with the encrypted fields of every stored credentials and saves the fields it returns, if any.
+// Credentials saved while they are being updated are left as they are. It returns how many credentials were updated,
+// the credentials that could not be updated are reported once all of them have been tried.
+// Only the credentials of users are updated.
+func (d *Database) updateOauthCredentials(ctx context.Context, update func(hashKey, ciphertext, encryptionKey string) (map[string]any, error)) (int, error) {
+	var updated int
+	var failed []string
+
+	iter := d.client.Scan(ctx, 0, oauthCredentialsPattern, 100).Iterator()
+	for iter.Next(ctx) {
+		hashKey := iter.Val()
+
+		err := d.client.Watch(ctx, func(tx *redis.Tx) error {
+			fields, err := tx.HMGet(ctx, hashKey, "credentials", "encryption_key").Result()
+			if err != nil {
+				return err
+			}
+
+			ciphertext, _ := fields[0].(string)
+			encryptionKey, _ := fields[1].(string)
+			if ciphertext == "" {
+				return nil
+			}
+
+			changes, err := update(hashKey, ciphertext, encryptionKey)
+			if err != nil || changes == nil {
+				return err
+			}
+
+			_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+				p.HSet(ctx, hashKey, changes)
+				return nil
+			})
+			if err == nil {
+				updated++
+			}
+			return err
+		}, hashKey)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s (%s)", hashKey, err.Error()))
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return updated, fmt.Errorf("database: credentials scan failed due to %s", err.Error())
+	}
+	if len(failed) > 0 {
+		return updated, fmt.Errorf("database: %d credentials could not be encrypted again: %s", len(failed), strings.Join(failed, ", "))
+	}
+
+	return updated, nil
+}
+
+// scheduleOauthRefresh records when the access token expires so it can be refreshed ahead of time, see GetExpiringOauthCredentials.
+// Access tokens that do not expire or cannot be refreshed are not scheduled.
+func scheduleOauthRefresh(ctx context.Context, p redis.Pipeliner, userID string, platform registry.MusicStreamingPlatform, credentials utils.OauthCredentials) {
+	member := oauthCredentialsMember(userID, platform)
+	if credentials.ExpiresAt == 0 || credentials.RefreshToken == "" {
+		p.ZRem(ctx, oauthExpiriesKey, member)
+		return
+	}
+
+	p.ZAdd(ctx, oauthExpiriesKey, redis.Z{Score: float64(credentials.ExpiresAt), Member: member})
+}
+
+// GetExpiringOauthCredentials returns the stored credentials whose access token expires before the given time, the soonest first.
+func (d *Database) GetExpiringOauthCredentials(ctx context.Context, before time.Time, limit int64) ([]OauthCredentialsRef, error) {
+	members, err := d.client.ZRangeByScore(ctx, oauthExpiriesKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(before.Unix(), 10),
+		Count: limit,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("database: expiring credentials fetch failed due to %s", err.Error())
+	}
+
+	refs := make([]OauthCredentialsRef, 0, len(members))
+	for _, member := range members {
+		userID, platform, found := strings.Cut(member, ":")
+		if !found {
+			continue
+		}
+		refs = append(refs, OauthCredentialsRef{UserID: userID, Platform: registry.MusicStreamingPlatform(platform)})
+	}
+
+	return refs, nil
+}
+
+// FlagOauthCredentials records that the credentials can no longer be refreshed, the user has to connect the platform again.
+func (d *Database) FlagOauthCredentials(ctx context.Context, userID string, platform registry.MusicStreamingPlatform, reason string) error {
+	var hashKey = oauthCredentialsKey(userID, platform)
+
+	_, err := d.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.HSet(ctx, hashKey, "needs_reauthorization", true)
+		p.HSet(ctx, hashKey, "refresh_error", reason)
+		p.HSet(ctx, hashKey, "updated_at", time.Now().Unix())
+		p.ZRem(ctx, oauthExpiriesKey, oauthCredentialsMember(userID, platform))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("database: oauth credentials flag failed for %s due to %s", platform, err.Error())
+	}
+
+	return nil
+}
+
+// GetConnectedPlatforms returns the platforms a user has stored OAuth credentials for.
+func (d *Database) GetConnectedPlatforms(ctx context.Context, userID string) ([]registry.MusicStreamingPlatform, error) {
+	members, err := d.client.SMembers(ctx, userPlatformsKey(userID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("database: connected platforms fetch failed for %s due to %s", userID, err.Error())
+	}
+
+	platforms := make([]registry.MusicStreamingPlatform, 0, len(members))
+	for _, member := range members {
+		platforms = append(platforms, registry.MusicStreamingPlatform(member))
+	}
+	sort.Slice(platforms, func(i, j int) bool { return platforms[i] < platforms[j] })
+
+	return platforms, nil
+}
+
+// oauthCredentialsPattern matches the keys of the credentials of every user, see oauthCredentialsKey.
+const oauthCredentialsPattern = "oauth_cred:*:*"
+
+func oauthCredentialsKey(userID string, platform registry.MusicStreamingPlatform) string {
+	return fmt.Sprintf("oauth_cred:%s:%s", userID, platform)
+}
+
+func oauthCredentialsMember(userID string, platform registry.MusicStreamingPlatform) string {
+	return fmt.Sprintf("%s:%s", userID, platform)
+}
+
+func userPlatformsKey(userID string) string {
+	return fmt.Sprintf("user_platforms:%s", userID)
+}
+
+// CreateUser stores a new user with a random ID.
+func (d *Database) CreateUser(ctx context.Context, name string) (User, error) {
+	id, err := newRandomToken(16)
+	if err != nil {
+		return User{}, err
+	}
+
+	user := User{ID: id, Name: name, CreatedAt: time.Now().Unix()}
+	bytesUser, err := json.Marshal(user)
+	if err != nil {
+		return User{}, fmt.Errorf("database: user conversion to bytes failed for %s due to %s", id, err.Error())
+	}
+
+	if err = d.client.Set(ctx, userKey(id), bytesUser, 0).Err(); err != nil {
+		return User{}, fmt.Errorf("database: user save failed for %s due to %s", id, err.Error())
+	}
+
+	return user, nil
+}
+
+// GetUser retrieves a user, it returns ErrUserNotFound when there is none with the ID.
+func (d *Database) GetUser(ctx context.Context, id string) (User, error) {
+	var user User
+
+	bytesUser, err := d.client.Get(ctx, userKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return user, ErrUserNotFound
+	}
+	if err != nil {
+		return user, fmt.Errorf("database: user fetch failed for %s due to %s", id, err.Error())
+	}
+
+	if err = json.Unmarshal(bytesUser, &user); err != nil {
+		return user, fmt.Errorf("database: user parse failed for %s due to %s", id, err.Error())
+	}
+	return user, nil
+}
+
+// GetUserByAccount retrieves the user an account on a streaming platform is linked to,
+// it returns ErrUserNotFound when the account is not linked to any user.
+func (d *Database) GetUserByAccount(ctx context.Context, platform registry.MusicStreamingPlatform, accountID string) (User, error) {
+	userID, err := d.client.Get(ctx, userAccountKey(platform, accountID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return User{}, ErrUserNotFound
+	}
+	if err != nil {
+		return User{}, fmt.Errorf("database: user account fetch failed for %s:%s due to %s", platform, accountID, err.Error())
+	}
+
+	return d.GetUser(ctx, userID)
+}
+
+// LinkAccount records that an account on a streaming platform belongs to a user, so they can sign in with it.
+func (d *Database) LinkAccount(ctx context.Context, userID string, platform registry.MusicStreamingPlatform, accountID string) error {
+	if err := d.client.Set(ctx, userAccountKey(platform, accountID), userID, 0).Err(); err != nil {
+		return fmt.Errorf("database: user account link failed for %s:%s due to %s", platform, accountID, err.Error())
+	}
+
+	return nil
+}
+
+// CreateSession starts a session for the user and returns its token, the session expires after the given lifetime.
+// Only a hash of the token is stored.
+func (d *Database) CreateSession(ctx context.Context, userID string, lifetime time.Duration) (string, error) {
+	token, err := newRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	if err = d.client.Set(ctx, sessionKey(token), userID, lifetime).Err(); err != nil {
+		return "", fmt.Errorf("database: session save failed for %s due to %s", userID, err.Error())
+	}
+
+	return token, nil
+}
+
+// GetSessionUser retrieves the user a session token belongs to, it returns ErrSessionNotFound when the session does not exist or has expired.
+func (d *Database) GetSessionUser(ctx context.Context, token string) (User, error) {
+	userID, err := d.client.Get(ctx, sessionKey(token)).Result()
+	if errors.Is(err, redis.Nil) {
+		return User{}, ErrSessionNotFound
+	}
+	if err != nil {
+		return User{}, fmt.Errorf("database: session fetch failed due to %s", err.Error())
+	}
+
+	user, err := d.GetUser(ctx, userID)
+	if errors.Is(err, ErrUserNotFound) {
+		return User{}, ErrSessionNotFound
+	}
+	return user, err
+}
+
+// DeleteSession ends a session.
+func (d *Database) DeleteSession(ctx context.Context, token string) error {
+	if err := d.client.Del(ctx, sessionKey(token)).Err(); err != nil {
+		return fmt.Errorf("database: session delete failed due to %s", err.Error())
+	}
+
+	return nil
+}
+
+// CreateOauthState records an oauth state under a random nonce and returns the nonce.
+// The state can be consumed once before the lifetime elapses.
+func (d *Database) CreateOauthState(ctx context.Context, state OauthState, lifetime time.Duration) (string, error) {
+	nonce, err := newRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	state.CreatedAt = time.Now().Unix()
+	bytesState, err := json.Marshal(state)
+	if err != nil {
+		return "", fmt.Errorf("database: oauth state conversion to bytes failed for %s due to %s", state.Platform, err.Error())
+	}
+
+	if err = d.client.Set(ctx, oauthStateKey(nonce), bytesState, lifetime).Err(); err != nil {
+		return "", fmt.Errorf("database: oauth state save failed for %s due to %s", state.Platform, err.Error())
+	}
+
+	return nonce, nil
+}
+
+// ConsumeOauthState retrieves and deletes the oauth state recorded for the nonce in one step, so it cannot be replayed.
+// It returns ErrOauthStateNotFound when the state has expired or has already been used.
+func (d *Database) ConsumeOauthState(ctx context.Context, nonce string) (OauthState, error) {
+	var state OauthState
+
+	bytesState, err := d.client.GetDel(ctx, oauthStateKey(nonce)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return state, ErrOauthStateNotFound
+	}
+	if err != nil {
+		return state, fmt.Errorf("database: oauth state fetch failed due to %s", err.Error())
+	}
+
+	if err = json.Unmarshal(bytesState, &state); err != nil {
+		return state, fmt.Errorf("database: oauth state parse failed due to %s", err.Error())
+	}
+	return state, nil
+}
+
+// releaseLockScript deletes a lock only if it is still held by the caller, it may have expired and been acquired by someone else.
+var releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// AcquireLock takes the named lock if nobody holds it, the lock is shared by every process using the database.
+// It returns false when the lock is held by someone else. The lock is released by calling the returned function,
+// or automatically once the ttl elapses.
+func (d *Database) AcquireLock(ctx context.Context, name string, ttl time.Duration) (func(), bool, error) {
+	token, err := newRandomToken(16)
+	if err != nil {
+		return nil, false, err
+	}
+
+	key := fmt.Sprintf("lock:%s", name)
+	acquired, err := d.client.SetNX(ctx, key, token, ttl).Result()
+	if err != nil {
+		return nil, false, fmt.Errorf("database: lock acquisition failed for %s due to %s", name, err.Error())
+	}
+	if !acquired {
+		return nil, false, nil
+	}
+
+	release := func() {
+		// the lock is released even when the caller's context has been cancelled.
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_ = releaseLockScript.Run(releaseCtx, d.client, []string{key}, token).Err()
+	}
+	return release, true, nil
+}
+
+// requestSlotLease is how long a request slot stays taken when it is not freed, e.g. because its process died.
+const requestSlotLease = 2 * time.Minute
+
+// reserveRequestScript takes a token from a bucket refilled at ARGV[1] tokens per second up to ARGV[2] tokens,
+// and returns 1 with the milliseconds to wait until it is due. The token is taken even when the bucket is empty, so the
+// waiting requests are sent in the order they came, unless the wait exceeds ARGV[3] milliseconds. No token is taken then
+// and 0 is returned with the wait, which bounds the debt of the bucket. The clock of the server is used so every process agrees on it.
+var reserveRequestScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local maxWait = tonumber(ARGV[3])
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "updated_at")
+local tokens = tonumber(state[1]) or burst
+local updatedAt = tonumber(state[2]) or now
+
+tokens = math.min(burst, tokens + (now - updatedAt) * rate / 1000) - 1
+local wait = 0
+if tokens < 0 then
+	wait = math.ceil(-tokens * 1000 / rate)
+end
+if wait > maxWait then
+	return {0, wait}
+end
+
+redis.call("HSET", KEYS[1], "tokens", tokens, "updated_at", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil((burst - tokens) * 1000 / rate) + 1000)
+return {1, wait}
+`)
+
+// cancelReservationScript gives back a token taken by reserveRequestScript from the bucket in KEYS[1] of up to ARGV[1] tokens.
+var cancelReservationScript = redis.NewScript(`
+local tokens = tonumber(redis.call("HGET", KEYS[1], "tokens"))
+if tokens == nil then
+	return 0
+end
+
+redis.call("HSET", KEYS[1], "tokens", math.min(tonumber(ARGV[1]), tokens + 1))
+return 1
+`)
+
+// acquireRequestSlotScript adds ARGV[3] to the slots taken in KEYS[1] unless ARGV[1] of them already are,
+// the slots are leased for ARGV[2] milliseconds. It returns 1 when the slot was taken.
+var acquireRequestSlotScript = redis.NewScript(`
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now)
+if redis.call("ZCARD", KEYS[1]) >= tonumber(ARGV[1]) then
+	return 0
+end
+
+redis.call("ZADD", KEYS[1], now + tonumber(ARGV[2]), ARGV[3])
+redis.call("PEXPIRE", KEYS[1], ARGV[2])
+return 1
+`)
+
+// ReserveRequest takes a token from the bucket of requests to the platform and returns how long to wait before sending the request.
+// It returns false without taking a token when the wait would exceed maxWait. The bucket is shared by every process using the database.
+func (d *Database) ReserveRequest(ctx context.Context, platform registry.MusicStreamingPlatform, requestsPerSecond float64, burst int, maxWait time.Duration) (time.Duration, bool, error) {
+	result, err := reserveRequestScript.Run(ctx, d.client, []string{rateLimitKey(platform)}, requestsPerSecond, burst, maxWait.Milliseconds()).Int64Slice()
+	if err != nil {
+		return 0, false, fmt.Errorf("database: rate limit reservation failed for %s due to %s", platform, err.Error())
+	}
+	if len(result) != 2 {
+		return 0, false, fmt.Errorf("database: rate limit reservation failed for %s due to an unexpected reply %v", platform, result)
+	}
+
+	return time.Duration(result[1]) * time.Millisecond, result[0] == 1, nil
+}
+
+// CancelReservation gives back the token of a reservation whose request was never sent, e.g. because it was cancelled while waiting.
+func (d *Database) CancelReservation(ctx context.Context, platform registry.MusicStreamingPlatform, burst int) error {
+	if err := cancelReservationScript.Run(ctx, d.client, []string{rateLimitKey(platform)}, burst).Err(); err != nil {
+		return fmt.Errorf("database: rate limit reservation cancellation failed for %s due to %s", platform, err.Error())
+	}
+	return nil
+}
+
+// AcquireRequestSlot takes one of the slots of requests in flight to the platform, the slots are shared by every process using the database.
+// It returns false when all of them are taken. The slot is freed by calling the returned function, or automatically once its lease elapses.
+func (d *Database) AcquireRequestSlot(ctx context.Context, platform registry.MusicStreamingPlatform, maxConcurrentRequests int) (func(), bool, error) {
+	token, err := newRandomToken(16)
+	if err != nil {
+		return nil, false, err
+	}
+
+	key := requestSlotsKey(platform)
+	acquired, err := acquireRequestSlotScript.Run(ctx, d.client, []string{key}, maxConcurrentRequests, requestSlotLease.Milliseconds(), token).Int()
+	if err != nil {
+		return nil, false, fmt.Errorf("database: request slot acquisition failed for %s due to %s", platform, err.Error())
+	}
+	if acquired == 0 {
+		return nil, false, nil
+	}
+
+	release := func() {
+		// the slot is freed even when the caller's context has been cancelled.
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_ = d.client.ZRem(releaseCtx, key, token).Err()
+	}
+	return release, true, nil
+}
+
+func rateLimitKey(platform registry.MusicStreamingPlatform) string {
+	return fmt.Sprintf("rate_limit:%s", platform)
+}
+
+func requestSlotsKey(platform registry.MusicStreamingPlatform) string {
+	return fmt.Sprintf("request_slots:%s", platform)
+}
+
+func oauthStateKey(nonce string) string {
+	return fmt.Sprintf("oauth_state:%s", nonce)
+}
+
+func userKey(id string) string {
+	return fmt.Sprintf("user:%s", id)
+}
+
+func userAccountKey(platform registry.MusicStreamingPlatform, accountID string) string {
+	return fmt.Sprintf("user_account:%s:%s", platform, accountID)
+}
+
+func sessionKey(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return fmt.Sprintf("session:%s", hex.EncodeToString(hash[:]))
+}
+
+// newRandomToken returns n random bytes encoded as hexadecimal.
+func newRandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("database: token generation failed due to %s", err.Error())
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// SaveConversionJob stores the conversion job, replacing any previous version of it.
+func (d *Database) SaveConversionJob(ctx context.Context, job ConversionJob) error {
+	job.UpdatedAt = time.Now().Unix()
+	bytesJob, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("database: conversion job conversion to bytes failed for %s due to %s", job.ID, err.Error())
+	}
+
+	err = d.client.Set(ctx, fmt.Sprintf("conversion:%s", job.ID), bytesJob, conversionJobTTL).Err()
+	if err != nil {
+		return fmt.Errorf("database: conversion job save failed for %s due to %s", job.ID, err.Error())
+	}
+
+	return nil
+}
+
+// GetConversionJob retrieves a conversion job, it returns ErrConversionJobNotFound when there is none with the ID.
+func (d *Database) GetConversionJob(ctx context.Context, id string) (ConversionJob, error) {
+	var job ConversionJob
+
+	bytesJob, err := d.client.Get(ctx, fmt.Sprintf("conversion:%s", id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return job, ErrConversionJobNotFound
+	}
+	if err != nil {
+		return job, fmt.Errorf("database: conversion job fetch failed for %s due to %s", id, err.Error())
+	}
+
+	if err = json.Unmarshal(bytesJob, &job); err != nil {
+		return job, fmt.Errorf("database: conversion job parse failed for %s due to %s", id, err.Error())
+	}
+	return job, nil
+}
+
+// PublishConversionEvent appends the event to the history of the conversion job and notifies its subscribers.
+func (d *Database) PublishConversionEvent(ctx context.Context, jobID string, event ConversionEvent) error {
+	var key = conversionEventsKey(jobID)
+
+	event.CreatedAt = time.Now().Unix()
+	bytesEvent, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("database: conversion event conversion to bytes failed for %s due to %s", jobID, err.Error())
+	}
+
+	_, err = d.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.RPush(ctx, key, bytesEvent)
+		p.Expire(ctx, key, conversionJobTTL)
+		// subscribers read the new events from the history, the message only wakes them up.
+		p.Publish(ctx, key, "")
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("database: conversion event publish failed for %s due to %s", jobID, err.Error())
+	}
+
+	return nil
+}
+
+// GetConversionEvents retrieves the events of a conversion job from the given index onwards, in the order they happened.
+func (d *Database) GetConversionEvents(ctx context.Context, jobID string, start int64) ([]ConversionEvent, error) {
+	rawEvents, err := d.client.LRange(ctx, conversionEventsKey(jobID), start, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("database: conversion events fetch failed for %s due to %s", jobID, err.Error())
+	}
+
+	events := make([]ConversionEvent, 0, len(rawEvents))
+	for _, rawEvent := range rawEvents {
+		var event ConversionEvent
+		if err = json.Unmarshal([]byte(rawEvent), &event); err != nil {
+			return nil, fmt.Errorf("database: conversion event parse failed for %s due to %s", jobID, err.Error())
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// SubscribeConversionEvents returns a subscription that receives a message whenever an event is published for the job.
+// The caller must close it once done.
+func (d *Database) SubscribeConversionEvents(ctx context.Context, jobID string) (*redis.PubSub, error) {
+	pubsub := d.client.Subscribe(ctx, conversionEventsKey(jobID))
+
+	// waiting for the confirmation guarantees no event published from now on is missed.
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, fmt.Errorf("database: conversion events subscription failed for %s due to %s", jobID, err.Error())
+	}
+
+	return pubsub, nil
+}
+
+// conversionEventsKey is both the key of the event history of a conversion job and the channel its events are announced on.
+func conversionEventsKey(jobID string) string {
+	return fmt.Sprintf("conversion_events:%s", jobID)
+}
+
+// GetTrackLink returns the track the source track was previously matched to on the destination platform.
+// Links recorded for the track itself are preferred over the ones recorded for its ISRC.
+func (d *Database) GetTrackLink(ctx context.Context, sourcePlatform registry.MusicStreamingPlatform, source utils.Track, destination registry.MusicStreamingPlatform) (utils.Track, bool, error) {
+	var keys []string
+	if sourcePlatform != "" && source.ID != "" {
+		keys = append(keys, trackLinksKey(sourcePlatform, source.ID))
+	}
+	if isrc := matching.NormaliseISRC(source.ISRC); isrc != "" {
+		keys = append(keys, isrcLinksKey(isrc))
+	}
+
+	for _, key := range keys {
+		rawLink, err := d.client.HGet(ctx, key, string(destination)).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return utils.Track{}, false, fmt.Errorf("database: track link fetch failed for %s due to %s", key, err.Error())
+		}
+
+		var link TrackLink
+		if err = json.Unmarshal([]byte(rawLink), &link); err != nil {
+			return utils.Track{}, false, fmt.Errorf("database: track link parse failed for %s due to %s", key, err.Error())
+		}
+		return link.toTrack(), true, nil
+	}
+
+	return utils.Track{}, false, nil
+}
+
+// SaveTrackLink records that the source track was matched to a track on the destination platform.
+// The link is saved in both directions, and each track is also saved under its own ISRC.
+func (d *Database) SaveTrackLink(ctx context.Context, sourcePlatform registry.MusicStreamingPlatform, source utils.Track, destination registry.MusicStreamingPlatform, match utils.Track) error {
+	var (
+		sourceLink      = newTrackLink(sourcePlatform, source, match.Confidence, match.MatchStrategy)
+		destinationLink = newTrackLink(destination, match, match.Confidence, match.MatchStrategy)
+		knownSource     = sourcePlatform != "" && source.ID != ""
+	)
+
+	bytesSourceLink, err := json.Marshal(sourceLink)
+	if err != nil {
+		return fmt.Errorf("database: track link conversion to bytes failed for %s due to %s", source.ID, err.Error())
+	}
+	bytesDestinationLink, err := json.Marshal(destinationLink)
+	if err != nil {
+		return fmt.Errorf("database: track link conversion to bytes failed for %s due to %s", match.ID, err.Error())
+	}
+
+	_, err = d.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		if knownSource {
+			p.HSet(ctx, trackLinksKey(sourcePlatform, source.ID), string(destination), bytesDestinationLink)
+			p.HSet(ctx, trackLinksKey(destination, match.ID), string(sourcePlatform), bytesSourceLink)
+		}
+		// the ISRC of the matched track may differ from the source's, e.g. when it was matched by text search.
+		if isrc := matching.NormaliseISRC(source.ISRC); isrc != "" && knownSource {
+			p.HSet(ctx, isrcLinksKey(isrc), string(sourcePlatform), bytesSourceLink)
+		}
+		if isrc := matching.NormaliseISRC(match.ISRC); isrc != "" {
+			p.HSet(ctx, isrcLinksKey(isrc), string(destination), bytesDestinationLink)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("database: track link save failed for %s due to %s", source.ID, err.Error())
+	}
+
+	return nil
+}
+
+// GetTrackLinks returns every track the given track is known to be linked to, keyed by their platform.
+func (d *Database) GetTrackLinks(ctx context.Context, platform registry.MusicStreamingPlatform, id string) (map[registry.MusicStreamingPlatform]TrackLink, error) {
+	rawLinks, err := d.client.HGetAll(ctx, trackLinksKey(platform, id)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("database: track links fetch failed for %s:%s due to %s", platform, id, err.Error())
+	}
+
+	links := make(map[registry.MusicStreamingPlatform]TrackLink, len(rawLinks))
+	for linkedPlatform, rawLink := range rawLinks {
+		var link TrackLink
+		if err = json.Unmarshal([]byte(rawLink), &link); err != nil {
+			return nil, fmt.Errorf("database: track link parse failed for %s:%s due to %s", platform, id, err.Error())
+		}
+		links[registry.MusicStreamingPlatform(linkedPlatform)] = link
+	}
+
+	return links, nil
+}
+
+func trackLinksKey(platform registry.MusicStreamingPlatform, id string) string {
+	return fmt.Sprintf("track_links:%s:%s", platform, id)
+}
+
+func isrcLinksKey(isrc string) string {
+	return fmt.Sprintf("isrc_links:%s", isrc)
+}
+
+// SaveMatchOverride stores the match override of the user who created it, replacing their previous one for the same source track and destination.
+func (d *Database) SaveMatchOverride(ctx context.Context, override MatchOverride) error {
+	var identifier = matchOverrideIdentifier(override.SourcePlatform, override.SourceID, override.DestinationPlatform)
+
+	override.CreatedAt = time.Now().Unix()
+	bytesOverride, err := json.Marshal(override)
+	if err != nil {
+		return fmt.Errorf("database: match override conversion to bytes failed for %s due to %s", identifier, err.Error())
+	}
+
+	_, err = d.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Set(ctx, matchOverrideKey(override.CreatedBy, identifier), bytesOverride, 0)
+		p.ZAdd(ctx, matchOverridesKey(override.CreatedBy), redis.Z{Score: float64(override.CreatedAt), Member: identifier})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("database: match override save failed for %s due to %s", identifier, err.Error())
+	}
+
+	return nil
+}
+
+// GetMatchOverride returns the track the user has chosen as the match of the source track on the destination platform.
+func (d *Database) GetMatchOverride(ctx context.Context, userID string, sourcePlatform registry.MusicStreamingPlatform, sourceID string, destination registry.MusicStreamingPlatform) (utils.Track, bool, error) {
+	var identifier = matchOverrideIdentifier(sourcePlatform, sourceID, destination)
+
+	bytesOverride, err := d.client.Get(ctx, matchOverrideKey(userID, identifier)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return utils.Track{}, false, nil
+	}
+	if err != nil {
+		return utils.Track{}, false, fmt.Errorf("database: match override fetch failed for %s due to %s", identifier, err.Error())
+	}
+
+	var override MatchOverride
+	if err = json.Unmarshal(bytesOverride, &override); err != nil {
+		return utils.Track{}, false, fmt.Errorf("database: match override parse failed for %s due to %s", identifier, err.Error())
+	}
+	return override.ToTrack(), true, nil
+}
+
+// GetMatchOverrides returns a page of the match overrides created by the user, the most recent first.
+func (d *Database) GetMatchOverrides(ctx context.Context, userID string, offset, limit int64) ([]MatchOverride, error) {
+	identifiers, err := d.client.ZRevRange(ctx, matchOverridesKey(userID), offset, offset+limit-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("database: match overrides fetch failed due to %s", err.Error())
+	}
+
+	overrides := make([]MatchOverride, 0, len(identifiers))
+	if len(identifiers) == 0 {
+		return overrides, nil
+	}
+
+	keys := make([]string, 0, len(identifiers))
+	for _, identifier := range identifiers {
+		keys = append(keys, matchOverrideKey(userID, identifier))
+	}
+
+	rawOverrides, err := d.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("database: match overrides fetch failed due to %s", err.Error())
+	}
+
+	for i, rawOverride := range rawOverrides {
+		value, ok := rawOverride.(string)
+		if !ok {
+			// the override was deleted after the page was read.
+			continue
+		}
+
+		var override MatchOverride
+		if err = json.Unmarshal([]byte(value), &override); err != nil {
+			return nil, fmt.Errorf("database: match override parse failed for %s due to %s", identifiers[i], err.Error())
+		}
+		overrides = append(overrides, override)
+	}
+
+	return overrides, nil
+}
+
+// DeleteMatchOverride removes a match override created by the user, it returns ErrMatchOverrideNotFound when there is none.
+func (d *Database) DeleteMatchOverride(ctx context.Context, userID string, sourcePlatform registry.MusicStreamingPlatform, sourceID string, destination registry.MusicStreamingPlatform) error {
+	var identifier = matchOverrideIdentifier(sourcePlatform, sourceID, destination)
+
+	var deleted *redis.IntCmd
+	_, err := d.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		deleted = p.Del(ctx, matchOverrideKey(userID, identifier))
+		p.ZRem(ctx, matchOverridesKey(userID), identifier)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("database: match override delete failed for %s due to %s", identifier, err.Error())
+	}
+	if deleted.Val() == 0 {
+		return ErrMatchOverrideNotFound
+	}
+
+	return nil
+}
+
+func matchOverrideIdentifier(sourcePlatform registry.MusicStreamingPlatform, sourceID string, destination registry.MusicStreamingPlatform) string {
+	return fmt.Sprintf("%s:%s:%s", sourcePlatform, sourceID, destination)
+}
+
+func matchOverrideKey(userID, identifier string) string {
+	return fmt.Sprintf("match_override:%s:%s", userID, identifier)
+}
+
+// matchOverridesKey is a sorted set of the identifiers of the match overrides of the user, scored by their creation time.
+func matchOverridesKey(userID string) string {
+	return fmt.Sprintf("match_overrides:%s", userID)
+}
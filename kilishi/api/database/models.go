@@ -1,9 +1,196 @@
 package database
 
-// OauthCredentialsInDB represents OAuth credentials stored in a database.
+import (
+	"time"
+
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+	"github.com/prettyirrelevant/kilishi/utils"
+)
+
+// OauthCredentialsInDB represents the OAuth credentials of a user stored in a database.
+// Credentials are encrypted in the database with their own key, they are decrypted when retrieved.
 type OauthCredentialsInDB struct {
+	UserID      string `redis:"user_id"`
 	Platform    string `redis:"platform"`
 	Credentials []byte `redis:"credentials"`
-	CreatedAt   int    `redis:"created_at"`
-	UpdatedAt   int    `redis:"updated_at"`
+	// EncryptionKey is the key the credentials are encrypted with, itself encrypted with `SECRET_KEY`.
+	EncryptionKey string `redis:"encryption_key"`
+	// NeedsReauthorization is set when the access token expired and could not be refreshed, RefreshError then holds the reason.
+	// The user has to connect the platform again.
+	NeedsReauthorization bool   `redis:"needs_reauthorization"`
+	RefreshError         string `redis:"refresh_error"`
+	CreatedAt            int    `redis:"created_at"`
+	UpdatedAt            int    `redis:"updated_at"`
+}
+
+// OauthCredentialsRef identifies the credentials a user stored for a platform.
+type OauthCredentialsRef struct {
+	UserID   string
+	Platform registry.MusicStreamingPlatform
+}
+
+// User is someone who has signed in, the OAuth credentials they connect are stored against them.
+type User struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// OauthState records an authorization started with `GET /v1/auth/:platform/login` until its callback is received.
+type OauthState struct {
+	Platform registry.MusicStreamingPlatform `json:"platform"`
+	// UserID is the user who started the authorization, it is empty when they were not signed in.
+	UserID string `json:"user_id,omitempty"`
+	// CodeVerifier is the PKCE code verifier whose challenge was sent to the platform, it proves the callback belongs to this authorization.
+	CodeVerifier string `json:"code_verifier,omitempty"`
+	CreatedAt    int64  `json:"created_at"`
+}
+
+// ConversionStatus is the stage a conversion job is at.
+type ConversionStatus string
+
+const (
+	ConversionPending   ConversionStatus = "pending"
+	ConversionFetching  ConversionStatus = "fetching"
+	ConversionMatching  ConversionStatus = "matching"
+	ConversionCreating  ConversionStatus = "creating"
+	ConversionCompleted ConversionStatus = "completed"
+	ConversionFailed    ConversionStatus = "failed"
+)
+
+// ConversionSource is what a conversion job converts.
+type ConversionSource string
+
+const (
+	ConversionSourcePlaylist ConversionSource = "playlist"
+	ConversionSourceAlbum    ConversionSource = "album"
+	// ConversionSourceLibrary converts the tracks saved to the library of the user, i.e. their liked songs.
+	ConversionSourceLibrary ConversionSource = "library"
+)
+
+// ConversionTarget is what a conversion job creates on the destination platform.
+type ConversionTarget string
+
+const (
+	// ConversionTargetPlaylist creates a playlist with the tracks of the source.
+	ConversionTargetPlaylist ConversionTarget = "playlist"
+	// ConversionTargetLibrary saves the matched tracks to the library of the user,
+	// an album source saves the album it was matched to instead.
+	ConversionTargetLibrary ConversionTarget = "library"
+)
+
+// ConversionJob represents a playlist, album or library conversion that runs in the background, it is stored as JSON.
+type ConversionJob struct {
+	ID         string           `json:"id"`
+	Status     ConversionStatus `json:"status"`
+	SourceType ConversionSource `json:"source_type"`
+	// SourceURL is empty when the source is the library of the user.
+	SourceURL           string                          `json:"source_url,omitempty"`
+	SourcePlatform      registry.MusicStreamingPlatform `json:"source_platform"`
+	DestinationPlatform registry.MusicStreamingPlatform `json:"destination_platform"`
+	Target              ConversionTarget                `json:"target"`
+	MinConfidence       float64                         `json:"min_confidence"`
+	Title               string                          `json:"title"`
+	Description         string                          `json:"description"`
+	Tracks              []ConversionTrackResult         `json:"tracks"`
+	MatchedTracks       int                             `json:"matched_tracks"`
+	// AlbumMatch is the album on the destination platform the source album was matched to, if any.
+	AlbumMatch *utils.Album `json:"album_match,omitempty"`
+	// PlaylistURL is the link to the playlist created on the destination platform once the job completes,
+	// or to the album saved to the library when an album is saved to the library.
+	PlaylistURL string `json:"playlist_url,omitempty"`
+	// Error is the reason the job failed.
+	Error     string `json:"error,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// ConversionTrackResult is the outcome of matching one track of the source playlist on the destination platform.
+type ConversionTrackResult struct {
+	Source utils.Track `json:"source"`
+	// Match is nil when the track could not be found, Error then holds the reason.
+	Match *utils.Track `json:"match,omitempty"`
+	Error string       `json:"error,omitempty"`
+}
+
+// ConversionEventType describes what happened to a conversion job.
+type ConversionEventType string
+
+const (
+	ConversionEventPlaylistFetched ConversionEventType = "playlist_fetched"
+	ConversionEventAlbumMatched    ConversionEventType = "album_matched"
+	ConversionEventTrackMatched    ConversionEventType = "track_matched"
+	ConversionEventTrackUnmatched  ConversionEventType = "track_unmatched"
+	ConversionEventTracksAdded     ConversionEventType = "tracks_added"
+	ConversionEventCompleted       ConversionEventType = "completed"
+	ConversionEventFailed          ConversionEventType = "failed"
+)
+
+// IsFinal reports whether no other event follows this one.
+func (c ConversionEventType) IsFinal() bool {
+	return c == ConversionEventCompleted || c == ConversionEventFailed
+}
+
+// ConversionEvent is a step in the progress of a conversion job, only the fields relevant to its type are set.
+type ConversionEvent struct {
+	Type ConversionEventType `json:"type"`
+	// Title and TrackCount describe the source playlist or album once it has been fetched.
+	Title      string `json:"title,omitempty"`
+	TrackCount int    `json:"track_count,omitempty"`
+	// Album is the album on the destination platform the source album was matched to.
+	Album *utils.Album `json:"album,omitempty"`
+	// Position is the 1-based position in the source playlist of the track the event is about.
+	Position int                    `json:"position,omitempty"`
+	Track    *ConversionTrackResult `json:"track,omitempty"`
+	// Added and Total report how many of the matched tracks have been added to the destination playlist.
+	Added         int    `json:"added,omitempty"`
+	Total         int    `json:"total,omitempty"`
+	MatchedTracks int    `json:"matched_tracks,omitempty"`
+	PlaylistURL   string `json:"playlist_url,omitempty"`
+	Error         string `json:"error,omitempty"`
+	CreatedAt     int64  `json:"created_at"`
+}
+
+// TrackLink records a track on a streaming platform that another track was matched to.
+type TrackLink struct {
+	Platform      registry.MusicStreamingPlatform `json:"platform"`
+	Track         utils.Track                     `json:"track"`
+	Confidence    float64                         `json:"confidence"`
+	MatchStrategy utils.MatchStrategy             `json:"match_strategy"`
+	UpdatedAt     int64                           `json:"updated_at"`
+}
+
+// toTrack returns the linked track as it would have been returned by a search.
+func (t TrackLink) toTrack() utils.Track {
+	track := t.Track
+	track.Confidence = t.Confidence
+	track.MatchStrategy = t.MatchStrategy
+	return track
+}
+
+func newTrackLink(platform registry.MusicStreamingPlatform, track utils.Track, confidence float64, strategy utils.MatchStrategy) TrackLink {
+	// the confidence and strategy describe the match rather than the track, the position is that of another playlist.
+	track.Confidence, track.MatchStrategy, track.Position = 0, "", 0
+	return TrackLink{Platform: platform, Track: track, Confidence: confidence, MatchStrategy: strategy, UpdatedAt: time.Now().Unix()}
+}
+
+// MatchOverride records the track a user has chosen as the match of a track on another platform.
+// It takes precedence over searching in the lookups and conversions of that user, e.g. when the search keeps picking a karaoke version.
+type MatchOverride struct {
+	SourcePlatform      registry.MusicStreamingPlatform `json:"source_platform"`
+	SourceID            string                          `json:"source_id"`
+	DestinationPlatform registry.MusicStreamingPlatform `json:"destination_platform"`
+	DestinationID       string                          `json:"destination_id"`
+	// Title and Artists describe the destination track as it was retrieved from the destination.
+	Title   string   `json:"title,omitempty"`
+	Artists []string `json:"artists,omitempty"`
+	Note    string   `json:"note,omitempty"`
+	// CreatedBy is the ID of the user who created the override, it is only used for their lookups.
+	CreatedBy string `json:"created_by,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// ToTrack returns the destination track as it would have been returned by a search.
+func (m MatchOverride) ToTrack() utils.Track {
+	return utils.Track{ID: m.DestinationID, Title: m.Title, Artists: m.Artists, Confidence: 1, MatchStrategy: utils.OverrideMatch}
 }
@@ -0,0 +1,87 @@
+package library
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/prettyirrelevant/kilishi/api/database"
+	"github.com/prettyirrelevant/kilishi/api/presenter"
+	"github.com/prettyirrelevant/kilishi/api/users"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/aggregator"
+)
+
+// GetSavedTracksController returns the tracks saved to the library of the user on a platform, i.e. their liked songs.
+func GetSavedTracksController(ag *aggregator.MusicStreamingPlatformsAggregator, db *database.Database) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var queryParams GetSavedTracksRequest
+
+		err := c.QueryParser(&queryParams)
+		if err != nil {
+			return c.
+				Status(http.StatusBadRequest).
+				JSON(presenter.ErrorResponse("validation error", err.Error()))
+		}
+
+		if ok, errors := queryParams.Validate(); !ok {
+			return c.
+				Status(http.StatusUnprocessableEntity).
+				JSON(presenter.ErrorResponse("validation error", errors...))
+		}
+
+		accessToken, err := users.AccessToken(c, ag, db, queryParams.Platform, strings.TrimSpace(queryParams.AccessToken))
+		if errors.Is(err, users.ErrNoAccessToken) {
+			return c.
+				Status(http.StatusUnauthorized).
+				JSON(presenter.ErrorResponse("access token required", err.Error()))
+		}
+		if err != nil {
+			return presenter.PlatformErrorResponse(c, "error fetching access token", err)
+		}
+
+		tracks, err := ag.GetSavedTracks(c.UserContext(), queryParams.Platform, accessToken)
+		if err != nil {
+			return presenter.PlatformErrorResponse(c, "error retrieving saved tracks", err)
+		}
+
+		return c.Status(http.StatusOK).JSON(presenter.SuccessResponse("saved tracks retrieved successfully", tracks))
+	}
+}
+
+// SaveTracksController saves tracks to the library of the user on a platform.
+func SaveTracksController(ag *aggregator.MusicStreamingPlatformsAggregator, db *database.Database) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var requestBody SaveTracksRequest
+
+		err := c.BodyParser(&requestBody)
+		if err != nil {
+			return c.
+				Status(http.StatusBadRequest).
+				JSON(presenter.ErrorResponse("validation error", err.Error()))
+		}
+
+		if ok, errors := requestBody.Validate(); !ok {
+			return c.
+				Status(http.StatusUnprocessableEntity).
+				JSON(presenter.ErrorResponse("validation error", errors...))
+		}
+
+		accessToken, err := users.AccessToken(c, ag, db, requestBody.Platform, strings.TrimSpace(requestBody.AccessToken))
+		if errors.Is(err, users.ErrNoAccessToken) {
+			return c.
+				Status(http.StatusUnauthorized).
+				JSON(presenter.ErrorResponse("access token required", err.Error()))
+		}
+		if err != nil {
+			return presenter.PlatformErrorResponse(c, "error fetching access token", err)
+		}
+
+		if err = ag.SaveTracks(c.UserContext(), requestBody.Platform, requestBody.Tracks, accessToken); err != nil {
+			return presenter.PlatformErrorResponse(c, "error saving tracks", err)
+		}
+
+		return c.Status(http.StatusOK).JSON(presenter.SuccessResponse("tracks saved successfully", len(requestBody.Tracks)))
+	}
+}
@@ -0,0 +1,13 @@
+package library
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/prettyirrelevant/kilishi/api/database"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/aggregator"
+)
+
+func RouterV1(router fiber.Router, aggregatorService *aggregator.MusicStreamingPlatformsAggregator, db *database.Database) {
+	router.Get("/v1/library/tracks", GetSavedTracksController(aggregatorService, db))
+	router.Put("/v1/library/tracks", SaveTracksController(aggregatorService, db))
+}
@@ -0,0 +1,68 @@
+package library
+
+import (
+	"fmt"
+
+	"github.com/prettyirrelevant/kilishi/api/validation"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/aggregator"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+	"github.com/prettyirrelevant/kilishi/utils"
+)
+
+// GetSavedTracksRequest is a struct that represents the query parameters for the GetSavedTracksController function.
+// `access_token` is optional, the credentials the signed in user connected for the platform are used when it is omitted.
+type GetSavedTracksRequest struct {
+	Platform    aggregator.MusicStreamingPlatform `query:"platform"`
+	AccessToken string                            `query:"access_token"`
+}
+
+func (g *GetSavedTracksRequest) Validate() (bool, []string) {
+	var foundErrors []string
+
+	err := validation.StreamingPlatform(g.Platform, fmt.Sprintf("%s does not support libraries", g.Platform), supportsLibrary)
+	if err != nil {
+		foundErrors = append(foundErrors, err.Error())
+	}
+	if len(foundErrors) > 0 {
+		return false, foundErrors
+	}
+
+	return true, foundErrors
+}
+
+// SaveTracksRequest is a struct that represents the request body for the SaveTracksController function.
+// `access_token` is optional, the credentials the signed in user connected for the platform are used when it is omitted.
+type SaveTracksRequest struct {
+	Platform    aggregator.MusicStreamingPlatform `json:"platform"`
+	AccessToken string                            `json:"access_token"`
+	Tracks      []utils.Track                     `json:"tracks"`
+}
+
+func (s *SaveTracksRequest) Validate() (bool, []string) {
+	var foundErrors []string
+
+	err := validation.StreamingPlatform(s.Platform, fmt.Sprintf("%s does not support libraries", s.Platform), supportsLibrary)
+	if err != nil {
+		foundErrors = append(foundErrors, err.Error())
+	}
+	if len(s.Tracks) == 0 {
+		foundErrors = append(foundErrors, "`tracks` requires at least one track")
+	}
+	for _, track := range s.Tracks {
+		err = validation.String(track.ID, "one of the tracks is missing an identifier")
+		if err != nil {
+			foundErrors = append(foundErrors, err.Error())
+			break
+		}
+	}
+	if len(foundErrors) > 0 {
+		return false, foundErrors
+	}
+
+	return true, foundErrors
+}
+
+// supportsLibrary reports whether the platform can read and save libraries.
+func supportsLibrary(c registry.Capabilities) bool {
+	return c.Library
+}
@@ -0,0 +1,136 @@
+package overrides
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/prettyirrelevant/kilishi/api/database"
+	"github.com/prettyirrelevant/kilishi/api/presenter"
+	"github.com/prettyirrelevant/kilishi/api/users"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/aggregator"
+)
+
+// CreateMatchOverrideController records the track the signed in user has chosen as the match of a track on another platform.
+// Their later lookups and conversions of the source track to the destination return it instead of searching, so the destination track
+// is retrieved from the destination first and the override is described by what it returned.
+func CreateMatchOverrideController(ag *aggregator.MusicStreamingPlatformsAggregator, db *database.Database) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, ok := users.CurrentUser(c)
+		if !ok {
+			return c.
+				Status(http.StatusUnauthorized).
+				JSON(presenter.ErrorResponse("you are not signed in"))
+		}
+
+		var requestBody CreateMatchOverrideRequest
+
+		err := c.BodyParser(&requestBody)
+		if err != nil {
+			return c.
+				Status(http.StatusBadRequest).
+				JSON(presenter.ErrorResponse("validation error", err.Error()))
+		}
+
+		if ok, errors := requestBody.Validate(); !ok {
+			return c.
+				Status(http.StatusUnprocessableEntity).
+				JSON(presenter.ErrorResponse("validation error", errors...))
+		}
+
+		destination, err := ag.GetTrackByID(c.UserContext(), requestBody.DestinationPlatform, strings.TrimSpace(requestBody.DestinationID))
+		if err != nil {
+			return presenter.PlatformErrorResponse(c, "error retrieving destination track", err)
+		}
+
+		override := database.MatchOverride{
+			SourcePlatform:      requestBody.SourcePlatform,
+			SourceID:            strings.TrimSpace(requestBody.SourceID),
+			DestinationPlatform: requestBody.DestinationPlatform,
+			DestinationID:       destination.ID,
+			Title:               destination.Title,
+			Artists:             destination.Artists,
+			Note:                requestBody.Note,
+			CreatedBy:           user.ID,
+		}
+		err = db.SaveMatchOverride(c.UserContext(), override)
+		if err != nil {
+			return c.
+				Status(http.StatusInternalServerError).
+				JSON(presenter.ErrorResponse("error saving match override", err.Error()))
+		}
+
+		return c.Status(http.StatusCreated).JSON(presenter.SuccessResponse("match override saved successfully", override))
+	}
+}
+
+// GetMatchOverridesController returns a page of the match overrides of the signed in user, the most recent first.
+func GetMatchOverridesController(_ *aggregator.MusicStreamingPlatformsAggregator, db *database.Database) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, ok := users.CurrentUser(c)
+		if !ok {
+			return c.
+				Status(http.StatusUnauthorized).
+				JSON(presenter.ErrorResponse("you are not signed in"))
+		}
+
+		var queryParams GetMatchOverridesRequest
+
+		err := c.QueryParser(&queryParams)
+		if err != nil {
+			return c.
+				Status(http.StatusBadRequest).
+				JSON(presenter.ErrorResponse("validation error", err.Error()))
+		}
+
+		if ok, errors := queryParams.Validate(); !ok {
+			return c.
+				Status(http.StatusUnprocessableEntity).
+				JSON(presenter.ErrorResponse("validation error", errors...))
+		}
+
+		overrides, err := db.GetMatchOverrides(c.UserContext(), user.ID, queryParams.Offset, queryParams.Limit)
+		if err != nil {
+			return c.
+				Status(http.StatusInternalServerError).
+				JSON(presenter.ErrorResponse("error retrieving match overrides", err.Error()))
+		}
+
+		return c.Status(http.StatusOK).JSON(presenter.SuccessResponse("match overrides retrieved successfully", overrides))
+	}
+}
+
+// DeleteMatchOverrideController removes a match override created by the signed in user,
+// the source track is searched for again on its next lookup.
+func DeleteMatchOverrideController(_ *aggregator.MusicStreamingPlatformsAggregator, db *database.Database) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, ok := users.CurrentUser(c)
+		if !ok {
+			return c.
+				Status(http.StatusUnauthorized).
+				JSON(presenter.ErrorResponse("you are not signed in"))
+		}
+
+		err := db.DeleteMatchOverride(
+			c.UserContext(),
+			user.ID,
+			aggregator.MusicStreamingPlatform(c.Params("source_platform")),
+			c.Params("source_id"),
+			aggregator.MusicStreamingPlatform(c.Params("destination_platform")),
+		)
+		if errors.Is(err, database.ErrMatchOverrideNotFound) {
+			return c.
+				Status(http.StatusNotFound).
+				JSON(presenter.ErrorResponse("match override not found"))
+		}
+		if err != nil {
+			return c.
+				Status(http.StatusInternalServerError).
+				JSON(presenter.ErrorResponse("error deleting match override", err.Error()))
+		}
+
+		return c.Status(http.StatusOK).JSON(presenter.SuccessResponse("match override deleted successfully", nil))
+	}
+}
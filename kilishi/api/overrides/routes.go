@@ -0,0 +1,14 @@
+package overrides
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/prettyirrelevant/kilishi/api/database"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/aggregator"
+)
+
+func RouterV1(router fiber.Router, aggregatorService *aggregator.MusicStreamingPlatformsAggregator, db *database.Database) {
+	router.Post("/v1/overrides", CreateMatchOverrideController(aggregatorService, db))
+	router.Get("/v1/overrides", GetMatchOverridesController(aggregatorService, db))
+	router.Delete("/v1/overrides/:source_platform/:source_id/:destination_platform", DeleteMatchOverrideController(aggregatorService, db))
+}
@@ -0,0 +1,80 @@
+package overrides
+
+import (
+	"fmt"
+
+	"github.com/prettyirrelevant/kilishi/api/validation"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/aggregator"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+)
+
+const (
+	// defaultNumOfOverridesPerPage is the number of match overrides returned when no limit is provided.
+	defaultNumOfOverridesPerPage = 50
+	// maximumNumOfOverridesPerPage is the most match overrides that can be returned at once.
+	maximumNumOfOverridesPerPage = 200
+)
+
+// CreateMatchOverrideRequest is a struct that represents the request body for the CreateMatchOverrideController function.
+type CreateMatchOverrideRequest struct {
+	SourcePlatform      aggregator.MusicStreamingPlatform `json:"source_platform"`
+	SourceID            string                            `json:"source_id"`
+	DestinationPlatform aggregator.MusicStreamingPlatform `json:"destination_platform"`
+	DestinationID       string                            `json:"destination_id"`
+	// Note is an optional explanation of the correction e.g. "the match was a karaoke version".
+	Note string `json:"note"`
+}
+
+func (c *CreateMatchOverrideRequest) Validate() (bool, []string) {
+	var foundErrors []string
+
+	err := validation.StreamingPlatform(c.SourcePlatform, fmt.Sprintf("%s is not a supported streaming platform", c.SourcePlatform), func(registry.Capabilities) bool { return true })
+	if err != nil {
+		foundErrors = append(foundErrors, err.Error())
+	}
+	err = validation.String(c.SourceID, "`source_id` is required.")
+	if err != nil {
+		foundErrors = append(foundErrors, err.Error())
+	}
+	err = validation.StreamingPlatform(c.DestinationPlatform, fmt.Sprintf("%s is not a supported destination of match overrides", c.DestinationPlatform), func(c registry.Capabilities) bool { return c.LookupTrack && c.GetTrack })
+	if err != nil {
+		foundErrors = append(foundErrors, err.Error())
+	}
+	err = validation.String(c.DestinationID, "`destination_id` is required.")
+	if err != nil {
+		foundErrors = append(foundErrors, err.Error())
+	}
+	if c.SourcePlatform == c.DestinationPlatform {
+		foundErrors = append(foundErrors, "`source_platform` and `destination_platform` cannot be the same.")
+	}
+	if len(foundErrors) > 0 {
+		return false, foundErrors
+	}
+
+	return true, foundErrors
+}
+
+// GetMatchOverridesRequest is a struct that represents the query parameters for the GetMatchOverridesController function.
+type GetMatchOverridesRequest struct {
+	Offset int64 `query:"offset"`
+	Limit  int64 `query:"limit"`
+}
+
+func (g *GetMatchOverridesRequest) Validate() (bool, []string) {
+	var foundErrors []string
+
+	if g.Limit == 0 {
+		g.Limit = defaultNumOfOverridesPerPage
+	}
+	if g.Offset < 0 {
+		foundErrors = append(foundErrors, "offset cannot be negative")
+	}
+	if g.Limit < 1 || g.Limit > maximumNumOfOverridesPerPage {
+		foundErrors = append(foundErrors, fmt.Sprintf("limit must be between 1 and %d", maximumNumOfOverridesPerPage))
+	}
+	if len(foundErrors) > 0 {
+		return false, foundErrors
+	}
+
+	return true, foundErrors
+}
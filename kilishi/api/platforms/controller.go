@@ -0,0 +1,19 @@
+package platforms
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/prettyirrelevant/kilishi/api/database"
+	"github.com/prettyirrelevant/kilishi/api/presenter"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/aggregator"
+)
+
+// GetPlatformsController returns the supported music streaming platforms along with their display names and what each of them can do,
+// so clients only offer the conversions a platform supports.
+func GetPlatformsController(ag *aggregator.MusicStreamingPlatformsAggregator, _ *database.Database) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.Status(http.StatusOK).JSON(presenter.SuccessResponse("All supported music streaming platforms returned successfully!", ag.SupportedPlatforms()))
+	}
+}
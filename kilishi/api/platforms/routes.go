@@ -0,0 +1,12 @@
+package platforms
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/prettyirrelevant/kilishi/api/database"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/aggregator"
+)
+
+func RouterV1(router fiber.Router, aggregatorService *aggregator.MusicStreamingPlatformsAggregator, db *database.Database) {
+	router.Get("/v1/platforms", GetPlatformsController(aggregatorService, db))
+}
@@ -1,13 +1,17 @@
 package playlists
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/gofiber/fiber/v2"
 
 	"github.com/prettyirrelevant/kilishi/api/database"
 	"github.com/prettyirrelevant/kilishi/api/presenter"
+	"github.com/prettyirrelevant/kilishi/api/users"
 	"github.com/prettyirrelevant/kilishi/streaming_platforms/aggregator"
 	"github.com/prettyirrelevant/kilishi/utils"
 )
@@ -23,18 +27,21 @@ func GetPlaylistController(ag *aggregator.MusicStreamingPlatformsAggregator, _ *
 				JSON(presenter.ErrorResponse("validation error", err.Error()))
 		}
 
+		queryParams.DetectPlatform()
 		if ok, errors := queryParams.Validate(); !ok {
 			return c.
 				Status(http.StatusUnprocessableEntity).
 				JSON(presenter.ErrorResponse("validation error", errors...))
 		}
 
-		x := ag.GetStreamingPlatform(queryParams.Platform)
-		playlist, err := x.GetPlaylist(queryParams.PlaylistURL)
+		x, err := ag.GetStreamingPlatform(queryParams.Platform)
 		if err != nil {
-			return c.
-				Status(http.StatusInternalServerError).
-				JSON(presenter.ErrorResponse("error retrieving playlist", err.Error()))
+			return presenter.PlatformErrorResponse(c, "error retrieving playlist", err)
+		}
+
+		playlist, err := x.GetPlaylist(c.UserContext(), queryParams.PlaylistURL)
+		if err != nil {
+			return presenter.PlatformErrorResponse(c, "error retrieving playlist", err)
 		}
 
 		return c.Status(http.StatusOK).JSON(presenter.SuccessResponse("playlist retrieved successfully", playlist))
@@ -58,15 +65,98 @@ func FindTrackController(ag *aggregator.MusicStreamingPlatformsAggregator, _ *da
 				JSON(presenter.ErrorResponse("validation error", errors...))
 		}
 
-		x := ag.GetStreamingPlatform(queryParams.Platform)
-		track, err := x.LookupTrack(utils.Track{Title: queryParams.Title, Artists: queryParams.Artists})
+		source := utils.Track{
+			ID:       strings.TrimSpace(queryParams.ID),
+			Title:    queryParams.Title,
+			Artists:  queryParams.Artists,
+			ISRC:     strings.TrimSpace(queryParams.ISRC),
+			Duration: queryParams.Duration,
+			Album:    queryParams.Album,
+		}
+		// only the best match is needed, so a match recorded earlier can be used instead of searching.
+		if queryParams.Candidates == 0 {
+			user, _ := users.CurrentUser(c)
+			match, _err := ag.FindTrack(c.UserContext(), user.ID, queryParams.SourcePlatform, source, queryParams.Platform, queryParams.MinConfidence)
+			if _err != nil {
+				return presenter.PlatformErrorResponse(c, "error searching for track", _err)
+			}
+
+			return c.Status(http.StatusOK).JSON(presenter.SuccessResponse("track found successfully", match))
+		}
+
+		candidates, err := ag.SearchTrack(c.UserContext(), queryParams.Platform, source)
 		if err != nil {
+			return presenter.PlatformErrorResponse(c, "error searching for track", err)
+		}
+
+		var matches []utils.Track
+		for _, candidate := range candidates {
+			if candidate.Confidence >= queryParams.MinConfidence {
+				matches = append(matches, candidate)
+			}
+		}
+		if len(matches) == 0 {
+			return presenter.PlatformErrorResponse(c, "error searching for track", fmt.Errorf("%w with a confidence of at least %.2f", aggregator.ErrNoMatch, queryParams.MinConfidence))
+		}
+
+		if len(matches) > queryParams.Candidates {
+			matches = matches[:queryParams.Candidates]
+		}
+
+		return c.Status(http.StatusOK).JSON(presenter.SuccessResponse("tracks found successfully", matches))
+	}
+}
+
+// FindTrackResult is the outcome of searching for one of the tracks of a batch.
+type FindTrackResult struct {
+	// Track is nil when the track could not be found, Error then holds the reason.
+	Track *utils.Track `json:"track"`
+	Error string       `json:"error,omitempty"`
+}
+
+// FindTracksController searches for a batch of tracks on a platform and returns the best match of each in the order they were sent.
+// The searches run concurrently, bounded per platform by the aggregator.
+func FindTracksController(ag *aggregator.MusicStreamingPlatformsAggregator, _ *database.Database) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var requestBody FindTracksRequest
+
+		err := c.BodyParser(&requestBody)
+		if err != nil {
+			return c.
+				Status(http.StatusBadRequest).
+				JSON(presenter.ErrorResponse("validation error", err.Error()))
+		}
+
+		if ok, errors := requestBody.Validate(); !ok {
 			return c.
-				Status(http.StatusInternalServerError).
-				JSON(presenter.ErrorResponse("error searching for track", err.Error()))
+				Status(http.StatusUnprocessableEntity).
+				JSON(presenter.ErrorResponse("validation error", errors...))
+		}
+
+		user, _ := users.CurrentUser(c)
+		var wg sync.WaitGroup
+		results := make([]FindTrackResult, len(requestBody.Tracks))
+		for i, track := range requestBody.Tracks {
+			if err = validateTrack(track); err != nil {
+				results[i].Error = err.Error()
+				continue
+			}
+
+			wg.Add(1)
+			go func(result *FindTrackResult, source utils.Track) {
+				defer wg.Done()
+
+				match, _err := ag.FindTrack(c.UserContext(), user.ID, requestBody.SourcePlatform, source, requestBody.Platform, requestBody.MinConfidence)
+				if _err != nil {
+					result.Error = _err.Error()
+					return
+				}
+				result.Track = &match
+			}(&results[i], track)
 		}
+		wg.Wait()
 
-		return c.Status(http.StatusOK).JSON(presenter.SuccessResponse("track found successfully", track))
+		return c.Status(http.StatusOK).JSON(presenter.SuccessResponse("tracks searched successfully", results))
 	}
 }
 
@@ -87,32 +177,31 @@ func CreatePlaylistController(ag *aggregator.MusicStreamingPlatformsAggregator,
 				JSON(presenter.ErrorResponse("validation error", errors...))
 		}
 
-		x := ag.GetStreamingPlatform(requestBody.Platform)
-		accessToken := strings.TrimSpace(requestBody.AccessToken)
-		if accessToken == "" && x.RequiresAccessToken() {
-			credentialsInDB, _err := db.GetDBOauthCredentials(requestBody.Platform)
-			if _err != nil {
-				return c.
-					Status(http.StatusInternalServerError).
-					JSON(presenter.ErrorResponse("error fetching access token from db", _err.Error()))
-			}
-
-			var credentials utils.OauthCredentials
-			err = credentials.FromDB(credentialsInDB.Credentials)
-			if err != nil {
-				return c.
-					Status(http.StatusInternalServerError).
-					JSON(presenter.ErrorResponse("error parsing access token from db", err.Error()))
-			}
+		// the playlist is created in the account of the signed in user unless an access token is provided.
+		providedAccessToken := strings.TrimSpace(requestBody.AccessToken)
+		accessToken, err := users.AccessToken(c, ag, db, requestBody.Platform, providedAccessToken)
+		if errors.Is(err, users.ErrNoAccessToken) {
+			return c.
+				Status(http.StatusUnauthorized).
+				JSON(presenter.ErrorResponse("access token required", err.Error()))
+		}
+		if err != nil {
+			return presenter.PlatformErrorResponse(c, "error fetching access token", err)
+		}
 
-			accessToken = credentials.AccessToken
+		destination, err := ag.GetStreamingPlatform(requestBody.Platform)
+		if err != nil {
+			return presenter.PlatformErrorResponse(c, "error creating playlist", err)
 		}
 
-		playlistURL, err := x.CreatePlaylist(requestBody.Playlist, accessToken)
+		var playlistURL string
+		owner := users.CredentialsOwner(c, ag, requestBody.Platform, providedAccessToken)
+		err = users.RetryOnUnauthorized(c.UserContext(), ag, db, owner, requestBody.Platform, accessToken, func(accessToken string) (_err error) {
+			playlistURL, _err = destination.CreatePlaylist(c.UserContext(), requestBody.Playlist, accessToken)
+			return _err
+		})
 		if err != nil {
-			return c.
-				Status(http.StatusInternalServerError).
-				JSON(presenter.ErrorResponse("error creating playlist", err.Error()))
+			return presenter.PlatformErrorResponse(c, "error creating playlist", err)
 		}
 
 		return c.Status(http.StatusOK).JSON(presenter.SuccessResponse("playlist created successfully", playlistURL))
@@ -120,8 +209,14 @@ func CreatePlaylistController(ag *aggregator.MusicStreamingPlatformsAggregator,
 }
 
 // GetSupportedPlatformsController returns a handler function for getting the list of supported music streaming platforms.
+// Only their names are returned, the capabilities of every platform are listed by `/v1/platforms`.
 func GetSupportedPlatformsController(ag *aggregator.MusicStreamingPlatformsAggregator, _ *database.Database) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		return c.Status(http.StatusOK).JSON(presenter.SuccessResponse("All supported music streaming platforms returned successfully!", ag.SupportedPlatforms()))
+		var platforms []aggregator.MusicStreamingPlatform
+		for _, factory := range ag.SupportedPlatforms() {
+			platforms = append(platforms, factory.Name)
+		}
+
+		return c.Status(http.StatusOK).JSON(presenter.SuccessResponse("All supported music streaming platforms returned successfully!", platforms))
 	}
 }
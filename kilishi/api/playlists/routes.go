@@ -11,5 +11,6 @@ func RouterV1(router fiber.Router, aggregatorService *aggregator.MusicStreamingP
 	router.Get("/v1/playlists", GetPlaylistController(aggregatorService, db))
 	router.Post("/v1/playlists", CreatePlaylistController(aggregatorService, db))
 	router.Get("/v1/playlists/tracks", FindTrackController(aggregatorService, db))
+	router.Post("/v1/playlists/tracks/batch", FindTracksController(aggregatorService, db))
 	router.Get("/v1/playlists/supported", GetSupportedPlatformsController(aggregatorService, db))
 }
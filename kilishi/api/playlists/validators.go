@@ -1,27 +1,53 @@
 package playlists
 
 import (
-	"errors"
 	"fmt"
 	"strings"
 
+	"github.com/prettyirrelevant/kilishi/api/validation"
 	"github.com/prettyirrelevant/kilishi/streaming_platforms/aggregator"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
 	"github.com/prettyirrelevant/kilishi/utils"
 )
 
+const (
+	// maximumNumOfCandidates is the most candidates any streaming platform retrieves for a search.
+	maximumNumOfCandidates = 10
+	// maximumNumOfBatchTracks is the most tracks that can be searched for in a single batch.
+	maximumNumOfBatchTracks = 200
+)
+
+// GetPlaylistRequest is a struct that represents the query parameters for the GetPlaylistController function.
+// `platform` is optional, it is detected from `playlist_url` when omitted.
 type GetPlaylistRequest struct {
 	Platform    aggregator.MusicStreamingPlatform `query:"platform"`
 	PlaylistURL string                            `query:"playlist_url"`
 }
 
+// DetectPlatform fills in the platform from the playlist URL when the caller did not provide one.
+func (g *GetPlaylistRequest) DetectPlatform() {
+	if strings.TrimSpace(string(g.Platform)) != "" {
+		return
+	}
+
+	if platform, kind, ok := aggregator.DetectPlatform(g.PlaylistURL); ok && kind == registry.PlaylistURL {
+		g.Platform = platform
+	}
+}
+
 func (g *GetPlaylistRequest) Validate() (bool, []string) {
 	var foundErrors []string
+	var err error
 
-	err := validateStreamingPlatform(g.Platform, fmt.Sprintf("%s is not a supported streaming platform", g.Platform))
-	if err != nil {
-		foundErrors = append(foundErrors, err.Error())
+	if strings.TrimSpace(string(g.Platform)) == "" {
+		foundErrors = append(foundErrors, "`platform` could not be detected from `playlist_url`, please provide it.")
+	} else {
+		err = validation.StreamingPlatform(g.Platform, fmt.Sprintf("%s is not a supported streaming platform", g.Platform), func(c registry.Capabilities) bool { return c.GetPlaylist })
+		if err != nil {
+			foundErrors = append(foundErrors, err.Error())
+		}
 	}
-	err = validateString(g.PlaylistURL, "`playlist_url` is required.")
+	err = validation.String(g.PlaylistURL, "`playlist_url` is required.")
 	if err != nil {
 		foundErrors = append(foundErrors, err.Error())
 	}
@@ -36,23 +62,81 @@ type FindTrackRequest struct {
 	Platform aggregator.MusicStreamingPlatform `query:"platform"`
 	Title    string                            `query:"title"`
 	Artists  []string                          `query:"artists"`
+	// SourcePlatform and ID are optional, they identify the track on the platform it comes from
+	// so a match recorded for it earlier can be reused.
+	SourcePlatform aggregator.MusicStreamingPlatform `query:"source_platform"`
+	ID             string                            `query:"id"`
+	// ISRC is optional, when provided an exact lookup is attempted before searching by title and artists.
+	ISRC string `query:"isrc"`
+	// Duration (in seconds) and Album are optional, they help tell candidates with the same title apart.
+	Duration int    `query:"duration"`
+	Album    string `query:"album"`
+	// Candidates is the number of ranked candidates to return, only the best match is returned when it is zero.
+	Candidates int `query:"candidates"`
+	// MinConfidence is the confidence from 0 to 1 below which a candidate is not considered a match.
+	MinConfidence float64 `query:"min_confidence"`
 }
 
 func (f *FindTrackRequest) Validate() (bool, []string) {
 	var foundErrors []string
 
-	err := validateStreamingPlatform(f.Platform, fmt.Sprintf("%s is not a supported streaming platform", f.Platform))
+	err := validation.StreamingPlatform(f.Platform, fmt.Sprintf("%s is not a supported streaming platform", f.Platform), func(c registry.Capabilities) bool { return c.LookupTrack })
 	if err != nil {
 		foundErrors = append(foundErrors, err.Error())
 	}
-	err = validateString(f.Title, "title is required")
+	err = validateSourcePlatform(f.SourcePlatform)
 	if err != nil {
 		foundErrors = append(foundErrors, err.Error())
 	}
-	err = validateStringSlice(f.Artists, "artists is required")
+	err = validation.String(f.Title, "title is required")
 	if err != nil {
 		foundErrors = append(foundErrors, err.Error())
 	}
+	err = validation.StringSlice(f.Artists, "artists is required")
+	if err != nil {
+		foundErrors = append(foundErrors, err.Error())
+	}
+	if f.Candidates < 0 || f.Candidates > maximumNumOfCandidates {
+		foundErrors = append(foundErrors, fmt.Sprintf("candidates must be between 0 and %d", maximumNumOfCandidates))
+	}
+	if f.MinConfidence < 0 || f.MinConfidence > 1 {
+		foundErrors = append(foundErrors, "min_confidence must be between 0 and 1")
+	}
+	if len(foundErrors) > 0 {
+		return false, foundErrors
+	}
+
+	return true, foundErrors
+}
+
+// FindTracksRequest is a struct that represents the request body for the FindTracksController function.
+// The tracks are validated one by one, an invalid track is reported in its result instead of failing the whole batch.
+type FindTracksRequest struct {
+	Platform aggregator.MusicStreamingPlatform `json:"platform"`
+	Tracks   []utils.Track                     `json:"tracks"`
+	// SourcePlatform is optional, it is the platform the IDs of the tracks belong to.
+	SourcePlatform aggregator.MusicStreamingPlatform `json:"source_platform"`
+	// MinConfidence is the confidence from 0 to 1 below which a candidate is not considered a match.
+	MinConfidence float64 `json:"min_confidence"`
+}
+
+func (f *FindTracksRequest) Validate() (bool, []string) {
+	var foundErrors []string
+
+	err := validation.StreamingPlatform(f.Platform, fmt.Sprintf("%s is not a supported streaming platform", f.Platform), func(c registry.Capabilities) bool { return c.LookupTrack })
+	if err != nil {
+		foundErrors = append(foundErrors, err.Error())
+	}
+	err = validateSourcePlatform(f.SourcePlatform)
+	if err != nil {
+		foundErrors = append(foundErrors, err.Error())
+	}
+	if len(f.Tracks) == 0 || len(f.Tracks) > maximumNumOfBatchTracks {
+		foundErrors = append(foundErrors, fmt.Sprintf("`tracks` must have between 1 and %d tracks", maximumNumOfBatchTracks))
+	}
+	if f.MinConfidence < 0 || f.MinConfidence > 1 {
+		foundErrors = append(foundErrors, "min_confidence must be between 0 and 1")
+	}
 	if len(foundErrors) > 0 {
 		return false, foundErrors
 	}
@@ -70,11 +154,11 @@ type ConvertPlaylistRequest struct {
 func (c *ConvertPlaylistRequest) Validate() (bool, []string) {
 	var foundErrors []string
 
-	err := validateStreamingPlatform(c.Platform, fmt.Sprintf("%s is not a supported streaming platform", c.Platform))
+	err := validation.StreamingPlatform(c.Platform, fmt.Sprintf("%s does not support playlist creation", c.Platform), func(c registry.Capabilities) bool { return c.CreatePlaylist })
 	if err != nil {
 		foundErrors = append(foundErrors, err.Error())
 	}
-	err = validateString(c.Playlist.Title, "`playlist` requires a title")
+	err = validation.String(c.Playlist.Title, "`playlist` requires a title")
 	if err != nil {
 		foundErrors = append(foundErrors, err.Error())
 	}
@@ -83,7 +167,7 @@ func (c *ConvertPlaylistRequest) Validate() (bool, []string) {
 	}
 
 	for _, track := range c.Playlist.Tracks {
-		err := validateString(track.ID, "one of the tracks is missing an identifier")
+		err := validation.String(track.ID, "one of the tracks is missing an identifier")
 		if err != nil {
 			foundErrors = append(foundErrors, err.Error())
 			break
@@ -95,34 +179,18 @@ func (c *ConvertPlaylistRequest) Validate() (bool, []string) {
 	return true, foundErrors
 }
 
-func validateString(m, errMsg string) error {
-	if strings.TrimSpace(m) == "" {
-		return errors.New(errMsg)
-	}
-	return nil
-}
-
-func validateStringSlice(m []string, errMsg string) error {
-	if len(m) == 0 {
-		return errors.New(errMsg)
-	}
-	for _, i := range m {
-		err := validateString(i, errMsg)
-		if err != nil {
-			return err
-		}
+// validateTrack checks that a track has what is needed to search for it.
+func validateTrack(track utils.Track) error {
+	if err := validation.String(track.Title, "title is required"); err != nil {
+		return err
 	}
-
-	return nil
+	return validation.StringSlice(track.Artists, "artists is required")
 }
 
-func validateStreamingPlatform(m aggregator.MusicStreamingPlatform, errMsg string) error {
-	if err := validateString(string(m), errMsg); err != nil {
-		return err
+// validateSourcePlatform checks that the optional source platform is registered when it is provided.
+func validateSourcePlatform(m aggregator.MusicStreamingPlatform) error {
+	if strings.TrimSpace(string(m)) == "" {
+		return nil
 	}
-	if ok := aggregator.AllMusicStreamingPlatforms[m]; !ok {
-		return errors.New(errMsg)
-	}
-
-	return nil
+	return validation.StreamingPlatform(m, fmt.Sprintf("%s is not a supported streaming platform", m), func(registry.Capabilities) bool { return true })
 }
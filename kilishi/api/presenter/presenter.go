@@ -1,6 +1,25 @@
 package presenter
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+)
+
+// Error codes tell clients why a request to a streaming platform failed without parsing the messages, see PlatformErrorResponse.
+const (
+	ErrorCodeNotFound            = "not_found"
+	ErrorCodeInvalidInput        = "invalid_input"
+	ErrorCodeUnauthorized        = "unauthorized"
+	ErrorCodeForbidden           = "forbidden"
+	ErrorCodeRateLimited         = "rate_limited"
+	ErrorCodeUpstreamUnavailable = "upstream_unavailable"
+	ErrorCodeInternal            = "internal_error"
+)
 
 func SuccessResponse(message string, data any) *fiber.Map {
 	return &fiber.Map{
@@ -15,3 +34,35 @@ func ErrorResponse(message string, errorMsgs ...string) *fiber.Map {
 		"errors":  errorMsgs,
 	}
 }
+
+// PlatformErrorResponse answers with the status and error code matching the kind of error a streaming platform failed with,
+// e.g. 404 for `registry.ErrNotFound`. Rate limited requests tell the client when to retry with the `Retry-After` header,
+// and errors of an unknown kind are answered with a 500.
+func PlatformErrorResponse(c *fiber.Ctx, message string, err error) error {
+	status, code := http.StatusInternalServerError, ErrorCodeInternal
+
+	var rateLimitErr *registry.RateLimitError
+	switch {
+	case errors.Is(err, registry.ErrNotFound):
+		status, code = http.StatusNotFound, ErrorCodeNotFound
+	case errors.Is(err, registry.ErrInvalidInput):
+		status, code = http.StatusUnprocessableEntity, ErrorCodeInvalidInput
+	case errors.Is(err, registry.ErrUnauthorized):
+		status, code = http.StatusUnauthorized, ErrorCodeUnauthorized
+	case errors.Is(err, registry.ErrForbidden):
+		status, code = http.StatusForbidden, ErrorCodeForbidden
+	case errors.Is(err, registry.ErrRateLimited):
+		status, code = http.StatusTooManyRequests, ErrorCodeRateLimited
+		if errors.As(err, &rateLimitErr) && rateLimitErr.RetryAfter > 0 {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(rateLimitErr.RetryAfter.Seconds())))
+		}
+	case errors.Is(err, registry.ErrUpstreamUnavailable):
+		status, code = http.StatusBadGateway, ErrorCodeUpstreamUnavailable
+	}
+
+	return c.Status(status).JSON(&fiber.Map{
+		"message": message,
+		"code":    code,
+		"errors":  []string{err.Error()},
+	})
+}
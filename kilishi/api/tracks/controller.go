@@ -0,0 +1,109 @@
+package tracks
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/prettyirrelevant/kilishi/api/database"
+	"github.com/prettyirrelevant/kilishi/api/presenter"
+	"github.com/prettyirrelevant/kilishi/api/users"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/aggregator"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+	"github.com/prettyirrelevant/kilishi/utils"
+)
+
+// TrackLink is the equivalent of a resolved track on another platform.
+type TrackLink struct {
+	// Track is nil when the track could not be found, Error then holds the reason.
+	Track *utils.Track `json:"track"`
+	Error string       `json:"error,omitempty"`
+}
+
+// ResolveTrackResponse is the track a link points at and its equivalents, keyed by the platform they are on.
+type ResolveTrackResponse struct {
+	Source utils.Track                                      `json:"source"`
+	Links  map[aggregator.MusicStreamingPlatform]*TrackLink `json:"links"`
+}
+
+// GetTrackLinksController returns every track on other platforms the given track is known to be the same recording as.
+func GetTrackLinksController(_ *aggregator.MusicStreamingPlatformsAggregator, db *database.Database) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		platform := aggregator.MusicStreamingPlatform(c.Params("platform"))
+		if _, ok := registry.Get(platform); !ok {
+			return c.
+				Status(http.StatusNotFound).
+				JSON(presenter.ErrorResponse(fmt.Sprintf("%s is not a supported streaming platform", platform)))
+		}
+
+		links, err := db.GetTrackLinks(c.UserContext(), platform, c.Params("id"))
+		if err != nil {
+			return c.
+				Status(http.StatusInternalServerError).
+				JSON(presenter.ErrorResponse("error retrieving track links", err.Error()))
+		}
+
+		return c.Status(http.StatusOK).JSON(presenter.SuccessResponse("track links retrieved successfully", links))
+	}
+}
+
+// ResolveTrackController fetches the track a link points at and searches for it on every other platform that can look tracks up.
+// A platform the track could not be found on is reported in its link instead of failing the request.
+func ResolveTrackController(ag *aggregator.MusicStreamingPlatformsAggregator, _ *database.Database) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var queryParams ResolveTrackRequest
+
+		err := c.QueryParser(&queryParams)
+		if err != nil {
+			return c.
+				Status(http.StatusBadRequest).
+				JSON(presenter.ErrorResponse("validation error", err.Error()))
+		}
+
+		queryParams.DetectPlatform()
+		if ok, errors := queryParams.Validate(); !ok {
+			return c.
+				Status(http.StatusUnprocessableEntity).
+				JSON(presenter.ErrorResponse("validation error", errors...))
+		}
+
+		source, err := ag.GetTrack(c.UserContext(), queryParams.Platform, strings.TrimSpace(queryParams.URL))
+		if err != nil {
+			return presenter.PlatformErrorResponse(c, "error retrieving track", err)
+		}
+
+		// anonymous callers have no match overrides, their user ID is empty.
+		user, _ := users.CurrentUser(c)
+
+		response := ResolveTrackResponse{Source: source, Links: make(map[aggregator.MusicStreamingPlatform]*TrackLink)}
+		var wg sync.WaitGroup
+
+		// the links are created before the searches start, so every goroutine writes to its own link without a lock.
+		for _, factory := range ag.SupportedPlatforms() {
+			if factory.Name == queryParams.Platform || !factory.Capabilities.LookupTrack {
+				continue
+			}
+
+			link := &TrackLink{}
+			response.Links[factory.Name] = link
+
+			wg.Add(1)
+			go func(destination aggregator.MusicStreamingPlatform) {
+				defer wg.Done()
+
+				match, _err := ag.FindRetrievedTrack(c.UserContext(), user.ID, queryParams.Platform, source, destination, queryParams.MinConfidence)
+				if _err != nil {
+					link.Error = _err.Error()
+					return
+				}
+				link.Track = &match
+			}(factory.Name)
+		}
+		wg.Wait()
+
+		return c.Status(http.StatusOK).JSON(presenter.SuccessResponse("track resolved successfully", response))
+	}
+}
@@ -0,0 +1,13 @@
+package tracks
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/prettyirrelevant/kilishi/api/database"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/aggregator"
+)
+
+func RouterV1(router fiber.Router, aggregatorService *aggregator.MusicStreamingPlatformsAggregator, db *database.Database) {
+	router.Get("/v1/tracks/resolve", ResolveTrackController(aggregatorService, db))
+	router.Get("/v1/tracks/:platform/:id/links", GetTrackLinksController(aggregatorService, db))
+}
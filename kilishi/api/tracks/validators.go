@@ -0,0 +1,45 @@
+package tracks
+
+import (
+	"strings"
+
+	"github.com/prettyirrelevant/kilishi/api/validation"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/aggregator"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+)
+
+// ResolveTrackRequest is a struct that represents the query parameters for the ResolveTrackController function.
+type ResolveTrackRequest struct {
+	URL string `query:"url"`
+	// MinConfidence is the confidence from 0 to 1 below which a track is reported as not found.
+	MinConfidence float64 `query:"min_confidence"`
+
+	// Platform is detected from URL, see DetectPlatform.
+	Platform aggregator.MusicStreamingPlatform `query:"-"`
+}
+
+// DetectPlatform fills in the platform the track URL belongs to.
+func (r *ResolveTrackRequest) DetectPlatform() {
+	if platform, kind, ok := aggregator.DetectPlatform(strings.TrimSpace(r.URL)); ok && kind == registry.TrackURL {
+		r.Platform = platform
+	}
+}
+
+func (r *ResolveTrackRequest) Validate() (bool, []string) {
+	var foundErrors []string
+
+	err := validation.String(r.URL, "`url` is required.")
+	if err != nil {
+		foundErrors = append(foundErrors, err.Error())
+	} else if factory, ok := registry.Get(r.Platform); !ok || !factory.Capabilities.GetTrack {
+		foundErrors = append(foundErrors, "`url` is not a track link of a supported streaming platform.")
+	}
+	if r.MinConfidence < 0 || r.MinConfidence > 1 {
+		foundErrors = append(foundErrors, "min_confidence must be between 0 and 1")
+	}
+	if len(foundErrors) > 0 {
+		return false, foundErrors
+	}
+
+	return true, foundErrors
+}
@@ -0,0 +1,141 @@
+package users
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/prettyirrelevant/kilishi/api/database"
+	"github.com/prettyirrelevant/kilishi/api/presenter"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/aggregator"
+	"github.com/prettyirrelevant/kilishi/utils"
+)
+
+// SessionResponse is returned when a user signs in.
+type SessionResponse struct {
+	User database.User `json:"user"`
+	// Token is the session token, clients that do not keep cookies send it as a bearer token.
+	Token string `json:"token"`
+}
+
+// UserResponse describes the signed in user.
+type UserResponse struct {
+	database.User
+	// Platforms are the streaming platforms the user has connected an account on.
+	Platforms []aggregator.MusicStreamingPlatform `json:"platforms"`
+	// Connections describe the state of the credentials stored for each of the platforms.
+	Connections []ConnectionResponse `json:"connections"`
+}
+
+// ConnectionResponse describes the credentials a user stored for a platform.
+type ConnectionResponse struct {
+	Platform aggregator.MusicStreamingPlatform `json:"platform"`
+	// ExpiresAt is the unix time the access token expires at, it is 0 if it does not expire.
+	ExpiresAt int `json:"expires_at"`
+	// NeedsReauthorization is set when the access token could not be refreshed, the platform has to be connected again.
+	NeedsReauthorization bool   `json:"needs_reauthorization"`
+	RefreshError         string `json:"refresh_error,omitempty"`
+}
+
+// CreateUserController creates a user and signs them in.
+// Accounts on streaming platforms can then be connected to the user through their oauth callbacks.
+func CreateUserController(ag *aggregator.MusicStreamingPlatformsAggregator, db *database.Database) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var requestBody CreateUserRequest
+
+		err := c.BodyParser(&requestBody)
+		if err != nil {
+			return c.
+				Status(http.StatusBadRequest).
+				JSON(presenter.ErrorResponse("validation error", err.Error()))
+		}
+
+		if ok, errors := requestBody.Validate(); !ok {
+			return c.
+				Status(http.StatusUnprocessableEntity).
+				JSON(presenter.ErrorResponse("validation error", errors...))
+		}
+
+		user, err := db.CreateUser(c.UserContext(), requestBody.Name)
+		if err != nil {
+			return c.
+				Status(http.StatusInternalServerError).
+				JSON(presenter.ErrorResponse("error creating user", err.Error()))
+		}
+
+		token, err := StartSession(c, db, user, ag.Config.SessionLifetime)
+		if err != nil {
+			return c.
+				Status(http.StatusInternalServerError).
+				JSON(presenter.ErrorResponse("error starting session", err.Error()))
+		}
+
+		return c.Status(http.StatusCreated).JSON(presenter.SuccessResponse("user created successfully", SessionResponse{User: user, Token: token}))
+	}
+}
+
+// GetCurrentUserController returns the signed in user and the platforms they have connected.
+func GetCurrentUserController(_ *aggregator.MusicStreamingPlatformsAggregator, db *database.Database) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, ok := CurrentUser(c)
+		if !ok {
+			return c.
+				Status(http.StatusUnauthorized).
+				JSON(presenter.ErrorResponse("you are not signed in"))
+		}
+
+		platforms, err := db.GetConnectedPlatforms(c.UserContext(), user.ID)
+		if err != nil {
+			return c.
+				Status(http.StatusInternalServerError).
+				JSON(presenter.ErrorResponse("error retrieving connected platforms", err.Error()))
+		}
+
+		connections := make([]ConnectionResponse, 0, len(platforms))
+		for _, platform := range platforms {
+			credentialsInDB, _err := db.GetDBOauthCredentials(c.UserContext(), user.ID, platform)
+			if _err != nil {
+				return c.
+					Status(http.StatusInternalServerError).
+					JSON(presenter.ErrorResponse("error retrieving connected platforms", _err.Error()))
+			}
+
+			var credentials utils.OauthCredentials
+			if _err = credentials.FromDB(credentialsInDB.Credentials); _err != nil {
+				return c.
+					Status(http.StatusInternalServerError).
+					JSON(presenter.ErrorResponse("error retrieving connected platforms", _err.Error()))
+			}
+
+			connections = append(connections, ConnectionResponse{
+				Platform:             platform,
+				ExpiresAt:            credentials.ExpiresAt,
+				NeedsReauthorization: credentialsInDB.NeedsReauthorization,
+				RefreshError:         credentialsInDB.RefreshError,
+			})
+		}
+
+		return c.Status(http.StatusOK).JSON(presenter.SuccessResponse("user retrieved successfully", UserResponse{User: user, Platforms: platforms, Connections: connections}))
+	}
+}
+
+// SignOutController ends the session the request was made with.
+func SignOutController(_ *aggregator.MusicStreamingPlatformsAggregator, db *database.Database) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token, ok := SessionToken(c)
+		if !ok {
+			return c.
+				Status(http.StatusUnauthorized).
+				JSON(presenter.ErrorResponse("you are not signed in"))
+		}
+
+		if err := db.DeleteSession(c.UserContext(), token); err != nil {
+			return c.
+				Status(http.StatusInternalServerError).
+				JSON(presenter.ErrorResponse("error ending session", err.Error()))
+		}
+
+		c.ClearCookie(SessionCookieName)
+		return c.Status(http.StatusOK).JSON(presenter.SuccessResponse("signed out successfully", nil))
+	}
+}
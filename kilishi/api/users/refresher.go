@@ -0,0 +1,217 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/prettyirrelevant/kilishi/api/database"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/aggregator"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+	"github.com/prettyirrelevant/kilishi/utils"
+)
+
+const (
+	// refreshAhead is how long before they expire access tokens are refreshed.
+	refreshAhead = 5 * time.Minute
+	// refreshLockTTL bounds how long a refresh can hold its lock, in case the process holding it dies.
+	refreshLockTTL = 30 * time.Second
+	// refreshBatchSize is the number of credentials refreshed in one sweep, the rest are picked up by the next one.
+	refreshBatchSize = 100
+)
+
+// ErrCannotRefresh is returned by RefreshCredentials when the stored credentials have no refresh token or the platform does not support refreshing.
+var ErrCannotRefresh = errors.New("access token cannot be refreshed")
+
+// RefreshCredentials refreshes the access token the user stored for the platform if it is about to expire and returns the new credentials.
+// Only one process refreshes the credentials at a time, the others wait for it and return what it stored.
+// Credentials whose refresh token has been revoked, or that expired and cannot be refreshed, are flagged so the user is asked to reconnect.
+func RefreshCredentials(ctx context.Context, ag *aggregator.MusicStreamingPlatformsAggregator, db *database.Database, userID string, platform aggregator.MusicStreamingPlatform) (utils.OauthCredentials, error) {
+	return refreshCredentials(ctx, ag, db, userID, platform, func(credentials utils.OauthCredentials) bool {
+		return credentials.ExpiresWithin(refreshAhead)
+	})
+}
+
+// ForceRefreshCredentials refreshes the access token the user stored for the platform even if it is not about to expire.
+// When rejectedAccessToken is set, the credentials are only refreshed if they still hold it, another request may have replaced it already.
+func ForceRefreshCredentials(ctx context.Context, ag *aggregator.MusicStreamingPlatformsAggregator, db *database.Database, userID string, platform aggregator.MusicStreamingPlatform, rejectedAccessToken string) (utils.OauthCredentials, error) {
+	return refreshCredentials(ctx, ag, db, userID, platform, func(credentials utils.OauthCredentials) bool {
+		return rejectedAccessToken == "" || credentials.AccessToken == rejectedAccessToken
+	})
+}
+
+// refreshCredentials refreshes the credentials the user stored for the platform if needsRefresh reports they have to be.
+func refreshCredentials(ctx context.Context, ag *aggregator.MusicStreamingPlatformsAggregator, db *database.Database, userID string, platform aggregator.MusicStreamingPlatform, needsRefresh func(utils.OauthCredentials) bool) (utils.OauthCredentials, error) {
+	lockName := fmt.Sprintf("oauth_refresh:%s:%s", userID, platform)
+	release, acquired, err := db.AcquireLock(ctx, lockName, refreshLockTTL)
+	if err != nil {
+		return utils.OauthCredentials{}, err
+	}
+	if !acquired {
+		return waitForRefresh(ctx, db, lockName, userID, platform)
+	}
+	defer release()
+
+	// the credentials are read once the lock is held, another process may have just refreshed them.
+	credentials, err := storedCredentials(ctx, db, userID, platform)
+	if err != nil {
+		return credentials, err
+	}
+	if !needsRefresh(credentials) {
+		return credentials, nil
+	}
+
+	// the credentials of a platform that is not supported anymore are kept, it may be configured again.
+	streamingPlatform, err := ag.GetStreamingPlatform(platform)
+	if err != nil {
+		return credentials, err
+	}
+
+	refresher, isRefresher := streamingPlatform.(registry.OauthTokenRefresher)
+	if !isRefresher || credentials.RefreshToken == "" {
+		// the access token is still usable until it expires.
+		if credentials.ExpiresWithin(0) {
+			return credentials, flagCredentials(ctx, db, userID, platform, ErrCannotRefresh)
+		}
+		return credentials, ErrCannotRefresh
+	}
+
+	newCredentials, err := refresher.RefreshAccessToken(ctx, credentials)
+	if errors.Is(err, registry.ErrUnauthorized) {
+		return credentials, flagCredentials(ctx, db, userID, platform, err)
+	}
+	if err != nil {
+		return credentials, err
+	}
+
+	// platforms that do not rotate refresh tokens leave it out of the response.
+	if newCredentials.RefreshToken == "" {
+		newCredentials.RefreshToken = credentials.RefreshToken
+	}
+	if err = db.SetOauthCredentials(ctx, userID, platform, newCredentials); err != nil {
+		return newCredentials, err
+	}
+
+	return newCredentials, nil
+}
+
+// StartTokenRefresher refreshes the access tokens that are about to expire every interval until the context is cancelled.
+// Every process of the server runs it, a lock makes sure only one of them sweeps at a time.
+func StartTokenRefresher(ctx context.Context, ag *aggregator.MusicStreamingPlatformsAggregator, db *database.Database, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		refreshExpiringCredentials(ctx, ag, db, interval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RetryOnUnauthorized calls fn with the access token, and calls it once more with a refreshed one if the platform rejects it.
+// The access token is only refreshed when it belongs to the credentials stored by the user, userID is empty otherwise.
+func RetryOnUnauthorized(ctx context.Context, ag *aggregator.MusicStreamingPlatformsAggregator, db *database.Database, userID string, platform aggregator.MusicStreamingPlatform, accessToken string, fn func(accessToken string) error) error {
+	err := fn(accessToken)
+	if userID == "" || !errors.Is(err, registry.ErrUnauthorized) {
+		return err
+	}
+
+	// the access token was revoked or expired early.
+	credentials, _err := ForceRefreshCredentials(ctx, ag, db, userID, platform, accessToken)
+	if _err != nil {
+		return err
+	}
+	return fn(credentials.AccessToken)
+}
+
+// refreshExpiringCredentials refreshes a batch of the access tokens that expire before the next sweep.
+func refreshExpiringCredentials(ctx context.Context, ag *aggregator.MusicStreamingPlatformsAggregator, db *database.Database, interval time.Duration) {
+	release, acquired, err := db.AcquireLock(ctx, "oauth_refresher", interval)
+	if err != nil {
+		log.Printf("users: token refresher lock failed due to %s", err.Error())
+		return
+	}
+	if !acquired {
+		return
+	}
+	defer release()
+
+	refs, err := db.GetExpiringOauthCredentials(ctx, time.Now().Add(interval+refreshAhead), refreshBatchSize)
+	if err != nil {
+		log.Printf("users: token refresher failed due to %s", err.Error())
+		return
+	}
+
+	for _, ref := range refs {
+		if ctx.Err() != nil {
+			return
+		}
+
+		_, err = RefreshCredentials(ctx, ag, db, ref.UserID, ref.Platform)
+		if err != nil && !errors.Is(err, ErrCannotRefresh) {
+			log.Printf("users: %s access token of user %s could not be refreshed due to %s", ref.Platform, ref.UserID, err.Error())
+		}
+	}
+}
+
+// waitForRefresh waits for the refresh another process is doing to finish and returns the credentials it stored.
+func waitForRefresh(ctx context.Context, db *database.Database, lockName, userID string, platform aggregator.MusicStreamingPlatform) (utils.OauthCredentials, error) {
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+
+	deadline := time.Now().Add(refreshLockTTL)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return utils.OauthCredentials{}, ctx.Err()
+		case <-ticker.C:
+		}
+
+		release, acquired, err := db.AcquireLock(ctx, lockName, refreshLockTTL)
+		if err != nil {
+			return utils.OauthCredentials{}, err
+		}
+		if acquired {
+			release()
+			break
+		}
+	}
+
+	return storedCredentials(ctx, db, userID, platform)
+}
+
+// storedCredentials returns the credentials the user stored for the platform, failing with ErrNoAccessToken if they have to reconnect it.
+func storedCredentials(ctx context.Context, db *database.Database, userID string, platform aggregator.MusicStreamingPlatform) (utils.OauthCredentials, error) {
+	var credentials utils.OauthCredentials
+
+	credentialsInDB, err := db.GetDBOauthCredentials(ctx, userID, platform)
+	if errors.Is(err, database.ErrOauthCredentialsNotFound) {
+		return credentials, fmt.Errorf("%w: connect your %s account or provide an access token", ErrNoAccessToken, platform)
+	}
+	if err != nil {
+		return credentials, err
+	}
+	if credentialsInDB.NeedsReauthorization {
+		return credentials, fmt.Errorf("%w: reconnect your %s account, %s", ErrNoAccessToken, platform, credentialsInDB.RefreshError)
+	}
+
+	if err = credentials.FromDB(credentialsInDB.Credentials); err != nil {
+		return credentials, err
+	}
+	return credentials, nil
+}
+
+// flagCredentials records that the user has to reconnect the platform and returns the reason as an ErrNoAccessToken.
+func flagCredentials(ctx context.Context, db *database.Database, userID string, platform aggregator.MusicStreamingPlatform, reason error) error {
+	if err := db.FlagOauthCredentials(ctx, userID, platform, reason.Error()); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("%w: reconnect your %s account, %s", ErrNoAccessToken, platform, reason.Error())
+}
@@ -0,0 +1,14 @@
+package users
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/prettyirrelevant/kilishi/api/database"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/aggregator"
+)
+
+func RouterV1(router fiber.Router, aggregatorService *aggregator.MusicStreamingPlatformsAggregator, db *database.Database) {
+	router.Post("/v1/users", CreateUserController(aggregatorService, db))
+	router.Get("/v1/users/me", GetCurrentUserController(aggregatorService, db))
+	router.Delete("/v1/users/me/session", SignOutController(aggregatorService, db))
+}
@@ -0,0 +1,155 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/prettyirrelevant/kilishi/api/database"
+	"github.com/prettyirrelevant/kilishi/api/presenter"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/aggregator"
+)
+
+// SessionCookieName is the cookie the session token is stored in for browsers, other clients send it as a bearer token.
+const SessionCookieName = "kilishi_session"
+
+// Keys of the signed in user and their session token in the locals of a request.
+const (
+	userLocalsKey         = "user"
+	sessionTokenLocalsKey = "session_token"
+)
+
+var (
+	// ErrNoAccessToken is returned by AccessToken when no access token was provided and none is stored for the user.
+	ErrNoAccessToken  = errors.New("no access token")
+	errSignInRequired = fmt.Errorf("%w: sign in or provide an access token", ErrNoAccessToken)
+)
+
+// Authenticate looks up the session of the request and makes its user available to the handlers through CurrentUser.
+// Requests without a session go through as anonymous, an invalid bearer token is rejected while an invalid cookie is cleared.
+func Authenticate(db *database.Database) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token, fromCookie := sessionToken(c)
+		if token == "" {
+			return c.Next()
+		}
+
+		user, err := db.GetSessionUser(c.UserContext(), token)
+		if errors.Is(err, database.ErrSessionNotFound) {
+			if fromCookie {
+				c.ClearCookie(SessionCookieName)
+				return c.Next()
+			}
+			return c.
+				Status(http.StatusUnauthorized).
+				JSON(presenter.ErrorResponse("invalid or expired session"))
+		}
+		if err != nil {
+			return c.
+				Status(http.StatusInternalServerError).
+				JSON(presenter.ErrorResponse("error retrieving session", err.Error()))
+		}
+
+		c.Locals(userLocalsKey, user)
+		c.Locals(sessionTokenLocalsKey, token)
+		return c.Next()
+	}
+}
+
+// CurrentUser returns the user who made the request, if they are signed in.
+func CurrentUser(c *fiber.Ctx) (database.User, bool) {
+	user, ok := c.Locals(userLocalsKey).(database.User)
+	return user, ok
+}
+
+// SessionToken returns the token of the session the request was made with, if the user is signed in.
+func SessionToken(c *fiber.Ctx) (string, bool) {
+	token, ok := c.Locals(sessionTokenLocalsKey).(string)
+	return token, ok
+}
+
+// StartSession signs the user in, the session token is set as a cookie and returned for clients that do not keep cookies.
+func StartSession(c *fiber.Ctx, db *database.Database, user database.User, lifetime time.Duration) (string, error) {
+	token, err := db.CreateSession(c.UserContext(), user.ID, lifetime)
+	if err != nil {
+		return "", err
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     SessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  time.Now().Add(lifetime),
+		Secure:   c.Protocol() == "https",
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+
+	c.Locals(userLocalsKey, user)
+	c.Locals(sessionTokenLocalsKey, token)
+	return token, nil
+}
+
+// AccessToken returns the access token provided by the caller, falling back to the one the signed in user stored for the platform.
+// A stored access token that is about to expire is refreshed first.
+// It fails with ErrNoAccessToken when neither is available, or when the user has to reconnect the platform,
+// and with aggregator.ErrUnsupportedPlatform when the platform has not been initialised.
+func AccessToken(c *fiber.Ctx, ag *aggregator.MusicStreamingPlatformsAggregator, db *database.Database, platform aggregator.MusicStreamingPlatform, accessToken string) (string, error) {
+	streamingPlatform, err := ag.GetStreamingPlatform(platform)
+	if err != nil {
+		return "", err
+	}
+
+	userID := CredentialsOwner(c, ag, platform, accessToken)
+	if userID == "" {
+		if accessToken == "" && streamingPlatform.RequiresAccessToken() {
+			return "", errSignInRequired
+		}
+		return accessToken, nil
+	}
+
+	credentials, err := storedCredentials(c.UserContext(), db, userID, platform)
+	if err != nil {
+		return "", err
+	}
+
+	if credentials.ExpiresWithin(refreshAhead) {
+		credentials, err = RefreshCredentials(c.UserContext(), ag, db, userID, platform)
+		// an access token that cannot be refreshed is used until it expires.
+		if err != nil && !errors.Is(err, ErrCannotRefresh) {
+			return "", err
+		}
+	}
+
+	return credentials.AccessToken, nil
+}
+
+// CredentialsOwner returns the ID of the user whose stored credentials AccessToken uses for the platform.
+// It is empty when the caller provided the access token, the platform does not need one or is not supported, or nobody is signed in.
+func CredentialsOwner(c *fiber.Ctx, ag *aggregator.MusicStreamingPlatformsAggregator, platform aggregator.MusicStreamingPlatform, accessToken string) string {
+	streamingPlatform, err := ag.GetStreamingPlatform(platform)
+	if err != nil || accessToken != "" || !streamingPlatform.RequiresAccessToken() {
+		return ""
+	}
+
+	user, ok := CurrentUser(c)
+	if !ok {
+		return ""
+	}
+	return user.ID
+}
+
+// sessionToken returns the session token sent with the request and whether it came from the cookie.
+func sessionToken(c *fiber.Ctx) (string, bool) {
+	if authorization := c.Get(fiber.HeaderAuthorization); authorization != "" {
+		if strings.HasPrefix(authorization, "Bearer ") {
+			return strings.TrimSpace(strings.TrimPrefix(authorization, "Bearer ")), false
+		}
+	}
+
+	return c.Cookies(SessionCookieName), true
+}
@@ -0,0 +1,38 @@
+package users
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// maximumNameLength is the longest name a user can have.
+const maximumNameLength = 100
+
+// CreateUserRequest is a struct that represents the request body for the CreateUserController function.
+type CreateUserRequest struct {
+	// Name is optional, it defaults to the name of the first account the user connects.
+	Name string `json:"name"`
+}
+
+func (c *CreateUserRequest) Validate() (bool, []string) {
+	var foundErrors []string
+
+	c.Name = strings.TrimSpace(c.Name)
+	if err := validateLength(c.Name, maximumNameLength, "`name` must not be longer than 100 characters."); err != nil {
+		foundErrors = append(foundErrors, err.Error())
+	}
+	if len(foundErrors) > 0 {
+		return false, foundErrors
+	}
+
+	return true, foundErrors
+}
+
+func validateLength(m string, maximumLength int, errMsg string) error {
+	if utf8.RuneCountInString(m) > maximumLength {
+		return errors.New(errMsg)
+	}
+
+	return nil
+}
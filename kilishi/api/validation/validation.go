@@ -0,0 +1,44 @@
+// Package validation holds the checks shared by the request validators of the API.
+package validation
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+)
+
+// String fails with the error message when the value is blank.
+func String(m, errMsg string) error {
+	if strings.TrimSpace(m) == "" {
+		return errors.New(errMsg)
+	}
+	return nil
+}
+
+// StringSlice fails with the error message when the slice is empty or any of its values is blank.
+func StringSlice(m []string, errMsg string) error {
+	if len(m) == 0 {
+		return errors.New(errMsg)
+	}
+	for _, i := range m {
+		err := String(i, errMsg)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// StreamingPlatform checks that the platform is registered and has the capability the request needs.
+func StreamingPlatform(m registry.MusicStreamingPlatform, errMsg string, supports func(registry.Capabilities) bool) error {
+	if err := String(string(m), errMsg); err != nil {
+		return err
+	}
+	if factory, ok := registry.Get(m); !ok || !supports(factory.Capabilities) {
+		return errors.New(errMsg)
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+)
+
+func TestString(t *testing.T) {
+	if err := String(" \t", "`title` is required."); err == nil || err.Error() != "`title` is required." {
+		t.Errorf("String() of a blank value error = %v, want the message", err)
+	}
+	if err := String("Essence", "`title` is required."); err != nil {
+		t.Errorf("String() error = %v, want nil", err)
+	}
+
+	if err := StringSlice(nil, "artists is required"); err == nil {
+		t.Error("StringSlice() of an empty slice error = nil, want an error")
+	}
+	if err := StringSlice([]string{"Wizkid", " "}, "artists is required"); err == nil {
+		t.Error("StringSlice() with a blank value error = nil, want an error")
+	}
+	if err := StringSlice([]string{"Wizkid", "Tems"}, "artists is required"); err != nil {
+		t.Errorf("StringSlice() error = %v, want nil", err)
+	}
+}
+
+func TestStreamingPlatform(t *testing.T) {
+	registry.Register(registry.Factory{
+		Name:         "validationtest",
+		Capabilities: registry.Capabilities{GetPlaylist: true},
+		New: func(registry.Options) (registry.MusicStreamingPlatformInterface, error) {
+			return nil, nil
+		},
+	})
+
+	testCases := []struct {
+		platform registry.MusicStreamingPlatform
+		supports func(registry.Capabilities) bool
+		valid    bool
+	}{
+		{platform: "validationtest", supports: func(c registry.Capabilities) bool { return c.GetPlaylist }, valid: true},
+		{platform: "validationtest", supports: func(c registry.Capabilities) bool { return c.CreatePlaylist }, valid: false},
+		{platform: "unknown", supports: func(registry.Capabilities) bool { return true }, valid: false},
+		{platform: " ", supports: func(registry.Capabilities) bool { return true }, valid: false},
+	}
+
+	for _, tc := range testCases {
+		err := StreamingPlatform(tc.platform, "not supported", tc.supports)
+		if (err == nil) != tc.valid {
+			t.Errorf("StreamingPlatform(%q) error = %v, want valid %t", tc.platform, err, tc.valid)
+		}
+	}
+}
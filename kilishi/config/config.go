@@ -1,36 +1,60 @@
 package config
 
 import (
+	"time"
+
 	"github.com/caarlos0/env/v7"
 	_ "github.com/joho/godotenv/autoload" // autoload environment variables from .env file
+
+	"github.com/prettyirrelevant/kilishi/utils"
 )
 
+// Config holds the core configuration of the application.
+// Each streaming platform loads its own configuration when it is registered, see `registry.Factory`.
 type Config struct {
-	Debug                   bool   `env:"DEBUG,notEmpty"`
-	Port                    int    `env:"PORT,notEmpty"`
-	Address                 string `env:"ADDRESS,notEmpty"`
-	SecretKey               string `env:"SECRET_KEY,notEmpty"`
-	InitializationVector    string `env:"INITIALIZATION_VECTOR,notEmpty"`
-	DatabaseURL             string `env:"DATABASE_URL,notEmpty"`
-	SpotifyClientID         string `env:"SPOTIFY_CLIENT_ID,notEmpty"`
-	SpotifyClientSecret     string `env:"SPOTIFY_CLIENT_SECRET,notEmpty"`
-	SpotifyClientAuthURL    string `env:"SPOTIFY_CLIENT_AUTH_URL,notEmpty"`
-	SpotifyBaseAPIURL       string `env:"SPOTIFY_BASE_API_URL,notEmpty"`
-	SpotifyUserID           string `env:"SPOTIFY_USER_ID,notEmpty"`
-	SpotifyAuthRedirectURL  string `env:"SPOTIFY_AUTH_REDIRECT_URL,notEmpty"`
-	DeezerBaseAPIURL        string `env:"DEEZER_BASE_API_URL,notEmpty"`
-	DeezerAppID             string `env:"DEEZER_APP_ID,notEmpty"`
-	DeezerClientSecret      string `env:"DEEZER_CLIENT_SECRET,notEmpty"`
-	DeezerAuthenticationURL string `env:"DEEZER_AUTHENTICATION_URL,notEmpty"`
-	YTMusicAPIBaseURL       string `env:"YTMUSICAPI_BASE_URL,notEmpty"`
+	Debug       bool   `env:"DEBUG,notEmpty"`
+	Port        int    `env:"PORT,notEmpty"`
+	Address     string `env:"ADDRESS,notEmpty"`
+	SecretKey   string `env:"SECRET_KEY,notEmpty"`
+	DatabaseURL string `env:"DATABASE_URL,notEmpty"`
+	// PreviousSecretKeys are keys SECRET_KEY replaced, data encrypted or signed with them can still be read.
+	PreviousSecretKeys []string `env:"PREVIOUS_SECRET_KEYS" envSeparator:","`
+	// RequestTimeout is the deadline of an incoming request, provider calls still in flight are cancelled when it elapses.
+	RequestTimeout time.Duration `env:"REQUEST_TIMEOUT" envDefault:"2m"`
+	// ConversionTimeout is the deadline of a conversion job, it runs in the background so it outlives its request.
+	ConversionTimeout time.Duration `env:"CONVERSION_TIMEOUT" envDefault:"30m"`
+	// MaximumConcurrentLookups is the number of track searches that may run on each streaming platform at the same time.
+	MaximumConcurrentLookups int `env:"MAXIMUM_CONCURRENT_LOOKUPS" envDefault:"5"`
+	// SessionLifetime is how long a user stays signed in.
+	SessionLifetime time.Duration `env:"SESSION_LIFETIME" envDefault:"720h"`
+	// TokenRefreshInterval is how often the access tokens stored by users are checked, those about to expire are refreshed.
+	TokenRefreshInterval time.Duration `env:"TOKEN_REFRESH_INTERVAL" envDefault:"1m"`
+
+	keyring *utils.Keyring
 }
 
 func New() (*Config, error) {
 	var cfg Config
 
-	if err := env.Parse(&cfg, env.Options{RequiredIfNoDef: true}); err != nil {
+	if err := Load(&cfg); err != nil {
+		return &cfg, err
+	}
+
+	keyring, err := utils.NewKeyring(cfg.SecretKey, cfg.PreviousSecretKeys...)
+	if err != nil {
 		return &cfg, err
 	}
+	cfg.keyring = keyring
 
 	return &cfg, nil
 }
+
+// Keyring returns the keys used to encrypt and sign data, made of SECRET_KEY and PREVIOUS_SECRET_KEYS.
+func (c *Config) Keyring() *utils.Keyring {
+	return c.keyring
+}
+
+// Load populates the struct pointed to by v from environment variables using its `env` struct tags.
+func Load(v any) error {
+	return env.Parse(v, env.Options{RequiredIfNoDef: true})
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -15,9 +18,16 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/gofiber/fiber/v2/utils"
 
+	"github.com/prettyirrelevant/kilishi/api/albums"
 	"github.com/prettyirrelevant/kilishi/api/auth"
+	"github.com/prettyirrelevant/kilishi/api/conversions"
 	"github.com/prettyirrelevant/kilishi/api/database"
+	"github.com/prettyirrelevant/kilishi/api/library"
+	"github.com/prettyirrelevant/kilishi/api/overrides"
+	"github.com/prettyirrelevant/kilishi/api/platforms"
 	"github.com/prettyirrelevant/kilishi/api/playlists"
+	"github.com/prettyirrelevant/kilishi/api/tracks"
+	"github.com/prettyirrelevant/kilishi/api/users"
 	"github.com/prettyirrelevant/kilishi/config"
 	"github.com/prettyirrelevant/kilishi/streaming_platforms/aggregator"
 )
@@ -26,6 +36,12 @@ func main() {
 	cfg := setupConfiguration()
 	db := setupDatabase(cfg)
 
+	// the server is started unless a maintenance command is given e.g. `./kilishi rotate-credentials`.
+	if len(os.Args) > 1 {
+		runCommand(db, os.Args[1])
+		return
+	}
+
 	fiberConfig := fiber.Config{}
 	if !cfg.Debug {
 		fiberConfig.Prefork = true
@@ -36,13 +52,28 @@ func main() {
 	setupMiddlewares(app, cfg)
 
 	apiGroup := app.Group("/api")
-	aggregatorService := aggregator.New(cfg)
-
+	aggregatorService := setupAggregator(cfg)
+	aggregatorService.SetMappingStore(db)
+	// the prefork workers share the rate limits of the platforms, so together they stay under their quotas.
+	aggregatorService.SetRateLimitStore(db)
+
+	// handlers find the signed in user, if any, through `users.CurrentUser`.
+	apiGroup.Use(users.Authenticate(db))
+	users.RouterV1(apiGroup, aggregatorService, db)
 	playlists.RouterV1(apiGroup, aggregatorService, db)
 	auth.RouterV1(apiGroup, aggregatorService, db)
+	conversions.RouterV1(apiGroup, aggregatorService, db)
+	tracks.RouterV1(apiGroup, aggregatorService, db)
+	overrides.RouterV1(apiGroup, aggregatorService, db)
+	albums.RouterV1(apiGroup, aggregatorService, db)
+	library.RouterV1(apiGroup, aggregatorService, db)
+	platforms.RouterV1(apiGroup, aggregatorService, db)
 
 	apiGroup.Get("/v1/ping", HealthCheckController)
 
+	// every process runs the refresher, they take turns through a lock in the database.
+	go users.StartTokenRefresher(context.Background(), aggregatorService, db, cfg.TokenRefreshInterval)
+
 	log.Fatal(app.Listen(fmt.Sprintf("%s:%d", cfg.Address, cfg.Port)))
 }
 
@@ -56,12 +87,20 @@ func setupMiddlewares(app *fiber.App, cfg *config.Config) {
 		Expiration: 1440 * time.Minute,
 		Methods:    []string{fiber.MethodGet},
 		Next: func(c *fiber.Ctx) bool {
-			noCacheEndpoints := map[string]bool{"/api/v1/auth/deezer/callback": true, "/api/v1/auth/spotify/callback": true, "/api/v1/ping": true}
+			noCacheEndpoints := map[string]bool{"/api/v1/ping": true}
 			if _, ok := noCacheEndpoints[c.Path()]; ok && c.Method() == fiber.MethodGet {
 				return true
 			}
 
-			return false
+			// oauth callbacks are registered per platform, conversion jobs change as they run,
+			// overrides can be deleted, track links grow with every match, libraries change with every like
+			// and users are only visible to themselves, none of them should be cached.
+			return strings.HasPrefix(c.Path(), "/api/v1/auth/") ||
+				strings.HasPrefix(c.Path(), "/api/v1/conversions") ||
+				strings.HasPrefix(c.Path(), "/api/v1/library") ||
+				strings.HasPrefix(c.Path(), "/api/v1/overrides") ||
+				strings.HasPrefix(c.Path(), "/api/v1/users") ||
+				(strings.HasPrefix(c.Path(), "/api/v1/tracks/") && strings.HasSuffix(c.Path(), "/links"))
 		},
 		KeyGenerator: func(c *fiber.Ctx) string {
 			return utils.CopyString(c.OriginalURL())
@@ -74,10 +113,41 @@ func setupMiddlewares(app *fiber.App, cfg *config.Config) {
 	}))
 	app.Use(logger.New())
 	app.Use(recover.New())
+	app.Use(func(c *fiber.Ctx) error {
+		// handlers pass `c.UserContext()` down to the streaming platforms and the database,
+		// requests still in flight are cancelled once the deadline elapses.
+		ctx, cancel := context.WithTimeout(c.UserContext(), cfg.RequestTimeout)
+		defer cancel()
+
+		c.SetUserContext(ctx)
+		return c.Next()
+	})
+}
+
+// runCommand runs a maintenance command against the database and exits.
+//   - migrate-credentials encrypts the OAuth credentials stored before they had their own encryption key, it only needs to run once.
+//   - rotate-credentials encrypts the keys of the OAuth credentials with `SECRET_KEY`, it runs after `SECRET_KEY` is rotated.
+func runCommand(db *database.Database, command string) {
+	var count int
+	var err error
+
+	switch command {
+	case "migrate-credentials":
+		count, err = db.MigrateOauthCredentials(context.Background())
+	case "rotate-credentials":
+		count, err = db.RotateOauthCredentialsKeys(context.Background())
+	default:
+		log.Fatalf("unknown command %q, expected migrate-credentials or rotate-credentials", command)
+	}
+
+	log.Printf("%s: %d credentials encrypted again", command, count)
+	if err != nil {
+		log.Fatal(err)
+	}
 }
 
 func setupDatabase(cfg *config.Config) *database.Database {
-	db, err := database.New(cfg.DatabaseURL)
+	db, err := database.New(context.Background(), cfg)
 	if err != nil {
 		panic(err)
 	}
@@ -85,6 +155,15 @@ func setupDatabase(cfg *config.Config) *database.Database {
 	return db
 }
 
+func setupAggregator(cfg *config.Config) *aggregator.MusicStreamingPlatformsAggregator {
+	aggregatorService, err := aggregator.New(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	return aggregatorService
+}
+
 func setupConfiguration() *config.Config {
 	cfg, err := config.New()
 	if err != nil {
@@ -1,40 +1,73 @@
 package aggregator
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
 	"github.com/imroc/req/v3"
 
 	"github.com/prettyirrelevant/kilishi/config"
-	"github.com/prettyirrelevant/kilishi/streaming_platforms/deezer"
-	"github.com/prettyirrelevant/kilishi/streaming_platforms/spotify"
-	"github.com/prettyirrelevant/kilishi/streaming_platforms/ytmusic"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/matching"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+	"github.com/prettyirrelevant/kilishi/utils"
+)
+
+// minimumLinkConfidence is the confidence a match needs to be remembered by the TrackMappingStore.
+const minimumLinkConfidence = 0.8
+
+var (
+	// ErrNoMatch is returned by FindTrack when none of the candidates is a good enough match.
+	ErrNoMatch = registry.NewError(registry.ErrNotFound, "no track found")
+	// ErrNoAlbumMatch is returned by FindAlbum when the album found is not a good enough match.
+	ErrNoAlbumMatch = registry.NewError(registry.ErrNotFound, "no album found")
+	// ErrUnsupportedPlatform is returned for platforms that have not been initialised, e.g. a name that is not registered.
+	ErrUnsupportedPlatform = registry.NewError(registry.ErrInvalidInput, "streaming platform is not supported")
 )
 
-// New creates a new MusicStreamingPlatformsAggregator instance.
-func New(configuration *config.Config) *MusicStreamingPlatformsAggregator {
-	return &MusicStreamingPlatformsAggregator{
-		Config: configuration,
-		YTMusic: ytmusic.New(&ytmusic.InitialisationOpts{
-			RequestClient:       createRequestClient(configuration),
-			BaseAPIURL:          configuration.YTMusicAPIBaseURL,
-			AuthenticationToken: configuration.SecretKey,
-		}),
-		Deezer: deezer.New(&deezer.InitialisationOpts{
-			RequestClient:     createRequestClient(configuration),
-			AppID:             configuration.DeezerAppID,
-			BaseAPIURL:        configuration.DeezerBaseAPIURL,
-			ClientSecret:      configuration.DeezerClientSecret,
-			AuthenticationURL: configuration.DeezerAuthenticationURL,
-		}),
-		Spotify: spotify.New(&spotify.InitialisationOpts{
-			RequestClient:             createRequestClient(configuration),
-			UserID:                    configuration.SpotifyUserID,
-			BaseAPIURL:                configuration.SpotifyBaseAPIURL,
-			ClientID:                  configuration.SpotifyClientID,
-			ClientSecret:              configuration.SpotifyClientSecret,
-			AuthenticationURL:         configuration.SpotifyClientAuthURL,
-			AuthenticationRedirectURL: configuration.SpotifyAuthRedirectURL,
-		}),
+// New creates a new MusicStreamingPlatformsAggregator instance with every registered streaming platform,
+// optional platforms whose credentials are not set are left out.
+func New(configuration *config.Config) (*MusicStreamingPlatformsAggregator, error) {
+	if configuration.MaximumConcurrentLookups < 1 {
+		return nil, fmt.Errorf("aggregator: MAXIMUM_CONCURRENT_LOOKUPS must be at least 1, got %d", configuration.MaximumConcurrentLookups)
+	}
+
+	aggregator := &MusicStreamingPlatformsAggregator{
+		Config:      configuration,
+		platforms:   make(map[MusicStreamingPlatform]MusicStreamingPlatformInterface),
+		lookupSlots: make(map[MusicStreamingPlatform]chan struct{}),
+		rateLimiter: newRateLimiter(),
+	}
+
+	for _, factory := range registry.All() {
+		if err := aggregator.initialise(factory); err != nil {
+			return aggregator, err
+		}
 	}
+
+	return aggregator, nil
+}
+
+// initialise creates the platform of the factory, a platform that is not configured is left out.
+func (m *MusicStreamingPlatformsAggregator) initialise(factory registry.Factory) error {
+	platform, err := factory.New(registry.Options{
+		RequestClient: createRequestClient(m.Config),
+		Config:        m.Config,
+		RateLimiter:   m.rateLimiter,
+	})
+	if errors.Is(err, registry.ErrNotConfigured) {
+		log.Printf("aggregator: %s is disabled as its credentials are not set", factory.Name)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("aggregator: %s initialisation failed due to %s", factory.Name, err.Error())
+	}
+
+	m.platforms[factory.Name] = platform
+	m.lookupSlots[factory.Name] = make(chan struct{}, m.Config.MaximumConcurrentLookups)
+	return nil
 }
 
 func createRequestClient(configuration *config.Config) *req.Client {
@@ -45,25 +78,305 @@ func createRequestClient(configuration *config.Config) *req.Client {
 	return client
 }
 
-// SupportedPlatforms returns a list of supported music streaming platforms.
-func (m *MusicStreamingPlatformsAggregator) SupportedPlatforms() []MusicStreamingPlatform {
-	var platforms []MusicStreamingPlatform
-	for k := range AllMusicStreamingPlatforms {
-		platforms = append(platforms, k)
+// SupportedPlatforms returns a list of supported music streaming platforms, i.e. the registered platforms that were initialised.
+func (m *MusicStreamingPlatformsAggregator) SupportedPlatforms() []registry.Factory {
+	var factories []registry.Factory
+	for _, factory := range registry.All() {
+		if _, ok := m.platforms[factory.Name]; ok {
+			factories = append(factories, factory)
+		}
 	}
-	return platforms
+	return factories
 }
 
 // GetStreamingPlatform retrieves the music streaming platform from the MusicStreamingPlatformsAggregator.
-func (m *MusicStreamingPlatformsAggregator) GetStreamingPlatform(platform MusicStreamingPlatform) MusicStreamingPlatformInterface {
-	switch platform {
-	case Spotify:
-		return m.Spotify
-	case Deezer:
-		return m.Deezer
-	case YTMusic:
-		return m.YTMusic
-	default:
-		return nil
+// It fails with ErrUnsupportedPlatform when the platform has not been initialised.
+func (m *MusicStreamingPlatformsAggregator) GetStreamingPlatform(platform MusicStreamingPlatform) (MusicStreamingPlatformInterface, error) {
+	streamingPlatform, ok := m.platforms[platform]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedPlatform, platform)
+	}
+	return streamingPlatform, nil
+}
+
+// SearchTrack searches for the track on the platform and returns the candidates ranked by how well they match it.
+// At most `MAXIMUM_CONCURRENT_LOOKUPS` searches run on a platform at the same time, the others wait for their turn.
+func (m *MusicStreamingPlatformsAggregator) SearchTrack(ctx context.Context, platform MusicStreamingPlatform, track utils.Track) ([]utils.Track, error) {
+	release, err := m.acquireLookupSlot(ctx, platform)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	streamingPlatform, err := m.GetStreamingPlatform(platform)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := streamingPlatform.SearchTracks(ctx, track)
+	if err != nil {
+		return nil, err
+	}
+
+	return matching.Rank(track, candidates), nil
+}
+
+// GetTrack returns the track a link on the platform points at.
+func (m *MusicStreamingPlatformsAggregator) GetTrack(ctx context.Context, platform MusicStreamingPlatform, trackURL string) (utils.Track, error) {
+	streamingPlatform, err := m.GetStreamingPlatform(platform)
+	if err != nil {
+		return utils.Track{}, err
+	}
+
+	getter, ok := streamingPlatform.(registry.TrackGetter)
+	if !ok {
+		return utils.Track{}, fmt.Errorf("aggregator: %s does not support retrieving tracks", platform)
+	}
+
+	return getter.GetTrack(ctx, trackURL)
+}
+
+// GetTrackByID returns the track with the given ID on the platform.
+func (m *MusicStreamingPlatformsAggregator) GetTrackByID(ctx context.Context, platform MusicStreamingPlatform, trackID string) (utils.Track, error) {
+	streamingPlatform, err := m.GetStreamingPlatform(platform)
+	if err != nil {
+		return utils.Track{}, err
+	}
+
+	getter, ok := streamingPlatform.(registry.TrackGetter)
+	if !ok {
+		return utils.Track{}, fmt.Errorf("aggregator: %s does not support retrieving tracks", platform)
+	}
+
+	return getter.GetTrack(ctx, getter.TrackURL(trackID))
+}
+
+// GetAlbum returns the album a link on the platform points at, along with its tracks.
+func (m *MusicStreamingPlatformsAggregator) GetAlbum(ctx context.Context, platform MusicStreamingPlatform, albumURL string) (utils.Album, error) {
+	streamingPlatform, err := m.GetStreamingPlatform(platform)
+	if err != nil {
+		return utils.Album{}, err
+	}
+
+	provider, ok := streamingPlatform.(registry.AlbumProvider)
+	if !ok {
+		return utils.Album{}, fmt.Errorf("aggregator: %s does not support albums", platform)
+	}
+
+	return provider.GetAlbum(ctx, albumURL)
+}
+
+// SaveAlbum saves an album retrieved from the platform to the library of the owner of the access token.
+func (m *MusicStreamingPlatformsAggregator) SaveAlbum(ctx context.Context, platform MusicStreamingPlatform, album utils.Album, accessToken string) error {
+	streamingPlatform, err := m.GetStreamingPlatform(platform)
+	if err != nil {
+		return err
 	}
+
+	saver, ok := streamingPlatform.(registry.AlbumSaver)
+	if !ok {
+		return fmt.Errorf("aggregator: %s does not support saving albums", platform)
+	}
+
+	return saver.SaveAlbum(ctx, album, accessToken)
+}
+
+// GetAuthorizationURL returns the link users follow to grant access to their account on the platform.
+// The code challenge is only sent to platforms that implement registry.PKCEAuthorizer.
+func (m *MusicStreamingPlatformsAggregator) GetAuthorizationURL(platform MusicStreamingPlatform, state, codeChallenge string) (string, error) {
+	streamingPlatform, err := m.GetStreamingPlatform(platform)
+	if err != nil {
+		return "", err
+	}
+
+	if authorizer, ok := streamingPlatform.(registry.PKCEAuthorizer); ok {
+		return authorizer.GetPKCEAuthorizationURL(state, codeChallenge)
+	}
+
+	authorizer, ok := streamingPlatform.(registry.OauthAuthorizer)
+	if !ok {
+		return "", fmt.Errorf("aggregator: %s does not support oauth", platform)
+	}
+
+	return authorizer.GetAuthorizationURL(state)
+}
+
+// GetAuthorizationCode exchanges the authorization code received by the callback for the user's credentials on the platform.
+// The code verifier is only sent to platforms that implement registry.PKCEAuthorizer.
+func (m *MusicStreamingPlatformsAggregator) GetAuthorizationCode(ctx context.Context, platform MusicStreamingPlatform, code, codeVerifier string) (utils.OauthCredentials, error) {
+	streamingPlatform, err := m.GetStreamingPlatform(platform)
+	if err != nil {
+		return utils.OauthCredentials{}, err
+	}
+
+	if authorizer, ok := streamingPlatform.(registry.PKCEAuthorizer); ok {
+		return authorizer.ExchangeAuthorizationCode(ctx, code, codeVerifier)
+	}
+
+	return streamingPlatform.GetAuthorizationCode(ctx, code)
+}
+
+// GetAccount returns the account on the platform that the access token belongs to.
+func (m *MusicStreamingPlatformsAggregator) GetAccount(ctx context.Context, platform MusicStreamingPlatform, accessToken string) (utils.Account, error) {
+	streamingPlatform, err := m.GetStreamingPlatform(platform)
+	if err != nil {
+		return utils.Account{}, err
+	}
+
+	getter, ok := streamingPlatform.(registry.AccountGetter)
+	if !ok {
+		return utils.Account{}, fmt.Errorf("aggregator: %s does not support retrieving accounts", platform)
+	}
+
+	return getter.GetAccount(ctx, accessToken)
+}
+
+// GetSavedTracks returns the tracks saved to the library of the owner of the access token on the platform.
+func (m *MusicStreamingPlatformsAggregator) GetSavedTracks(ctx context.Context, platform MusicStreamingPlatform, accessToken string) ([]utils.Track, error) {
+	streamingPlatform, err := m.GetStreamingPlatform(platform)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, ok := streamingPlatform.(registry.LibraryProvider)
+	if !ok {
+		return nil, fmt.Errorf("aggregator: %s does not support libraries", platform)
+	}
+
+	return provider.GetSavedTracks(ctx, accessToken)
+}
+
+// SaveTracks saves tracks retrieved from the platform to the library of the owner of the access token.
+func (m *MusicStreamingPlatformsAggregator) SaveTracks(ctx context.Context, platform MusicStreamingPlatform, tracks []utils.Track, accessToken string) error {
+	streamingPlatform, err := m.GetStreamingPlatform(platform)
+	if err != nil {
+		return err
+	}
+
+	provider, ok := streamingPlatform.(registry.LibraryProvider)
+	if !ok {
+		return fmt.Errorf("aggregator: %s does not support libraries", platform)
+	}
+
+	return provider.SaveTracks(ctx, tracks, accessToken)
+}
+
+// FindAlbum searches for the album on the destination and scores how well the result matches it.
+// It shares the concurrency limit of SearchTrack and fails with ErrNoAlbumMatch when the confidence is below minConfidence.
+func (m *MusicStreamingPlatformsAggregator) FindAlbum(ctx context.Context, album utils.Album, destination MusicStreamingPlatform, minConfidence float64) (utils.Album, error) {
+	streamingPlatform, err := m.GetStreamingPlatform(destination)
+	if err != nil {
+		return utils.Album{}, err
+	}
+
+	provider, ok := streamingPlatform.(registry.AlbumProvider)
+	if !ok {
+		return utils.Album{}, fmt.Errorf("aggregator: %s does not support albums", destination)
+	}
+
+	release, err := m.acquireLookupSlot(ctx, destination)
+	if err != nil {
+		return utils.Album{}, err
+	}
+	defer release()
+
+	match, err := provider.LookupAlbum(ctx, album)
+	if err != nil {
+		return utils.Album{}, err
+	}
+
+	match.Confidence = matching.ScoreAlbum(album, match)
+	if match.Confidence < minConfidence {
+		return utils.Album{}, fmt.Errorf("%w with a confidence of at least %.2f", ErrNoAlbumMatch, minConfidence)
+	}
+	return match, nil
+}
+
+// acquireLookupSlot waits until fewer than `MAXIMUM_CONCURRENT_LOOKUPS` searches run on the platform,
+// the returned function must be called once the search is done.
+func (m *MusicStreamingPlatformsAggregator) acquireLookupSlot(ctx context.Context, platform MusicStreamingPlatform) (func(), error) {
+	slots, ok := m.lookupSlots[platform]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedPlatform, platform)
+	}
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// SetMappingStore makes FindTrack reuse the matches recorded in the store and record the new ones.
+func (m *MusicStreamingPlatformsAggregator) SetMappingStore(store TrackMappingStore) {
+	m.mappings = store
+}
+
+// SetRateLimitStore makes the platforms share their rate limits through the store, with every process using it.
+func (m *MusicStreamingPlatformsAggregator) SetRateLimitStore(store RateLimitStore) {
+	m.rateLimiter.setStore(store)
+}
+
+// FindTrack returns the best match on the destination for a track from the source platform, which may be empty when unknown.
+// A match chosen by the user is always used, userID is empty for anonymous lookups, and a match recorded in the mapping store is returned without searching
+// as long as its confidence is high enough. It fails with ErrNoMatch when none of the candidates has a confidence of at least minConfidence.
+// The track may have been described by the caller, so the match is not recorded in the mapping store, see FindRetrievedTrack.
+func (m *MusicStreamingPlatformsAggregator) FindTrack(ctx context.Context, userID string, sourcePlatform MusicStreamingPlatform, track utils.Track, destination MusicStreamingPlatform, minConfidence float64) (utils.Track, error) {
+	return m.findTrack(ctx, userID, sourcePlatform, track, destination, minConfidence, false)
+}
+
+// FindRetrievedTrack is FindTrack for a track retrieved from the source platform, e.g. along with one of its playlists.
+// Its ID, ISRC and metadata come from the platform, so a good enough match is recorded in the mapping store for later lookups to reuse.
+func (m *MusicStreamingPlatformsAggregator) FindRetrievedTrack(ctx context.Context, userID string, sourcePlatform MusicStreamingPlatform, track utils.Track, destination MusicStreamingPlatform, minConfidence float64) (utils.Track, error) {
+	return m.findTrack(ctx, userID, sourcePlatform, track, destination, minConfidence, true)
+}
+
+func (m *MusicStreamingPlatformsAggregator) findTrack(ctx context.Context, userID string, sourcePlatform MusicStreamingPlatform, track utils.Track, destination MusicStreamingPlatform, minConfidence float64, record bool) (utils.Track, error) {
+	if m.mappings != nil && userID != "" && sourcePlatform != "" && track.ID != "" {
+		override, ok, err := m.mappings.GetMatchOverride(ctx, userID, sourcePlatform, track.ID, destination)
+		if err != nil {
+			log.Printf("aggregator: match override lookup failed due to %s", err.Error())
+		} else if ok {
+			return override, nil
+		}
+	}
+
+	if m.mappings != nil {
+		// the store only saves time, the track is searched for when it cannot be reached.
+		link, ok, err := m.mappings.GetTrackLink(ctx, sourcePlatform, track, destination)
+		if err != nil {
+			log.Printf("aggregator: track link lookup failed due to %s", err.Error())
+		} else if ok && link.Confidence >= minConfidence {
+			return link, nil
+		}
+	}
+
+	candidates, err := m.SearchTrack(ctx, destination, track)
+	if err != nil {
+		return utils.Track{}, err
+	}
+	if len(candidates) == 0 || candidates[0].Confidence < minConfidence {
+		return utils.Track{}, fmt.Errorf("%w with a confidence of at least %.2f", ErrNoMatch, minConfidence)
+	}
+
+	match := candidates[0]
+	if record && m.mappings != nil && match.Confidence >= minimumLinkConfidence {
+		if err = m.mappings.SaveTrackLink(ctx, sourcePlatform, track, destination, match); err != nil {
+			log.Printf("aggregator: track link save failed due to %s", err.Error())
+		}
+	}
+
+	return match, nil
+}
+
+// DetectPlatform asks every registered streaming platform whether it recognises the link.
+// It returns the platform the link belongs to and whether it points at a playlist, an album or a track.
+func DetectPlatform(link string) (MusicStreamingPlatform, registry.URLKind, bool) {
+	factory, kind, ok := registry.Match(strings.TrimSpace(link))
+	if !ok {
+		return "", "", false
+	}
+
+	return factory.Name, kind, true
 }
@@ -0,0 +1,88 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prettyirrelevant/kilishi/config"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+	"github.com/prettyirrelevant/kilishi/utils"
+)
+
+func TestUnsupportedPlatform(t *testing.T) {
+	aggregator := &MusicStreamingPlatformsAggregator{
+		platforms:   make(map[MusicStreamingPlatform]MusicStreamingPlatformInterface),
+		lookupSlots: make(map[MusicStreamingPlatform]chan struct{}),
+		rateLimiter: newRateLimiter(),
+	}
+
+	if platform, err := aggregator.GetStreamingPlatform("unknown"); platform != nil || !errors.Is(err, ErrUnsupportedPlatform) {
+		t.Errorf("GetStreamingPlatform() = %v, %v, want %v", platform, err, ErrUnsupportedPlatform)
+	}
+
+	// lookups fail straight away instead of waiting for a slot that never frees up.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err := aggregator.SearchTrack(ctx, "unknown", utils.Track{Title: "Essence"})
+	if !errors.Is(err, ErrUnsupportedPlatform) || !errors.Is(err, registry.ErrInvalidInput) {
+		t.Errorf("SearchTrack() error = %v, want %v", err, ErrUnsupportedPlatform)
+	}
+	if _, err = aggregator.FindAlbum(ctx, utils.Album{Title: "Made In Lagos"}, "unknown", 0); !errors.Is(err, ErrUnsupportedPlatform) {
+		t.Errorf("FindAlbum() error = %v, want %v", err, ErrUnsupportedPlatform)
+	}
+	if _, err = aggregator.FindTrack(ctx, "", "spotify", utils.Track{ID: "1", Title: "Essence"}, "unknown", 0); !errors.Is(err, ErrUnsupportedPlatform) {
+		t.Errorf("FindTrack() error = %v, want %v", err, ErrUnsupportedPlatform)
+	}
+	if ctx.Err() != nil {
+		t.Error("the lookups blocked until the deadline")
+	}
+}
+
+func TestInitialiseLeavesOutPlatformsThatAreNotConfigured(t *testing.T) {
+	aggregator := &MusicStreamingPlatformsAggregator{
+		Config:      &config.Config{MaximumConcurrentLookups: 1},
+		platforms:   make(map[MusicStreamingPlatform]MusicStreamingPlatformInterface),
+		lookupSlots: make(map[MusicStreamingPlatform]chan struct{}),
+		rateLimiter: newRateLimiter(),
+	}
+
+	for name, err := range map[MusicStreamingPlatform]error{"configured": nil, "unconfigured": registry.ErrNotConfigured} {
+		err := err
+		factory := registry.Factory{
+			Name: name,
+			New: func(registry.Options) (registry.MusicStreamingPlatformInterface, error) {
+				return nil, err
+			},
+		}
+		registry.Register(factory)
+
+		if _err := aggregator.initialise(factory); _err != nil {
+			t.Fatalf("initialise(%s) error = %v", name, _err)
+		}
+	}
+
+	// a platform that fails for any other reason stops the startup.
+	failing := registry.Factory{
+		Name: "failing",
+		New: func(registry.Options) (registry.MusicStreamingPlatformInterface, error) {
+			return nil, errors.New("APP_SECRET must be set as well")
+		},
+	}
+	if err := aggregator.initialise(failing); err == nil {
+		t.Error("initialise(failing) error = nil, want an error")
+	}
+
+	var names []MusicStreamingPlatform
+	for _, factory := range aggregator.SupportedPlatforms() {
+		names = append(names, factory.Name)
+	}
+	if len(names) != 1 || names[0] != "configured" {
+		t.Errorf("SupportedPlatforms() = %v, want only the configured platform", names)
+	}
+	if _, err := aggregator.GetStreamingPlatform("unconfigured"); !errors.Is(err, ErrUnsupportedPlatform) {
+		t.Errorf("GetStreamingPlatform() error = %v, want %v", err, ErrUnsupportedPlatform)
+	}
+}
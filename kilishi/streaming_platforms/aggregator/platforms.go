@@ -0,0 +1,13 @@
+package aggregator
+
+// Every supported streaming platform registers itself with the registry when its package is imported.
+import (
+	_ "github.com/prettyirrelevant/kilishi/streaming_platforms/applemusic"
+	_ "github.com/prettyirrelevant/kilishi/streaming_platforms/audiomack"
+	_ "github.com/prettyirrelevant/kilishi/streaming_platforms/boomplay"
+	_ "github.com/prettyirrelevant/kilishi/streaming_platforms/deezer"
+	_ "github.com/prettyirrelevant/kilishi/streaming_platforms/soundcloud"
+	_ "github.com/prettyirrelevant/kilishi/streaming_platforms/spotify"
+	_ "github.com/prettyirrelevant/kilishi/streaming_platforms/tidal"
+	_ "github.com/prettyirrelevant/kilishi/streaming_platforms/ytmusic"
+)
@@ -0,0 +1,223 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+)
+
+// requestSlotPollInterval is how often a request waiting for a slot shared through the RateLimitStore checks whether one was freed.
+const requestSlotPollInterval = 50 * time.Millisecond
+
+// maximumReservationWait is the longest a request waits for a token, the requests that would wait longer are
+// refused as rate limited instead of taking the platform's tokens further into debt.
+const maximumReservationWait = 30 * time.Second
+
+// rateLimiter paces the requests sent to every platform, within this process until a RateLimitStore is set.
+// The store only coordinates the processes, the limits of this process are used instead whenever it cannot be reached.
+type rateLimiter struct {
+	mu    sync.Mutex
+	store RateLimitStore
+	// buckets hold the tokens left for each platform, a request takes one and waits when there are none.
+	buckets map[MusicStreamingPlatform]*tokenBucket
+	// slots bounds the number of requests in flight to each platform.
+	slots map[MusicStreamingPlatform]chan struct{}
+}
+
+type tokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		buckets: make(map[MusicStreamingPlatform]*tokenBucket),
+		slots:   make(map[MusicStreamingPlatform]chan struct{}),
+	}
+}
+
+func (l *rateLimiter) setStore(store RateLimitStore) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.store = store
+}
+
+func (l *rateLimiter) currentStore() RateLimitStore {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.store
+}
+
+// Acquire waits for a free slot and then for a token of the platform, see registry.RateLimiter.
+func (l *rateLimiter) Acquire(ctx context.Context, platform MusicStreamingPlatform, limit registry.RateLimit) (func(), error) {
+	release := func() {}
+	if limit.MaxConcurrentRequests > 0 {
+		var err error
+		release, err = l.acquireSlot(ctx, platform, limit.MaxConcurrentRequests)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if limit.RequestsPerSecond > 0 {
+		burst := limit.Burst
+		if burst < 1 {
+			burst = 1
+		}
+
+		wait, cancel, err := l.reserveToken(ctx, platform, limit.RequestsPerSecond, burst)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		if err = sleep(ctx, wait); err != nil {
+			// the request is never sent, so its token is given back to the requests still waiting.
+			cancel()
+			release()
+			return nil, err
+		}
+	}
+
+	return release, nil
+}
+
+// reserveToken takes a token from the bucket of the platform and returns how long to wait until it is due,
+// along with a function that gives the token back when the request is not sent after all.
+// The reservation is refused with a registry.RateLimitError when the wait would outlast the context or maximumReservationWait.
+func (l *rateLimiter) reserveToken(ctx context.Context, platform MusicStreamingPlatform, requestsPerSecond float64, burst int) (time.Duration, func(), error) {
+	maxWait := maximumReservationWait
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < maxWait {
+		maxWait = time.Until(deadline)
+	}
+
+	if store := l.currentStore(); store != nil {
+		wait, reserved, err := store.ReserveRequest(ctx, platform, requestsPerSecond, burst, maxWait)
+		if err == nil {
+			if !reserved {
+				return 0, nil, reservationRefused(platform, wait)
+			}
+
+			return wait, func() {
+				// the token is given back even when the caller's context has been cancelled.
+				cancelCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+
+				if _err := store.CancelReservation(cancelCtx, platform, burst); _err != nil {
+					log.Printf("aggregator: shared rate limit of %s is unavailable due to %s", platform, _err.Error())
+				}
+			}, nil
+		}
+		// the request is cancelled while it waits when the context is done, so only failures of the store are worth logging.
+		if ctx.Err() == nil {
+			log.Printf("aggregator: shared rate limit of %s is unavailable due to %s", platform, err.Error())
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	bucket, ok := l.buckets[platform]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(burst), updatedAt: now}
+		l.buckets[platform] = bucket
+	}
+
+	bucket.tokens += now.Sub(bucket.updatedAt).Seconds() * requestsPerSecond
+	if bucket.tokens > float64(burst) {
+		bucket.tokens = float64(burst)
+	}
+	bucket.updatedAt = now
+
+	// the token is taken even when the bucket is empty, so the requests waiting for one are sent in the order they came.
+	var wait time.Duration
+	if tokens := bucket.tokens - 1; tokens < 0 {
+		wait = time.Duration(-tokens / requestsPerSecond * float64(time.Second))
+	}
+	if wait > maxWait {
+		return 0, nil, reservationRefused(platform, wait)
+	}
+
+	bucket.tokens--
+	return wait, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if bucket.tokens++; bucket.tokens > float64(burst) {
+			bucket.tokens = float64(burst)
+		}
+	}, nil
+}
+
+// reservationRefused returns the error of a request that would have waited too long for a token of the platform.
+func reservationRefused(platform MusicStreamingPlatform, wait time.Duration) error {
+	retryAfter := time.Duration(math.Ceil(wait.Seconds())) * time.Second
+	return fmt.Errorf("aggregator: %s requests are queued for longer than the request can wait: %w", platform, &registry.RateLimitError{RetryAfter: retryAfter})
+}
+
+// acquireSlot waits until fewer than maxConcurrentRequests requests are in flight to the platform,
+// the returned function must be called once the request is done.
+func (l *rateLimiter) acquireSlot(ctx context.Context, platform MusicStreamingPlatform, maxConcurrentRequests int) (func(), error) {
+	if store := l.currentStore(); store != nil {
+		release, err := acquireSharedSlot(ctx, store, platform, maxConcurrentRequests)
+		if err == nil || ctx.Err() != nil {
+			return release, err
+		}
+		log.Printf("aggregator: shared request slots of %s are unavailable due to %s", platform, err.Error())
+	}
+
+	l.mu.Lock()
+	slots, ok := l.slots[platform]
+	if !ok {
+		slots = make(chan struct{}, maxConcurrentRequests)
+		l.slots[platform] = slots
+	}
+	l.mu.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func acquireSharedSlot(ctx context.Context, store RateLimitStore, platform MusicStreamingPlatform, maxConcurrentRequests int) (func(), error) {
+	for {
+		release, acquired, err := store.AcquireRequestSlot(ctx, platform, maxConcurrentRequests)
+		if err != nil {
+			return nil, err
+		}
+		if acquired {
+			return release, nil
+		}
+
+		if err = sleep(ctx, requestSlotPollInterval); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// sleep pauses for the duration unless the context is done first.
+func sleep(ctx context.Context, duration time.Duration) error {
+	if duration <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,180 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+)
+
+const testPlatform MusicStreamingPlatform = "test"
+
+// assertWait checks a wait computed from the clock, which moves a little between reservations.
+func assertWait(t *testing.T, got, want time.Duration) {
+	t.Helper()
+
+	if got > want || got < want-100*time.Millisecond {
+		t.Errorf("wait = %s, want %s", got, want)
+	}
+}
+
+func TestReserveTokenCapsTheDebt(t *testing.T) {
+	limiter := newRateLimiter()
+	ctx, cancel := context.WithTimeout(context.Background(), 2500*time.Millisecond)
+	defer cancel()
+
+	// the burst is used up straight away, the next requests are queued a second apart.
+	for i, want := range []time.Duration{0, time.Second, 2 * time.Second} {
+		wait, _, err := limiter.reserveToken(ctx, testPlatform, 1, 1)
+		if err != nil {
+			t.Fatalf("reservation %d error = %v", i, err)
+		}
+		assertWait(t, wait, want)
+	}
+
+	// the next one would outlast the context, so it is refused without taking a token.
+	for i := 0; i < 3; i++ {
+		_, _, err := limiter.reserveToken(ctx, testPlatform, 1, 1)
+
+		var rateLimitErr *registry.RateLimitError
+		if !errors.As(err, &rateLimitErr) || rateLimitErr.RetryAfter != 3*time.Second {
+			t.Fatalf("reservation past the deadline error = %v, want a RateLimitError to retry after 3s", err)
+		}
+	}
+	if tokens := limiter.buckets[testPlatform].tokens; tokens < -2.1 || tokens > -1.9 {
+		t.Errorf("tokens = %v, refused reservations must not take any", tokens)
+	}
+
+	// requests without a deadline are bounded by maximumReservationWait.
+	limiter = newRateLimiter()
+	reserved := 0
+	for ; reserved < 100; reserved++ {
+		if _, _, err := limiter.reserveToken(context.Background(), testPlatform, 1, 1); err != nil {
+			if !errors.Is(err, registry.ErrRateLimited) {
+				t.Fatalf("reservation error = %v, want %v", err, registry.ErrRateLimited)
+			}
+			break
+		}
+	}
+	if want := int(maximumReservationWait/time.Second) + 1; reserved != want {
+		t.Errorf("%d reservations were taken, want %d", reserved, want)
+	}
+}
+
+func TestReserveTokenCancel(t *testing.T) {
+	limiter := newRateLimiter()
+
+	_, _, err := limiter.reserveToken(context.Background(), testPlatform, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, cancel, err := limiter.reserveToken(context.Background(), testPlatform, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the token of the cancelled reservation goes to the next request.
+	cancel()
+	wait, _, err := limiter.reserveToken(context.Background(), testPlatform, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertWait(t, wait, time.Second)
+
+	// a refund never fills the bucket past its burst.
+	limiter = newRateLimiter()
+	_, cancel, _ = limiter.reserveToken(context.Background(), testPlatform, 1, 1)
+	cancel()
+	cancel()
+	if tokens := limiter.buckets[testPlatform].tokens; tokens != 1 {
+		t.Errorf("tokens = %v, want the burst of 1", tokens)
+	}
+}
+
+func TestAcquireGivesBackTheTokenWhenCancelled(t *testing.T) {
+	limiter := newRateLimiter()
+	limit := registry.RateLimit{RequestsPerSecond: 1, Burst: 1, MaxConcurrentRequests: 1}
+
+	release, err := limiter.Acquire(context.Background(), testPlatform, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+
+	// the second request waits a second for its token but is cancelled before.
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err = limiter.Acquire(ctx, testPlatform, limit); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Acquire() error = %v, want %v", err, context.Canceled)
+	}
+
+	wait, _, err := limiter.reserveToken(context.Background(), testPlatform, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertWait(t, wait, 950*time.Millisecond)
+
+	// the slot of the cancelled request was freed.
+	if slots := len(limiter.slots[testPlatform]); slots != 0 {
+		t.Errorf("%d slots are taken, want none", slots)
+	}
+}
+
+type fakeRateLimitStore struct {
+	wait      time.Duration
+	maxWaits  []time.Duration
+	cancelled int
+}
+
+func (s *fakeRateLimitStore) ReserveRequest(_ context.Context, _ MusicStreamingPlatform, _ float64, _ int, maxWait time.Duration) (time.Duration, bool, error) {
+	s.maxWaits = append(s.maxWaits, maxWait)
+	return s.wait, s.wait <= maxWait, nil
+}
+
+func (s *fakeRateLimitStore) CancelReservation(_ context.Context, _ MusicStreamingPlatform, _ int) error {
+	s.cancelled++
+	return nil
+}
+
+func (s *fakeRateLimitStore) AcquireRequestSlot(_ context.Context, _ MusicStreamingPlatform, _ int) (func(), bool, error) {
+	return func() {}, true, nil
+}
+
+func TestReserveTokenWithStore(t *testing.T) {
+	store := &fakeRateLimitStore{wait: 40 * time.Second}
+	limiter := newRateLimiter()
+	limiter.setStore(store)
+
+	// the store refuses reservations past maximumReservationWait.
+	_, _, err := limiter.reserveToken(context.Background(), testPlatform, 1, 1)
+	var rateLimitErr *registry.RateLimitError
+	if !errors.As(err, &rateLimitErr) || rateLimitErr.RetryAfter != 40*time.Second {
+		t.Fatalf("reserveToken() error = %v, want a RateLimitError to retry after 40s", err)
+	}
+	if store.maxWaits[0] != maximumReservationWait {
+		t.Errorf("maxWait = %s, want %s", store.maxWaits[0], maximumReservationWait)
+	}
+
+	// the deadline of the context shortens the longest wait, and cancelled requests give their token back.
+	store.wait = 200 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err = limiter.Acquire(ctx, testPlatform, registry.RateLimit{RequestsPerSecond: 1, Burst: 1}); !errors.Is(err, registry.ErrRateLimited) {
+		t.Errorf("Acquire() error = %v, want %v", err, registry.ErrRateLimited)
+	}
+	if maxWait := store.maxWaits[1]; maxWait > 100*time.Millisecond {
+		t.Errorf("maxWait = %s, want the time left before the deadline", maxWait)
+	}
+
+	store.wait = time.Second
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err = limiter.Acquire(ctx, testPlatform, registry.RateLimit{RequestsPerSecond: 1, Burst: 1}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Acquire() error = %v, want %v", err, context.Canceled)
+	}
+	if store.cancelled != 1 {
+		t.Errorf("%d reservations were cancelled, want 1", store.cancelled)
+	}
+}
@@ -1,48 +1,49 @@
 package aggregator
 
 import (
+	"context"
+	"time"
+
 	"github.com/prettyirrelevant/kilishi/config"
-	"github.com/prettyirrelevant/kilishi/streaming_platforms/deezer"
-	"github.com/prettyirrelevant/kilishi/streaming_platforms/spotify"
-	"github.com/prettyirrelevant/kilishi/streaming_platforms/ytmusic"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
 	"github.com/prettyirrelevant/kilishi/utils"
 )
 
-const (
-	Spotify MusicStreamingPlatform = "spotify"
-	Deezer  MusicStreamingPlatform = "deezer"
-	YTMusic MusicStreamingPlatform = "ytmusic"
+type (
+	MusicStreamingPlatform          = registry.MusicStreamingPlatform
+	MusicStreamingPlatformInterface = registry.MusicStreamingPlatformInterface
 )
 
-var AllMusicStreamingPlatforms = map[MusicStreamingPlatform]bool{Spotify: true, Deezer: true, YTMusic: true}
-
 // MusicStreamingPlatformsAggregator is a struct that represents an aggregator of different music streaming platforms.
 type MusicStreamingPlatformsAggregator struct {
-	Config  *config.Config
-	Spotify *spotify.Spotify
-	Deezer  *deezer.Deezer
-	YTMusic *ytmusic.YTMusic
+	Config    *config.Config
+	platforms map[MusicStreamingPlatform]MusicStreamingPlatformInterface
+	// lookupSlots bounds the number of track searches running on each platform at the same time.
+	lookupSlots map[MusicStreamingPlatform]chan struct{}
+	mappings    TrackMappingStore
+	rateLimiter *rateLimiter
 }
 
-type MusicStreamingPlatformInterface interface {
-	// CreatePlaylist creates a new playlist on the platform.
-	// It takes a utils.Playlist object and an access token string
-	// and returns the URL of the newly created playlist and an error, if any.
-	CreatePlaylist(playlist utils.Playlist, accessToken string) (string, error)
-
-	// GetPlaylist returns a utils.Playlist object for a given playlist URL.
-	// It takes a playlist URL string and returns the corresponding playlist object and an error, if any.
-	GetPlaylist(playlistURL string) (utils.Playlist, error)
-
-	// LookupTrack searches for a track on the streaming platform.
-	LookupTrack(track utils.Track) (utils.Track, error)
-
-	// GetAuthorizationCode returns an oauth credentials object for the given authorization code.
-	// It takes an authorization code string and returns an oauth credentials object and an error, if any.
-	GetAuthorizationCode(code string) (utils.OauthCredentials, error)
-
-	// RequiresAccessToken returns a boolean indicating whether the platform requires an access token for API calls.
-	RequiresAccessToken() bool
+// RateLimitStore shares the rate limits of the platforms between the processes serving the API, e.g. the prefork workers.
+type RateLimitStore interface {
+	// ReserveRequest takes a token from the bucket of the platform and returns how long to wait before sending the request.
+	// It returns false without taking a token when the wait would exceed maxWait.
+	ReserveRequest(ctx context.Context, platform MusicStreamingPlatform, requestsPerSecond float64, burst int, maxWait time.Duration) (time.Duration, bool, error)
+	// CancelReservation gives back the token of a reservation whose request was never sent.
+	CancelReservation(ctx context.Context, platform MusicStreamingPlatform, burst int) error
+	// AcquireRequestSlot takes one of the slots of requests in flight to the platform, it returns false when they are all taken.
+	// The slot is freed by calling the returned function.
+	AcquireRequestSlot(ctx context.Context, platform MusicStreamingPlatform, maxConcurrentRequests int) (func(), bool, error)
 }
 
-type MusicStreamingPlatform string
+// TrackMappingStore remembers which track on another platform a track was matched to, so it is not searched for again.
+// It also holds the matches users have chosen themselves, which take precedence over everything else in their own lookups.
+type TrackMappingStore interface {
+	// GetMatchOverride returns the track the user has chosen as the match of the source track on the destination.
+	GetMatchOverride(ctx context.Context, userID string, sourcePlatform MusicStreamingPlatform, sourceID string, destination MusicStreamingPlatform) (utils.Track, bool, error)
+	// GetTrackLink returns the track the source track was matched to on the destination, the source platform may be empty.
+	GetTrackLink(ctx context.Context, sourcePlatform MusicStreamingPlatform, source utils.Track, destination MusicStreamingPlatform) (utils.Track, bool, error)
+	// SaveTrackLink records that the source track was matched to a track on the destination.
+	// The links are shared by every caller, so the source track must have been retrieved from its platform rather than described by a caller.
+	SaveTrackLink(ctx context.Context, sourcePlatform MusicStreamingPlatform, source utils.Track, destination MusicStreamingPlatform, match utils.Track) error
+}
@@ -0,0 +1,359 @@
+package applemusic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/matching"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+	"github.com/prettyirrelevant/kilishi/utils"
+)
+
+const (
+	baseLibraryPlaylistURL = "https://music.apple.com/library/playlist/"
+	developerTokenLifetime = 12 * time.Hour
+	// artworkSize is the width and height in pixels requested for artworks.
+	artworkSize = "600"
+)
+
+// New initializes an `AppleMusic` object.
+func New(opts *InitialisationOpts) *AppleMusic {
+	return &AppleMusic{
+		RequestClient: setupRequestClient(opts.RequestClient.SetTimeout(opts.RequestTimeout), opts.BaseAPIURL),
+		Config: Config{
+			BaseAPIURL:     opts.BaseAPIURL,
+			TeamID:         opts.TeamID,
+			KeyID:          opts.KeyID,
+			PrivateKey:     opts.PrivateKey,
+			Storefront:     opts.Storefront,
+			RequestTimeout: opts.RequestTimeout,
+		},
+	}
+}
+
+// GetPlaylist returns information about a catalog playlist.
+func (a *AppleMusic) GetPlaylist(ctx context.Context, playlistURL string) (utils.Playlist, error) {
+	storefront, playlistID, err := parsePlaylistURL(playlistURL)
+	if err != nil {
+		return utils.Playlist{}, err
+	}
+
+	developerToken, err := a.getDeveloperToken()
+	if err != nil {
+		return utils.Playlist{}, err
+	}
+
+	var response appleMusicAPIGetPlaylistResponse
+	err = a.RequestClient.
+		Get("/v1/catalog/"+storefront+"/playlists/"+playlistID).
+		SetBearerAuthToken(developerToken).
+		SetQueryParam("include", "tracks").
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return utils.Playlist{}, err
+	}
+	if len(response.Data) == 0 {
+		return utils.Playlist{}, fmt.Errorf("applemusic: no playlist found with id %s", playlistID)
+	}
+
+	playlist := parseGetPlaylistResponse(&response)
+	next := response.Data[0].Relationships.Tracks.Next
+
+	// apple music returns at most 100 tracks per request, this loop follows the `next` links for the rest.
+	for next != "" {
+		var tracksResp appleMusicAPITracksResponse
+		err := a.RequestClient.
+			Get(next).
+			SetBearerAuthToken(developerToken).
+			Do(ctx).
+			Into(&tracksResp)
+
+		if err != nil {
+			// the request was cancelled, do not return a partial playlist.
+			if ctx.Err() != nil {
+				return utils.Playlist{}, ctx.Err()
+			}
+			break
+		}
+
+		playlist.Tracks = append(playlist.Tracks, parseSongsResponse(tracksResp.Data)...)
+		next = tracksResp.Next
+	}
+
+	playlist.Finalise(string(Platform))
+	return playlist, nil
+}
+
+// CreatePlaylist creates a playlist in the library of the user the Music-User-Token belongs to.
+func (a *AppleMusic) CreatePlaylist(ctx context.Context, playlist utils.Playlist, accessToken string) (string, error) {
+	developerToken, err := a.getDeveloperToken()
+	if err != nil {
+		return "", err
+	}
+
+	var trackIDs []string
+	var tracksPayload []map[string]string
+	for _, entry := range playlist.Tracks {
+		if ok := utils.Contains(trackIDs, entry.ID); !ok {
+			trackIDs = append(trackIDs, entry.ID)
+			tracksPayload = append(tracksPayload, map[string]string{"id": entry.ID, "type": "songs"})
+		}
+	}
+
+	var response appleMusicAPICreatePlaylistResponse
+	err = a.RequestClient.
+		Post("/v1/me/library/playlists").
+		SetBearerAuthToken(developerToken).
+		SetHeader("Music-User-Token", accessToken).
+		SetBodyJsonMarshal(map[string]any{
+			"attributes": map[string]string{
+				"name":        playlist.Title,
+				"description": playlist.Description,
+			},
+			"relationships": map[string]any{
+				"tracks": map[string]any{"data": tracksPayload},
+			},
+		}).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return "", err
+	}
+	if len(response.Data) == 0 {
+		return "", errors.New("applemusic: playlist creation returned no playlist")
+	}
+
+	return baseLibraryPlaylistURL + response.Data[0].ID, nil
+}
+
+// LookupTrack searches for a track on Apple Music and returns the top result.
+func (a *AppleMusic) LookupTrack(ctx context.Context, track utils.Track) (utils.Track, error) {
+	tracks, err := a.SearchTracks(ctx, track)
+	if err != nil {
+		return utils.Track{}, err
+	}
+
+	return tracks[0], nil
+}
+
+// SearchTracks returns the tracks in the Apple Music catalog of the configured storefront that match the track, best first.
+// An exact ISRC lookup is tried first, the catalog search is used when it finds nothing.
+func (a *AppleMusic) SearchTracks(ctx context.Context, track utils.Track) ([]utils.Track, error) {
+	developerToken, err := a.getDeveloperToken()
+	if err != nil {
+		return nil, fmt.Errorf("applemusic: %w", err)
+	}
+
+	if isrc := matching.NormaliseISRC(track.ISRC); isrc != "" {
+		var songsResp appleMusicAPITracksResponse
+		_err := a.RequestClient.
+			Get("/v1/catalog/"+a.Config.Storefront+"/songs").
+			SetBearerAuthToken(developerToken).
+			SetQueryParam("filter[isrc]", isrc).
+			Do(ctx).
+			Into(&songsResp)
+
+		if _err != nil {
+			return nil, fmt.Errorf("applemusic: %w", _err)
+		}
+		if len(songsResp.Data) > 0 {
+			return utils.SetMatchStrategy(parseSongsResponse(songsResp.Data), utils.ISRCMatch), nil
+		}
+	}
+
+	var response appleMusicAPISearchResponse
+	err = a.RequestClient.
+		Get("/v1/catalog/" + a.Config.Storefront + "/search").
+		SetBearerAuthToken(developerToken).
+		SetQueryParams(map[string]string{
+			"term":  trackToSearchQuery(track),
+			"types": "songs",
+			"limit": "5",
+		}).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return nil, fmt.Errorf("applemusic: %w", err)
+	}
+	if len(response.Results.Songs.Data) == 0 {
+		return nil, registry.NewError(registry.ErrNotFound, "applemusic: no track found that matches %s", track.Title)
+	}
+
+	return utils.SetMatchStrategy(parseSongsResponse(response.Results.Songs.Data), utils.TextSearchMatch), nil
+}
+
+// TrackURL returns the link of a song in the catalog of the configured storefront.
+func (a *AppleMusic) TrackURL(trackID string) string {
+	return "https://music.apple.com/" + a.Config.Storefront + "/song/" + trackID
+}
+
+// GetTrack returns information about a catalog song.
+func (a *AppleMusic) GetTrack(ctx context.Context, trackURL string) (utils.Track, error) {
+	storefront, trackID, err := parseTrackURL(trackURL)
+	if err != nil {
+		return utils.Track{}, err
+	}
+
+	developerToken, err := a.getDeveloperToken()
+	if err != nil {
+		return utils.Track{}, err
+	}
+
+	var response appleMusicAPITracksResponse
+	err = a.RequestClient.
+		Get("/v1/catalog/" + storefront + "/songs/" + trackID).
+		SetBearerAuthToken(developerToken).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return utils.Track{}, err
+	}
+	if len(response.Data) == 0 {
+		return utils.Track{}, registry.NewError(registry.ErrNotFound, "applemusic: no track found with id %s", trackID)
+	}
+
+	return parseSongsResponse(response.Data)[0], nil
+}
+
+// GetAlbum returns information about a catalog album along with its tracks.
+func (a *AppleMusic) GetAlbum(ctx context.Context, albumURL string) (utils.Album, error) {
+	storefront, albumID, err := parseAlbumURL(albumURL)
+	if err != nil {
+		return utils.Album{}, err
+	}
+
+	developerToken, err := a.getDeveloperToken()
+	if err != nil {
+		return utils.Album{}, err
+	}
+
+	var response appleMusicAPIAlbumsResponse
+	err = a.RequestClient.
+		Get("/v1/catalog/"+storefront+"/albums/"+albumID).
+		SetBearerAuthToken(developerToken).
+		SetQueryParam("include", "tracks").
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return utils.Album{}, err
+	}
+	if len(response.Data) == 0 {
+		return utils.Album{}, registry.NewError(registry.ErrNotFound, "applemusic: no album found with id %s", albumID)
+	}
+
+	album := parseAlbum(response.Data[0])
+	album.Tracks = parseSongsResponse(response.Data[0].Relationships.Tracks.Data)
+
+	// an album is returned whole or not at all, unlike playlists a missing page is an error.
+	for next := response.Data[0].Relationships.Tracks.Next; next != ""; {
+		var tracksResp appleMusicAPITracksResponse
+		err = a.RequestClient.
+			Get(next).
+			SetBearerAuthToken(developerToken).
+			Do(ctx).
+			Into(&tracksResp)
+
+		if err != nil {
+			return utils.Album{}, err
+		}
+
+		album.Tracks = append(album.Tracks, parseSongsResponse(tracksResp.Data)...)
+		next = tracksResp.Next
+	}
+
+	album.Finalise(string(Platform))
+	return album, nil
+}
+
+// LookupAlbum searches for an album in the catalog of the configured storefront and returns the top result.
+// An exact UPC lookup is tried first, the catalog search is used when it finds nothing.
+func (a *AppleMusic) LookupAlbum(ctx context.Context, album utils.Album) (utils.Album, error) {
+	developerToken, err := a.getDeveloperToken()
+	if err != nil {
+		return utils.Album{}, fmt.Errorf("applemusic: %w", err)
+	}
+
+	if upc := matching.NormaliseUPC(album.UPC); upc != "" {
+		var albumsResp appleMusicAPIAlbumsResponse
+		_err := a.RequestClient.
+			Get("/v1/catalog/"+a.Config.Storefront+"/albums").
+			SetBearerAuthToken(developerToken).
+			SetQueryParam("filter[upc]", upc).
+			Do(ctx).
+			Into(&albumsResp)
+
+		if _err == nil && len(albumsResp.Data) > 0 {
+			match := parseAlbum(albumsResp.Data[0])
+			match.Platform = string(Platform)
+			match.MatchStrategy = utils.UPCMatch
+			return match, nil
+		}
+	}
+
+	var response appleMusicAPISearchResponse
+	err = a.RequestClient.
+		Get("/v1/catalog/" + a.Config.Storefront + "/search").
+		SetBearerAuthToken(developerToken).
+		SetQueryParams(map[string]string{
+			"term":  albumToSearchQuery(album),
+			"types": "albums",
+			"limit": "1",
+		}).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return utils.Album{}, fmt.Errorf("applemusic: %w", err)
+	}
+	if len(response.Results.Albums.Data) == 0 {
+		return utils.Album{}, registry.NewError(registry.ErrNotFound, "applemusic: no album found that matches %s", album.Title)
+	}
+
+	match := parseAlbum(response.Results.Albums.Data[0])
+	match.Platform = string(Platform)
+	match.MatchStrategy = utils.TextSearchMatch
+	return match, nil
+}
+
+// GetAuthorizationCode wraps the Music-User-Token obtained from MusicKit in an oauth credentials object.
+// Apple Music has no authorization code exchange, the user token is issued to the client directly.
+func (*AppleMusic) GetAuthorizationCode(_ context.Context, code string) (utils.OauthCredentials, error) {
+	if code == "" {
+		return utils.OauthCredentials{}, errors.New("applemusic: music user token is required")
+	}
+
+	return utils.OauthCredentials{AccessToken: code}, nil
+}
+
+// RequiresAccessToken specifies if the streaming requires Oauth.
+func (*AppleMusic) RequiresAccessToken() bool {
+	return true
+}
+
+// getDeveloperToken returns a cached developer token or signs a new one.
+func (a *AppleMusic) getDeveloperToken() (string, error) {
+	if token, ok := utils.GlobalCache.Get("appleMusicDeveloperToken"); ok {
+		if val, ok := token.(string); ok {
+			return val, nil
+		}
+
+		return "", errors.New("developer token corrupted in cache")
+	}
+
+	token, err := generateDeveloperToken(a.Config.TeamID, a.Config.KeyID, a.Config.PrivateKey, developerTokenLifetime)
+	if err != nil {
+		return "", err
+	}
+
+	// refresh a little before apple considers the token expired.
+	utils.GlobalCache.Set("appleMusicDeveloperToken", token, developerTokenLifetime-time.Minute)
+	return token, nil
+}
@@ -0,0 +1,417 @@
+package applemusic
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imroc/req/v3"
+
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+	"github.com/prettyirrelevant/kilishi/utils"
+)
+
+// generatePrivateKey returns a PKCS#8 key shaped like the `.p8` file from the Apple developer portal.
+func generatePrivateKey(t *testing.T) string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+// recordedAppleMusic returns a client of a server that answers with the responses recorded from the Apple Music API in testdata,
+// keyed by the request path, or by the path and decoded query when the path is answered differently per query.
+// Unknown resources are answered the way Apple Music does, with a 404, and every request is recorded.
+func recordedAppleMusic(t *testing.T, responses map[string]string) (*AppleMusic, *[]string) {
+	t.Helper()
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, _ := url.QueryUnescape(r.URL.Query().Encode())
+		requests = append(requests, r.URL.Path+"?"+query)
+
+		name, status := responses[r.URL.Path+"?"+query], http.StatusOK
+		if name == "" {
+			name = responses[r.URL.Path]
+		}
+		if name == "" {
+			name, status = "resource_not_found.json", http.StatusNotFound
+		}
+
+		body, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+
+	return New(&InitialisationOpts{
+		RequestClient:  req.C(),
+		BaseAPIURL:     server.URL,
+		TeamID:         "TEAM123456",
+		KeyID:          "KEY1234567",
+		PrivateKey:     generatePrivateKey(t),
+		Storefront:     "us",
+		RequestTimeout: 5 * time.Second,
+	}), &requests
+}
+
+func TestGetPlaylist(t *testing.T) {
+	client, requests := recordedAppleMusic(t, map[string]string{
+		"/v1/catalog/us/playlists/pl.2b0e6e332fdf4b7a91164da3162127b5?include=tracks":  "playlist.json",
+		"/v1/catalog/us/playlists/pl.2b0e6e332fdf4b7a91164da3162127b5/tracks?offset=2": "playlist_tracks.json",
+		"/v1/catalog/us/playlists/pl.empty":                                            "empty_data.json",
+	})
+
+	playlist, err := client.GetPlaylist(context.Background(), "https://music.apple.com/us/playlist/afrobeats-hits/pl.2b0e6e332fdf4b7a91164da3162127b5")
+	if err != nil {
+		t.Fatalf("GetPlaylist() error = %v", err)
+	}
+	if playlist.Title != "Afrobeats Hits" || playlist.Owner != "Apple Music Afrobeats" || playlist.Description != "The biggest songs right now." {
+		t.Errorf("GetPlaylist() = %+v, attributes of the playlist are missing", playlist)
+	}
+	if want := "https://is1-ssl.mzstatic.com/image/thumb/Features116/v4/a4/4b/5e/a44b5e2c/U0gtTVMtV1ctQWZyb2JlYXRzX0hpdHMuanBn.jpg/600x600cc.jpg"; playlist.ImageURL != want {
+		t.Errorf("ImageURL = %q, want %q", playlist.ImageURL, want)
+	}
+	if playlist.Platform != string(Platform) || playlist.TrackCount != 3 || len(*requests) != 2 {
+		t.Errorf("Platform, TrackCount = %q, %d after %v, want %q, 3 from both pages", playlist.Platform, playlist.TrackCount, *requests, Platform)
+	}
+
+	// apple music joins the artists of a song into a single name.
+	want := utils.Track{
+		ID:         "1625328890",
+		Title:      "Calm Down",
+		Artists:    []string{"Rema", "Selena Gomez"},
+		ISRC:       "USUM72206091",
+		Duration:   239,
+		Album:      "Calm Down (with Selena Gomez) - Single",
+		ArtworkURL: "https://is1-ssl.mzstatic.com/image/thumb/Music122/v4/2c/4e/9b/2c4e9b1e/22UM1IM25621.rgb.jpg/600x600bb.jpg",
+		URL:        "https://music.apple.com/us/album/calm-down/1625328888?i=1625328890",
+		Position:   2,
+	}
+	if !reflect.DeepEqual(playlist.Tracks[1], want) {
+		t.Errorf("Tracks[1] = %+v, want %+v", playlist.Tracks[1], want)
+	}
+	if last := playlist.Tracks[2]; last.Title != "Last Last" || last.Position != 3 || !last.Explicit {
+		t.Errorf("Tracks[2] = %+v, want the explicit track of the second page", last)
+	}
+
+	if _, err = client.GetPlaylist(context.Background(), "https://music.apple.com/us/album/fever/1"); !errors.Is(err, registry.ErrInvalidInput) {
+		t.Errorf("GetPlaylist() of an album url error = %v, want %v", err, registry.ErrInvalidInput)
+	}
+	if _, err = client.GetPlaylist(context.Background(), "https://music.apple.com/us/playlist/missing/pl.missing"); !errors.Is(err, registry.ErrNotFound) {
+		t.Errorf("GetPlaylist() of an unknown playlist error = %v, want %v", err, registry.ErrNotFound)
+	}
+	if _, err = client.GetPlaylist(context.Background(), "https://music.apple.com/us/playlist/empty/pl.empty"); err == nil {
+		t.Error("GetPlaylist() of an empty response error = nil, want an error")
+	}
+}
+
+func TestSearchTracks(t *testing.T) {
+	client, requests := recordedAppleMusic(t, map[string]string{
+		"/v1/catalog/us/songs?filter[isrc]=USUM72206091":                "songs_isrc.json",
+		"/v1/catalog/us/songs":                                          "empty_data.json",
+		"/v1/catalog/us/search?limit=5&term=Essence Wizkid&types=songs": "search_songs.json",
+		"/v1/catalog/us/search":                                         "search_empty.json",
+	})
+
+	// a dashed ISRC, as some platforms return them, is looked up as it is stored.
+	tracks, err := client.SearchTracks(context.Background(), utils.Track{Title: "Calm Down", Artists: []string{"Rema"}, ISRC: "us-um7-22-06091"})
+	if err != nil {
+		t.Fatalf("SearchTracks() error = %v", err)
+	}
+	if len(tracks) != 1 || tracks[0].ID != "1625328890" || tracks[0].MatchStrategy != utils.ISRCMatch || len(*requests) != 1 {
+		t.Errorf("SearchTracks() = %+v after %v, want the song with the ISRC from its lookup alone", tracks, *requests)
+	}
+
+	// no song has the ISRC, so the catalog is searched with the title and the first artist.
+	*requests = nil
+	tracks, err = client.SearchTracks(context.Background(), utils.Track{Title: "Essence", Artists: []string{"Wizkid", "Tems"}, ISRC: "XX0000000000"})
+	if err != nil {
+		t.Fatalf("SearchTracks() error = %v", err)
+	}
+	if len(tracks) != 2 || tracks[1].ID != "1580283853" || tracks[1].MatchStrategy != utils.TextSearchMatch {
+		t.Errorf("SearchTracks() = %+v, want both search results", tracks)
+	}
+	if want := []string{"/v1/catalog/us/songs?filter[isrc]=XX0000000000", "/v1/catalog/us/search?limit=5&term=Essence Wizkid&types=songs"}; !reflect.DeepEqual(*requests, want) {
+		t.Errorf("requests = %v, want %v", *requests, want)
+	}
+	if want := []string{"Wizkid", "Justin Bieber", "Tems"}; !reflect.DeepEqual(tracks[1].Artists, want) {
+		t.Errorf("Artists = %q, want %q", tracks[1].Artists, want)
+	}
+
+	if _, err = client.SearchTracks(context.Background(), utils.Track{Title: "Unreleased", Artists: []string{"Nobody"}}); !errors.Is(err, registry.ErrNotFound) {
+		t.Errorf("SearchTracks() of an unknown track error = %v, want %v", err, registry.ErrNotFound)
+	}
+
+	track, err := client.LookupTrack(context.Background(), utils.Track{Title: "Essence", Artists: []string{"Wizkid"}})
+	if err != nil || track.ID != "1534358717" {
+		t.Errorf("LookupTrack() = %q, %v, want the top result", track.ID, err)
+	}
+}
+
+func TestCreatePlaylist(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/me/library/playlists" {
+			t.Errorf("request = %s %s, want POST /v1/me/library/playlists", r.Method, r.URL.Path)
+		}
+
+		name, status := "create_playlist.json", http.StatusCreated
+		if r.Header.Get("Music-User-Token") != "music-user-token" {
+			name, status = "unauthenticated.json", http.StatusUnauthorized
+		}
+
+		var body struct {
+			Attributes struct {
+				Name        string `json:"name"`
+				Description string `json:"description"`
+			} `json:"attributes"`
+			Relationships struct {
+				Tracks struct {
+					Data []map[string]string `json:"data"`
+				} `json:"tracks"`
+			} `json:"relationships"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("request body could not be decoded: %v", err)
+		}
+		if body.Attributes.Name != "Road Trip" || body.Attributes.Description != "Converted with kilishi" {
+			t.Errorf("attributes = %+v, want the title and description of the playlist", body.Attributes)
+		}
+		// duplicate tracks are only added once.
+		if want := []map[string]string{{"id": "1534358717", "type": "songs"}, {"id": "1625328890", "type": "songs"}}; !reflect.DeepEqual(body.Relationships.Tracks.Data, want) {
+			t.Errorf("tracks = %v, want %v", body.Relationships.Tracks.Data, want)
+		}
+
+		response, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write(response)
+	}))
+	defer server.Close()
+
+	client := New(&InitialisationOpts{
+		RequestClient:  req.C(),
+		BaseAPIURL:     server.URL,
+		TeamID:         "TEAM123456",
+		KeyID:          "KEY1234567",
+		PrivateKey:     generatePrivateKey(t),
+		Storefront:     "us",
+		RequestTimeout: 5 * time.Second,
+	})
+	playlist := utils.Playlist{
+		Title:       "Road Trip",
+		Description: "Converted with kilishi",
+		Tracks:      []utils.Track{{ID: "1534358717"}, {ID: "1625328890"}, {ID: "1534358717"}},
+	}
+
+	playlistURL, err := client.CreatePlaylist(context.Background(), playlist, "music-user-token")
+	if err != nil {
+		t.Fatalf("CreatePlaylist() error = %v", err)
+	}
+	if want := "https://music.apple.com/library/playlist/p.eoGxxxBt8MPdq"; playlistURL != want {
+		t.Errorf("CreatePlaylist() = %q, want %q", playlistURL, want)
+	}
+
+	// an expired user token is reported as such, so the user is asked to connect their account again.
+	if _, err = client.CreatePlaylist(context.Background(), playlist, "expired-token"); !errors.Is(err, registry.ErrUnauthorized) {
+		t.Errorf("CreatePlaylist() with an expired token error = %v, want %v", err, registry.ErrUnauthorized)
+	}
+}
+
+func TestGetTrack(t *testing.T) {
+	client, requests := recordedAppleMusic(t, map[string]string{"/v1/catalog/us/songs/1534358717": "song.json"})
+
+	want := utils.Track{
+		ID:         "1534358717",
+		Title:      "Essence",
+		Artists:    []string{"Wizkid", "Tems"},
+		ISRC:       "USRC12100001",
+		Duration:   248,
+		Album:      "Made In Lagos",
+		Explicit:   true,
+		ArtworkURL: "https://is1-ssl.mzstatic.com/image/thumb/Music124/v4/6f/0e/7a/6f0e7a5c/196006437741.jpg/600x600bb.jpg",
+		URL:        "https://music.apple.com/us/album/essence-feat-tems/1534358210?i=1534358717",
+	}
+	// songs are shared from their own page or from the album they are on, and the link of a track must lead back to it.
+	for _, trackURL := range []string{
+		"https://music.apple.com/us/song/essence-feat-tems/1534358717",
+		"https://music.apple.com/us/album/made-in-lagos/1534358210?i=1534358717",
+		client.TrackURL("1534358717"),
+	} {
+		track, err := client.GetTrack(context.Background(), trackURL)
+		if err != nil {
+			t.Fatalf("GetTrack(%q) error = %v", trackURL, err)
+		}
+		if !reflect.DeepEqual(track, want) {
+			t.Errorf("GetTrack(%q) = %+v, want %+v", trackURL, track, want)
+		}
+	}
+	if len(*requests) != 3 {
+		t.Errorf("requests = %v, want one per link", *requests)
+	}
+
+	if _, err := client.GetTrack(context.Background(), "https://music.apple.com/us/song/unknown/1"); !errors.Is(err, registry.ErrNotFound) {
+		t.Errorf("GetTrack() of an unknown song error = %v, want %v", err, registry.ErrNotFound)
+	}
+	if _, err := client.GetTrack(context.Background(), "https://music.apple.com/us/album/made-in-lagos/1534358210"); !errors.Is(err, registry.ErrInvalidInput) {
+		t.Errorf("GetTrack() of an album url error = %v, want %v", err, registry.ErrInvalidInput)
+	}
+}
+
+func TestGetAlbum(t *testing.T) {
+	client, _ := recordedAppleMusic(t, map[string]string{
+		"/v1/catalog/us/albums/1534358210?include=tracks":  "album.json",
+		"/v1/catalog/us/albums/1534358210/tracks?offset=2": "album_tracks.json",
+	})
+
+	album, err := client.GetAlbum(context.Background(), "https://music.apple.com/us/album/made-in-lagos/1534358210")
+	if err != nil {
+		t.Fatalf("GetAlbum() error = %v", err)
+	}
+	if album.Title != "Made In Lagos" || album.UPC != "196006437741" || album.ReleaseDate != "2020-10-30" || album.Platform != string(Platform) {
+		t.Errorf("GetAlbum() = %+v, attributes of the album are missing", album)
+	}
+	if want := "https://is1-ssl.mzstatic.com/image/thumb/Music124/v4/6f/0e/7a/6f0e7a5c/196006437741.jpg/600x600bb.jpg"; album.ImageURL != want {
+		t.Errorf("ImageURL = %q, want %q", album.ImageURL, want)
+	}
+
+	var titles []string
+	for i, track := range album.Tracks {
+		titles = append(titles, track.Title)
+		if track.Position != i+1 {
+			t.Errorf("Tracks[%d].Position = %d, want %d", i, track.Position, i+1)
+		}
+	}
+	if want := []string{"Reckless", "Ginger", "Essence"}; !reflect.DeepEqual(titles, want) {
+		t.Errorf("track titles = %v, want %v, every page must be followed", titles, want)
+	}
+
+	// an album missing some of its tracks would be converted as if it were complete.
+	client, _ = recordedAppleMusic(t, map[string]string{"/v1/catalog/us/albums/1534358210?include=tracks": "album.json"})
+	if _, err = client.GetAlbum(context.Background(), "https://music.apple.com/us/album/made-in-lagos/1534358210"); !errors.Is(err, registry.ErrNotFound) {
+		t.Errorf("GetAlbum() with a missing page error = %v, want %v", err, registry.ErrNotFound)
+	}
+}
+
+func TestLookupAlbum(t *testing.T) {
+	client, requests := recordedAppleMusic(t, map[string]string{
+		"/v1/catalog/us/albums?filter[upc]=196006437741":                       "albums_upc.json",
+		"/v1/catalog/us/search?limit=1&term=Made In Lagos Wizkid&types=albums": "search_albums.json",
+		"/v1/catalog/us/search": "search_empty.json",
+	})
+
+	// the UPC is looked up without the leading zero of its EAN form.
+	album, err := client.LookupAlbum(context.Background(), utils.Album{Title: "Made In Lagos", Artists: []string{"Wizkid"}, UPC: "0196006437741"})
+	if err != nil {
+		t.Fatalf("LookupAlbum() error = %v", err)
+	}
+	if album.ID != "1534358210" || album.MatchStrategy != utils.UPCMatch || album.Platform != string(Platform) {
+		t.Errorf("LookupAlbum() = %+v, want the album with the UPC", album)
+	}
+	if len(*requests) != 1 {
+		t.Errorf("requests = %v, want the UPC lookup alone", *requests)
+	}
+
+	// no album has the UPC, so the search is used.
+	album, err = client.LookupAlbum(context.Background(), utils.Album{Title: "Made In Lagos", Artists: []string{"Wizkid"}, UPC: "5054197000001"})
+	if err != nil {
+		t.Fatalf("LookupAlbum() error = %v", err)
+	}
+	if album.ID != "1540112154" || album.MatchStrategy != utils.TextSearchMatch {
+		t.Errorf("LookupAlbum() = %+v, want the top search result", album)
+	}
+
+	if _, err = client.LookupAlbum(context.Background(), utils.Album{Title: "Unreleased", Artists: []string{"Nobody"}}); !errors.Is(err, registry.ErrNotFound) {
+		t.Errorf("LookupAlbum() of an unknown album error = %v, want %v", err, registry.ErrNotFound)
+	}
+}
+
+func TestGenerateDeveloperToken(t *testing.T) {
+	privateKey := generatePrivateKey(t)
+	key, err := parsePrivateKey(strings.ReplaceAll(privateKey, "\n", `\n`))
+	if err != nil {
+		t.Fatalf("parsePrivateKey() of a key with escaped newlines error = %v", err)
+	}
+
+	token, err := generateDeveloperToken("TEAM123456", "KEY1234567", privateKey, time.Hour)
+	if err != nil {
+		t.Fatalf("generateDeveloperToken() error = %v", err)
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("generateDeveloperToken() = %q, want a JWT", token)
+	}
+
+	var header map[string]string
+	var claims struct {
+		Issuer    string `json:"iss"`
+		IssuedAt  int64  `json:"iat"`
+		ExpiresAt int64  `json:"exp"`
+	}
+	decodeSegment(t, parts[0], &header)
+	decodeSegment(t, parts[1], &claims)
+	if header["alg"] != "ES256" || header["kid"] != "KEY1234567" {
+		t.Errorf("header = %v, want ES256 signed with KEY1234567", header)
+	}
+	if claims.Issuer != "TEAM123456" || claims.ExpiresAt-claims.IssuedAt != int64(time.Hour/time.Second) {
+		t.Errorf("claims = %+v, want a token of TEAM123456 valid for an hour", claims)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(signature) != 64 {
+		t.Fatalf("signature = %q, want the 64 bytes of R || S", parts[2])
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(&key.PublicKey, digest[:], r, s) {
+		t.Error("signature does not verify with the public key")
+	}
+
+	if _, err := generateDeveloperToken("TEAM123456", "KEY1234567", "not a key", time.Hour); err == nil {
+		t.Error("generateDeveloperToken() of an invalid key error = nil, want an error")
+	}
+}
+
+func decodeSegment(t *testing.T, segment string, v any) {
+	t.Helper()
+
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		t.Fatalf("segment %q is not base64url encoded: %v", segment, err)
+	}
+	if err = json.Unmarshal(raw, v); err != nil {
+		t.Fatalf("segment %q is not JSON: %v", raw, err)
+	}
+}
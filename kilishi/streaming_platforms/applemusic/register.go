@@ -0,0 +1,50 @@
+package applemusic
+
+import (
+	"github.com/prettyirrelevant/kilishi/config"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+)
+
+// Platform is the name Apple Music is registered under.
+const Platform registry.MusicStreamingPlatform = "applemusic"
+
+func init() {
+	registry.Register(registry.Factory{
+		Name:        Platform,
+		DisplayName: "Apple Music",
+		URLMatchers: []registry.URLMatcher{
+			{Kind: registry.PlaylistURL, Pattern: playlistURLRegex},
+			{Kind: registry.AlbumURL, Pattern: albumURLRegex},
+			{Kind: registry.TrackURL, Pattern: trackURLRegex},
+		},
+		Capabilities: registry.Capabilities{GetPlaylist: true, CreatePlaylist: true, LookupTrack: true, GetTrack: true, Albums: true, ClientToken: true},
+		New: func(opts registry.Options) (registry.MusicStreamingPlatformInterface, error) {
+			var cfg Config
+			if err := config.Load(&cfg); err != nil {
+				return nil, err
+			}
+			// apple music is optional, it is only enabled once the MusicKit key is set.
+			if err := registry.CheckCredentials(map[string]string{
+				"APPLE_MUSIC_TEAM_ID":     cfg.TeamID,
+				"APPLE_MUSIC_KEY_ID":      cfg.KeyID,
+				"APPLE_MUSIC_PRIVATE_KEY": cfg.PrivateKey,
+			}); err != nil {
+				return nil, err
+			}
+
+			return New(&InitialisationOpts{
+				RequestClient: registry.LimitRequests(opts.RequestClient, opts.RateLimiter, Platform, registry.RateLimit{
+					RequestsPerSecond:     cfg.RateLimit,
+					Burst:                 cfg.RateLimitBurst,
+					MaxConcurrentRequests: cfg.MaxConcurrentRequests,
+				}),
+				BaseAPIURL:     cfg.BaseAPIURL,
+				TeamID:         cfg.TeamID,
+				KeyID:          cfg.KeyID,
+				PrivateKey:     cfg.PrivateKey,
+				Storefront:     cfg.Storefront,
+				RequestTimeout: cfg.RequestTimeout,
+			}), nil
+		},
+	})
+}
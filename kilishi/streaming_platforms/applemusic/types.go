@@ -0,0 +1,153 @@
+package applemusic
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/imroc/req/v3"
+)
+
+// AppleMusic encapsulates all methods relating to Apple Music.
+type AppleMusic struct {
+	RequestClient *req.Client
+	Config        Config
+}
+
+type InitialisationOpts struct {
+	RequestClient  *req.Client
+	BaseAPIURL     string
+	TeamID         string
+	KeyID          string
+	PrivateKey     string
+	Storefront     string
+	RequestTimeout time.Duration
+}
+
+type Config struct {
+	BaseAPIURL            string        `env:"APPLE_MUSIC_BASE_API_URL" envDefault:"https://api.music.apple.com"`
+	TeamID                string        `env:"APPLE_MUSIC_TEAM_ID" envDefault:""`
+	KeyID                 string        `env:"APPLE_MUSIC_KEY_ID" envDefault:""`
+	PrivateKey            string        `env:"APPLE_MUSIC_PRIVATE_KEY" envDefault:""`
+	Storefront            string        `env:"APPLE_MUSIC_STOREFRONT" envDefault:"us"`
+	RequestTimeout        time.Duration `env:"APPLE_MUSIC_REQUEST_TIMEOUT" envDefault:"30s"`
+	RateLimit             float64       `env:"APPLE_MUSIC_RATE_LIMIT" envDefault:"10"`
+	RateLimitBurst        int           `env:"APPLE_MUSIC_RATE_LIMIT_BURST" envDefault:"10"`
+	MaxConcurrentRequests int           `env:"APPLE_MUSIC_MAX_CONCURRENT_REQUESTS" envDefault:"10"`
+}
+
+// API Types (Autogenerated).
+type appleMusicAPISong struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	Attributes struct {
+		Name             string             `json:"name"`
+		ArtistName       string             `json:"artistName"`
+		AlbumName        string             `json:"albumName"`
+		DurationInMillis int                `json:"durationInMillis"`
+		ISRC             string             `json:"isrc"`
+		URL              string             `json:"url"`
+		ContentRating    string             `json:"contentRating"`
+		Artwork          appleMusicAPIImage `json:"artwork"`
+	} `json:"attributes"`
+}
+
+type appleMusicAPIImage struct {
+	URL string `json:"url"`
+}
+
+type appleMusicAPITracksResponse struct {
+	Next string              `json:"next"`
+	Data []appleMusicAPISong `json:"data"`
+}
+
+type appleMusicAPIGetPlaylistResponse struct {
+	Data []struct {
+		ID         string `json:"id"`
+		Type       string `json:"type"`
+		Attributes struct {
+			Name        string             `json:"name"`
+			CuratorName string             `json:"curatorName"`
+			URL         string             `json:"url"`
+			Artwork     appleMusicAPIImage `json:"artwork"`
+			Description struct {
+				Standard string `json:"standard"`
+				Short    string `json:"short"`
+			} `json:"description"`
+		} `json:"attributes"`
+		Relationships struct {
+			Tracks appleMusicAPITracksResponse `json:"tracks"`
+		} `json:"relationships"`
+	} `json:"data"`
+}
+
+type appleMusicAPIAlbum struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	Attributes struct {
+		Name        string             `json:"name"`
+		ArtistName  string             `json:"artistName"`
+		UPC         string             `json:"upc"`
+		ReleaseDate string             `json:"releaseDate"`
+		TrackCount  int                `json:"trackCount"`
+		URL         string             `json:"url"`
+		Artwork     appleMusicAPIImage `json:"artwork"`
+	} `json:"attributes"`
+	Relationships struct {
+		Tracks appleMusicAPITracksResponse `json:"tracks"`
+	} `json:"relationships"`
+}
+
+type appleMusicAPIAlbumsResponse struct {
+	Data []appleMusicAPIAlbum `json:"data"`
+}
+
+type appleMusicAPISearchResponse struct {
+	Results struct {
+		Songs struct {
+			Next string              `json:"next"`
+			Data []appleMusicAPISong `json:"data"`
+		} `json:"songs"`
+		Albums struct {
+			Next string               `json:"next"`
+			Data []appleMusicAPIAlbum `json:"data"`
+		} `json:"albums"`
+	} `json:"results"`
+}
+
+type appleMusicAPICreatePlaylistResponse struct {
+	Data []struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+	} `json:"data"`
+}
+
+type appleMusicAPIError struct {
+	Errors []struct {
+		ID     string `json:"id"`
+		Title  string `json:"title"`
+		Detail string `json:"detail"`
+		Status string `json:"status"`
+		Code   string `json:"code"`
+	} `json:"errors"`
+	// kind is the kind of error the platform answered with, see Unwrap.
+	kind error
+}
+
+func (e *appleMusicAPIError) Error() string {
+	var reasons []string
+	for _, entry := range e.Errors {
+		reasons = append(reasons, fmt.Sprintf("%s (%s)", entry.Title, entry.Detail))
+	}
+
+	status := ""
+	if len(e.Errors) > 0 {
+		status = e.Errors[0].Status
+	}
+	return fmt.Sprintf("Apple Music API Error: status: %s  reason: %s", status, strings.Join(reasons, "; "))
+}
+
+// Unwrap returns the kind of error the platform answered with, e.g. `registry.ErrNotFound`.
+func (e *appleMusicAPIError) Unwrap() error {
+	return e.kind
+}
@@ -0,0 +1,257 @@
+package applemusic
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imroc/req/v3"
+
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+	"github.com/prettyirrelevant/kilishi/utils"
+)
+
+var (
+	playlistURLRegex = regexp.MustCompile(`^https://music\.apple\.com/([a-z]{2})/playlist/(?:[^/?]+/)?(pl\.[a-zA-Z0-9-]+)`)
+	albumURLRegex    = regexp.MustCompile(`^https://music\.apple\.com/([a-z]{2})/album/(?:[^/?]+/)?(\d+)(?:\?(?:l=[\w-]+)?)?$`)
+	trackURLRegex    = regexp.MustCompile(`^https://music\.apple\.com/([a-z]{2})/(?:song/(?:[^/?]+/)?(\d+)|album/(?:[^/?]+/)?\d+\?(?:.*&)?i=(\d+))`)
+	// artistSeparatorRegex splits the combined artist names apple music returns.
+	artistSeparatorRegex = regexp.MustCompile(`\s*(?:,|&)\s*`)
+)
+
+// parsePlaylistURL validates an Apple Music playlist URL and returns the storefront and playlist ID.
+func parsePlaylistURL(playlistURL string) (string, string, error) {
+	matches := playlistURLRegex.FindStringSubmatch(playlistURL)
+	if len(matches) < 3 {
+		return "", "", registry.NewError(registry.ErrInvalidInput, "applemusic: playlist url is invalid. check that it follows the format https://music.apple.com/<storefront>/playlist/<name>/<id>")
+	}
+
+	return matches[1], matches[2], nil
+}
+
+// parseTrackURL validates an Apple Music song URL, or an album URL that points at one of its songs, and returns the storefront and song ID.
+func parseTrackURL(trackURL string) (string, string, error) {
+	matches := trackURLRegex.FindStringSubmatch(trackURL)
+	if len(matches) < 4 {
+		return "", "", registry.NewError(registry.ErrInvalidInput, "applemusic: track url is invalid. check that it follows the format https://music.apple.com/<storefront>/song/<name>/<id>")
+	}
+
+	// links shared from an album carry the song ID in the `i` query parameter.
+	if matches[2] != "" {
+		return matches[1], matches[2], nil
+	}
+	return matches[1], matches[3], nil
+}
+
+// parseAlbumURL validates an Apple Music album URL and returns the storefront and album ID.
+func parseAlbumURL(albumURL string) (string, string, error) {
+	matches := albumURLRegex.FindStringSubmatch(albumURL)
+	if len(matches) < 3 {
+		return "", "", registry.NewError(registry.ErrInvalidInput, "applemusic: album url is invalid. check that it follows the format https://music.apple.com/<storefront>/album/<name>/<id>")
+	}
+
+	return matches[1], matches[2], nil
+}
+
+// parseGetPlaylistResponse transforms the playlist object returned from Apple Music API into our internal object.
+func parseGetPlaylistResponse(response *appleMusicAPIGetPlaylistResponse) utils.Playlist {
+	if len(response.Data) == 0 {
+		return utils.Playlist{}
+	}
+
+	data := response.Data[0]
+	return utils.Playlist{
+		ID:          data.ID,
+		Title:       data.Attributes.Name,
+		Description: data.Attributes.Description.Standard,
+		Tracks:      parseSongsResponse(data.Relationships.Tracks.Data),
+		Owner:       data.Attributes.CuratorName,
+		ImageURL:    artworkURL(data.Attributes.Artwork),
+		URL:         data.Attributes.URL,
+	}
+}
+
+// parseSongsResponse transforms the songs returned from Apple Music API into our internal object.
+func parseSongsResponse(songs []appleMusicAPISong) []utils.Track {
+	var tracks []utils.Track
+	for _, entry := range songs {
+		tracks = append(tracks, utils.Track{
+			ID:         entry.ID,
+			Title:      utils.CleanTrackTitle(entry.Attributes.Name),
+			Artists:    splitArtistName(entry.Attributes.ArtistName),
+			ISRC:       entry.Attributes.ISRC,
+			Duration:   entry.Attributes.DurationInMillis / 1000,
+			Album:      entry.Attributes.AlbumName,
+			Explicit:   entry.Attributes.ContentRating == "explicit",
+			ArtworkURL: artworkURL(entry.Attributes.Artwork),
+			URL:        entry.Attributes.URL,
+		})
+	}
+
+	return tracks
+}
+
+// parseAlbum transforms the album object returned from Apple Music API into our internal object.
+func parseAlbum(album appleMusicAPIAlbum) utils.Album {
+	return utils.Album{
+		ID:          album.ID,
+		Title:       album.Attributes.Name,
+		Artists:     splitArtistName(album.Attributes.ArtistName),
+		UPC:         album.Attributes.UPC,
+		ReleaseDate: album.Attributes.ReleaseDate,
+		ImageURL:    artworkURL(album.Attributes.Artwork),
+		URL:         album.Attributes.URL,
+		TrackCount:  album.Attributes.TrackCount,
+	}
+}
+
+// artworkURL fills in the size of the artwork, apple music returns a URL template with {w} and {h} placeholders.
+func artworkURL(artwork appleMusicAPIImage) string {
+	return strings.NewReplacer("{w}", artworkSize, "{h}", artworkSize).Replace(artwork.URL)
+}
+
+// splitArtistName breaks Apple Music's combined artist name (e.g. "Wizkid, Tems & Justin Bieber") into a slice.
+func splitArtistName(name string) []string {
+	var artists []string
+	for _, artist := range artistSeparatorRegex.Split(name, -1) {
+		if artist = strings.TrimSpace(artist); artist != "" {
+			artists = append(artists, artist)
+		}
+	}
+	return artists
+}
+
+// trackToSearchQuery transforms our internal track object into an Apple Music search term.
+func trackToSearchQuery(track utils.Track) string {
+	q := track.Title
+	for _, artistName := range track.Artists {
+		q += " " + artistName
+		break
+	}
+
+	return q
+}
+
+// albumToSearchQuery transforms our internal album object into an Apple Music search term.
+func albumToSearchQuery(album utils.Album) string {
+	q := album.Title
+	for _, artistName := range album.Artists {
+		q += " " + artistName
+		break
+	}
+
+	return q
+}
+
+// generateDeveloperToken creates the ES256 signed JWT Apple Music expects as the developer token.
+// https://developer.apple.com/documentation/applemusicapi/generating_developer_tokens
+func generateDeveloperToken(teamID, keyID, privateKey string, expiresIn time.Duration) (string, error) {
+	key, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": "ES256", "kid": keyID})
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims, err := json.Marshal(map[string]any{"iss": teamID, "iat": now.Unix(), "exp": now.Add(expiresIn).Unix()})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	// JWS expects the raw R || S concatenation, each left padded to the curve size.
+	keySize := (key.Curve.Params().BitSize + 7) / 8
+	signature := make([]byte, 2*keySize)
+	r.FillBytes(signature[:keySize])
+	s.FillBytes(signature[keySize:])
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parsePrivateKey decodes the PKCS#8 `.p8` key downloaded from the Apple developer portal.
+func parsePrivateKey(privateKey string) (*ecdsa.PrivateKey, error) {
+	// environment variables usually carry the key with escaped newlines.
+	block, _ := pem.Decode([]byte(strings.ReplaceAll(privateKey, `\n`, "\n")))
+	if block == nil {
+		return nil, errors.New("applemusic: private key is not PEM encoded")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("applemusic: private key parse failed due to %s", err.Error())
+	}
+
+	ecdsaKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("applemusic: private key is not an ECDSA key")
+	}
+	return ecdsaKey, nil
+}
+
+func setupRequestClient(reqClient *req.Client, baseURL string) *req.Client {
+	return reqClient.
+		SetBaseURL(baseURL).
+		EnableDumpEachRequest().
+		SetCommonContentType(utils.ApplicationJSON).
+		SetCommonErrorResult(&appleMusicAPIError{}).
+		OnAfterResponse(func(client *req.Client, resp *req.Response) error {
+			// There is an underlying error, e.g. network error or unmarshal error.
+			if resp.Err != nil {
+				// the platform could not be reached, see `registry.ErrUpstreamUnavailable`.
+				if resp.Response == nil {
+					resp.Err = registry.TransportError(resp.Err)
+				}
+				return nil
+			}
+			// Server returns an error message, convert it to human-readable Go error.
+			if apiErr, ok := resp.ErrorResult().(*appleMusicAPIError); ok {
+				apiErr.kind = registry.ErrorKind(resp.Response)
+				resp.Err = apiErr
+				return nil
+			}
+			// Edge case: neither an error state response nor a success state response,
+			// dump content to help troubleshoot.
+			if !resp.IsSuccessState() {
+				resp.Err = registry.NewError(registry.ErrorKind(resp.Response), "bad status: %s\nraw content:\n%s", resp.Status, resp.Dump())
+				return nil
+			}
+			return nil
+		}).
+		SetCommonRetryCount(3).
+		AddCommonRetryCondition(func(resp *req.Response, err error) bool {
+			return resp.Response != nil && resp.StatusCode == 429
+		}).
+		SetCommonRetryInterval(func(resp *req.Response, attempt int) time.Duration {
+			if resp.Response == nil {
+				return 2 * time.Second
+			}
+
+			retryAfterHeader := resp.Header.Get("Retry-After")
+			if retryAfter, err := strconv.Atoi(retryAfterHeader); retryAfterHeader != "" && err == nil {
+				return time.Duration(retryAfter) * time.Second
+			}
+
+			return 2 * time.Second
+		})
+}
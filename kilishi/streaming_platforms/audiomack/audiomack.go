@@ -0,0 +1,156 @@
+package audiomack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+	"github.com/prettyirrelevant/kilishi/utils"
+)
+
+// New initializes an `Audiomack` object.
+func New(opts *InitialisationOpts) *Audiomack {
+	return &Audiomack{
+		RequestClient: setupRequestClient(opts.RequestClient.SetTimeout(opts.RequestTimeout), opts.BaseAPIURL),
+		Config: Config{
+			BaseAPIURL:     opts.BaseAPIURL,
+			ConsumerKey:    opts.ConsumerKey,
+			ConsumerSecret: opts.ConsumerSecret,
+			RequestTimeout: opts.RequestTimeout,
+		},
+	}
+}
+
+// GetPlaylist returns information about a playlist.
+func (a *Audiomack) GetPlaylist(ctx context.Context, playlistURL string) (utils.Playlist, error) {
+	artistSlug, playlistSlug, err := parsePlaylistURL(playlistURL)
+	if err != nil {
+		return utils.Playlist{}, err
+	}
+
+	path := "/playlist/" + artistSlug + "/" + playlistSlug
+	authorization, err := oauthAuthorizationHeader(http.MethodGet, a.Config.BaseAPIURL+path, nil, a.Config, "", "")
+	if err != nil {
+		return utils.Playlist{}, err
+	}
+
+	var response audiomackAPIGetPlaylistResponse
+	err = a.RequestClient.
+		Get(path).
+		SetHeader("Authorization", authorization).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return utils.Playlist{}, err
+	}
+
+	playlist := utils.Playlist{
+		ID:          response.Results.ID,
+		Title:       response.Results.Title,
+		Description: response.Results.Description,
+		Tracks:      parseSongsResponse(response.Results.Tracks),
+		Owner:       response.Results.Artist.Name,
+		ImageURL:    response.Results.ImageURL,
+		URL:         baseWebsiteURL + artistSlug + "/playlist/" + playlistSlug,
+	}
+	playlist.Finalise(string(Platform))
+	return playlist, nil
+}
+
+// CreatePlaylist creates a public playlist for the user the access token belongs to.
+// The access token is expected in the format `oauth_token:oauth_token_secret`.
+func (a *Audiomack) CreatePlaylist(ctx context.Context, playlist utils.Playlist, accessToken string) (string, error) {
+	token, tokenSecret, err := splitAccessToken(accessToken)
+	if err != nil {
+		return "", err
+	}
+
+	var trackIDs []string
+	for _, entry := range playlist.Tracks {
+		if ok := utils.Contains(trackIDs, entry.ID); !ok {
+			trackIDs = append(trackIDs, entry.ID)
+		}
+	}
+
+	// the genre is left out, the playlist may mix tracks of any genre and audiomack does not require one.
+	formData := map[string]string{
+		"title":    playlist.Title,
+		"private":  "no",
+		"music_id": strings.Join(trackIDs, ","),
+	}
+	authorization, err := oauthAuthorizationHeader(http.MethodPost, a.Config.BaseAPIURL+"/playlist", formData, a.Config, token, tokenSecret)
+	if err != nil {
+		return "", err
+	}
+
+	var response audiomackAPICreatePlaylistResponse
+	err = a.RequestClient.
+		Post("/playlist").
+		SetHeader("Authorization", authorization).
+		SetFormData(formData).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return "", err
+	}
+
+	return baseWebsiteURL + response.Results.Artist.URLSlug + "/playlist/" + response.Results.URLSlug, nil
+}
+
+// LookupTrack searches for a track on Audiomack and returns the top result.
+func (a *Audiomack) LookupTrack(ctx context.Context, track utils.Track) (utils.Track, error) {
+	tracks, err := a.SearchTracks(ctx, track)
+	if err != nil {
+		return utils.Track{}, err
+	}
+
+	return tracks[0], nil
+}
+
+// SearchTracks returns the songs on Audiomack that match the track, best first.
+func (a *Audiomack) SearchTracks(ctx context.Context, track utils.Track) ([]utils.Track, error) {
+	queryParams := map[string]string{
+		"q":     trackToSearchQuery(track),
+		"show":  "songs",
+		"limit": "5",
+	}
+	authorization, err := oauthAuthorizationHeader(http.MethodGet, a.Config.BaseAPIURL+"/search", queryParams, a.Config, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("audiomack: %w", err)
+	}
+
+	var response audiomackAPISearchResponse
+	err = a.RequestClient.
+		Get("/search").
+		SetHeader("Authorization", authorization).
+		SetQueryParams(queryParams).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return nil, fmt.Errorf("audiomack: %w", err)
+	}
+
+	tracks := parseSongsResponse(response.Results)
+	if len(tracks) == 0 {
+		return nil, registry.NewError(registry.ErrNotFound, "audiomack: no track found that matches %s", track.Title)
+	}
+
+	return utils.SetMatchStrategy(tracks, utils.TextSearchMatch), nil
+}
+
+// GetAuthorizationCode is not supported as Audiomack uses OAuth 1.0a, whose three-legged flow
+// needs the request token secret that is never sent back to the callback.
+func (*Audiomack) GetAuthorizationCode(_ context.Context, _ string) (utils.OauthCredentials, error) {
+	return utils.OauthCredentials{}, errors.New("audiomack: authorization code exchange is not supported, provide `oauth_token:oauth_token_secret` as the access token")
+}
+
+// RequiresAccessToken specifies if the streaming requires Oauth.
+func (*Audiomack) RequiresAccessToken() bool {
+	return true
+}
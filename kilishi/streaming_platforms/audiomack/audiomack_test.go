@@ -0,0 +1,249 @@
+package audiomack
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec // OAuth 1.0a mandates HMAC-SHA1 signatures.
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imroc/req/v3"
+
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+	"github.com/prettyirrelevant/kilishi/utils"
+)
+
+const (
+	testConsumerKey    = "consumer-key"
+	testConsumerSecret = "consumer-secret"
+	testAccessToken    = "user-token:user-token-secret"
+)
+
+// recordedAudiomack returns a client of a server that answers with the responses recorded from the Audiomack API in testdata,
+// keyed by the request method, path and decoded query or form. Requests without a valid OAuth 1.0a signature are refused.
+func recordedAudiomack(t *testing.T, responses map[string]string) (*Audiomack, *[]string) {
+	t.Helper()
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, status := "", http.StatusOK
+		if err := verifySignature(r); err != nil {
+			name, status = "unauthorized.json", http.StatusUnauthorized
+		} else {
+			params, _ := url.QueryUnescape(r.Form.Encode())
+			requests = append(requests, r.Method+" "+r.URL.Path+"?"+params)
+			if name = responses[r.Method+" "+r.URL.Path+"?"+params]; name == "" {
+				name, status = "not_found.json", http.StatusNotFound
+			}
+		}
+
+		body, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+
+	return New(&InitialisationOpts{
+		RequestClient:  req.C(),
+		BaseAPIURL:     server.URL,
+		ConsumerKey:    testConsumerKey,
+		ConsumerSecret: testConsumerSecret,
+		RequestTimeout: 5 * time.Second,
+	}), &requests
+}
+
+// verifySignature checks the OAuth 1.0a signature of the request the way the Audiomack API does.
+// https://oauth.net/core/1.0a/#signing_process
+func verifySignature(r *http.Request) error {
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "OAuth ") {
+		return errors.New("authorization header is missing")
+	}
+
+	oauthParams := make(map[string]string)
+	for _, param := range strings.Split(strings.TrimPrefix(authorization, "OAuth "), ", ") {
+		key, quoted, ok := strings.Cut(param, "=")
+		if !ok {
+			return errors.New("authorization header is malformed")
+		}
+		encoded, err := strconv.Unquote(quoted)
+		if err != nil {
+			return err
+		}
+		if oauthParams[key], err = url.PathUnescape(encoded); err != nil {
+			return err
+		}
+	}
+	if oauthParams["oauth_consumer_key"] != testConsumerKey || oauthParams["oauth_signature_method"] != "HMAC-SHA1" {
+		return errors.New("oauth parameters are invalid")
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	var pairs []string
+	for key, values := range r.Form {
+		for _, value := range values {
+			pairs = append(pairs, percentEncode(key)+"="+percentEncode(value))
+		}
+	}
+	for key, value := range oauthParams {
+		if key != "oauth_signature" {
+			pairs = append(pairs, percentEncode(key)+"="+percentEncode(value))
+		}
+	}
+	sort.Strings(pairs)
+
+	tokenSecret := ""
+	if oauthParams["oauth_token"] != "" {
+		tokenSecret = "user-token-secret"
+	}
+
+	baseString := r.Method + "&" + percentEncode("http://"+r.Host+r.URL.Path) + "&" + percentEncode(strings.Join(pairs, "&"))
+	mac := hmac.New(sha1.New, []byte(testConsumerSecret+"&"+tokenSecret))
+	mac.Write([]byte(baseString))
+	if want := base64.StdEncoding.EncodeToString(mac.Sum(nil)); oauthParams["oauth_signature"] != want {
+		return errors.New("oauth signature does not match")
+	}
+	return nil
+}
+
+func TestGetPlaylist(t *testing.T) {
+	client, requests := recordedAudiomack(t, map[string]string{
+		"GET /playlist/audiomack/afro-heat?": "playlist.json",
+	})
+
+	// playlists are read with the consumer credentials alone.
+	playlist, err := client.GetPlaylist(context.Background(), "https://audiomack.com/audiomack/playlist/afro-heat?key=share")
+	if err != nil {
+		t.Fatalf("GetPlaylist() error = %v", err)
+	}
+	if playlist.ID != "2839147" || playlist.Title != "Afro Heat" || playlist.Owner != "Audiomack" {
+		t.Errorf("GetPlaylist() = %+v, attributes of the playlist are missing", playlist)
+	}
+	if want := "https://audiomack.com/audiomack/playlist/afro-heat"; playlist.URL != want {
+		t.Errorf("URL = %q, want %q without the query of the shared link", playlist.URL, want)
+	}
+
+	// the album embedded in the playlist is skipped, the featured artists are split out and the explicit flag is a "yes" or "no".
+	want := []utils.Track{
+		{
+			ID:         "21948321",
+			Title:      "Rush",
+			Artists:    []string{"Ayra Starr"},
+			ISRC:       "USUM72301234",
+			Duration:   185,
+			Album:      "19 & Dangerous",
+			ArtworkURL: "https://assets.audiomack.com/ayrastarr/rush-275-275.jpg",
+			URL:        "https://audiomack.com/ayrastarr/song/rush",
+			Position:   1,
+		},
+		{
+			ID:         "22019842",
+			Title:      "Calm Down Remix",
+			Artists:    []string{"Rema", "Selena Gomez"},
+			ISRC:       "USUM72206091",
+			Duration:   239,
+			Explicit:   true,
+			ArtworkURL: "https://assets.audiomack.com/remamusic/calm-down-remix-275-275.jpg",
+			URL:        "https://audiomack.com/remamusic/song/calm-down-remix",
+			Position:   2,
+		},
+	}
+	if !reflect.DeepEqual(playlist.Tracks, want) || playlist.TrackCount != 2 {
+		t.Errorf("Tracks = %+v, want %+v", playlist.Tracks, want)
+	}
+
+	*requests = nil
+	if _, err = client.GetPlaylist(context.Background(), "https://audiomack.com/wizkid/song/essence"); !errors.Is(err, registry.ErrInvalidInput) {
+		t.Errorf("GetPlaylist() of a song url error = %v, want %v", err, registry.ErrInvalidInput)
+	}
+	if len(*requests) != 0 {
+		t.Errorf("requests = %v, want none for a song url", *requests)
+	}
+	if _, err = client.GetPlaylist(context.Background(), "https://audiomack.com/audiomack/playlist/missing"); !errors.Is(err, registry.ErrNotFound) {
+		t.Errorf("GetPlaylist() of an unknown playlist error = %v, want %v", err, registry.ErrNotFound)
+	}
+}
+
+func TestSearchTracks(t *testing.T) {
+	client, requests := recordedAudiomack(t, map[string]string{
+		"GET /search?limit=5&q=Essence Wizkid&show=songs":                "search.json",
+		"GET /search?limit=5&q=Love, Damini (Reprise) Nobody&show=songs": "search_empty.json",
+	})
+
+	// the search query is part of the signature, which only holds if it is encoded the way the server decodes it.
+	tracks, err := client.SearchTracks(context.Background(), utils.Track{Title: "Essence", Artists: []string{"Wizkid", "Tems"}})
+	if err != nil {
+		t.Fatalf("SearchTracks() error = %v after %v", err, *requests)
+	}
+	if len(tracks) != 2 || tracks[0].ID != "18273645" || tracks[1].ID != "18399012" {
+		t.Fatalf("SearchTracks() = %+v, want both search results", tracks)
+	}
+	for _, track := range tracks {
+		if track.MatchStrategy != utils.TextSearchMatch {
+			t.Errorf("MatchStrategy = %q, want %q", track.MatchStrategy, utils.TextSearchMatch)
+		}
+	}
+	// several featured artists are listed in a single comma separated field.
+	if want := []string{"Wizkid", "Tems", "Justin Bieber"}; !reflect.DeepEqual(tracks[1].Artists, want) {
+		t.Errorf("Artists = %v, want %v", tracks[1].Artists, want)
+	}
+
+	track, err := client.LookupTrack(context.Background(), utils.Track{Title: "Essence", Artists: []string{"Wizkid"}})
+	if err != nil || track.ID != "18273645" {
+		t.Errorf("LookupTrack() = %q, %v, want the top result", track.ID, err)
+	}
+
+	// reserved characters in the query are signed too.
+	if _, err = client.LookupTrack(context.Background(), utils.Track{Title: "Love, Damini (Reprise)", Artists: []string{"Nobody"}}); !errors.Is(err, registry.ErrNotFound) {
+		t.Errorf("LookupTrack() without results error = %v, want %v", err, registry.ErrNotFound)
+	}
+}
+
+func TestCreatePlaylist(t *testing.T) {
+	client, requests := recordedAudiomack(t, map[string]string{
+		"POST /playlist?music_id=21948321,22019842&private=no&title=Road Trip": "create_playlist.json",
+	})
+	playlist := utils.Playlist{
+		Title:  "Road Trip",
+		Tracks: []utils.Track{{ID: "21948321"}, {ID: "22019842"}, {ID: "21948321"}},
+	}
+
+	// the playlist is signed with the token secret of the user, and a track is only added once.
+	playlistURL, err := client.CreatePlaylist(context.Background(), playlist, testAccessToken)
+	if err != nil {
+		t.Fatalf("CreatePlaylist() error = %v after %v", err, *requests)
+	}
+	if want := "https://audiomack.com/ada-lovelace/playlist/road-trip"; playlistURL != want {
+		t.Errorf("CreatePlaylist() = %q, want %q", playlistURL, want)
+	}
+
+	if _, err = client.CreatePlaylist(context.Background(), playlist, "user-token:revoked-secret"); !errors.Is(err, registry.ErrUnauthorized) {
+		t.Errorf("CreatePlaylist() with another token secret error = %v, want %v", err, registry.ErrUnauthorized)
+	}
+
+	*requests = nil
+	if _, err = client.CreatePlaylist(context.Background(), playlist, "user-token"); err == nil {
+		t.Error("CreatePlaylist() without the token secret error = nil, want an error")
+	}
+	if len(*requests) != 0 {
+		t.Errorf("requests = %v, want none without the token secret", *requests)
+	}
+}
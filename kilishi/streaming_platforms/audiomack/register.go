@@ -0,0 +1,47 @@
+package audiomack
+
+import (
+	"github.com/prettyirrelevant/kilishi/config"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+)
+
+// Platform is the name Audiomack is registered under.
+const Platform registry.MusicStreamingPlatform = "audiomack"
+
+func init() {
+	registry.Register(registry.Factory{
+		Name:        Platform,
+		DisplayName: "Audiomack",
+		URLMatchers: []registry.URLMatcher{
+			{Kind: registry.PlaylistURL, Pattern: playlistURLRegex},
+			{Kind: registry.AlbumURL, Pattern: albumURLRegex},
+			{Kind: registry.TrackURL, Pattern: trackURLRegex},
+		},
+		Capabilities: registry.Capabilities{GetPlaylist: true, CreatePlaylist: true, LookupTrack: true},
+		New: func(opts registry.Options) (registry.MusicStreamingPlatformInterface, error) {
+			var cfg Config
+			if err := config.Load(&cfg); err != nil {
+				return nil, err
+			}
+			// audiomack is optional, it is only enabled once the consumer key is set.
+			if err := registry.CheckCredentials(map[string]string{
+				"AUDIOMACK_CONSUMER_KEY":    cfg.ConsumerKey,
+				"AUDIOMACK_CONSUMER_SECRET": cfg.ConsumerSecret,
+			}); err != nil {
+				return nil, err
+			}
+
+			return New(&InitialisationOpts{
+				RequestClient: registry.LimitRequests(opts.RequestClient, opts.RateLimiter, Platform, registry.RateLimit{
+					RequestsPerSecond:     cfg.RateLimit,
+					Burst:                 cfg.RateLimitBurst,
+					MaxConcurrentRequests: cfg.MaxConcurrentRequests,
+				}),
+				BaseAPIURL:     cfg.BaseAPIURL,
+				ConsumerKey:    cfg.ConsumerKey,
+				ConsumerSecret: cfg.ConsumerSecret,
+				RequestTimeout: cfg.RequestTimeout,
+			}), nil
+		},
+	})
+}
@@ -0,0 +1,96 @@
+package audiomack
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/imroc/req/v3"
+)
+
+// Audiomack encapsulates all methods relating to Audiomack.
+type Audiomack struct {
+	RequestClient *req.Client
+	Config        Config
+}
+
+type InitialisationOpts struct {
+	RequestClient  *req.Client
+	BaseAPIURL     string
+	ConsumerKey    string
+	ConsumerSecret string
+	RequestTimeout time.Duration
+}
+
+type Config struct {
+	BaseAPIURL            string        `env:"AUDIOMACK_BASE_API_URL" envDefault:"https://api.audiomack.com/v1"`
+	ConsumerKey           string        `env:"AUDIOMACK_CONSUMER_KEY" envDefault:""`
+	ConsumerSecret        string        `env:"AUDIOMACK_CONSUMER_SECRET" envDefault:""`
+	RequestTimeout        time.Duration `env:"AUDIOMACK_REQUEST_TIMEOUT" envDefault:"30s"`
+	RateLimit             float64       `env:"AUDIOMACK_RATE_LIMIT" envDefault:"5"`
+	RateLimitBurst        int           `env:"AUDIOMACK_RATE_LIMIT_BURST" envDefault:"5"`
+	MaxConcurrentRequests int           `env:"AUDIOMACK_MAX_CONCURRENT_REQUESTS" envDefault:"5"`
+}
+
+// API Types (Autogenerated).
+type audiomackAPISong struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Artist   string `json:"artist"`
+	Featured string `json:"featuring"`
+	Album    string `json:"album"`
+	Duration string `json:"duration"`
+	ISRC     string `json:"isrc"`
+	URLSlug  string `json:"url_slug"`
+	ImageURL string `json:"image"`
+	// Explicit is "yes" for songs with explicit content.
+	Explicit string `json:"explicit"`
+	Uploader struct {
+		URLSlug string `json:"url_slug"`
+	} `json:"uploader"`
+}
+
+type audiomackAPIGetPlaylistResponse struct {
+	Results struct {
+		ID          string `json:"id"`
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		URLSlug     string `json:"url_slug"`
+		ImageURL    string `json:"image"`
+		Artist      struct {
+			Name    string `json:"name"`
+			URLSlug string `json:"url_slug"`
+		} `json:"artist"`
+		Tracks []audiomackAPISong `json:"tracks"`
+	} `json:"results"`
+}
+
+type audiomackAPISearchResponse struct {
+	Results []audiomackAPISong `json:"results"`
+}
+
+type audiomackAPICreatePlaylistResponse struct {
+	Results struct {
+		ID      string `json:"id"`
+		URLSlug string `json:"url_slug"`
+		Artist  struct {
+			URLSlug string `json:"url_slug"`
+		} `json:"artist"`
+	} `json:"results"`
+}
+
+type audiomackAPIError struct {
+	ErrorCode int    `json:"errorcode"`
+	Message   string `json:"message"`
+	// kind is the kind of error the platform answered with, see Unwrap.
+	kind error
+}
+
+func (e *audiomackAPIError) Error() string {
+	return fmt.Sprintf("Audiomack API Error: code: %v  reason: %s", e.ErrorCode, e.Message)
+}
+
+// Unwrap returns the kind of error the platform answered with, e.g. `registry.ErrNotFound`.
+func (e *audiomackAPIError) Unwrap() error {
+	return e.kind
+}
@@ -0,0 +1,178 @@
+package audiomack
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // OAuth 1.0a mandates HMAC-SHA1 signatures.
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imroc/req/v3"
+
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+	"github.com/prettyirrelevant/kilishi/utils"
+)
+
+const baseWebsiteURL = "https://audiomack.com/"
+
+var (
+	playlistURLRegex = regexp.MustCompile(`^https://(?:www\.)?audiomack\.com/([\w-]+)/playlist/([\w-]+)/?(?:\?.*)?$`)
+	albumURLRegex    = regexp.MustCompile(`^https://(?:www\.)?audiomack\.com/([\w-]+)/album/([\w-]+)/?(?:\?.*)?$`)
+	trackURLRegex    = regexp.MustCompile(`^https://(?:www\.)?audiomack\.com/([\w-]+)/song/([\w-]+)/?(?:\?.*)?$`)
+)
+
+// parsePlaylistURL validates an Audiomack playlist URL and returns the artist and playlist slugs.
+func parsePlaylistURL(playlistURL string) (string, string, error) {
+	matches := playlistURLRegex.FindStringSubmatch(playlistURL)
+	if len(matches) < 3 {
+		return "", "", registry.NewError(registry.ErrInvalidInput, "audiomack: playlist url is invalid. check that it follows the format https://audiomack.com/<artist>/playlist/<playlist>")
+	}
+
+	return matches[1], matches[2], nil
+}
+
+// parseSongsResponse transforms the songs returned from Audiomack API into our internal object.
+func parseSongsResponse(songs []audiomackAPISong) []utils.Track {
+	var tracks []utils.Track
+	for _, entry := range songs {
+		if entry.Type != "" && entry.Type != "song" {
+			continue
+		}
+
+		artists := []string{entry.Artist}
+		for _, featured := range strings.Split(entry.Featured, ",") {
+			if featured = strings.TrimSpace(featured); featured != "" {
+				artists = append(artists, featured)
+			}
+		}
+
+		// audiomack returns the duration in seconds as a string.
+		duration, _ := strconv.Atoi(entry.Duration)
+		tracks = append(tracks, utils.Track{
+			ID:         entry.ID,
+			Title:      utils.CleanTrackTitle(entry.Title),
+			Artists:    artists,
+			ISRC:       entry.ISRC,
+			Duration:   duration,
+			Album:      entry.Album,
+			Explicit:   entry.Explicit == "yes",
+			ArtworkURL: entry.ImageURL,
+			URL:        baseWebsiteURL + entry.Uploader.URLSlug + "/song/" + entry.URLSlug,
+		})
+	}
+
+	return tracks
+}
+
+// trackToSearchQuery transforms our internal track object into an Audiomack search query.
+func trackToSearchQuery(track utils.Track) string {
+	q := track.Title
+	for _, artistName := range track.Artists {
+		q += " " + artistName
+		break
+	}
+
+	return q
+}
+
+// splitAccessToken breaks an access token of the form `oauth_token:oauth_token_secret` into its parts.
+func splitAccessToken(accessToken string) (string, string, error) {
+	parts := strings.SplitN(accessToken, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", errors.New("audiomack: access token must take the format <oauth_token>:<oauth_token_secret>")
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// oauthAuthorizationHeader builds an OAuth 1.0a `Authorization` header for the request.
+// https://oauth.net/core/1.0a/#signing_process
+func oauthAuthorizationHeader(method, endpoint string, params map[string]string, cfg Config, token, tokenSecret string) (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	oauthParams := map[string]string{
+		"oauth_consumer_key":     cfg.ConsumerKey,
+		"oauth_nonce":            hex.EncodeToString(nonce),
+		"oauth_signature_method": "HMAC-SHA1",
+		"oauth_timestamp":        strconv.FormatInt(time.Now().Unix(), 10),
+		"oauth_version":          "1.0",
+	}
+	if token != "" {
+		oauthParams["oauth_token"] = token
+	}
+
+	var pairs []string
+	for k, v := range params {
+		pairs = append(pairs, percentEncode(k)+"="+percentEncode(v))
+	}
+	for k, v := range oauthParams {
+		pairs = append(pairs, percentEncode(k)+"="+percentEncode(v))
+	}
+	sort.Strings(pairs)
+
+	baseString := strings.Join([]string{method, percentEncode(endpoint), percentEncode(strings.Join(pairs, "&"))}, "&")
+	signingKey := percentEncode(cfg.ConsumerSecret) + "&" + percentEncode(tokenSecret)
+
+	mac := hmac.New(sha1.New, []byte(signingKey))
+	mac.Write([]byte(baseString))
+	oauthParams["oauth_signature"] = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	var headerParams []string
+	for k, v := range oauthParams {
+		headerParams = append(headerParams, fmt.Sprintf("%s=%q", percentEncode(k), percentEncode(v)))
+	}
+	sort.Strings(headerParams)
+
+	return "OAuth " + strings.Join(headerParams, ", "), nil
+}
+
+// percentEncode encodes a value as described in RFC 3986, which is what OAuth 1.0a expects.
+func percentEncode(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+func setupRequestClient(reqClient *req.Client, baseURL string) *req.Client {
+	return reqClient.
+		SetBaseURL(baseURL).
+		EnableDumpEachRequest().
+		SetCommonErrorResult(&audiomackAPIError{}).
+		OnAfterResponse(func(client *req.Client, resp *req.Response) error {
+			// There is an underlying error, e.g. network error or unmarshal error.
+			if resp.Err != nil {
+				// the platform could not be reached, see `registry.ErrUpstreamUnavailable`.
+				if resp.Response == nil {
+					resp.Err = registry.TransportError(resp.Err)
+				}
+				return nil
+			}
+			// Server returns an error message, convert it to human-readable Go error.
+			if apiErr, ok := resp.ErrorResult().(*audiomackAPIError); ok {
+				apiErr.kind = registry.ErrorKind(resp.Response)
+				resp.Err = apiErr
+				return nil
+			}
+			// Edge case: neither an error state response nor a success state response,
+			// dump content to help troubleshoot.
+			if !resp.IsSuccessState() {
+				resp.Err = registry.NewError(registry.ErrorKind(resp.Response), "bad status: %s\nraw content:\n%s", resp.Status, resp.Dump())
+				return nil
+			}
+			return nil
+		}).
+		SetCommonRetryCount(2).
+		AddCommonRetryCondition(func(resp *req.Response, err error) bool {
+			return resp.Response != nil && resp.StatusCode == 429
+		}).
+		SetCommonRetryBackoffInterval(2*time.Second, 5*time.Second)
+}
@@ -0,0 +1,173 @@
+package boomplay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+	"github.com/prettyirrelevant/kilishi/utils"
+)
+
+const maximumNumOfTracksPerRequest = 100
+
+// New initializes a `Boomplay` object.
+func New(opts *InitialisationOpts) *Boomplay {
+	return &Boomplay{
+		RequestClient: setupRequestClient(opts.RequestClient.SetTimeout(opts.RequestTimeout), opts.BaseAPIURL),
+		Config: Config{
+			BaseAPIURL:        opts.BaseAPIURL,
+			AppID:             opts.AppID,
+			AppSecret:         opts.AppSecret,
+			AuthenticationURL: opts.AuthenticationURL,
+			RequestTimeout:    opts.RequestTimeout,
+		},
+	}
+}
+
+// GetPlaylist returns information about a playlist along with all of its songs.
+func (b *Boomplay) GetPlaylist(ctx context.Context, playlistURL string) (utils.Playlist, error) {
+	playlistID, err := parsePlaylistURL(playlistURL)
+	if err != nil {
+		return utils.Playlist{}, err
+	}
+
+	clientAuthToken, err := b.getClientAuthenticationCredentials(ctx)
+	if err != nil {
+		return utils.Playlist{}, err
+	}
+
+	var response boomplayAPIGetPlaylistResponse
+	err = b.RequestClient.
+		Get("/playlists/" + playlistID).
+		SetBearerAuthToken(clientAuthToken).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return utils.Playlist{}, err
+	}
+
+	playlist := utils.Playlist{
+		ID:          strconv.Itoa(response.Data.PlaylistID),
+		Title:       response.Data.Title,
+		Description: response.Data.Description,
+		Tracks:      parseSongsResponse(response.Data.Songs),
+		Owner:       response.Data.Owner.Name,
+		ImageURL:    response.Data.Cover,
+		URL:         response.Data.ShareURL,
+	}
+
+	// the playlist object only embeds the first page of songs, the rest are paged with offset-limit.
+	for offset := len(response.Data.Songs); offset < response.Data.SongCount; offset += maximumNumOfTracksPerRequest {
+		var songsResp boomplayAPIGetPlaylistSongsResponse
+		err := b.RequestClient.
+			Get("/playlists/" + playlistID + "/songs").
+			SetBearerAuthToken(clientAuthToken).
+			SetQueryParams(map[string]string{
+				"offset": strconv.Itoa(offset),
+				"limit":  strconv.Itoa(maximumNumOfTracksPerRequest),
+			}).
+			Do(ctx).
+			Into(&songsResp)
+
+		if err != nil {
+			// the request was cancelled, do not return a partial playlist.
+			if ctx.Err() != nil {
+				return utils.Playlist{}, ctx.Err()
+			}
+			break
+		}
+
+		playlist.Tracks = append(playlist.Tracks, parseSongsResponse(songsResp.Data.Songs)...)
+		if !songsResp.Data.HasMore {
+			break
+		}
+	}
+
+	playlist.Finalise(string(Platform))
+	return playlist, nil
+}
+
+// CreatePlaylist is not supported as Boomplay's API does not allow writing to a user's library.
+func (*Boomplay) CreatePlaylist(_ context.Context, _ utils.Playlist, _ string) (string, error) {
+	return "", errors.New("boomplay: playlist creation is not supported by the boomplay api")
+}
+
+// LookupTrack searches for a track on Boomplay and returns the top result.
+func (b *Boomplay) LookupTrack(ctx context.Context, track utils.Track) (utils.Track, error) {
+	tracks, err := b.SearchTracks(ctx, track)
+	if err != nil {
+		return utils.Track{}, err
+	}
+
+	return tracks[0], nil
+}
+
+// SearchTracks returns the songs on Boomplay that match the track, best first.
+func (b *Boomplay) SearchTracks(ctx context.Context, track utils.Track) ([]utils.Track, error) {
+	clientAuthToken, err := b.getClientAuthenticationCredentials(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("boomplay: %w", err)
+	}
+
+	var response boomplayAPISearchResponse
+	err = b.RequestClient.
+		Get("/search").
+		SetBearerAuthToken(clientAuthToken).
+		SetQueryParams(map[string]string{
+			"keyword": trackToSearchQuery(track),
+			"type":    "song",
+			"limit":   "5",
+		}).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return nil, fmt.Errorf("boomplay: %w", err)
+	}
+	if len(response.Data.Songs) == 0 {
+		return nil, registry.NewError(registry.ErrNotFound, "boomplay: no track found that matches %s", track.Title)
+	}
+
+	return utils.SetMatchStrategy(parseSongsResponse(response.Data.Songs), utils.TextSearchMatch), nil
+}
+
+func (*Boomplay) GetAuthorizationCode(_ context.Context, _ string) (utils.OauthCredentials, error) {
+	return utils.OauthCredentials{}, nil // no-op
+}
+
+// RequiresAccessToken specifies if the streaming requires Oauth.
+func (*Boomplay) RequiresAccessToken() bool {
+	return false // no-op
+}
+
+// getClientAuthenticationCredentials fetches the app credentials needed for catalog requests.
+func (b *Boomplay) getClientAuthenticationCredentials(ctx context.Context) (string, error) {
+	if token, ok := utils.GlobalCache.Get("boomplayClientAuthToken"); ok {
+		if val, ok := token.(string); ok {
+			return val, nil
+		}
+
+		return "", errors.New("client authentication credentials corrupted in cache")
+	}
+
+	var response boomplayAPIClientCredentialsResponse
+	err := b.RequestClient.
+		Post(b.Config.AuthenticationURL).
+		SetBodyJsonMarshal(map[string]string{
+			"app_id":     b.Config.AppID,
+			"app_secret": b.Config.AppSecret,
+		}).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return "", err
+	}
+
+	utils.GlobalCache.Set("boomplayClientAuthToken", response.Data.AccessToken, time.Second*time.Duration(response.Data.ExpiresIn))
+	return response.Data.AccessToken, nil
+}
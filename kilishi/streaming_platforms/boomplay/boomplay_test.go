@@ -0,0 +1,214 @@
+package boomplay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/imroc/req/v3"
+
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+	"github.com/prettyirrelevant/kilishi/utils"
+)
+
+func TestGetPlaylist(t *testing.T) {
+	// responses recorded from the Boomplay Open API, keyed by the request path and decoded query.
+	responses := map[string]string{
+		"/playlists/45230181?":                         "playlist.json",
+		"/playlists/45230181/songs?limit=100&offset=2": "playlist_songs.json",
+		"/playlists/45230199?":                         "playlist_partial.json",
+		"/playlists/45230199/songs?limit=100&offset=1": "server_error.json",
+	}
+	var requests []string
+	tokenRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, status := "", http.StatusOK
+		query, _ := url.QueryUnescape(r.URL.Query().Encode())
+		switch {
+		case r.URL.Path == "/oauth/token":
+			tokenRequests++
+			var credentials map[string]string
+			if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil || credentials["app_id"] != "app-id" || credentials["app_secret"] != "app-secret" {
+				t.Errorf("app credentials = %v, %v, want the app id and secret", credentials, err)
+			}
+			name = "token.json"
+		case r.Header.Get("Authorization") != "Bearer bp-client-token":
+			name, status = "unauthorized.json", http.StatusUnauthorized
+		case responses[r.URL.Path+"?"+query] == "":
+			requests = append(requests, r.URL.Path+"?"+query)
+			name, status = "not_found.json", http.StatusNotFound
+		default:
+			requests = append(requests, r.URL.Path+"?"+query)
+			name = responses[r.URL.Path+"?"+query]
+			if name == "server_error.json" {
+				status = http.StatusInternalServerError
+			}
+		}
+
+		body, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	// the app credentials are cached, so they are requested from this server alone.
+	utils.GlobalCache.Set("boomplayClientAuthToken", nil, 0)
+	client := New(&InitialisationOpts{
+		RequestClient:     req.C(),
+		BaseAPIURL:        server.URL,
+		AppID:             "app-id",
+		AppSecret:         "app-secret",
+		AuthenticationURL: server.URL + "/oauth/token",
+		RequestTimeout:    5 * time.Second,
+	})
+
+	// the playlist embeds its first two songs, the third is paged in after them.
+	playlist, err := client.GetPlaylist(context.Background(), "https://www.boomplay.com/playlists/45230181?from=home")
+	if err != nil {
+		t.Fatalf("GetPlaylist() error = %v", err)
+	}
+	if want := []string{"/playlists/45230181?", "/playlists/45230181/songs?limit=100&offset=2"}; !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests = %v, want %v", requests, want)
+	}
+	if playlist.ID != "45230181" || playlist.Title != "Afrobeats Hot 50" || playlist.Owner != "Boomplay Music" || playlist.TrackCount != 3 {
+		t.Errorf("GetPlaylist() = %+v, attributes of the playlist are missing", playlist)
+	}
+	want := []utils.Track{
+		{
+			ID:         "105384371",
+			Title:      "Essence",
+			Artists:    []string{"Wizkid"},
+			ISRC:       "USRC12100001",
+			Duration:   248,
+			Album:      "Made In Lagos",
+			ArtworkURL: "https://source.boomplaymusic.com/group10/M00/album/17462213.jpg",
+			URL:        "https://www.boomplay.com/songs/105384371",
+			Position:   1,
+		},
+		{
+			ID:         "116478312",
+			Title:      "Last Last",
+			Artists:    []string{"Burna Boy"},
+			ISRC:       "USAT22203075",
+			Duration:   172,
+			Album:      "Love, Damini",
+			Explicit:   true,
+			ArtworkURL: "https://source.boomplaymusic.com/group10/M00/album/46719321.jpg",
+			URL:        "https://www.boomplay.com/songs/116478312",
+			Position:   2,
+		},
+		{
+			ID:         "130982214",
+			Title:      "Rush",
+			Artists:    []string{"Ayra Starr"},
+			ISRC:       "USUM72301234",
+			Duration:   185,
+			Album:      "19 & Dangerous (Deluxe)",
+			ArtworkURL: "https://source.boomplaymusic.com/group10/M00/album/55920114.jpg",
+			URL:        "https://www.boomplay.com/songs/130982214",
+			Position:   3,
+		},
+	}
+	if !reflect.DeepEqual(playlist.Tracks, want) {
+		t.Errorf("Tracks = %+v, want %+v", playlist.Tracks, want)
+	}
+
+	// a page of songs that fails to load leaves the songs fetched so far rather than failing the whole playlist.
+	playlist, err = client.GetPlaylist(context.Background(), "https://boomplay.com/playlists/45230199")
+	if err != nil {
+		t.Fatalf("GetPlaylist() error = %v", err)
+	}
+	if len(playlist.Tracks) != 1 || playlist.Tracks[0].ID != "98120447" {
+		t.Errorf("Tracks = %+v, want the embedded song alone", playlist.Tracks)
+	}
+
+	requests = nil
+	if _, err = client.GetPlaylist(context.Background(), "https://www.boomplay.com/albums/17462213"); !errors.Is(err, registry.ErrInvalidInput) {
+		t.Errorf("GetPlaylist() of an album url error = %v, want %v", err, registry.ErrInvalidInput)
+	}
+	if len(requests) != 0 {
+		t.Errorf("requests = %v, want none for an album url", requests)
+	}
+	if _, err = client.GetPlaylist(context.Background(), "https://www.boomplay.com/playlists/1"); !errors.Is(err, registry.ErrNotFound) {
+		t.Errorf("GetPlaylist() of an unknown playlist error = %v, want %v", err, registry.ErrNotFound)
+	}
+
+	if tokenRequests != 1 {
+		t.Errorf("app credentials were requested %d times, want once", tokenRequests)
+	}
+}
+
+func TestSearchTracks(t *testing.T) {
+	responses := map[string]string{
+		"/search?keyword=Essence Wizkid&limit=5&type=song":    "search.json",
+		"/search?keyword=Unreleased Nobody&limit=5&type=song": "search_empty.json",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := "token.json"
+		if r.URL.Path != "/oauth/token" {
+			query, _ := url.QueryUnescape(r.URL.Query().Encode())
+			if name = responses[r.URL.Path+"?"+query]; name == "" {
+				t.Errorf("%s?%s was not recorded", r.URL.Path, query)
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+		}
+
+		body, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	utils.GlobalCache.Set("boomplayClientAuthToken", nil, 0)
+	client := New(&InitialisationOpts{
+		RequestClient:     req.C(),
+		BaseAPIURL:        server.URL,
+		AppID:             "app-id",
+		AppSecret:         "app-secret",
+		AuthenticationURL: server.URL + "/oauth/token",
+		RequestTimeout:    5 * time.Second,
+	})
+
+	// boomplay has no ISRC lookup, the keyword is the title and the first artist alone as more artists narrow the search too much.
+	tracks, err := client.SearchTracks(context.Background(), utils.Track{Title: "Essence", Artists: []string{"Wizkid", "Tems"}, ISRC: "USRC12100001"})
+	if err != nil {
+		t.Fatalf("SearchTracks() error = %v", err)
+	}
+	if len(tracks) != 2 || tracks[0].ID != "105384371" || tracks[1].ID != "107729401" {
+		t.Fatalf("SearchTracks() = %+v, want both search results", tracks)
+	}
+	for _, track := range tracks {
+		if track.MatchStrategy != utils.TextSearchMatch {
+			t.Errorf("MatchStrategy = %q, want %q", track.MatchStrategy, utils.TextSearchMatch)
+		}
+	}
+	// the featured artists are dropped from the titles boomplay returns.
+	if want := "Essence Remix"; tracks[1].Title != want {
+		t.Errorf("Title = %q, want %q", tracks[1].Title, want)
+	}
+
+	track, err := client.LookupTrack(context.Background(), utils.Track{Title: "Essence", Artists: []string{"Wizkid"}})
+	if err != nil || track.ID != "105384371" {
+		t.Errorf("LookupTrack() = %q, %v, want the top result", track.ID, err)
+	}
+
+	if _, err = client.LookupTrack(context.Background(), utils.Track{Title: "Unreleased", Artists: []string{"Nobody"}}); !errors.Is(err, registry.ErrNotFound) {
+		t.Errorf("LookupTrack() without results error = %v, want %v", err, registry.ErrNotFound)
+	}
+}
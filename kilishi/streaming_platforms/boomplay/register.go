@@ -0,0 +1,50 @@
+package boomplay
+
+import (
+	"github.com/prettyirrelevant/kilishi/config"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+)
+
+// Platform is the name Boomplay is registered under.
+const Platform registry.MusicStreamingPlatform = "boomplay"
+
+func init() {
+	registry.Register(registry.Factory{
+		Name:        Platform,
+		DisplayName: "Boomplay",
+		URLMatchers: []registry.URLMatcher{
+			{Kind: registry.PlaylistURL, Pattern: playlistURLRegex},
+			{Kind: registry.AlbumURL, Pattern: albumURLRegex},
+			{Kind: registry.TrackURL, Pattern: trackURLRegex},
+		},
+		Capabilities: registry.Capabilities{GetPlaylist: true, LookupTrack: true},
+		New: func(opts registry.Options) (registry.MusicStreamingPlatformInterface, error) {
+			var cfg Config
+			if err := config.Load(&cfg); err != nil {
+				return nil, err
+			}
+			// boomplay is optional, it is only enabled once the partner credentials and endpoints are set.
+			if err := registry.CheckCredentials(map[string]string{
+				"BOOMPLAY_APP_ID":       cfg.AppID,
+				"BOOMPLAY_APP_SECRET":   cfg.AppSecret,
+				"BOOMPLAY_BASE_API_URL": cfg.BaseAPIURL,
+				"BOOMPLAY_AUTH_URL":     cfg.AuthenticationURL,
+			}); err != nil {
+				return nil, err
+			}
+
+			return New(&InitialisationOpts{
+				RequestClient: registry.LimitRequests(opts.RequestClient, opts.RateLimiter, Platform, registry.RateLimit{
+					RequestsPerSecond:     cfg.RateLimit,
+					Burst:                 cfg.RateLimitBurst,
+					MaxConcurrentRequests: cfg.MaxConcurrentRequests,
+				}),
+				BaseAPIURL:        cfg.BaseAPIURL,
+				AppID:             cfg.AppID,
+				AppSecret:         cfg.AppSecret,
+				AuthenticationURL: cfg.AuthenticationURL,
+				RequestTimeout:    cfg.RequestTimeout,
+			}), nil
+		},
+	})
+}
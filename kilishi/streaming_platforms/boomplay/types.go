@@ -0,0 +1,105 @@
+package boomplay
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/imroc/req/v3"
+)
+
+// Boomplay encapsulates all methods relating to Boomplay.
+// Boomplay only exposes its catalogue through its partner Open API, which has no playlist write access.
+type Boomplay struct {
+	RequestClient *req.Client
+	Config        Config
+}
+
+type InitialisationOpts struct {
+	RequestClient     *req.Client
+	BaseAPIURL        string
+	AppID             string
+	AppSecret         string
+	AuthenticationURL string
+	RequestTimeout    time.Duration
+}
+
+type Config struct {
+	BaseAPIURL            string        `env:"BOOMPLAY_BASE_API_URL" envDefault:""`
+	AppID                 string        `env:"BOOMPLAY_APP_ID" envDefault:""`
+	AppSecret             string        `env:"BOOMPLAY_APP_SECRET" envDefault:""`
+	AuthenticationURL     string        `env:"BOOMPLAY_AUTH_URL" envDefault:""`
+	RequestTimeout        time.Duration `env:"BOOMPLAY_REQUEST_TIMEOUT" envDefault:"30s"`
+	RateLimit             float64       `env:"BOOMPLAY_RATE_LIMIT" envDefault:"5"`
+	RateLimitBurst        int           `env:"BOOMPLAY_RATE_LIMIT_BURST" envDefault:"5"`
+	MaxConcurrentRequests int           `env:"BOOMPLAY_MAX_CONCURRENT_REQUESTS" envDefault:"5"`
+}
+
+// API Types (Autogenerated).
+type boomplayAPISong struct {
+	SongID   int    `json:"song_id"`
+	SongName string `json:"song_name"`
+	Duration int    `json:"duration"`
+	ISRC     string `json:"isrc"`
+	Artist   struct {
+		ArtistID int    `json:"artist_id"`
+		Name     string `json:"name"`
+	} `json:"artist"`
+	Album struct {
+		AlbumID int    `json:"album_id"`
+		Name    string `json:"name"`
+		Cover   string `json:"cover"`
+	} `json:"album"`
+	Explicit bool   `json:"explicit"`
+	ShareURL string `json:"share_url"`
+}
+
+type boomplayAPIGetPlaylistResponse struct {
+	Data struct {
+		PlaylistID  int    `json:"playlist_id"`
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		SongCount   int    `json:"song_count"`
+		Cover       string `json:"cover"`
+		ShareURL    string `json:"share_url"`
+		Owner       struct {
+			Name string `json:"name"`
+		} `json:"owner"`
+		Songs []boomplayAPISong `json:"songs"`
+	} `json:"data"`
+}
+
+type boomplayAPIGetPlaylistSongsResponse struct {
+	Data struct {
+		Songs   []boomplayAPISong `json:"songs"`
+		HasMore bool              `json:"has_more"`
+	} `json:"data"`
+}
+
+type boomplayAPISearchResponse struct {
+	Data struct {
+		Songs []boomplayAPISong `json:"songs"`
+	} `json:"data"`
+}
+
+type boomplayAPIClientCredentialsResponse struct {
+	Data struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	} `json:"data"`
+}
+
+type boomplayAPIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	// kind is the kind of error the platform answered with, see Unwrap.
+	kind error
+}
+
+func (e *boomplayAPIError) Error() string {
+	return fmt.Sprintf("Boomplay API Error: code: %v  reason: %s", e.Code, e.Message)
+}
+
+// Unwrap returns the kind of error the platform answered with, e.g. `registry.ErrNotFound`.
+func (e *boomplayAPIError) Unwrap() error {
+	return e.kind
+}
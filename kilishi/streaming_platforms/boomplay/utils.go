@@ -0,0 +1,95 @@
+package boomplay
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/imroc/req/v3"
+
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+	"github.com/prettyirrelevant/kilishi/utils"
+)
+
+var (
+	playlistURLRegex = regexp.MustCompile(`^https://(?:www\.)?boomplay\.com/playlists/(\d+)/?(?:\?.*)?$`)
+	albumURLRegex    = regexp.MustCompile(`^https://(?:www\.)?boomplay\.com/albums/(\d+)/?(?:\?.*)?$`)
+	trackURLRegex    = regexp.MustCompile(`^https://(?:www\.)?boomplay\.com/songs/(\d+)/?(?:\?.*)?$`)
+)
+
+// parsePlaylistURL validates a Boomplay playlist URL and returns the playlist ID.
+func parsePlaylistURL(playlistURL string) (string, error) {
+	matches := playlistURLRegex.FindStringSubmatch(playlistURL)
+	if len(matches) < 2 {
+		return "", registry.NewError(registry.ErrInvalidInput, "boomplay: playlist url is invalid. check that it follows the format https://www.boomplay.com/playlists/<id>")
+	}
+
+	return matches[1], nil
+}
+
+// parseSongsResponse transforms the songs returned from Boomplay API into our internal object.
+func parseSongsResponse(songs []boomplayAPISong) []utils.Track {
+	var tracks []utils.Track
+	for _, entry := range songs {
+		tracks = append(tracks, utils.Track{
+			ID:         strconv.Itoa(entry.SongID),
+			Title:      utils.CleanTrackTitle(entry.SongName),
+			Artists:    []string{entry.Artist.Name},
+			ISRC:       entry.ISRC,
+			Duration:   entry.Duration,
+			Album:      entry.Album.Name,
+			Explicit:   entry.Explicit,
+			ArtworkURL: entry.Album.Cover,
+			URL:        entry.ShareURL,
+		})
+	}
+
+	return tracks
+}
+
+// trackToSearchQuery transforms our internal track object into a Boomplay search keyword.
+func trackToSearchQuery(track utils.Track) string {
+	q := track.Title
+	for _, artistName := range track.Artists {
+		q += " " + artistName
+		break
+	}
+
+	return q
+}
+
+func setupRequestClient(reqClient *req.Client, baseURL string) *req.Client {
+	return reqClient.
+		SetBaseURL(baseURL).
+		EnableDumpEachRequest().
+		SetCommonContentType(utils.ApplicationJSON).
+		SetCommonErrorResult(&boomplayAPIError{}).
+		OnAfterResponse(func(client *req.Client, resp *req.Response) error {
+			// There is an underlying error, e.g. network error or unmarshal error.
+			if resp.Err != nil {
+				// the platform could not be reached, see `registry.ErrUpstreamUnavailable`.
+				if resp.Response == nil {
+					resp.Err = registry.TransportError(resp.Err)
+				}
+				return nil
+			}
+			// Server returns an error message, convert it to human-readable Go error.
+			if apiErr, ok := resp.ErrorResult().(*boomplayAPIError); ok {
+				apiErr.kind = registry.ErrorKind(resp.Response)
+				resp.Err = apiErr
+				return nil
+			}
+			// Edge case: neither an error state response nor a success state response,
+			// dump content to help troubleshoot.
+			if !resp.IsSuccessState() {
+				resp.Err = registry.NewError(registry.ErrorKind(resp.Response), "bad status: %s\nraw content:\n%s", resp.Status, resp.Dump())
+				return nil
+			}
+			return nil
+		}).
+		SetCommonRetryCount(2).
+		AddCommonRetryCondition(func(resp *req.Response, err error) bool {
+			return resp.Response != nil && resp.StatusCode == 429
+		}).
+		SetCommonRetryBackoffInterval(2*time.Second, 5*time.Second)
+}
@@ -1,28 +1,40 @@
 package deezer
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/url"
+	"strconv"
 	"strings"
 
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/matching"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
 	"github.com/prettyirrelevant/kilishi/utils"
 )
 
 var basePlaylistURL = "https://www.deezer.com/en/playlist/"
 
+// oauthPermissions are the permissions requested from users, to create playlists and manage their library.
+const oauthPermissions = "basic_access,manage_library,offline_access"
+
 // New initializes a `Spotify` object.
 func New(opts *InitialisationOpts) *Deezer {
 	return &Deezer{
-		RequestClient: setupRequestClient(opts.RequestClient),
+		RequestClient: setupRequestClient(opts.RequestClient.SetTimeout(opts.RequestTimeout)),
 		Config: Config{
-			AppID:             opts.AppID,
-			BaseAPIURL:        opts.BaseAPIURL,
-			ClientSecret:      opts.ClientSecret,
-			AuthenticationURL: opts.AuthenticationURL,
+			AppID:                     opts.AppID,
+			BaseAPIURL:                opts.BaseAPIURL,
+			ClientSecret:              opts.ClientSecret,
+			AuthenticationURL:         opts.AuthenticationURL,
+			AuthorizationURL:          opts.AuthorizationURL,
+			AuthenticationRedirectURL: opts.AuthenticationRedirectURL,
+			RequestTimeout:            opts.RequestTimeout,
 		},
 	}
 }
 
-func (d *Deezer) GetPlaylist(playlistURL string) (utils.Playlist, error) {
+func (d *Deezer) GetPlaylist(ctx context.Context, playlistURL string) (utils.Playlist, error) {
 	playlistID, err := parsePlaylistURL(playlistURL)
 	if err != nil {
 		return utils.Playlist{}, err
@@ -31,17 +43,19 @@ func (d *Deezer) GetPlaylist(playlistURL string) (utils.Playlist, error) {
 	var response deezerAPIGetPlaylistResponse
 	err = d.RequestClient.
 		Get(d.Config.BaseAPIURL + "/playlist/" + playlistID).
-		Do().
+		Do(ctx).
 		Into(&response)
 
 	if err != nil {
 		return utils.Playlist{}, err
 	}
 
-	return parseGetPlaylistResponse(&response), nil
+	playlist := parseGetPlaylistResponse(&response)
+	playlist.Finalise(string(Platform))
+	return playlist, nil
 }
 
-func (d *Deezer) CreatePlaylist(playlist utils.Playlist, accessToken string) (string, error) {
+func (d *Deezer) CreatePlaylist(ctx context.Context, playlist utils.Playlist, accessToken string) (string, error) {
 	var response deezerAPICreatePlaylistResponse
 	var tracksIDs []string
 
@@ -52,7 +66,7 @@ func (d *Deezer) CreatePlaylist(playlist utils.Playlist, accessToken string) (st
 			"title":        playlist.Title,
 			"access_token": accessToken,
 		}).
-		Do().
+		Do(ctx).
 		Into(&response)
 
 	if err != nil {
@@ -74,7 +88,7 @@ func (d *Deezer) CreatePlaylist(playlist utils.Playlist, accessToken string) (st
 			"access_token": accessToken,
 			"songs":        strings.Join(tracksIDs, ","),
 		}).
-		Do().
+		Do(ctx).
 		Into(&_response)
 
 	if err != nil {
@@ -84,7 +98,7 @@ func (d *Deezer) CreatePlaylist(playlist utils.Playlist, accessToken string) (st
 	return fmt.Sprintf("%s%d", basePlaylistURL, response.ID), nil
 }
 
-func (d *Deezer) GetAuthorizationCode(code string) (utils.OauthCredentials, error) {
+func (d *Deezer) GetAuthorizationCode(ctx context.Context, code string) (utils.OauthCredentials, error) {
 	var response deezerAPIBearerCredentialsResponse
 	err := d.RequestClient.
 		Get(d.Config.AuthenticationURL).
@@ -94,14 +108,34 @@ func (d *Deezer) GetAuthorizationCode(code string) (utils.OauthCredentials, erro
 			"code":   code,
 			"output": "json",
 		}).
-		Do().
+		Do(ctx).
 		Into(&response)
 
 	if err != nil {
 		return utils.OauthCredentials{}, err
 	}
 
-	return utils.OauthCredentials{AccessToken: response.AccessToken, ExpiresAt: int(response.Expires)}, nil
+	// deezer returns 0 for access tokens issued with the `offline_access` permission, they do not expire.
+	return utils.OauthCredentials{AccessToken: response.AccessToken, ExpiresAt: utils.ExpiresAt(int(response.Expires))}, nil
+}
+
+// GetAuthorizationURL returns the link users follow to grant access to their account.
+// Deezer does not send the state back, so it is added to the redirect URL instead.
+func (d *Deezer) GetAuthorizationURL(state string) (string, error) {
+	redirectURL, err := url.Parse(d.Config.AuthenticationRedirectURL)
+	if err != nil {
+		return "", fmt.Errorf("deezer: redirect url is invalid due to %s", err.Error())
+	}
+
+	redirectQuery := redirectURL.Query()
+	redirectQuery.Set("state", state)
+	redirectURL.RawQuery = redirectQuery.Encode()
+
+	query := url.Values{}
+	query.Set("app_id", d.Config.AppID)
+	query.Set("redirect_uri", redirectURL.String())
+	query.Set("perms", oauthPermissions)
+	return d.Config.AuthorizationURL + "?" + query.Encode(), nil
 }
 
 // RequiresAccessToken specifies if the streaming requires Oauth.
@@ -109,27 +143,233 @@ func (*Deezer) RequiresAccessToken() bool {
 	return true
 }
 
-func (d *Deezer) LookupTrack(track utils.Track) (utils.Track, error) {
-	var foundTrack utils.Track
+// LookupTrack searches for a track on Deezer and returns the top result.
+func (d *Deezer) LookupTrack(ctx context.Context, track utils.Track) (utils.Track, error) {
+	tracks, err := d.SearchTracks(ctx, track)
+	if err != nil {
+		return utils.Track{}, err
+	}
+
+	return tracks[0], nil
+}
+
+// SearchTracks returns the tracks on Deezer that match the track, best first.
+// An exact ISRC lookup is tried first, the title and artist search is used when it finds nothing.
+func (d *Deezer) SearchTracks(ctx context.Context, track utils.Track) ([]utils.Track, error) {
+	if isrc := matching.NormaliseISRC(track.ISRC); isrc != "" {
+		var trackResp deezerAPITrack
+		err := d.RequestClient.
+			Get(d.Config.BaseAPIURL + "/track/isrc:" + isrc).
+			SetContentType(utils.ApplicationJSON).
+			Do(ctx).
+			Into(&trackResp)
+
+		// deezer responds with a `DataException` error when no track has the ISRC, the search is used then.
+		if err != nil && !errors.Is(err, registry.ErrNotFound) {
+			return nil, fmt.Errorf("deezer: %w", err)
+		}
+		if err == nil && trackResp.ID != 0 {
+			return utils.SetMatchStrategy([]utils.Track{parseTrackResponse(&trackResp)}, utils.ISRCMatch), nil
+		}
+	}
 
 	var response deezerAPISearchTrackResponse
 	err := d.RequestClient.
 		Get(d.Config.BaseAPIURL + "/search/track").
 		SetContentType(utils.ApplicationJSON).
 		SetQueryParams(map[string]string{
-			"q": trackToSearchQuery(track),
+			"q":     trackToSearchQuery(track),
+			"limit": "5",
 		}).
-		Do().
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return nil, fmt.Errorf("deezer: %w", err)
+	}
+	if len(response.Data) == 0 {
+		return nil, registry.NewError(registry.ErrNotFound, "deezer: no track found that matches %s", track.Title)
+	}
+
+	return utils.SetMatchStrategy(parseTracksResponse(response.Data), utils.TextSearchMatch), nil
+}
+
+// TrackURL returns the Deezer link of the track with the given ID.
+func (d *Deezer) TrackURL(trackID string) string {
+	return "https://www.deezer.com/track/" + trackID
+}
+
+// GetTrack returns the track a Deezer link points at.
+func (d *Deezer) GetTrack(ctx context.Context, trackURL string) (utils.Track, error) {
+	trackID, err := parseTrackURL(trackURL)
+	if err != nil {
+		return utils.Track{}, err
+	}
+
+	var response deezerAPITrack
+	err = d.RequestClient.
+		Get(d.Config.BaseAPIURL + "/track/" + trackID).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return utils.Track{}, err
+	}
+
+	return parseTrackResponse(&response), nil
+}
+
+// GetAlbum returns the album a Deezer link points at, along with its tracks.
+func (d *Deezer) GetAlbum(ctx context.Context, albumURL string) (utils.Album, error) {
+	albumID, err := parseAlbumURL(albumURL)
+	if err != nil {
+		return utils.Album{}, err
+	}
+
+	var response deezerAPIAlbum
+	err = d.RequestClient.
+		Get(d.Config.BaseAPIURL + "/album/" + albumID).
+		Do(ctx).
 		Into(&response)
 
 	if err != nil {
-		return foundTrack, fmt.Errorf("deezer: %s", err.Error())
+		return utils.Album{}, err
 	}
 
+	album := parseAlbumResponse(&response)
+	album.Finalise(string(Platform))
+	return album, nil
+}
+
+// LookupAlbum searches for an album on Deezer and returns the top result.
+// An exact UPC lookup is tried first, the title and artist search is used when it finds nothing.
+func (d *Deezer) LookupAlbum(ctx context.Context, album utils.Album) (utils.Album, error) {
+	if album.UPC != "" {
+		var albumResp deezerAPIAlbum
+		err := d.RequestClient.
+			Get(d.Config.BaseAPIURL + "/album/upc:" + album.UPC).
+			Do(ctx).
+			Into(&albumResp)
+
+		// deezer responds with a `DataException` error when no album has the UPC.
+		if err == nil && albumResp.ID != 0 {
+			albumResp.Tracks.Data = nil
+			match := parseAlbumResponse(&albumResp)
+			match.Platform = string(Platform)
+			match.MatchStrategy = utils.UPCMatch
+			return match, nil
+		}
+	}
+
+	var response deezerAPISearchAlbumResponse
+	err := d.RequestClient.
+		Get(d.Config.BaseAPIURL + "/search/album").
+		SetQueryParams(map[string]string{
+			"q":     albumToSearchQuery(album),
+			"limit": "1",
+		}).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return utils.Album{}, fmt.Errorf("deezer: %w", err)
+	}
 	if len(response.Data) == 0 {
-		return foundTrack, fmt.Errorf("deezer: no track found that matches %s", track.Title)
+		return utils.Album{}, registry.NewError(registry.ErrNotFound, "deezer: no album found that matches %s", album.Title)
+	}
+
+	match := parseAlbumResponse(&response.Data[0])
+	match.Platform = string(Platform)
+	match.MatchStrategy = utils.TextSearchMatch
+	return match, nil
+}
+
+// SaveAlbum saves the album to the library of the owner of the access token.
+func (d *Deezer) SaveAlbum(ctx context.Context, album utils.Album, accessToken string) error {
+	var response any
+	return d.RequestClient.
+		Post(d.Config.BaseAPIURL + "/user/me/albums").
+		SetQueryParams(map[string]string{
+			"album_id":     album.ID,
+			"access_token": accessToken,
+		}).
+		Do(ctx).
+		Into(&response)
+}
+
+// GetAccount returns the account of the owner of the access token.
+func (d *Deezer) GetAccount(ctx context.Context, accessToken string) (utils.Account, error) {
+	var response deezerAPIUserResponse
+	err := d.RequestClient.
+		Get(d.Config.BaseAPIURL + "/user/me").
+		SetQueryParams(map[string]string{"access_token": accessToken}).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return utils.Account{}, err
+	}
+
+	return utils.Account{ID: strconv.Itoa(response.ID), Name: response.Name}, nil
+}
+
+// GetSavedTracks returns the tracks saved to the library of the owner of the access token, i.e. their favourite tracks.
+func (d *Deezer) GetSavedTracks(ctx context.Context, accessToken string) ([]utils.Track, error) {
+	var tracks []utils.Track
+
+	// deezer returns the saved tracks a page at a time, this while loop gets every page.
+	for index := 0; ; {
+		var response deezerAPISavedTracksResponse
+		err := d.RequestClient.
+			Get(d.Config.BaseAPIURL + "/user/me/tracks").
+			SetQueryParams(map[string]string{
+				"access_token": accessToken,
+				"index":        strconv.Itoa(index),
+				"limit":        "100",
+			}).
+			Do(ctx).
+			Into(&response)
+
+		if err != nil {
+			return nil, err
+		}
+
+		tracks = append(tracks, parseTracksResponse(response.Data)...)
+		if response.Next == "" || len(response.Data) == 0 {
+			break
+		}
+		index += len(response.Data)
+	}
+
+	return tracks, nil
+}
+
+// SaveTracks adds the tracks to the favourite tracks of the owner of the access token.
+// Deezer only accepts a track at a time, so they are saved one after the other.
+func (d *Deezer) SaveTracks(ctx context.Context, tracks []utils.Track, accessToken string) error {
+	var trackIDs []string
+	for _, entry := range tracks {
+		if ok := utils.Contains(trackIDs, entry.ID); !ok {
+			trackIDs = append(trackIDs, entry.ID)
+		}
+	}
+
+	for i, trackID := range trackIDs {
+		var response any
+		err := d.RequestClient.
+			Post(d.Config.BaseAPIURL + "/user/me/tracks").
+			SetQueryParams(map[string]string{
+				"track_id":     trackID,
+				"access_token": accessToken,
+			}).
+			Do(ctx).
+			Into(&response)
+
+		if err != nil {
+			return err
+		}
+		registry.ReportProgress(ctx, i+1, len(trackIDs))
 	}
 
-	foundTrack = parseSearchTracksResponse(response)[0]
-	return foundTrack, nil
+	return nil
 }
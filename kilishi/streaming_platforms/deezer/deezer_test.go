@@ -0,0 +1,89 @@
+package deezer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/imroc/req/v3"
+
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+	"github.com/prettyirrelevant/kilishi/utils"
+)
+
+func TestSearchTracks(t *testing.T) {
+	// responses recorded from the Deezer API, which answers its errors with a 200 status.
+	responses := map[string]string{
+		"/track/isrc:USRC12100001": "track_isrc.json",
+		"/track/isrc:USRC12100009": "no_data.json",
+		"/track/isrc:USRC12100042": "quota_exceeded.json",
+		"/search/track":            "search_track.json",
+	}
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+
+		body, err := os.ReadFile(filepath.Join("testdata", responses[r.URL.Path]))
+		if err != nil {
+			t.Errorf("%s: %v", r.URL.Path, err)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	client := New(&InitialisationOpts{RequestClient: req.C(), BaseAPIURL: server.URL, RequestTimeout: 5 * time.Second})
+	// the quota is retried after a few seconds otherwise.
+	client.RequestClient.SetCommonRetryCount(0)
+
+	// a dashed ISRC, as some platforms return them, is looked up as it is stored.
+	tracks, err := client.SearchTracks(context.Background(), utils.Track{Title: "Essence", Artists: []string{"Wizkid"}, ISRC: "us-rc1-21-00001"})
+	if err != nil {
+		t.Fatalf("SearchTracks() error = %v", err)
+	}
+	want := []utils.Track{{
+		ID:            "1175658622",
+		Title:         "Essence",
+		Artists:       []string{"Wizkid", "Tems"},
+		ISRC:          "USRC12100001",
+		Duration:      248,
+		Album:         "Made In Lagos",
+		ArtworkURL:    "https://e-cdns-images.dzcdn.net/images/cover/2b0e6e332fdf4b7a91164da3162127b5/1000x1000-000000-80-0-0.jpg",
+		URL:           "https://www.deezer.com/track/1175658622",
+		MatchStrategy: utils.ISRCMatch,
+	}}
+	if !reflect.DeepEqual(tracks, want) || !reflect.DeepEqual(requests, []string{"/track/isrc:USRC12100001"}) {
+		t.Errorf("SearchTracks() = %+v after %v, want %+v from the ISRC lookup alone", tracks, requests, want)
+	}
+
+	// no track has the ISRC, so the search is used.
+	requests = nil
+	tracks, err = client.SearchTracks(context.Background(), utils.Track{Title: "Essence", Artists: []string{"Wizkid"}, ISRC: "USRC12100009"})
+	if err != nil {
+		t.Fatalf("SearchTracks() error = %v", err)
+	}
+	if len(tracks) != 2 || tracks[1].ID != "1433561772" || tracks[1].MatchStrategy != utils.TextSearchMatch {
+		t.Errorf("SearchTracks() = %+v, want both search results", tracks)
+	}
+	if want := []string{"/track/isrc:USRC12100009", "/search/track"}; !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests = %v, want %v", requests, want)
+	}
+
+	// the search would be refused too, so the quota is reported rather than hidden behind a weaker match.
+	requests = nil
+	_, err = client.SearchTracks(context.Background(), utils.Track{Title: "Essence", ISRC: "USRC12100042"})
+	if !errors.Is(err, registry.ErrRateLimited) {
+		t.Errorf("SearchTracks() error = %v, want %v", err, registry.ErrRateLimited)
+	}
+	if len(requests) != 1 {
+		t.Errorf("requests = %v, want only the ISRC lookup", requests)
+	}
+}
@@ -0,0 +1,43 @@
+package deezer
+
+import (
+	"github.com/prettyirrelevant/kilishi/config"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+)
+
+// Platform is the name Deezer is registered under.
+const Platform registry.MusicStreamingPlatform = "deezer"
+
+func init() {
+	registry.Register(registry.Factory{
+		Name:        Platform,
+		DisplayName: "Deezer",
+		URLMatchers: []registry.URLMatcher{
+			{Kind: registry.PlaylistURL, Pattern: playlistURLRegex},
+			{Kind: registry.AlbumURL, Pattern: albumURLRegex},
+			{Kind: registry.TrackURL, Pattern: trackURLRegex},
+		},
+		Capabilities: registry.Capabilities{GetPlaylist: true, CreatePlaylist: true, LookupTrack: true, GetTrack: true, Albums: true, SaveAlbum: true, Library: true, Oauth: true},
+		New: func(opts registry.Options) (registry.MusicStreamingPlatformInterface, error) {
+			var cfg Config
+			if err := config.Load(&cfg); err != nil {
+				return nil, err
+			}
+
+			return New(&InitialisationOpts{
+				RequestClient: registry.LimitRequests(opts.RequestClient, opts.RateLimiter, Platform, registry.RateLimit{
+					RequestsPerSecond:     cfg.RateLimit,
+					Burst:                 cfg.RateLimitBurst,
+					MaxConcurrentRequests: cfg.MaxConcurrentRequests,
+				}),
+				BaseAPIURL:                cfg.BaseAPIURL,
+				AppID:                     cfg.AppID,
+				ClientSecret:              cfg.ClientSecret,
+				AuthenticationURL:         cfg.AuthenticationURL,
+				AuthorizationURL:          cfg.AuthorizationURL,
+				AuthenticationRedirectURL: cfg.AuthenticationRedirectURL,
+				RequestTimeout:            cfg.RequestTimeout,
+			}), nil
+		},
+	})
+}
@@ -2,6 +2,7 @@ package deezer
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/imroc/req/v3"
 )
@@ -12,18 +13,28 @@ type Deezer struct {
 }
 
 type InitialisationOpts struct {
-	RequestClient     *req.Client
-	AppID             string
-	BaseAPIURL        string
-	ClientSecret      string
-	AuthenticationURL string
+	RequestClient             *req.Client
+	AppID                     string
+	BaseAPIURL                string
+	ClientSecret              string
+	AuthenticationURL         string
+	AuthorizationURL          string
+	AuthenticationRedirectURL string
+	RequestTimeout            time.Duration
 }
 
 type Config struct {
-	BaseAPIURL        string
-	AppID             string
-	ClientSecret      string
-	AuthenticationURL string
+	BaseAPIURL                string        `env:"DEEZER_BASE_API_URL,notEmpty"`
+	AppID                     string        `env:"DEEZER_APP_ID,notEmpty"`
+	ClientSecret              string        `env:"DEEZER_CLIENT_SECRET,notEmpty"`
+	AuthenticationURL         string        `env:"DEEZER_AUTHENTICATION_URL,notEmpty"`
+	AuthorizationURL          string        `env:"DEEZER_AUTHORIZATION_URL" envDefault:"https://connect.deezer.com/oauth/auth.php"`
+	AuthenticationRedirectURL string        `env:"DEEZER_AUTH_REDIRECT_URL,notEmpty"`
+	RequestTimeout            time.Duration `env:"DEEZER_REQUEST_TIMEOUT" envDefault:"30s"`
+	// Deezer allows 50 requests every 5 seconds, the requests are paced a little below it.
+	RateLimit             float64 `env:"DEEZER_RATE_LIMIT" envDefault:"8"`
+	RateLimitBurst        int     `env:"DEEZER_RATE_LIMIT_BURST" envDefault:"5"`
+	MaxConcurrentRequests int     `env:"DEEZER_MAX_CONCURRENT_REQUESTS" envDefault:"10"`
 }
 
 // API Types (Autogenerated).
@@ -31,6 +42,9 @@ type deezerAPIGetPlaylistResponse struct {
 	ID          int    `json:"id"`
 	Title       string `json:"title"`
 	Description string `json:"description"`
+	Link        string `json:"link"`
+	PictureXL   string `json:"picture_xl"`
+	NbTracks    int    `json:"nb_tracks"`
 	Creator     struct {
 		ID        int    `json:"id"`
 		Name      string `json:"name"`
@@ -39,38 +53,84 @@ type deezerAPIGetPlaylistResponse struct {
 	} `json:"creator"`
 	Type   string `json:"type"`
 	Tracks struct {
-		Data []struct {
-			ID     int    `json:"id"`
-			Title  string `json:"title"`
-			Artist struct {
-				ID   int    `json:"id"`
-				Name string `json:"name"`
-			} `json:"artist"`
-		} `json:"data"`
+		Data []deezerAPITrack `json:"data"`
 	} `json:"tracks"`
 }
 
+type deezerAPITrack struct {
+	ID             int    `json:"id"`
+	Title          string `json:"title"`
+	TitleShort     string `json:"title_short"`
+	TitleVersion   string `json:"title_version"`
+	ISRC           string `json:"isrc"`
+	Link           string `json:"link"`
+	Duration       int    `json:"duration"`
+	Rank           int    `json:"rank"`
+	ExplicitLyrics bool   `json:"explicit_lyrics"`
+	Album          struct {
+		Title   string `json:"title"`
+		CoverXL string `json:"cover_xl"`
+	} `json:"album"`
+	Artist struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"artist"`
+	// Contributors is only returned when a single track is requested.
+	Contributors []struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"contributors"`
+	Type string `json:"type"`
+}
+
+type deezerAPIAlbum struct {
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	UPC         string `json:"upc"`
+	Link        string `json:"link"`
+	CoverXL     string `json:"cover_xl"`
+	NbTracks    int    `json:"nb_tracks"`
+	ReleaseDate string `json:"release_date"`
+	Artist      struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"artist"`
+	// Contributors and Tracks are only returned when a single album is requested.
+	Contributors []struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"contributors"`
+	Tracks struct {
+		Data []deezerAPITrack `json:"data"`
+	} `json:"tracks"`
+	Type string `json:"type"`
+}
+
+type deezerAPISearchAlbumResponse struct {
+	Data  []deezerAPIAlbum `json:"data"`
+	Total int              `json:"total"`
+}
+
 type deezerAPISearchTrackResponse struct {
-	Data []struct {
-		ID           int    `json:"id"`
-		Title        string `json:"title"`
-		TitleShort   string `json:"title_short"`
-		TitleVersion string `json:"title_version"`
-		Duration     int    `json:"duration"`
-		Rank         int    `json:"rank"`
-		Artist       struct {
-			ID   int    `json:"id"`
-			Name string `json:"name"`
-		} `json:"artist"`
-		Type string `json:"type"`
-	} `json:"data"`
-	Total int `json:"total"`
+	Data  []deezerAPITrack `json:"data"`
+	Total int              `json:"total"`
+}
+
+type deezerAPISavedTracksResponse struct {
+	Data  []deezerAPITrack `json:"data"`
+	Total int              `json:"total"`
+	Next  string           `json:"next"`
 }
 
 type deezerAPICreatePlaylistResponse struct {
 	ID int `json:"id"`
 }
 
+type deezerAPIUserResponse struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
 type deezerAPIBearerCredentialsResponse struct {
 	AccessToken string `json:"access_token"`
 	Expires     int64  `json:"expires"`
@@ -82,8 +142,15 @@ type deezerAPIError struct {
 		Message string `json:"message"`
 		Code    int    `json:"code"`
 	} `json:"error"`
+	// kind is the kind of error the platform answered with, see Unwrap.
+	kind error
 }
 
 func (e *deezerAPIError) Error() string {
 	return fmt.Sprintf("Deezer API Error: code %v type: %s  reason: %s", e.APIError.Code, e.APIError.Type, e.APIError.Message)
 }
+
+// Unwrap returns the kind of error the platform answered with, e.g. `registry.ErrNotFound`.
+func (e *deezerAPIError) Unwrap() error {
+	return e.kind
+}
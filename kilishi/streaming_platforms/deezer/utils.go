@@ -2,6 +2,7 @@ package deezer
 
 import (
 	"fmt"
+	"net/http"
 	"regexp"
 	"strconv"
 	"strings"
@@ -9,20 +10,57 @@ import (
 
 	"github.com/imroc/req/v3"
 
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
 	"github.com/prettyirrelevant/kilishi/utils"
 )
 
+var (
+	playlistURLRegex = regexp.MustCompile(`^https://(?:www\.)?deezer\.com/(?:[a-z]{2}/)?playlist/(\d+)/?(?:\?.*)?$`)
+	albumURLRegex    = regexp.MustCompile(`^https://(?:www\.)?deezer\.com/(?:[a-z]{2}/)?album/(\d+)/?(?:\?.*)?$`)
+	trackURLRegex    = regexp.MustCompile(`^https://(?:www\.)?deezer\.com/(?:[a-z]{2}/)?track/(\d+)/?(?:\?.*)?$`)
+)
+
 // parsePlaylistURL validates a Deezer playlist URL and returns the playlist ID.
 func parsePlaylistURL(playlistURL string) (string, error) {
-	re := regexp.MustCompile(`^https://www\.deezer\.com/../playlist/(\d+)$`)
-	matches := re.FindStringSubmatch(playlistURL)
+	matches := playlistURLRegex.FindStringSubmatch(playlistURL)
+	if len(matches) < 2 {
+		return "", registry.NewError(registry.ErrInvalidInput, "deezer: playlist url is invalid. check that it follows the format https://www.deezer.com/<country_code>/playlist/<id>")
+	}
+
+	return matches[1], nil
+}
+
+// parseTrackURL validates a Deezer track URL and returns the track ID.
+func parseTrackURL(trackURL string) (string, error) {
+	matches := trackURLRegex.FindStringSubmatch(trackURL)
+	if len(matches) < 2 {
+		return "", registry.NewError(registry.ErrInvalidInput, "deezer: track url is invalid. check that it follows the format https://www.deezer.com/<country_code>/track/<id>")
+	}
+
+	return matches[1], nil
+}
+
+// parseAlbumURL validates a Deezer album URL and returns the album ID.
+func parseAlbumURL(albumURL string) (string, error) {
+	matches := albumURLRegex.FindStringSubmatch(albumURL)
 	if len(matches) < 2 {
-		return "", fmt.Errorf("deezer: playlist url is invalid. check that it follows the format https://www.deezer.com/<country_code>/playlist/<id>")
+		return "", registry.NewError(registry.ErrInvalidInput, "deezer: album url is invalid. check that it follows the format https://www.deezer.com/<country_code>/album/<id>")
 	}
 
 	return matches[1], nil
 }
 
+// albumToSearchQuery transforms our internal album object into a Deezer search query.
+func albumToSearchQuery(album utils.Album) string {
+	q := fmt.Sprintf("album:%q", album.Title)
+	for _, artistName := range album.Artists {
+		q += fmt.Sprintf(" artist:%q", artistName)
+		break
+	}
+
+	return q
+}
+
 // trackToSearchQuery transforms our internal track object into a Deezer search query.
 func trackToSearchQuery(track utils.Track) string {
 	q := fmt.Sprintf("track:%q", track.Title)
@@ -36,40 +74,107 @@ func trackToSearchQuery(track utils.Track) string {
 
 // parseGetPlaylistResponse transforms the playlist object returned from Deezer API into our internal object.
 func parseGetPlaylistResponse(response *deezerAPIGetPlaylistResponse) utils.Playlist {
-	tracks := parsePlaylistTracksResponse(response)
+	tracks := parseTracksResponse(response.Tracks.Data)
 	return utils.Playlist{
 		ID:          strconv.Itoa(response.ID),
 		Title:       response.Title,
 		Description: response.Description,
 		Tracks:      tracks,
+		Owner:       response.Creator.Name,
+		ImageURL:    response.PictureXL,
+		URL:         response.Link,
 	}
 }
-func parsePlaylistTracksResponse(data *deezerAPIGetPlaylistResponse) []utils.Track {
-	var tracks []utils.Track
-	for _, track := range data.Tracks.Data {
-		tracks = append(tracks, utils.Track{
-			ID:      strconv.Itoa(track.ID),
-			Title:   utils.CleanTrackTitle(track.Title),
-			Artists: []string{track.Artist.Name},
-		})
+
+// parseAlbumResponse transforms an album returned from Deezer API into our internal object.
+func parseAlbumResponse(album *deezerAPIAlbum) utils.Album {
+	artists := []string{}
+	for _, contributor := range album.Contributors {
+		artists = append(artists, contributor.Name)
+	}
+	if len(artists) == 0 {
+		artists = append(artists, album.Artist.Name)
 	}
 
-	return tracks
+	// the tracks of an album do not repeat the album they belong to.
+	tracks := parseTracksResponse(album.Tracks.Data)
+	for i := range tracks {
+		tracks[i].Album = album.Title
+		tracks[i].ArtworkURL = album.CoverXL
+	}
+
+	return utils.Album{
+		ID:          strconv.Itoa(album.ID),
+		Title:       album.Title,
+		Artists:     artists,
+		Tracks:      tracks,
+		UPC:         album.UPC,
+		ReleaseDate: album.ReleaseDate,
+		ImageURL:    album.CoverXL,
+		URL:         album.Link,
+		TrackCount:  album.NbTracks,
+	}
 }
 
-func parseSearchTracksResponse(data deezerAPISearchTrackResponse) []utils.Track {
+// parseTracksResponse transforms the tracks returned from Deezer API into our internal object.
+func parseTracksResponse(data []deezerAPITrack) []utils.Track {
 	var tracks []utils.Track
-	for _, track := range data.Data {
-		tracks = append(tracks, utils.Track{
-			ID:      strconv.Itoa(track.ID),
-			Title:   utils.CleanTrackTitle(track.Title),
-			Artists: []string{track.Artist.Name},
-		})
+	for i := range data {
+		tracks = append(tracks, parseTrackResponse(&data[i]))
 	}
 
 	return tracks
 }
 
+// parseTrackResponse transforms a single track returned from Deezer API into our internal object.
+func parseTrackResponse(track *deezerAPITrack) utils.Track {
+	artists := []string{}
+	for _, contributor := range track.Contributors {
+		artists = append(artists, contributor.Name)
+	}
+	if len(artists) == 0 {
+		artists = append(artists, track.Artist.Name)
+	}
+
+	return utils.Track{
+		ID:         strconv.Itoa(track.ID),
+		Title:      utils.CleanTrackTitle(track.Title),
+		Artists:    artists,
+		ISRC:       track.ISRC,
+		Duration:   track.Duration,
+		Album:      track.Album.Title,
+		Explicit:   track.ExplicitLyrics,
+		ArtworkURL: track.Album.CoverXL,
+		URL:        track.Link,
+	}
+}
+
+// deezerErrorKind returns the kind of error deezer answered with, it mostly answers errors with a 200 status so they are told apart by their code.
+// https://developers.deezer.com/api/errors
+func deezerErrorKind(apiErr *deezerAPIError, resp *http.Response) error {
+	switch apiErr.APIError.Code {
+	case 4:
+		return &registry.RateLimitError{}
+	case 200:
+		// the access token is valid but lacks the permission, e.g. `manage_library`.
+		return registry.ErrForbidden
+	case 300:
+		return registry.ErrUnauthorized
+	case 500, 501, 600:
+		return registry.ErrInvalidInput
+	case 700:
+		return registry.ErrUpstreamUnavailable
+	case 800:
+		return registry.ErrNotFound
+	}
+
+	// deezer answers requests made with an expired or revoked access token with an oauth exception.
+	if apiErr.APIError.Type == "OAuthException" {
+		return registry.ErrUnauthorized
+	}
+	return registry.ErrorKind(resp)
+}
+
 func setupRequestClient(reqClient *req.Client) *req.Client {
 	return reqClient.
 		EnableDumpEachRequest().
@@ -86,17 +191,22 @@ func setupRequestClient(reqClient *req.Client) *req.Client {
 		OnAfterResponse(func(client *req.Client, resp *req.Response) error {
 			// There is an underlying error, e.g. network error or unmarshal error.
 			if resp.Err != nil {
+				// the platform could not be reached, see `registry.ErrUpstreamUnavailable`.
+				if resp.Response == nil {
+					resp.Err = registry.TransportError(resp.Err)
+				}
 				return nil
 			}
 			// Server returns an error message, convert it to human-readable Go error.
 			if apiErr, ok := resp.ErrorResult().(*deezerAPIError); ok {
+				apiErr.kind = deezerErrorKind(apiErr, resp.Response)
 				resp.Err = apiErr
 				return nil
 			}
 			// Edge case: neither an error state response nor a success state response,
 			// dump content to help troubleshoot.
 			if !resp.IsSuccessState() {
-				resp.Err = fmt.Errorf("bad status: %s\nraw content:\n%s", resp.Status, resp.Dump())
+				resp.Err = registry.NewError(registry.ErrorKind(resp.Response), "bad status: %s\nraw content:\n%s", resp.Status, resp.Dump())
 				return nil
 			}
 			return nil
@@ -0,0 +1,146 @@
+package matching
+
+import (
+	"math"
+	"sort"
+
+	"github.com/prettyirrelevant/kilishi/utils"
+)
+
+// Weights of the signals used to score a candidate, the weights of the signals missing on either track are
+// left out and the remaining ones are scaled so the confidence still ranges from 0 to 1.
+const (
+	titleWeight    = 0.45
+	artistsWeight  = 0.35
+	durationWeight = 0.15
+	albumWeight    = 0.05
+)
+
+// Weights of the signals used to score an album candidate, see the track weights above.
+const (
+	albumTitleWeight      = 0.55
+	albumArtistsWeight    = 0.35
+	albumTrackCountWeight = 0.10
+)
+
+// A difference in duration below durationTolerance is ignored, the score then drops linearly and
+// candidates that differ by maximumDurationDelta or more get nothing.
+const (
+	durationTolerance    = 2
+	maximumDurationDelta = 30
+)
+
+// Rank scores every candidate against the source track and returns them with the best match first.
+// The confidence of each candidate is recorded on it.
+func Rank(source utils.Track, candidates []utils.Track) []utils.Track {
+	ranked := make([]utils.Track, 0, len(candidates))
+	for _, candidate := range candidates {
+		candidate.Confidence = Score(source, candidate)
+		ranked = append(ranked, candidate)
+	}
+
+	// a stable sort keeps the platform's own order for candidates that score the same.
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Confidence > ranked[j].Confidence
+	})
+
+	return ranked
+}
+
+// Score returns how confident we are that the candidate is the same recording as the source track, from 0 to 1.
+func Score(source, candidate utils.Track) float64 {
+	// an ISRC that normalises to nothing, e.g. "-", must not match the candidates that have none.
+	if isrc := NormaliseISRC(source.ISRC); isrc != "" && isrc == NormaliseISRC(candidate.ISRC) {
+		return 1
+	}
+
+	var score, totalWeight float64
+
+	score += titleWeight * titleSimilarity(source.Title, candidate.Title)
+	totalWeight += titleWeight
+
+	if len(source.Artists) > 0 && len(candidate.Artists) > 0 {
+		score += artistsWeight * artistsOverlap(source.Artists, candidate.Artists)
+		totalWeight += artistsWeight
+	}
+
+	if source.Duration > 0 && candidate.Duration > 0 {
+		score += durationWeight * durationScore(source.Duration, candidate.Duration)
+		totalWeight += durationWeight
+	}
+
+	if source.Album != "" && candidate.Album != "" {
+		score += albumWeight * titleSimilarity(source.Album, candidate.Album)
+		totalWeight += albumWeight
+	}
+
+	return math.Round(score/totalWeight*1000) / 1000
+}
+
+// ScoreAlbum returns how confident we are that the candidate is the same release as the source album, from 0 to 1.
+func ScoreAlbum(source, candidate utils.Album) float64 {
+	// a UPC made of zeros normalises to nothing and must not match the candidates that have none.
+	if upc := NormaliseUPC(source.UPC); upc != "" && upc == NormaliseUPC(candidate.UPC) {
+		return 1
+	}
+
+	var score, totalWeight float64
+
+	score += albumTitleWeight * titleSimilarity(source.Title, candidate.Title)
+	totalWeight += albumTitleWeight
+
+	if len(source.Artists) > 0 && len(candidate.Artists) > 0 {
+		score += albumArtistsWeight * artistsOverlap(source.Artists, candidate.Artists)
+		totalWeight += albumArtistsWeight
+	}
+
+	// deluxe editions and regional releases often have a few more tracks, so the counts only need to be close.
+	if source.TrackCount > 0 && candidate.TrackCount > 0 {
+		score += albumTrackCountWeight * trackCountScore(source.TrackCount, candidate.TrackCount)
+		totalWeight += albumTrackCountWeight
+	}
+
+	return math.Round(score/totalWeight*1000) / 1000
+}
+
+// artistsOverlap returns the fraction of the source artists that are credited on the candidate.
+func artistsOverlap(sourceArtists, candidateArtists []string) float64 {
+	var matched int
+	for _, sourceArtist := range sourceArtists {
+		for _, candidateArtist := range candidateArtists {
+			if similarity(normaliseName(sourceArtist), normaliseName(candidateArtist)) >= 0.85 {
+				matched++
+				break
+			}
+		}
+	}
+
+	return float64(matched) / float64(len(sourceArtists))
+}
+
+// durationScore compares the lengths of two tracks in seconds.
+func durationScore(source, candidate int) float64 {
+	delta := source - candidate
+	if delta < 0 {
+		delta = -delta
+	}
+
+	switch {
+	case delta <= durationTolerance:
+		return 1
+	case delta >= maximumDurationDelta:
+		return 0
+	default:
+		return 1 - float64(delta-durationTolerance)/float64(maximumDurationDelta-durationTolerance)
+	}
+}
+
+// trackCountScore compares the number of tracks of two albums.
+func trackCountScore(source, candidate int) float64 {
+	delta, largest := source-candidate, source
+	if delta < 0 {
+		delta, largest = -delta, candidate
+	}
+
+	return 1 - float64(delta)/float64(largest)
+}
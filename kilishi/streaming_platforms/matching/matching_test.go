@@ -0,0 +1,265 @@
+package matching
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prettyirrelevant/kilishi/utils"
+)
+
+func TestNormaliseTitle(t *testing.T) {
+	testCases := []struct {
+		title string
+		want  string
+	}{
+		{title: "Essence (feat. Tems)", want: "essence"},
+		{title: "Peru ft Ed Sheeran", want: "peru"},
+		{title: "Peru [Ft. Ed Sheeran]", want: "peru"},
+		{title: "Come Together - Remastered 2009", want: "come together remastered 2009"},
+		{title: "Come Together (Remastered 2009)", want: "come together"},
+		{title: "Calm Down (Remix)", want: "calm down remix"},
+		{title: "Ye & Me", want: "ye and me"},
+		{title: "Soft Life", want: "soft life"},
+		// titles that start with the keyword keep it, the featured artists that follow are still stripped.
+		{title: "Featuring You", want: "featuring you"},
+		{title: "Feat. Me (feat. Tems)", want: "feat me"},
+		{title: "Ft. Lauderdale", want: "ft lauderdale"},
+		// nothing would be left of these, so they are compared as they are.
+		{title: "(feat. Tems)", want: "feat tems"},
+		{title: "(Remastered)", want: "remastered"},
+		{title: "?", want: ""},
+		{title: "", want: ""},
+	}
+
+	for _, tc := range testCases {
+		if got := normaliseTitle(tc.title); got != tc.want {
+			t.Errorf("normaliseTitle(%q) = %q, want %q", tc.title, got, tc.want)
+		}
+	}
+}
+
+func TestNormaliseIdentifiers(t *testing.T) {
+	testCases := []struct {
+		name      string
+		normalise func(string) string
+		value     string
+		want      string
+	}{
+		{name: "isrc", normalise: NormaliseISRC, value: "USUM72300001", want: "USUM72300001"},
+		{name: "isrc with dashes", normalise: NormaliseISRC, value: " us-um7-23-00001 ", want: "USUM72300001"},
+		{name: "isrc of dashes", normalise: NormaliseISRC, value: "--", want: ""},
+		{name: "upc", normalise: NormaliseUPC, value: "602445790883", want: "602445790883"},
+		{name: "ean", normalise: NormaliseUPC, value: "0602445790883", want: "602445790883"},
+		{name: "upc of zeros", normalise: NormaliseUPC, value: "000", want: ""},
+		{name: "blank upc", normalise: NormaliseUPC, value: "  ", want: ""},
+	}
+
+	for _, tc := range testCases {
+		if got := tc.normalise(tc.value); got != tc.want {
+			t.Errorf("%s: normalise(%q) = %q, want %q", tc.name, tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	essence := utils.Track{
+		Title:    "Essence (feat. Tems)",
+		Artists:  []string{"Wizkid", "Tems"},
+		ISRC:     "USRC12100001",
+		Duration: 248,
+		Album:    "Made In Lagos",
+	}
+
+	testCases := []struct {
+		name      string
+		source    utils.Track
+		candidate utils.Track
+		want      float64
+	}{
+		{
+			name:      "same isrc",
+			source:    essence,
+			candidate: utils.Track{Title: "Something Else", ISRC: "usrc1-21-00001"},
+			want:      1,
+		},
+		{
+			name:      "same recording without isrc",
+			source:    utils.Track{Title: "Essence", Artists: []string{"Wizkid", "Tems"}, Duration: 248, Album: "Made In Lagos"},
+			candidate: utils.Track{Title: "Essence (feat. Tems)", Artists: []string{"WizKid", "Tems"}, Duration: 249, Album: "Made in Lagos (Deluxe Edition)"},
+			want:      0.973,
+		},
+		{
+			name:      "different isrc falls back to the metadata",
+			source:    essence,
+			candidate: utils.Track{Title: "Essence", Artists: []string{"Wizkid"}, ISRC: "USRC12100002", Duration: 248, Album: "Made In Lagos"},
+			want:      0.825,
+		},
+		{
+			name:      "duration far off",
+			source:    utils.Track{Title: "Essence", Artists: []string{"Wizkid"}, Duration: 248},
+			candidate: utils.Track{Title: "Essence", Artists: []string{"Wizkid"}, Duration: 300},
+			want:      0.842,
+		},
+		{
+			name:      "missing signals are left out",
+			source:    utils.Track{Title: "Essence"},
+			candidate: utils.Track{Title: "Essence", Artists: []string{"Wizkid"}, Duration: 248},
+			want:      1,
+		},
+		{
+			name:      "different song",
+			source:    utils.Track{Title: "Essence", Artists: []string{"Wizkid"}, Duration: 248},
+			candidate: utils.Track{Title: "Last Last", Artists: []string{"Burna Boy"}, Duration: 172},
+			want:      0.053,
+		},
+		{
+			name:      "blank isrc does not match a candidate without one",
+			source:    utils.Track{Title: "Essence", ISRC: "-"},
+			candidate: utils.Track{Title: "Last Last"},
+			want:      0.111,
+		},
+		{
+			name:      "titles that normalise to nothing and differ",
+			source:    utils.Track{Title: "?", Artists: []string{"Wizkid"}},
+			candidate: utils.Track{Title: "!", Artists: []string{"Wizkid"}},
+			want:      0.437,
+		},
+		{
+			name:      "titles that normalise to nothing and are the same",
+			source:    utils.Track{Title: "?", Artists: []string{"Wizkid"}},
+			candidate: utils.Track{Title: "?", Artists: []string{"Wizkid"}},
+			want:      1,
+		},
+		{
+			name:      "empty titles",
+			source:    utils.Track{Artists: []string{"Wizkid"}},
+			candidate: utils.Track{Artists: []string{"Wizkid"}},
+			want:      0.437,
+		},
+		{
+			name:      "title that starts with featuring",
+			source:    utils.Track{Title: "Featuring You", Artists: []string{"Wizkid"}},
+			candidate: utils.Track{Title: "Essence (feat. Tems)", Artists: []string{"Wizkid"}},
+			want:      0.524,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Score(tc.source, tc.candidate); got != tc.want {
+				t.Errorf("Score() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestScoreAlbum(t *testing.T) {
+	madeInLagos := utils.Album{Title: "Made In Lagos", Artists: []string{"Wizkid"}, UPC: "194398181224", TrackCount: 14}
+
+	testCases := []struct {
+		name      string
+		source    utils.Album
+		candidate utils.Album
+		want      float64
+	}{
+		{
+			name:      "same upc as an ean",
+			source:    madeInLagos,
+			candidate: utils.Album{Title: "Something Else", UPC: "0194398181224"},
+			want:      1,
+		},
+		{
+			name:      "deluxe edition",
+			source:    utils.Album{Title: "Made In Lagos", Artists: []string{"Wizkid"}, TrackCount: 14},
+			candidate: utils.Album{Title: "Made In Lagos (Deluxe Edition)", Artists: []string{"WizKid"}, TrackCount: 18},
+			want:      0.683,
+		},
+		{
+			name:      "missing signals are left out",
+			source:    utils.Album{Title: "Made In Lagos"},
+			candidate: utils.Album{Title: "made in lagos", Artists: []string{"Wizkid"}, TrackCount: 14},
+			want:      1,
+		},
+		{
+			name:      "upc of zeros does not match a candidate without one",
+			source:    utils.Album{Title: "Made In Lagos", UPC: "000"},
+			candidate: utils.Album{Title: "Love, Damini"},
+			want:      0.154,
+		},
+		{
+			name:      "titles that normalise to nothing",
+			source:    utils.Album{Title: "...", Artists: []string{"Wizkid"}},
+			candidate: utils.Album{Title: "!!!", Artists: []string{"Wizkid"}},
+			want:      0.389,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ScoreAlbum(tc.source, tc.candidate); got != tc.want {
+				t.Errorf("ScoreAlbum() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRank(t *testing.T) {
+	source := utils.Track{Title: "Essence", Artists: []string{"Wizkid", "Tems"}, ISRC: "USRC12100001", Duration: 248}
+
+	testCases := []struct {
+		name       string
+		candidates []utils.Track
+		wantIDs    []string
+	}{
+		{
+			name: "isrc match first",
+			candidates: []utils.Track{
+				{ID: "remix", Title: "Essence (Remix)", Artists: []string{"Wizkid", "Tems", "Justin Bieber"}, Duration: 251},
+				{ID: "cover", Title: "Essence", Artists: []string{"Someone Else"}, Duration: 200},
+				{ID: "original", Title: "Essence", Artists: []string{"Wizkid"}, ISRC: "USRC12100001", Duration: 248},
+			},
+			wantIDs: []string{"original", "remix", "cover"},
+		},
+		{
+			name: "ties keep the order of the platform",
+			candidates: []utils.Track{
+				{ID: "first", Title: "Essence", Artists: []string{"Wizkid", "Tems"}},
+				{ID: "different", Title: "Last Last", Artists: []string{"Burna Boy"}},
+				{ID: "second", Title: "Essence", Artists: []string{"Wizkid", "Tems"}},
+			},
+			wantIDs: []string{"first", "second", "different"},
+		},
+		{
+			name:       "no candidates",
+			candidates: nil,
+			wantIDs:    nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ranked := Rank(source, tc.candidates)
+
+			var ids []string
+			for i, track := range ranked {
+				ids = append(ids, track.ID)
+				if want := Score(source, track); track.Confidence != want {
+					t.Errorf("Confidence of %s = %v, want %v", track.ID, track.Confidence, want)
+				}
+				if i > 0 && ranked[i-1].Confidence < track.Confidence {
+					t.Errorf("%s is ranked after a worse match", track.ID)
+				}
+			}
+			if !reflect.DeepEqual(ids, tc.wantIDs) {
+				t.Errorf("Rank() ids = %v, want %v", ids, tc.wantIDs)
+			}
+		})
+	}
+
+	// the candidates passed in are left untouched.
+	candidates := []utils.Track{{ID: "original", Title: "Essence"}}
+	Rank(source, candidates)
+	if candidates[0].Confidence != 0 {
+		t.Errorf("Rank() changed the confidence of the candidates passed in to %v", candidates[0].Confidence)
+	}
+}
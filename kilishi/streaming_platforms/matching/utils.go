@@ -0,0 +1,131 @@
+package matching
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	// featuredArtistsRegex matches the featured artists section of a title e.g. "Essence (feat. Tems)" or "Peru ft Ed Sheeran".
+	featuredArtistsRegex = regexp.MustCompile(`(?i)[\(\[]?\s*\b(?:feat|ft|featuring)\b\.?.*$`)
+	// versionRegex matches the version details of a title that are not part of the song's name e.g. "(Remastered 2011)".
+	versionRegex = regexp.MustCompile(`(?i)[\(\[][^\)\]]*\b(?:remaster(?:ed)?|radio edit|single version|explicit|clean)\b[^\)\]]*[\)\]]`)
+)
+
+// normaliseTitle lowercases a track or album title and strips featured artists, version details and punctuation.
+// Words like "remix", "live" or "acoustic" are kept since they point at a different recording.
+func normaliseTitle(title string) string {
+	stripped := versionRegex.ReplaceAllString(title, "")
+	// a title that starts with the keyword is the name of the song e.g. "Featuring You", not a list of featured artists,
+	// so only the featured artists that follow the name are stripped.
+	for offset := 0; offset < len(stripped); {
+		loc := featuredArtistsRegex.FindStringIndex(stripped[offset:])
+		if loc == nil {
+			break
+		}
+
+		start := offset + loc[0]
+		if strings.TrimSpace(stripped[:start]) != "" {
+			stripped = stripped[:start]
+			break
+		}
+		offset = start + 1
+	}
+
+	// nothing is left of titles that only hold version details, those are compared as they are.
+	if normalised := normaliseName(stripped); normalised != "" {
+		return normalised
+	}
+	return normaliseName(title)
+}
+
+// titleSimilarity compares two titles once normalised, see normaliseTitle.
+func titleSimilarity(a, b string) float64 {
+	na, nb := normaliseTitle(a), normaliseTitle(b)
+	if na == "" && nb == "" {
+		// titles made of punctuation alone e.g. "?" or "...", are only alike when they are the same.
+		a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+		if a == "" || a != b {
+			return 0
+		}
+		return 1
+	}
+
+	return similarity(na, nb)
+}
+
+// normaliseName lowercases a name and replaces punctuation with spaces.
+func normaliseName(name string) string {
+	name = strings.ReplaceAll(strings.ToLower(name), "&", " and ")
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			return r
+		}
+		return ' '
+	}, name)
+
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// NormaliseISRC strips the dashes some platforms include in an ISRC e.g. US-UM7-23-00001.
+func NormaliseISRC(isrc string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(isrc), "-", ""))
+}
+
+// NormaliseUPC strips the leading zeros that turn a 12 digit UPC into the 13 digit EAN some platforms return.
+func NormaliseUPC(upc string) string {
+	return strings.TrimLeft(strings.TrimSpace(upc), "0")
+}
+
+// similarity returns how alike two strings are from 0 to 1 using their Levenshtein distance.
+func similarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+
+	return 1 - float64(levenshteinDistance(ra, rb))/float64(longest)
+}
+
+// levenshteinDistance returns the number of single character edits needed to turn a into b.
+func levenshteinDistance(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
+
+func minInt(values ...int) int {
+	result := values[0]
+	for _, value := range values[1:] {
+		if value < result {
+			result = value
+		}
+	}
+	return result
+}
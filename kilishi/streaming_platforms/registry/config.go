@@ -0,0 +1,33 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrNotConfigured is returned by the constructor of an optional streaming platform when none of its credentials are set,
+// the platform is then left out instead of failing the startup.
+var ErrNotConfigured = errors.New("streaming platform is not configured")
+
+// CheckCredentials takes the credentials of an optional streaming platform keyed by their environment variables.
+// It fails with ErrNotConfigured when none of them are set, and with an error naming the missing ones when only some are.
+func CheckCredentials(credentials map[string]string) error {
+	var missing []string
+	for name, value := range credentials {
+		if strings.TrimSpace(value) == "" {
+			missing = append(missing, name)
+		}
+	}
+
+	switch len(missing) {
+	case 0:
+		return nil
+	case len(credentials):
+		return ErrNotConfigured
+	default:
+		sort.Strings(missing)
+		return fmt.Errorf("registry: %s must be set as well", strings.Join(missing, ", "))
+	}
+}
@@ -0,0 +1,24 @@
+package registry
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckCredentials(t *testing.T) {
+	if err := CheckCredentials(map[string]string{"APP_ID": "id", "APP_SECRET": "secret"}); err != nil {
+		t.Errorf("CheckCredentials() of a configured platform error = %v, want nil", err)
+	}
+	if err := CheckCredentials(map[string]string{"APP_ID": "", "APP_SECRET": " "}); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("CheckCredentials() without credentials error = %v, want %v", err, ErrNotConfigured)
+	}
+
+	// a platform that is only partly configured is a mistake, it must not be left out silently.
+	err := CheckCredentials(map[string]string{"APP_ID": "id", "APP_SECRET": "", "BASE_URL": ""})
+	if err == nil || errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("CheckCredentials() of a partly configured platform error = %v, want the missing credentials", err)
+	}
+	if want := "registry: APP_SECRET, BASE_URL must be set as well"; err.Error() != want {
+		t.Errorf("CheckCredentials() error = %q, want %q", err, want)
+	}
+}
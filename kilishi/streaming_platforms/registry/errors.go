@@ -0,0 +1,108 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// The kinds of errors a streaming platform can fail with, the errors it returns wrap one of them when the reason is known.
+// They let callers tell the reasons apart with errors.Is, e.g. to answer with a matching status code.
+var (
+	// ErrNotFound is wrapped by the errors of requests for something that does not exist on the platform, e.g. a track no search result matches.
+	ErrNotFound = errors.New("not found")
+	// ErrInvalidInput is wrapped by the errors of requests the platform rejected, e.g. a malformed playlist link.
+	ErrInvalidInput = errors.New("invalid input")
+	// ErrUnauthorized is wrapped by the errors of requests made with an access token that has expired or was revoked.
+	// Credentials stored for a user can then be refreshed and the request retried.
+	ErrUnauthorized = errors.New("access token has expired or was revoked")
+	// ErrForbidden is wrapped by the errors of requests the platform refused although the access token is valid,
+	// e.g. a playlist of another user or a scope that was not granted. Refreshing the credentials does not help.
+	ErrForbidden = errors.New("forbidden")
+	// ErrRateLimited is wrapped by the errors of requests the platform rejected for exceeding its rate limit, see RateLimitError.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrUpstreamUnavailable is wrapped by the errors of requests the platform failed to answer, e.g. when it is down.
+	ErrUpstreamUnavailable = errors.New("upstream unavailable")
+)
+
+// Error is an error of a known kind with its own message.
+type Error struct {
+	kind    error
+	message string
+}
+
+// NewError returns an error of the given kind, formatted like fmt.Errorf.
+func NewError(kind error, format string, args ...any) error {
+	return &Error{kind: kind, message: fmt.Sprintf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	return e.message
+}
+
+func (e *Error) Unwrap() error {
+	return e.kind
+}
+
+// RateLimitError is the kind of errors of requests the platform rejected for exceeding its rate limit, it is an ErrRateLimited.
+type RateLimitError struct {
+	// RetryAfter is how long the platform asked to wait before retrying, it is 0 when it did not say.
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter == 0 {
+		return ErrRateLimited.Error()
+	}
+	return fmt.Sprintf("%s, retry after %s", ErrRateLimited.Error(), e.RetryAfter)
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// ErrorKind returns the kind of error a platform answered with the status code of the response.
+func ErrorKind(resp *http.Response) error {
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case resp.StatusCode == http.StatusForbidden:
+		return ErrForbidden
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return &RateLimitError{RetryAfter: RetryAfter(resp)}
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return ErrInvalidInput
+	default:
+		return ErrUpstreamUnavailable
+	}
+}
+
+// RetryAfter returns how long the `Retry-After` header of the response asks to wait, it is 0 when the header is missing or invalid.
+func RetryAfter(resp *http.Response) time.Duration {
+	header := resp.Header.Get("Retry-After")
+	if header == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil && time.Until(date) > 0 {
+		return time.Until(date).Round(time.Second)
+	}
+
+	return 0
+}
+
+// TransportError returns the error of a request that got no response, it is an ErrUpstreamUnavailable unless the request was cancelled.
+func TransportError(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+	return &Error{kind: ErrUpstreamUnavailable, message: err.Error()}
+}
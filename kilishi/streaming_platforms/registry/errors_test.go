@@ -0,0 +1,46 @@
+package registry
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestErrorKind(t *testing.T) {
+	testCases := []struct {
+		status     int
+		retryAfter string
+		want       error
+	}{
+		{status: http.StatusBadRequest, want: ErrInvalidInput},
+		{status: http.StatusUnauthorized, want: ErrUnauthorized},
+		{status: http.StatusForbidden, want: ErrForbidden},
+		{status: http.StatusNotFound, want: ErrNotFound},
+		{status: http.StatusUnprocessableEntity, want: ErrInvalidInput},
+		{status: http.StatusTooManyRequests, retryAfter: "3", want: ErrRateLimited},
+		{status: http.StatusInternalServerError, want: ErrUpstreamUnavailable},
+		{status: http.StatusBadGateway, want: ErrUpstreamUnavailable},
+	}
+
+	for _, tc := range testCases {
+		resp := &http.Response{StatusCode: tc.status, Header: http.Header{}}
+		if tc.retryAfter != "" {
+			resp.Header.Set("Retry-After", tc.retryAfter)
+		}
+
+		kind := ErrorKind(resp)
+		if !errors.Is(kind, tc.want) {
+			t.Errorf("ErrorKind(%d) = %v, want %v", tc.status, kind, tc.want)
+		}
+		// credentials are only refreshed for ErrUnauthorized, a refusal of a valid token must not trigger it.
+		if tc.status != http.StatusUnauthorized && errors.Is(kind, ErrUnauthorized) {
+			t.Errorf("ErrorKind(%d) = %v, only a 401 is an ErrUnauthorized", tc.status, kind)
+		}
+	}
+
+	var rateLimitErr *RateLimitError
+	if kind := ErrorKind(&http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"3"}}}); !errors.As(kind, &rateLimitErr) || rateLimitErr.RetryAfter != 3*time.Second {
+		t.Errorf("ErrorKind(429) = %v, want a RateLimitError to retry after 3s", kind)
+	}
+}
@@ -0,0 +1,21 @@
+package registry
+
+import "context"
+
+type progressKey struct{}
+
+// ProgressFunc is called as the tracks of a playlist are added to it, added is the number of tracks added so far.
+type ProgressFunc func(added, total int)
+
+// WithProgress returns a context that makes `CreatePlaylist` and `SaveTracks` report their progress to fn.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// ReportProgress is called by the streaming platforms that add tracks to a playlist or a library in batches, once per batch.
+// It does nothing when the context was not created with WithProgress.
+func ReportProgress(ctx context.Context, added, total int) {
+	if fn, ok := ctx.Value(progressKey{}).(ProgressFunc); ok {
+		fn(added, total)
+	}
+}
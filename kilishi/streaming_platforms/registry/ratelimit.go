@@ -0,0 +1,50 @@
+package registry
+
+import (
+	"context"
+
+	"github.com/imroc/req/v3"
+)
+
+// RateLimit is how fast requests may be sent to a platform, a field that is not positive lifts that limit.
+type RateLimit struct {
+	// RequestsPerSecond is the rate the requests are paced at once the burst has been used up.
+	RequestsPerSecond float64
+	// Burst is the number of requests that may be sent at once after a pause, it is at least 1.
+	Burst int
+	// MaxConcurrentRequests is the number of requests that may be in flight at the same time.
+	MaxConcurrentRequests int
+}
+
+// Unlimited reports whether the rate limit lets every request through straight away.
+func (l RateLimit) Unlimited() bool {
+	return l.RequestsPerSecond <= 0 && l.MaxConcurrentRequests <= 0
+}
+
+// RateLimiter paces the requests sent to each platform, so they stay under its quotas.
+type RateLimiter interface {
+	// Acquire waits until a request may be sent to the platform without exceeding its rate limit,
+	// the returned function must be called once the response has been read.
+	Acquire(ctx context.Context, platform MusicStreamingPlatform, limit RateLimit) (func(), error)
+}
+
+// LimitRequests makes every request sent with the client wait for the rate limiter first, retries included.
+// The limiter is shared by every caller of the client, so a burst of concurrent calls is paced as a whole.
+func LimitRequests(client *req.Client, limiter RateLimiter, platform MusicStreamingPlatform, limit RateLimit) *req.Client {
+	if limiter == nil || limit.Unlimited() {
+		return client
+	}
+
+	return client.WrapRoundTripFunc(func(rt req.RoundTripper) req.RoundTripFunc {
+		return func(r *req.Request) (*req.Response, error) {
+			release, err := limiter.Acquire(r.Context(), platform, limit)
+			if err != nil {
+				// the request was not sent, so the response is left without one like on transport errors.
+				return &req.Response{Request: r, Err: err}, err
+			}
+			defer release()
+
+			return rt.RoundTrip(r)
+		}
+	})
+}
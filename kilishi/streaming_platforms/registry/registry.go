@@ -0,0 +1,62 @@
+package registry
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	factories = make(map[MusicStreamingPlatform]Factory)
+	mutex     sync.RWMutex
+)
+
+// Register makes a streaming platform available to the rest of the application.
+// It is meant to be called from the `init` function of the platform's package and panics on duplicate or incomplete registrations.
+func Register(factory Factory) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if factory.Name == "" || factory.New == nil {
+		panic("registry: a streaming platform must have a name and a constructor")
+	}
+	if _, ok := factories[factory.Name]; ok {
+		panic(fmt.Sprintf("registry: %s has already been registered", factory.Name))
+	}
+
+	factories[factory.Name] = factory
+}
+
+// Get returns the factory registered under the given name.
+func Get(name MusicStreamingPlatform) (Factory, bool) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	factory, ok := factories[name]
+	return factory, ok
+}
+
+// All returns every registered factory sorted by name.
+func All() []Factory {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	var result []Factory
+	for _, factory := range factories {
+		result = append(result, factory)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// Match returns the factory whose URL matchers recognise the link along with the kind of the link.
+func Match(link string) (Factory, URLKind, bool) {
+	for _, factory := range All() {
+		if kind, ok := factory.MatchURL(link); ok {
+			return factory, kind, true
+		}
+	}
+
+	return Factory{}, "", false
+}
@@ -0,0 +1,169 @@
+package registry
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/imroc/req/v3"
+
+	"github.com/prettyirrelevant/kilishi/config"
+	"github.com/prettyirrelevant/kilishi/utils"
+)
+
+type MusicStreamingPlatform string
+
+// MusicStreamingPlatformInterface is implemented by every streaming platform.
+// The context passed to each method is used to cancel the underlying HTTP requests, e.g. when the client disconnects.
+type MusicStreamingPlatformInterface interface {
+	// CreatePlaylist creates a new playlist on the platform.
+	// It takes a utils.Playlist object and an access token string
+	// and returns the URL of the newly created playlist and an error, if any.
+	CreatePlaylist(ctx context.Context, playlist utils.Playlist, accessToken string) (string, error)
+
+	// GetPlaylist returns a utils.Playlist object for a given playlist URL.
+	// It takes a playlist URL string and returns the corresponding playlist object and an error, if any.
+	GetPlaylist(ctx context.Context, playlistURL string) (utils.Playlist, error)
+
+	// LookupTrack searches for a track on the streaming platform.
+	LookupTrack(ctx context.Context, track utils.Track) (utils.Track, error)
+
+	// SearchTracks searches for a track on the streaming platform and returns every candidate the platform retrieved.
+	// It returns an error when no candidate is found.
+	SearchTracks(ctx context.Context, track utils.Track) ([]utils.Track, error)
+
+	// GetAuthorizationCode returns an oauth credentials object for the given authorization code.
+	// It takes an authorization code string and returns an oauth credentials object and an error, if any.
+	GetAuthorizationCode(ctx context.Context, code string) (utils.OauthCredentials, error)
+
+	// RequiresAccessToken returns a boolean indicating whether the platform requires an access token for API calls.
+	RequiresAccessToken() bool
+}
+
+// OauthAuthorizer is implemented by platforms that issue user credentials through an authorization code callback.
+type OauthAuthorizer interface {
+	// GetAuthorizationURL returns the link users follow to grant access to their account.
+	// The state must be sent back to the callback along with the authorization code.
+	GetAuthorizationURL(state string) (string, error)
+}
+
+// PKCEAuthorizer is implemented by platforms whose authorization code flow requires a proof key (PKCE).
+// It is used instead of OauthAuthorizer and GetAuthorizationCode when a platform implements it.
+type PKCEAuthorizer interface {
+	// GetPKCEAuthorizationURL returns the link users follow to grant access to their account, along with the S256 challenge of the code verifier.
+	GetPKCEAuthorizationURL(state, codeChallenge string) (string, error)
+	// ExchangeAuthorizationCode exchanges the authorization code for the user's credentials, proving the authorization was started with the code verifier.
+	ExchangeAuthorizationCode(ctx context.Context, code, codeVerifier string) (utils.OauthCredentials, error)
+}
+
+// OauthTokenRefresher is implemented by platforms whose access tokens can be refreshed.
+type OauthTokenRefresher interface {
+	// RefreshAccessToken exchanges the refresh token in the credentials for a new access token.
+	RefreshAccessToken(ctx context.Context, credentials utils.OauthCredentials) (utils.OauthCredentials, error)
+}
+
+// AccountGetter is implemented by platforms that can tell which account an access token belongs to.
+type AccountGetter interface {
+	// GetAccount returns the account of the owner of the access token.
+	GetAccount(ctx context.Context, accessToken string) (utils.Account, error)
+}
+
+// TrackGetter is implemented by platforms that can retrieve a single track from its link.
+type TrackGetter interface {
+	// GetTrack returns the track the link points at.
+	GetTrack(ctx context.Context, trackURL string) (utils.Track, error)
+	// TrackURL returns the link of the track with the given ID, as accepted by GetTrack.
+	TrackURL(trackID string) string
+}
+
+// AlbumProvider is implemented by platforms that can retrieve albums from their links and search for them.
+type AlbumProvider interface {
+	// GetAlbum returns the album the link points at, along with its tracks.
+	GetAlbum(ctx context.Context, albumURL string) (utils.Album, error)
+
+	// LookupAlbum searches for an album on the platform, by its UPC when it is known, and returns the top result.
+	// The tracks of the album returned are not retrieved.
+	LookupAlbum(ctx context.Context, album utils.Album) (utils.Album, error)
+}
+
+// AlbumSaver is implemented by platforms that can save albums to the library of a user.
+type AlbumSaver interface {
+	// SaveAlbum saves an album retrieved from the platform to the library of the owner of the access token.
+	SaveAlbum(ctx context.Context, album utils.Album, accessToken string) error
+}
+
+// LibraryProvider is implemented by platforms that can read and save the tracks in the library of a user, i.e. their liked songs.
+type LibraryProvider interface {
+	// GetSavedTracks returns the tracks saved to the library of the owner of the access token.
+	GetSavedTracks(ctx context.Context, accessToken string) ([]utils.Track, error)
+
+	// SaveTracks saves tracks retrieved from the platform to the library of the owner of the access token.
+	// Progress is reported through ReportProgress.
+	SaveTracks(ctx context.Context, tracks []utils.Track, accessToken string) error
+}
+
+// Capabilities describes the operations a streaming platform supports.
+type Capabilities struct {
+	GetPlaylist    bool `json:"get_playlist"`
+	CreatePlaylist bool `json:"create_playlist"`
+	LookupTrack    bool `json:"lookup_track"`
+	// GetTrack is true when the platform implements TrackGetter.
+	GetTrack bool `json:"get_track"`
+	// Albums is true when the platform implements AlbumProvider.
+	Albums bool `json:"albums"`
+	// SaveAlbum is true when the platform implements AlbumSaver.
+	SaveAlbum bool `json:"save_album"`
+	// Library is true when the platform implements LibraryProvider.
+	Library bool `json:"library"`
+	// Oauth is true when the platform implements OauthAuthorizer.
+	Oauth bool `json:"oauth"`
+	// ClientToken is true when users connect their account with a token their client obtained from the platform, e.g. a MusicKit user token.
+	// The token is sent to `POST /v1/auth/:platform/token` and passed to GetAuthorizationCode as the code.
+	ClientToken bool `json:"client_token"`
+}
+
+// Options are passed to a factory when its streaming platform is initialised.
+type Options struct {
+	RequestClient *req.Client
+	Config        *config.Config
+	// RateLimiter paces the requests of the platform, they are not paced when it is nil.
+	RateLimiter RateLimiter
+}
+
+// Factory describes a streaming platform and knows how to initialise it.
+type Factory struct {
+	Name         MusicStreamingPlatform `json:"name"`
+	DisplayName  string                 `json:"display_name"`
+	Capabilities Capabilities           `json:"capabilities"`
+
+	// URLMatchers recognise links that belong to the platform.
+	URLMatchers []URLMatcher `json:"-"`
+
+	// New loads the platform's configuration and returns a ready to use instance of it.
+	New func(opts Options) (MusicStreamingPlatformInterface, error) `json:"-"`
+}
+
+// URLKind is the kind of resource a link points at.
+type URLKind string
+
+const (
+	PlaylistURL URLKind = "playlist"
+	AlbumURL    URLKind = "album"
+	TrackURL    URLKind = "track"
+)
+
+// URLMatcher recognises links of a single kind, e.g. https://open.spotify.com/playlist/<id> or spotify:playlist:<id>.
+type URLMatcher struct {
+	Kind    URLKind
+	Pattern *regexp.Regexp
+}
+
+// MatchURL returns the kind of the link if any of the factory's URL matchers recognises it.
+func (f *Factory) MatchURL(link string) (URLKind, bool) {
+	for _, matcher := range f.URLMatchers {
+		if matcher.Pattern.MatchString(link) {
+			return matcher.Kind, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,42 @@
+package soundcloud
+
+import (
+	"github.com/prettyirrelevant/kilishi/config"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+)
+
+// Platform is the name SoundCloud is registered under.
+const Platform registry.MusicStreamingPlatform = "soundcloud"
+
+func init() {
+	registry.Register(registry.Factory{
+		Name:        Platform,
+		DisplayName: "SoundCloud",
+		URLMatchers: []registry.URLMatcher{
+			{Kind: registry.PlaylistURL, Pattern: playlistURLRegex},
+			{Kind: registry.TrackURL, Pattern: trackURLRegex},
+		},
+		Capabilities: registry.Capabilities{GetPlaylist: true, CreatePlaylist: true, LookupTrack: true, Oauth: true},
+		New: func(opts registry.Options) (registry.MusicStreamingPlatformInterface, error) {
+			var cfg Config
+			if err := config.Load(&cfg); err != nil {
+				return nil, err
+			}
+
+			return New(&InitialisationOpts{
+				RequestClient: registry.LimitRequests(opts.RequestClient, opts.RateLimiter, Platform, registry.RateLimit{
+					RequestsPerSecond:     cfg.RateLimit,
+					Burst:                 cfg.RateLimitBurst,
+					MaxConcurrentRequests: cfg.MaxConcurrentRequests,
+				}),
+				BaseAPIURL:                cfg.BaseAPIURL,
+				ClientID:                  cfg.ClientID,
+				ClientSecret:              cfg.ClientSecret,
+				AuthenticationURL:         cfg.AuthenticationURL,
+				AuthorizationURL:          cfg.AuthorizationURL,
+				AuthenticationRedirectURL: cfg.AuthenticationRedirectURL,
+				RequestTimeout:            cfg.RequestTimeout,
+			}), nil
+		},
+	})
+}
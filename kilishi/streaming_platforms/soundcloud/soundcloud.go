@@ -0,0 +1,264 @@
+package soundcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+	"github.com/prettyirrelevant/kilishi/utils"
+)
+
+const maximumNumOfTracksPerPage = "200"
+
+// New initializes a `SoundCloud` object.
+func New(opts *InitialisationOpts) *SoundCloud {
+	return &SoundCloud{
+		RequestClient: setupRequestClient(opts.RequestClient.SetTimeout(opts.RequestTimeout), opts.BaseAPIURL),
+		Config: Config{
+			BaseAPIURL:                opts.BaseAPIURL,
+			ClientID:                  opts.ClientID,
+			ClientSecret:              opts.ClientSecret,
+			AuthenticationURL:         opts.AuthenticationURL,
+			AuthorizationURL:          opts.AuthorizationURL,
+			AuthenticationRedirectURL: opts.AuthenticationRedirectURL,
+			RequestTimeout:            opts.RequestTimeout,
+		},
+	}
+}
+
+// GetPlaylist returns the tracks of a set or of a user's likes.
+func (s *SoundCloud) GetPlaylist(ctx context.Context, playlistURL string) (utils.Playlist, error) {
+	location, err := parsePlaylistURL(playlistURL)
+	if err != nil {
+		return utils.Playlist{}, err
+	}
+
+	clientAuthToken, err := s.getClientAuthenticationCredentials(ctx)
+	if err != nil {
+		return utils.Playlist{}, err
+	}
+
+	var response soundcloudAPIResolveResponse
+	err = s.RequestClient.
+		Get("/resolve").
+		SetHeader("Authorization", "OAuth "+clientAuthToken).
+		SetQueryParam("url", location.ResolveURL).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return utils.Playlist{}, err
+	}
+
+	playlist := utils.Playlist{
+		ID:          strconv.Itoa(response.ID),
+		Title:       response.Title,
+		Description: response.Description,
+		Owner:       response.User.Username,
+		ImageURL:    response.ArtworkURL,
+		URL:         response.PermalinkURL,
+	}
+
+	tracksURL := fmt.Sprintf("/playlists/%d/tracks", response.ID)
+	if location.IsLikes {
+		playlist.Title = response.Username + "'s likes"
+		playlist.Owner = response.Username
+		playlist.ImageURL = response.AvatarURL
+		playlist.URL = response.PermalinkURL + "/likes"
+		tracksURL = fmt.Sprintf("/users/%d/likes/tracks", response.ID)
+	}
+
+	tracks, err := s.getPaginatedTracks(ctx, tracksURL, clientAuthToken)
+	if err != nil {
+		return utils.Playlist{}, err
+	}
+
+	playlist.Tracks = tracks
+	playlist.Finalise(string(Platform))
+	return playlist, nil
+}
+
+// CreatePlaylist creates a public set on the account the access token belongs to.
+func (s *SoundCloud) CreatePlaylist(ctx context.Context, playlist utils.Playlist, accessToken string) (string, error) {
+	var trackIDs []string
+	var tracksPayload []map[string]string
+	for _, entry := range playlist.Tracks {
+		if ok := utils.Contains(trackIDs, entry.ID); !ok {
+			trackIDs = append(trackIDs, entry.ID)
+			tracksPayload = append(tracksPayload, map[string]string{"id": entry.ID})
+		}
+	}
+
+	var response soundcloudAPICreatePlaylistResponse
+	err := s.RequestClient.
+		Post("/playlists").
+		SetHeader("Authorization", "OAuth "+accessToken).
+		SetContentType(utils.ApplicationJSON).
+		SetBodyJsonMarshal(map[string]any{
+			"playlist": map[string]any{
+				"title":       playlist.Title,
+				"description": playlist.Description,
+				"sharing":     "public",
+				"tracks":      tracksPayload,
+			},
+		}).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return "", err
+	}
+
+	return response.PermalinkURL, nil
+}
+
+// LookupTrack searches for a track on SoundCloud and returns the top result.
+func (s *SoundCloud) LookupTrack(ctx context.Context, track utils.Track) (utils.Track, error) {
+	tracks, err := s.SearchTracks(ctx, track)
+	if err != nil {
+		return utils.Track{}, err
+	}
+
+	return tracks[0], nil
+}
+
+// SearchTracks returns the tracks on SoundCloud that match the track, best first.
+func (s *SoundCloud) SearchTracks(ctx context.Context, track utils.Track) ([]utils.Track, error) {
+	clientAuthToken, err := s.getClientAuthenticationCredentials(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("soundcloud: %w", err)
+	}
+
+	var response []soundcloudAPITrack
+	err = s.RequestClient.
+		Get("/tracks").
+		SetHeader("Authorization", "OAuth "+clientAuthToken).
+		SetQueryParams(map[string]string{
+			"q":     trackToSearchQuery(track),
+			"limit": "5",
+		}).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return nil, fmt.Errorf("soundcloud: %w", err)
+	}
+
+	tracks := parseTracksResponse(response)
+	if len(tracks) == 0 {
+		return nil, registry.NewError(registry.ErrNotFound, "soundcloud: no track found that matches %s", track.Title)
+	}
+
+	return utils.SetMatchStrategy(tracks, utils.TextSearchMatch), nil
+}
+
+// GetAuthorizationCode exchanges an authorization code for the user's oauth credentials.
+func (s *SoundCloud) GetAuthorizationCode(ctx context.Context, code string) (utils.OauthCredentials, error) {
+	var response soundcloudAPIBearerCredentialsResponse
+	err := s.RequestClient.
+		Post(s.Config.AuthenticationURL).
+		SetFormData(map[string]string{
+			"grant_type":    "authorization_code",
+			"code":          code,
+			"client_id":     s.Config.ClientID,
+			"client_secret": s.Config.ClientSecret,
+			"redirect_uri":  s.Config.AuthenticationRedirectURL,
+		}).
+		SetContentType("application/x-www-form-urlencoded").
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return utils.OauthCredentials{}, err
+	}
+
+	return utils.OauthCredentials{AccessToken: response.AccessToken, RefreshToken: response.RefreshToken, ExpiresAt: utils.ExpiresAt(response.ExpiresIn)}, nil
+}
+
+// GetAuthorizationURL returns the link users follow to grant access to their account, the state is sent back to the callback.
+func (s *SoundCloud) GetAuthorizationURL(state string) (string, error) {
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", s.Config.ClientID)
+	query.Set("redirect_uri", s.Config.AuthenticationRedirectURL)
+	query.Set("state", state)
+	return s.Config.AuthorizationURL + "?" + query.Encode(), nil
+}
+
+// RequiresAccessToken specifies if the streaming requires Oauth.
+func (*SoundCloud) RequiresAccessToken() bool {
+	return true
+}
+
+// getPaginatedTracks follows SoundCloud's `next_href` links until all tracks have been fetched.
+func (s *SoundCloud) getPaginatedTracks(ctx context.Context, tracksURL, clientAuthToken string) ([]utils.Track, error) {
+	var tracks []utils.Track
+
+	var response soundcloudAPITracksResponse
+	err := s.RequestClient.
+		Get(tracksURL).
+		SetHeader("Authorization", "OAuth "+clientAuthToken).
+		SetQueryParams(map[string]string{
+			"linked_partitioning": "true",
+			"limit":               maximumNumOfTracksPerPage,
+		}).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return tracks, err
+	}
+
+	tracks = append(tracks, parseTracksResponse(response.Collection)...)
+	for response.NextHref != "" {
+		var nextResp soundcloudAPITracksResponse
+		err := s.RequestClient.
+			Get(response.NextHref).
+			SetHeader("Authorization", "OAuth "+clientAuthToken).
+			Do(ctx).
+			Into(&nextResp)
+
+		if err != nil {
+			// the request was cancelled, do not return a partial playlist.
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			break
+		}
+
+		tracks = append(tracks, parseTracksResponse(nextResp.Collection)...)
+		response = nextResp
+	}
+
+	return tracks, nil
+}
+
+// getClientAuthenticationCredentials fetches the client credentials needed for public resources.
+func (s *SoundCloud) getClientAuthenticationCredentials(ctx context.Context) (string, error) {
+	if token, ok := utils.GlobalCache.Get("soundcloudClientAuthToken"); ok {
+		if val, ok := token.(string); ok {
+			return val, nil
+		}
+
+		return "", errors.New("client authentication credentials corrupted in cache")
+	}
+
+	var response soundcloudAPIClientCredentialsResponse
+	err := s.RequestClient.
+		Post(s.Config.AuthenticationURL).
+		SetBasicAuth(s.Config.ClientID, s.Config.ClientSecret).
+		SetFormData(map[string]string{"grant_type": "client_credentials"}).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return "", err
+	}
+
+	utils.GlobalCache.Set("soundcloudClientAuthToken", response.AccessToken, time.Second*time.Duration(response.ExpiresIn))
+	return response.AccessToken, nil
+}
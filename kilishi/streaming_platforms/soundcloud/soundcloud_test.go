@@ -0,0 +1,179 @@
+package soundcloud
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/imroc/req/v3"
+
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+	"github.com/prettyirrelevant/kilishi/utils"
+)
+
+// recordedSoundCloud returns a client of a server that answers with the responses recorded from the SoundCloud API in testdata,
+// keyed by the request path and decoded query. The `next_href` links of the recorded pages are pointed at the server.
+func recordedSoundCloud(t *testing.T, responses map[string]string) (*SoundCloud, *[]string) {
+	t.Helper()
+
+	var requests []string
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, status := "", http.StatusOK
+		if r.URL.Path == "/oauth2/token" {
+			if id, secret, _ := r.BasicAuth(); id != "client-id" || secret != "client-secret" || r.FormValue("grant_type") != "client_credentials" {
+				t.Errorf("client credentials were requested without the client id and secret")
+			}
+			name = "client_credentials.json"
+		} else {
+			if r.Header.Get("Authorization") != "OAuth sc-client-token" {
+				t.Errorf("%s was requested without the client credentials", r.URL.Path)
+			}
+
+			query, _ := url.QueryUnescape(r.URL.Query().Encode())
+			requests = append(requests, r.URL.Path+"?"+query)
+			if name = responses[r.URL.Path+"?"+query]; name == "" {
+				name, status = "not_found.json", http.StatusNotFound
+			}
+		}
+
+		body, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(status)
+		_, _ = w.Write(bytes.ReplaceAll(body, []byte("https://api.soundcloud.com"), []byte(server.URL)))
+	}))
+	t.Cleanup(server.Close)
+
+	// the client credentials are cached, every test authenticates against its own server.
+	utils.GlobalCache.Set("soundcloudClientAuthToken", nil, 0)
+
+	return New(&InitialisationOpts{
+		RequestClient:     req.C(),
+		BaseAPIURL:        server.URL,
+		ClientID:          "client-id",
+		ClientSecret:      "client-secret",
+		AuthenticationURL: server.URL + "/oauth2/token",
+		RequestTimeout:    5 * time.Second,
+	}), &requests
+}
+
+func TestParsePlaylistURL(t *testing.T) {
+	testCases := []struct {
+		playlistURL string
+		want        playlistLocation
+	}{
+		{playlistURL: "https://soundcloud.com/ayo-adeyemi/sets/lagos-nights", want: playlistLocation{ResolveURL: "https://soundcloud.com/ayo-adeyemi/sets/lagos-nights"}},
+		{playlistURL: "https://m.soundcloud.com/ayo-adeyemi/sets/lagos-nights/?si=7f3a", want: playlistLocation{ResolveURL: "https://soundcloud.com/ayo-adeyemi/sets/lagos-nights"}},
+		{playlistURL: "https://soundcloud.com/ayo-adeyemi/likes", want: playlistLocation{ResolveURL: "https://soundcloud.com/ayo-adeyemi", IsLikes: true}},
+		{playlistURL: "https://www.soundcloud.com/ayo-adeyemi/likes/", want: playlistLocation{ResolveURL: "https://soundcloud.com/ayo-adeyemi", IsLikes: true}},
+		// a set called "likes" is still a set.
+		{playlistURL: "https://soundcloud.com/ayo-adeyemi/sets/likes", want: playlistLocation{ResolveURL: "https://soundcloud.com/ayo-adeyemi/sets/likes"}},
+	}
+
+	for _, tc := range testCases {
+		location, err := parsePlaylistURL(tc.playlistURL)
+		if err != nil || location != tc.want {
+			t.Errorf("parsePlaylistURL(%q) = %+v, %v, want %+v", tc.playlistURL, location, err, tc.want)
+		}
+	}
+
+	// tracks, profiles and the tracks of a set are not playlists, though the track pattern matches the likes and the sets.
+	for _, trackURL := range []string{
+		"https://soundcloud.com/wizkidofficial/essence-feat-tems",
+		"https://soundcloud.com/ayo-adeyemi",
+		"https://soundcloud.com/ayo-adeyemi/sets",
+		"https://soundcloud.com/ayo-adeyemi/sets/lagos-nights/s-Xy7Q2",
+		"https://on.soundcloud.com/ayo-adeyemi/likes",
+	} {
+		if _, err := parsePlaylistURL(trackURL); !errors.Is(err, registry.ErrInvalidInput) {
+			t.Errorf("parsePlaylistURL(%q) error = %v, want %v", trackURL, err, registry.ErrInvalidInput)
+		}
+	}
+	if !trackURLRegex.MatchString("https://soundcloud.com/wizkidofficial/essence-feat-tems") {
+		t.Error("trackURLRegex does not match a track url")
+	}
+}
+
+func TestGetPlaylist(t *testing.T) {
+	client, requests := recordedSoundCloud(t, map[string]string{
+		"/resolve?url=https://soundcloud.com/ayo-adeyemi/sets/lagos-nights":                 "resolve_set.json",
+		"/playlists/1694835021/tracks?limit=200&linked_partitioning=true":                   "set_tracks.json",
+		"/playlists/1694835021/tracks?cursor=1&limit=200&linked_partitioning=true":          "set_tracks_next.json",
+		"/resolve?url=https://soundcloud.com/ayo-adeyemi":                                   "resolve_user.json",
+		"/users/50123317/likes/tracks?limit=200&linked_partitioning=true":                   "likes_tracks.json",
+		"/users/50123317/likes/tracks?cursor=1381920554&limit=200&linked_partitioning=true": "likes_tracks_next.json",
+	})
+
+	// the tracks of the set are paged with the `next_href` links, and the track that is no longer available is skipped.
+	playlist, err := client.GetPlaylist(context.Background(), "https://soundcloud.com/ayo-adeyemi/sets/lagos-nights?si=7f3a")
+	if err != nil {
+		t.Fatalf("GetPlaylist() error = %v after %v", err, *requests)
+	}
+	if len(*requests) != 3 {
+		t.Errorf("requests = %v, want the resolve and both pages", *requests)
+	}
+	if playlist.ID != "1694835021" || playlist.Title != "Lagos Nights" || playlist.Owner != "Ayo Adeyemi" || playlist.URL != "https://soundcloud.com/ayo-adeyemi/sets/lagos-nights" {
+		t.Errorf("GetPlaylist() = %+v, attributes of the set are missing", playlist)
+	}
+
+	// the artist and the ISRC come from the publisher metadata, which uploads outside a label do not have.
+	want := []utils.Track{
+		{
+			ID:         "929455612",
+			Title:      "Essence",
+			Artists:    []string{"Wizkid"},
+			ISRC:       "USRC12100001",
+			Duration:   248,
+			ArtworkURL: "https://i1.sndcdn.com/artworks-000598271234-ab12cd-large.jpg",
+			URL:        "https://soundcloud.com/wizkidofficial/essence-feat-tems",
+			Position:   1,
+		},
+		{
+			ID:         "1520384417",
+			Title:      "Lagos Sunset",
+			Artists:    []string{"Kemi Sings"},
+			Duration:   301,
+			ArtworkURL: "https://i1.sndcdn.com/artworks-rT4gH9sLmQ2z-0-large.jpg",
+			URL:        "https://soundcloud.com/kemi-sings/lagos-sunset-live-session",
+			Position:   2,
+		},
+	}
+	if !reflect.DeepEqual(playlist.Tracks, want) {
+		t.Errorf("Tracks = %+v, want %+v", playlist.Tracks, want)
+	}
+
+	// the likes resolve to the user, whose liked tracks are paged the same way.
+	*requests = nil
+	playlist, err = client.GetPlaylist(context.Background(), "https://soundcloud.com/ayo-adeyemi/likes")
+	if err != nil {
+		t.Fatalf("GetPlaylist() error = %v after %v", err, *requests)
+	}
+	if playlist.Title != "Ayo Adeyemi's likes" || playlist.Owner != "Ayo Adeyemi" || playlist.URL != "https://soundcloud.com/ayo-adeyemi/likes" {
+		t.Errorf("GetPlaylist() = %+v, attributes of the likes are missing", playlist)
+	}
+	if want := "https://i1.sndcdn.com/avatars-pQ7wZ3nLk2Vd-large.jpg"; playlist.ImageURL != want {
+		t.Errorf("ImageURL = %q, want the avatar %q", playlist.ImageURL, want)
+	}
+	var isrcs []string
+	for _, track := range playlist.Tracks {
+		isrcs = append(isrcs, track.ISRC)
+	}
+	if want := []string{"USUM72206091", "USAT22203075"}; !reflect.DeepEqual(isrcs, want) || len(*requests) != 3 {
+		t.Errorf("ISRCs = %v after %v, want %v from both pages", isrcs, *requests, want)
+	}
+
+	if _, err = client.GetPlaylist(context.Background(), "https://soundcloud.com/ayo-adeyemi/sets/deleted"); !errors.Is(err, registry.ErrNotFound) {
+		t.Errorf("GetPlaylist() of an unknown set error = %v, want %v", err, registry.ErrNotFound)
+	}
+}
@@ -0,0 +1,116 @@
+package soundcloud
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/imroc/req/v3"
+)
+
+// SoundCloud encapsulates all methods relating to SoundCloud.
+type SoundCloud struct {
+	RequestClient *req.Client
+	Config        Config
+}
+
+type InitialisationOpts struct {
+	RequestClient             *req.Client
+	BaseAPIURL                string
+	ClientID                  string
+	ClientSecret              string
+	AuthenticationURL         string
+	AuthorizationURL          string
+	AuthenticationRedirectURL string
+	RequestTimeout            time.Duration
+}
+
+type Config struct {
+	BaseAPIURL                string        `env:"SOUNDCLOUD_BASE_API_URL,notEmpty"`
+	ClientID                  string        `env:"SOUNDCLOUD_CLIENT_ID,notEmpty"`
+	ClientSecret              string        `env:"SOUNDCLOUD_CLIENT_SECRET,notEmpty"`
+	AuthenticationURL         string        `env:"SOUNDCLOUD_AUTH_URL,notEmpty"`
+	AuthorizationURL          string        `env:"SOUNDCLOUD_AUTHORIZATION_URL" envDefault:"https://secure.soundcloud.com/authorize"`
+	AuthenticationRedirectURL string        `env:"SOUNDCLOUD_AUTH_REDIRECT_URL,notEmpty"`
+	RequestTimeout            time.Duration `env:"SOUNDCLOUD_REQUEST_TIMEOUT" envDefault:"30s"`
+	RateLimit                 float64       `env:"SOUNDCLOUD_RATE_LIMIT" envDefault:"5"`
+	RateLimitBurst            int           `env:"SOUNDCLOUD_RATE_LIMIT_BURST" envDefault:"5"`
+	MaxConcurrentRequests     int           `env:"SOUNDCLOUD_MAX_CONCURRENT_REQUESTS" envDefault:"5"`
+}
+
+// API Types (Autogenerated).
+type soundcloudAPITrack struct {
+	ID       int    `json:"id"`
+	Kind     string `json:"kind"`
+	Title    string `json:"title"`
+	Duration int    `json:"duration"`
+	User     struct {
+		ID       int    `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	PublisherMetadata struct {
+		Artist string `json:"artist"`
+		ISRC   string `json:"isrc"`
+	} `json:"publisher_metadata"`
+	PermalinkURL string `json:"permalink_url"`
+	ArtworkURL   string `json:"artwork_url"`
+}
+
+// soundcloudAPIResolveResponse is either a set or a user, depending on the resolved URL.
+type soundcloudAPIResolveResponse struct {
+	ID           int                  `json:"id"`
+	Kind         string               `json:"kind"`
+	Title        string               `json:"title"`
+	Description  string               `json:"description"`
+	Username     string               `json:"username"`
+	PermalinkURL string               `json:"permalink_url"`
+	ArtworkURL   string               `json:"artwork_url"`
+	AvatarURL    string               `json:"avatar_url"`
+	TrackCount   int                  `json:"track_count"`
+	Tracks       []soundcloudAPITrack `json:"tracks"`
+	User         struct {
+		Username string `json:"username"`
+	} `json:"user"`
+}
+
+type soundcloudAPITracksResponse struct {
+	Collection []soundcloudAPITrack `json:"collection"`
+	NextHref   string               `json:"next_href"`
+}
+
+type soundcloudAPICreatePlaylistResponse struct {
+	ID           int    `json:"id"`
+	Title        string `json:"title"`
+	PermalinkURL string `json:"permalink_url"`
+}
+
+type soundcloudAPIClientCredentialsResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+	TokenType   string `json:"token_type"`
+}
+
+type soundcloudAPIBearerCredentialsResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+type soundcloudAPIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+	Errors  []struct {
+		ErrorMessage string `json:"error_message"`
+	} `json:"errors"`
+	// kind is the kind of error the platform answered with, see Unwrap.
+	kind error
+}
+
+func (e *soundcloudAPIError) Error() string {
+	return fmt.Sprintf("SoundCloud API Error: status: %s  reason: %s  errors: %+v", e.Status, e.Message, e.Errors)
+}
+
+// Unwrap returns the kind of error the platform answered with, e.g. `registry.ErrNotFound`.
+func (e *soundcloudAPIError) Unwrap() error {
+	return e.kind
+}
@@ -0,0 +1,125 @@
+package soundcloud
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/imroc/req/v3"
+
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+	"github.com/prettyirrelevant/kilishi/utils"
+)
+
+const baseWebsiteURL = "https://soundcloud.com/"
+
+// playlistLocation describes what a SoundCloud URL points at.
+type playlistLocation struct {
+	// ResolveURL is the canonical URL passed to the `/resolve` endpoint.
+	ResolveURL string
+	// IsLikes is true when the URL points at a user's liked tracks rather than a set.
+	IsLikes bool
+}
+
+var (
+	playlistURLRegex = regexp.MustCompile(`^https://(?:www\.|m\.)?soundcloud\.com/([\w-]+)/(?:sets/([\w-]+)|(likes))/?(?:\?.*)?$`)
+	trackURLRegex    = regexp.MustCompile(`^https://(?:www\.|m\.)?soundcloud\.com/([\w-]+)/([\w-]+)/?(?:\?.*)?$`)
+)
+
+// parsePlaylistURL validates a SoundCloud set or likes URL.
+func parsePlaylistURL(playlistURL string) (playlistLocation, error) {
+	matches := playlistURLRegex.FindStringSubmatch(playlistURL)
+	if len(matches) < 4 {
+		return playlistLocation{}, registry.NewError(registry.ErrInvalidInput, "soundcloud: playlist url is invalid. check that it follows the format https://soundcloud.com/<user>/sets/<set> or https://soundcloud.com/<user>/likes")
+	}
+
+	if matches[3] != "" {
+		return playlistLocation{ResolveURL: baseWebsiteURL + matches[1], IsLikes: true}, nil
+	}
+	return playlistLocation{ResolveURL: baseWebsiteURL + matches[1] + "/sets/" + matches[2]}, nil
+}
+
+// parseTracksResponse transforms the tracks returned from SoundCloud API into our internal object.
+func parseTracksResponse(response []soundcloudAPITrack) []utils.Track {
+	var tracks []utils.Track
+	for _, entry := range response {
+		// sets may contain tracks that are no longer available, these come back without a title.
+		if entry.Title == "" {
+			continue
+		}
+
+		tracks = append(tracks, utils.Track{
+			ID:         strconv.Itoa(entry.ID),
+			Title:      utils.CleanTrackTitle(entry.Title),
+			Artists:    []string{trackArtist(entry)},
+			ISRC:       entry.PublisherMetadata.ISRC,
+			Duration:   entry.Duration / 1000,
+			ArtworkURL: entry.ArtworkURL,
+			URL:        entry.PermalinkURL,
+		})
+	}
+
+	return tracks
+}
+
+// trackArtist returns the artist credited by the publisher, falling back to the uploader's username.
+func trackArtist(track soundcloudAPITrack) string {
+	if track.PublisherMetadata.Artist != "" {
+		return track.PublisherMetadata.Artist
+	}
+	return track.User.Username
+}
+
+// trackToSearchQuery transforms our internal track object into a SoundCloud search query.
+func trackToSearchQuery(track utils.Track) string {
+	q := track.Title
+	for _, artistName := range track.Artists {
+		q += " " + artistName
+		break
+	}
+
+	return q
+}
+
+func setupRequestClient(reqClient *req.Client, baseURL string) *req.Client {
+	return reqClient.
+		SetBaseURL(baseURL).
+		EnableDumpEachRequest().
+		SetCommonHeader("Accept", "application/json; charset=utf-8").
+		SetCommonErrorResult(&soundcloudAPIError{}).
+		OnAfterResponse(func(client *req.Client, resp *req.Response) error {
+			// The access token has expired or was revoked, see `registry.ErrUnauthorized`.
+			if resp.Response != nil && (resp.StatusCode == http.StatusUnauthorized) {
+				resp.Err = fmt.Errorf("%w: %s", registry.ErrUnauthorized, resp.String())
+				return nil
+			}
+			// There is an underlying error, e.g. network error or unmarshal error.
+			if resp.Err != nil {
+				// the platform could not be reached, see `registry.ErrUpstreamUnavailable`.
+				if resp.Response == nil {
+					resp.Err = registry.TransportError(resp.Err)
+				}
+				return nil
+			}
+			// Server returns an error message, convert it to human-readable Go error.
+			if apiErr, ok := resp.ErrorResult().(*soundcloudAPIError); ok {
+				apiErr.kind = registry.ErrorKind(resp.Response)
+				resp.Err = apiErr
+				return nil
+			}
+			// Edge case: neither an error state response nor a success state response,
+			// dump content to help troubleshoot.
+			if !resp.IsSuccessState() {
+				resp.Err = registry.NewError(registry.ErrorKind(resp.Response), "bad status: %s\nraw content:\n%s", resp.Status, resp.Dump())
+				return nil
+			}
+			return nil
+		}).
+		SetCommonRetryCount(3).
+		AddCommonRetryCondition(func(resp *req.Response, err error) bool {
+			return resp.Response != nil && resp.StatusCode == 429
+		}).
+		SetCommonRetryBackoffInterval(2*time.Second, 5*time.Second)
+}
@@ -1,41 +1,50 @@
 package spotify
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"sync"
+	"net/url"
+	"strings"
 	"time"
 
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/matching"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
 	"github.com/prettyirrelevant/kilishi/utils"
 )
 
 const (
 	basePlaylistURL              = "https://open.spotify.com/playlist/"
 	maximumNumOfTracksPerRequest = 100
+	// maximumNumOfTrackIDsPerRequest is the most tracks `GET /tracks` returns at once.
+	maximumNumOfTrackIDsPerRequest = 50
+	// oauthScopes are the permissions requested from users, to create playlists and manage their library.
+	oauthScopes = "playlist-modify-public user-library-read user-library-modify"
 )
 
 // New initializes a `Spotify` object.
 func New(opts *InitialisationOpts) *Spotify {
 	return &Spotify{
-		RequestClient: setupRequestClient(opts.RequestClient),
+		RequestClient: setupRequestClient(opts.RequestClient.SetTimeout(opts.RequestTimeout)),
 		Config: Config{
-			UserID:                    opts.UserID,
 			ClientID:                  opts.ClientID,
 			BaseAPIURL:                opts.BaseAPIURL,
 			ClientSecret:              opts.ClientSecret,
 			AuthenticationURL:         opts.AuthenticationURL,
+			AuthorizationURL:          opts.AuthorizationURL,
 			AuthenticationRedirectURL: opts.AuthenticationRedirectURL,
+			RequestTimeout:            opts.RequestTimeout,
 		},
 	}
 }
 
-func (s *Spotify) GetPlaylist(playlistURL string) (utils.Playlist, error) {
+func (s *Spotify) GetPlaylist(ctx context.Context, playlistURL string) (utils.Playlist, error) {
 	playlistID, err := parsePlaylistURL(playlistURL)
 	if err != nil {
 		return utils.Playlist{}, err
 	}
 
-	clientAuthToken, err := s.getClientAuthenticationCredentials()
+	clientAuthToken, err := s.getClientAuthenticationCredentials(ctx)
 	if err != nil {
 		return utils.Playlist{}, err
 	}
@@ -45,7 +54,7 @@ func (s *Spotify) GetPlaylist(playlistURL string) (utils.Playlist, error) {
 		Get(s.Config.BaseAPIURL + "/playlists/" + playlistID).
 		SetBearerAuthToken(clientAuthToken).
 		SetContentType(utils.ApplicationJSON).
-		Do().
+		Do(ctx).
 		Into(&response)
 
 	if err != nil {
@@ -54,6 +63,7 @@ func (s *Spotify) GetPlaylist(playlistURL string) (utils.Playlist, error) {
 
 	playlist := parseGetPlaylistResponse(&response)
 	if response.Tracks.Next == "" {
+		playlist.Finalise(string(Platform))
 		return playlist, nil
 	}
 
@@ -65,10 +75,14 @@ func (s *Spotify) GetPlaylist(playlistURL string) (utils.Playlist, error) {
 			Get(response.Tracks.Next).
 			SetBearerAuthToken(clientAuthToken).
 			SetContentType(utils.ApplicationJSON).
-			Do().
+			Do(ctx).
 			Into(&playlistItemsResp)
 
 		if err != nil {
+			// the request was cancelled, do not return a partial playlist.
+			if ctx.Err() != nil {
+				return utils.Playlist{}, ctx.Err()
+			}
 			break
 		}
 
@@ -80,24 +94,25 @@ func (s *Spotify) GetPlaylist(playlistURL string) (utils.Playlist, error) {
 		response.Tracks = playlistItemsResp
 	}
 
+	playlist.Finalise(string(Platform))
 	return playlist, nil
 }
 
 // CreatePlaylist uses our internal playlist object to create a playlist on Spotify.
-func (s *Spotify) CreatePlaylist(playlist utils.Playlist, accessToken string) (string, error) {
+// The playlist is created in the account of the owner of the access token.
+func (s *Spotify) CreatePlaylist(ctx context.Context, playlist utils.Playlist, accessToken string) (string, error) {
 	var response spotifyAPICreatePlaylistResponse
 	var trackURIs []string
-	var wg sync.WaitGroup
 
 	err := s.RequestClient.
-		Post(s.Config.BaseAPIURL + "/users/" + s.Config.UserID + "/playlists").
+		Post(s.Config.BaseAPIURL + "/me/playlists").
 		SetBearerAuthToken(accessToken).
 		SetBodyJsonMarshal(map[string]any{
 			"name":        playlist.Title,
 			"description": playlist.Description,
 			"public":      true,
 		}).
-		Do().
+		Do(ctx).
 		Into(&response)
 
 	if err != nil {
@@ -110,36 +125,39 @@ func (s *Spotify) CreatePlaylist(playlist utils.Playlist, accessToken string) (s
 		}
 	}
 
+	totalTracks := len(trackURIs)
 	// https://github.com/golang/go/wiki/SliceTricks#batching-with-minimal-allocation
 	requestsPayloads := make([][]string, 0, (len(trackURIs)+maximumNumOfTracksPerRequest-1)/maximumNumOfTracksPerRequest)
 	for maximumNumOfTracksPerRequest < len(trackURIs) {
 		trackURIs, requestsPayloads = trackURIs[maximumNumOfTracksPerRequest:], append(requestsPayloads, trackURIs[0:maximumNumOfTracksPerRequest:maximumNumOfTracksPerRequest])
 	}
 	requestsPayloads = append(requestsPayloads, trackURIs)
+
+	// the batches are added one after the other, so the tracks keep their order in the playlist.
+	var addedTracks int
 	for _, payload := range requestsPayloads {
-		wg.Add(1)
-
-		go func(entry []string) {
-			defer wg.Done()
-			err := s.RequestClient.
-				Post(s.Config.BaseAPIURL + "/playlists/" + response.ID + "/tracks").
-				SetBearerAuthToken(accessToken).
-				SetBodyJsonMarshal(map[string]any{
-					"uris": entry,
-				}).
-				Do()
-
-			if err != nil {
-				return
+		err = s.RequestClient.
+			Post(s.Config.BaseAPIURL + "/playlists/" + response.ID + "/tracks").
+			SetBearerAuthToken(accessToken).
+			SetBodyJsonMarshal(map[string]any{
+				"uris": payload,
+			}).
+			Do(ctx).Err
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
 			}
-		}(payload)
+			continue
+		}
+		addedTracks += len(payload)
+		registry.ReportProgress(ctx, addedTracks, totalTracks)
 	}
-	wg.Wait()
 
 	return basePlaylistURL + response.ID, nil
 }
 
-func (s *Spotify) RefreshAccessToken(payload utils.OauthCredentials) (utils.OauthCredentials, error) {
+func (s *Spotify) RefreshAccessToken(ctx context.Context, payload utils.OauthCredentials) (utils.OauthCredentials, error) {
 	var response spotifyAPIRefreshTokenResponse
 
 	err := s.RequestClient.
@@ -147,31 +165,43 @@ func (s *Spotify) RefreshAccessToken(payload utils.OauthCredentials) (utils.Oaut
 		SetFormData(map[string]string{"grant_type": "refresh_token", "refresh_token": payload.RefreshToken}).
 		SetBasicAuth(s.Config.ClientID, s.Config.ClientSecret).
 		SetContentType("application/x-www-form-urlencoded").
-		Do().
+		Do(ctx).
 		Into(&response)
 
 	if err != nil {
 		return utils.OauthCredentials{}, err
 	}
 
-	return utils.OauthCredentials{AccessToken: response.AccessToken, ExpiresAt: response.ExpiresIn}, nil
+	// spotify only returns a refresh token when the previous one should no longer be used.
+	return utils.OauthCredentials{AccessToken: response.AccessToken, RefreshToken: response.RefreshToken, ExpiresAt: utils.ExpiresAt(response.ExpiresIn)}, nil
 }
 
-func (s *Spotify) GetAuthorizationCode(code string) (utils.OauthCredentials, error) {
+func (s *Spotify) GetAuthorizationCode(ctx context.Context, code string) (utils.OauthCredentials, error) {
 	var response spotifyAPIBearerCredentialsResponse
 	err := s.RequestClient.
 		Post(s.Config.AuthenticationURL).
 		SetFormData(map[string]string{"grant_type": "authorization_code", "code": code, "redirect_uri": s.Config.AuthenticationRedirectURL}).
 		SetBasicAuth(s.Config.ClientID, s.Config.ClientSecret).
 		SetContentType("application/x-www-form-urlencoded").
-		Do().
+		Do(ctx).
 		Into(&response)
 
 	if err != nil {
 		return utils.OauthCredentials{}, err
 	}
 
-	return utils.OauthCredentials{AccessToken: response.AccessToken, RefreshToken: response.RefreshToken, ExpiresAt: response.ExpiresAt}, nil
+	return utils.OauthCredentials{AccessToken: response.AccessToken, RefreshToken: response.RefreshToken, ExpiresAt: utils.ExpiresAt(response.ExpiresIn)}, nil
+}
+
+// GetAuthorizationURL returns the link users follow to grant access to their account, the state is sent back to the callback.
+func (s *Spotify) GetAuthorizationURL(state string) (string, error) {
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", s.Config.ClientID)
+	query.Set("redirect_uri", s.Config.AuthenticationRedirectURL)
+	query.Set("scope", oauthScopes)
+	query.Set("state", state)
+	return s.Config.AuthorizationURL + "?" + query.Encode(), nil
 }
 
 // RequiresAccessToken specifies if the streaming requires Oauth.
@@ -179,40 +209,327 @@ func (*Spotify) RequiresAccessToken() bool {
 	return true
 }
 
-func (s *Spotify) LookupTrack(track utils.Track) (utils.Track, error) {
-	var foundTrack utils.Track
+// LookupTrack searches for a track on Spotify and returns the top result.
+func (s *Spotify) LookupTrack(ctx context.Context, track utils.Track) (utils.Track, error) {
+	tracks, err := s.SearchTracks(ctx, track)
+	if err != nil {
+		return utils.Track{}, err
+	}
 
-	clientAuthToken, err := s.getClientAuthenticationCredentials()
+	return tracks[0], nil
+}
+
+// SearchTracks returns the tracks on Spotify that match the track, best first.
+// An exact ISRC lookup is tried first, the title and artist search is used when it finds nothing.
+func (s *Spotify) SearchTracks(ctx context.Context, track utils.Track) ([]utils.Track, error) {
+	clientAuthToken, err := s.getClientAuthenticationCredentials(ctx)
 	if err != nil {
-		return foundTrack, fmt.Errorf("spotify: %s", err.Error())
+		return nil, fmt.Errorf("spotify: %w", err)
 	}
 
-	var response spotifyAPISearchResponse
+	if isrc := matching.NormaliseISRC(track.ISRC); isrc != "" {
+		tracks, _err := s.search(ctx, isrcToSearchQuery(isrc), clientAuthToken)
+		if _err != nil {
+			return nil, fmt.Errorf("spotify: %w", _err)
+		}
+		if len(tracks) > 0 {
+			return utils.SetMatchStrategy(tracks, utils.ISRCMatch), nil
+		}
+	}
+
+	tracks, err := s.search(ctx, trackToSearchQuery(track), clientAuthToken)
+	if err != nil {
+		return nil, fmt.Errorf("spotify: %w", err)
+	}
+	if len(tracks) == 0 {
+		return nil, registry.NewError(registry.ErrNotFound, "spotify: no track found that matches %s", track.Title)
+	}
+
+	return utils.SetMatchStrategy(tracks, utils.TextSearchMatch), nil
+}
+
+// TrackURL returns the Spotify link of the track with the given ID.
+func (s *Spotify) TrackURL(trackID string) string {
+	return "https://open.spotify.com/track/" + trackID
+}
+
+// GetTrack returns the track a Spotify link points at.
+func (s *Spotify) GetTrack(ctx context.Context, trackURL string) (utils.Track, error) {
+	trackID, err := parseTrackURL(trackURL)
+	if err != nil {
+		return utils.Track{}, err
+	}
+
+	clientAuthToken, err := s.getClientAuthenticationCredentials(ctx)
+	if err != nil {
+		return utils.Track{}, err
+	}
+
+	var response spotifyAPITrack
 	err = s.RequestClient.
+		Get(s.Config.BaseAPIURL + "/tracks/" + trackID).
+		SetBearerAuthToken(clientAuthToken).
+		SetContentType(utils.ApplicationJSON).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return utils.Track{}, err
+	}
+
+	return parseTrack(response), nil
+}
+
+// GetAlbum returns the album a Spotify link points at, along with its tracks.
+func (s *Spotify) GetAlbum(ctx context.Context, albumURL string) (utils.Album, error) {
+	albumID, err := parseAlbumURL(albumURL)
+	if err != nil {
+		return utils.Album{}, err
+	}
+
+	clientAuthToken, err := s.getClientAuthenticationCredentials(ctx)
+	if err != nil {
+		return utils.Album{}, err
+	}
+
+	var response spotifyAPIAlbum
+	err = s.RequestClient.
+		Get(s.Config.BaseAPIURL + "/albums/" + albumID).
+		SetBearerAuthToken(clientAuthToken).
+		SetContentType(utils.ApplicationJSON).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return utils.Album{}, err
+	}
+
+	var trackIDs []string
+	for _, entry := range response.Tracks.Items {
+		trackIDs = append(trackIDs, entry.ID)
+	}
+
+	// spotify returns at most 50 tracks per request, this while loop gets the other items.
+	for next := response.Tracks.Next; next != ""; {
+		var albumTracksResp spotifyAPIAlbumTracksResponse
+		err = s.RequestClient.
+			Get(next).
+			SetBearerAuthToken(clientAuthToken).
+			SetContentType(utils.ApplicationJSON).
+			Do(ctx).
+			Into(&albumTracksResp)
+
+		if err != nil {
+			return utils.Album{}, err
+		}
+
+		for _, entry := range albumTracksResp.Items {
+			trackIDs = append(trackIDs, entry.ID)
+		}
+		next = albumTracksResp.Next
+	}
+
+	album := parseAlbum(response)
+	// the tracks of an album do not have their ISRC, so they are retrieved again in full.
+	for start := 0; start < len(trackIDs); start += maximumNumOfTrackIDsPerRequest {
+		end := start + maximumNumOfTrackIDsPerRequest
+		if end > len(trackIDs) {
+			end = len(trackIDs)
+		}
+
+		var tracksResp spotifyAPIGetTracksResponse
+		err = s.RequestClient.
+			Get(s.Config.BaseAPIURL+"/tracks").
+			SetBearerAuthToken(clientAuthToken).
+			SetContentType(utils.ApplicationJSON).
+			SetQueryParam("ids", strings.Join(trackIDs[start:end], ",")).
+			Do(ctx).
+			Into(&tracksResp)
+
+		if err != nil {
+			return utils.Album{}, err
+		}
+
+		for _, entry := range tracksResp.Tracks {
+			album.Tracks = append(album.Tracks, parseTrack(entry))
+		}
+	}
+
+	album.Finalise(string(Platform))
+	return album, nil
+}
+
+// LookupAlbum searches for an album on Spotify and returns the top result.
+// An exact UPC lookup is tried first, the title and artist search is used when it finds nothing.
+func (s *Spotify) LookupAlbum(ctx context.Context, album utils.Album) (utils.Album, error) {
+	clientAuthToken, err := s.getClientAuthenticationCredentials(ctx)
+	if err != nil {
+		return utils.Album{}, fmt.Errorf("spotify: %w", err)
+	}
+
+	if album.UPC != "" {
+		albums, _err := s.searchAlbums(ctx, upcToSearchQuery(album.UPC), clientAuthToken)
+		if _err == nil && len(albums) > 0 {
+			match := albums[0]
+			// search results do not include the UPC, it is the one that was searched for.
+			match.UPC = album.UPC
+			match.MatchStrategy = utils.UPCMatch
+			return match, nil
+		}
+	}
+
+	albums, err := s.searchAlbums(ctx, albumToSearchQuery(album), clientAuthToken)
+	if err != nil {
+		return utils.Album{}, fmt.Errorf("spotify: %w", err)
+	}
+	if len(albums) == 0 {
+		return utils.Album{}, registry.NewError(registry.ErrNotFound, "spotify: no album found that matches %s", album.Title)
+	}
+
+	match := albums[0]
+	match.MatchStrategy = utils.TextSearchMatch
+	return match, nil
+}
+
+// SaveAlbum saves the album to the library of the owner of the access token.
+func (s *Spotify) SaveAlbum(ctx context.Context, album utils.Album, accessToken string) error {
+	return s.RequestClient.
+		Put(s.Config.BaseAPIURL + "/me/albums").
+		SetBearerAuthToken(accessToken).
+		SetBodyJsonMarshal(map[string]any{
+			"ids": []string{album.ID},
+		}).
+		Do(ctx).
+		Err
+}
+
+// GetAccount returns the account of the owner of the access token.
+func (s *Spotify) GetAccount(ctx context.Context, accessToken string) (utils.Account, error) {
+	var response spotifyAPIUserResponse
+	err := s.RequestClient.
+		Get(s.Config.BaseAPIURL + "/me").
+		SetBearerAuthToken(accessToken).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return utils.Account{}, err
+	}
+
+	return utils.Account{ID: response.ID, Name: response.DisplayName}, nil
+}
+
+// GetSavedTracks returns the tracks saved to the library of the owner of the access token, most recently saved first.
+func (s *Spotify) GetSavedTracks(ctx context.Context, accessToken string) ([]utils.Track, error) {
+	var tracks []utils.Track
+
+	// spotify returns at most 50 saved tracks per request, this while loop gets every page.
+	for next := s.Config.BaseAPIURL + "/me/tracks?limit=50"; next != ""; {
+		var response spotifyAPITracksResponse
+		err := s.RequestClient.
+			Get(next).
+			SetBearerAuthToken(accessToken).
+			SetContentType(utils.ApplicationJSON).
+			Do(ctx).
+			Into(&response)
+
+		if err != nil {
+			return nil, err
+		}
+
+		tracks = append(tracks, parseTracksResponse(response)...)
+		next = response.Next
+	}
+
+	return tracks, nil
+}
+
+// SaveTracks saves the tracks to the library of the owner of the access token.
+func (s *Spotify) SaveTracks(ctx context.Context, tracks []utils.Track, accessToken string) error {
+	var trackIDs []string
+	for _, entry := range tracks {
+		if ok := utils.Contains(trackIDs, entry.ID); !ok {
+			trackIDs = append(trackIDs, entry.ID)
+		}
+	}
+
+	for start := 0; start < len(trackIDs); start += maximumNumOfTrackIDsPerRequest {
+		end := start + maximumNumOfTrackIDsPerRequest
+		if end > len(trackIDs) {
+			end = len(trackIDs)
+		}
+
+		err := s.RequestClient.
+			Put(s.Config.BaseAPIURL + "/me/tracks").
+			SetBearerAuthToken(accessToken).
+			SetBodyJsonMarshal(map[string]any{
+				"ids": trackIDs[start:end],
+			}).
+			Do(ctx).
+			Err
+
+		if err != nil {
+			return err
+		}
+		registry.ReportProgress(ctx, end, len(trackIDs))
+	}
+
+	return nil
+}
+
+// searchAlbums returns the albums that match the search query.
+func (s *Spotify) searchAlbums(ctx context.Context, query, clientAuthToken string) ([]utils.Album, error) {
+	var response spotifyAPISearchAlbumsResponse
+	err := s.RequestClient.
+		Get(s.Config.BaseAPIURL + "/search").
+		SetBearerAuthToken(clientAuthToken).
+		SetContentType(utils.ApplicationJSON).
+		SetQueryParams(map[string]string{
+			"q":     query,
+			"type":  "album",
+			"limit": "1",
+		}).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var albums []utils.Album
+	for _, entry := range response.Albums.Items {
+		album := parseAlbum(entry)
+		album.Platform = string(Platform)
+		albums = append(albums, album)
+	}
+
+	return albums, nil
+}
+
+// search returns the tracks that match the search query.
+func (s *Spotify) search(ctx context.Context, query, clientAuthToken string) ([]utils.Track, error) {
+	var response spotifyAPISearchResponse
+	err := s.RequestClient.
 		Get(s.Config.BaseAPIURL + "/search").
 		SetBearerAuthToken(clientAuthToken).
 		SetContentType(utils.ApplicationJSON).
 		SetQueryParams(map[string]string{
-			"q":     trackToSearchQuery(track),
+			"q":     query,
 			"type":  "track",
 			"limit": "5",
 		}).
-		Do().
+		Do(ctx).
 		Into(&response)
 
 	if err != nil {
-		return foundTrack, fmt.Errorf("spotify: %s", err.Error())
-	}
-	if len(response.Tracks.Items) == 0 {
-		return foundTrack, fmt.Errorf("spotify: no track found that matches %s", track.Title)
+		return nil, err
 	}
 
-	foundTrack = parseSearchResponse(response)[0]
-	return foundTrack, nil
+	return parseSearchResponse(response), nil
 }
 
 // getClientAuthenticationCredentials fetches the client credentials needed for Spotify authentication.
-func (s *Spotify) getClientAuthenticationCredentials() (string, error) {
+func (s *Spotify) getClientAuthenticationCredentials(ctx context.Context) (string, error) {
 	if token, ok := utils.GlobalCache.Get("spotifyClientAuthToken"); ok {
 		if val, ok := token.(string); ok {
 			return val, nil
@@ -226,7 +543,7 @@ func (s *Spotify) getClientAuthenticationCredentials() (string, error) {
 		Post(s.Config.AuthenticationURL).
 		SetBasicAuth(s.Config.ClientID, s.Config.ClientSecret).
 		SetFormData(map[string]string{"grant_type": "client_credentials"}).
-		Do().
+		Do(ctx).
 		Into(&response)
 
 	if err != nil {
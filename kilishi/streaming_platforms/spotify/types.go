@@ -2,6 +2,7 @@ package spotify
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/imroc/req/v3"
 )
@@ -15,29 +16,36 @@ type InitialisationOpts struct {
 	RequestClient             *req.Client
 	BaseAPIURL                string
 	ClientID                  string
-	UserID                    string
 	ClientSecret              string
 	AuthenticationURL         string
+	AuthorizationURL          string
 	AuthenticationRedirectURL string
+	RequestTimeout            time.Duration
 }
 
 type Config struct {
-	BaseAPIURL                string
-	UserID                    string
-	ClientID                  string
-	ClientSecret              string
-	AuthenticationURL         string
-	AuthenticationRedirectURL string
+	BaseAPIURL                string        `env:"SPOTIFY_BASE_API_URL,notEmpty"`
+	ClientID                  string        `env:"SPOTIFY_CLIENT_ID,notEmpty"`
+	ClientSecret              string        `env:"SPOTIFY_CLIENT_SECRET,notEmpty"`
+	AuthenticationURL         string        `env:"SPOTIFY_CLIENT_AUTH_URL,notEmpty"`
+	AuthorizationURL          string        `env:"SPOTIFY_AUTHORIZATION_URL" envDefault:"https://accounts.spotify.com/authorize"`
+	AuthenticationRedirectURL string        `env:"SPOTIFY_AUTH_REDIRECT_URL,notEmpty"`
+	RequestTimeout            time.Duration `env:"SPOTIFY_REQUEST_TIMEOUT" envDefault:"30s"`
+	RateLimit                 float64       `env:"SPOTIFY_RATE_LIMIT" envDefault:"10"`
+	RateLimitBurst            int           `env:"SPOTIFY_RATE_LIMIT_BURST" envDefault:"10"`
+	MaxConcurrentRequests     int           `env:"SPOTIFY_MAX_CONCURRENT_REQUESTS" envDefault:"10"`
 }
 
 // API Types (Autogenerated).
 type spotifyAPIGetPlaylistResponse struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Images      []struct {
-		URL string `json:"url"`
-	} `json:"images"`
+	ID           string                 `json:"id"`
+	Name         string                 `json:"name"`
+	Description  string                 `json:"description"`
+	Images       []spotifyAPIImage      `json:"images"`
+	ExternalURLs spotifyAPIExternalURLs `json:"external_urls"`
+	Owner        struct {
+		DisplayName string `json:"display_name"`
+	} `json:"owner"`
 	Tracks spotifyAPITracksResponse `json:"tracks"`
 }
 
@@ -45,37 +53,90 @@ type spotifyAPITracksResponse struct {
 	Next  string `json:"next"`
 	Total int    `json:"total"`
 	Items []struct {
-		Track struct {
-			ID      string `json:"id"`
-			Name    string `json:"name"`
-			Artists []struct {
-				Name string `json:"name"`
-			} `json:"artists"`
-		} `json:"track"`
+		Track spotifyAPITrack `json:"track"`
 	} `json:"items"`
 }
 
 type spotifyAPISearchResponse struct {
 	Tracks struct {
-		Limit int    `json:"limit"`
-		Next  string `json:"next"`
-		Total int    `json:"total"`
-		Items []struct {
-			Artists []struct {
-				Name string `json:"name"`
-			} `json:"artists"`
-			ID   string `json:"id"`
-			Name string `json:"name"`
-		} `json:"items"`
+		Limit int               `json:"limit"`
+		Next  string            `json:"next"`
+		Total int               `json:"total"`
+		Items []spotifyAPITrack `json:"items"`
 	} `json:"tracks"`
 }
 
+type spotifyAPITrack struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Artists []struct {
+		Name string `json:"name"`
+	} `json:"artists"`
+	DurationMs   int                    `json:"duration_ms"`
+	Explicit     bool                   `json:"explicit"`
+	Album        spotifyAPIAlbum        `json:"album"`
+	ExternalIDs  spotifyAPIExternalIDs  `json:"external_ids"`
+	ExternalURLs spotifyAPIExternalURLs `json:"external_urls"`
+}
+
+type spotifyAPIAlbum struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Artists []struct {
+		Name string `json:"name"`
+	} `json:"artists"`
+	Images       []spotifyAPIImage      `json:"images"`
+	ReleaseDate  string                 `json:"release_date"`
+	TotalTracks  int                    `json:"total_tracks"`
+	ExternalIDs  spotifyAPIExternalIDs  `json:"external_ids"`
+	ExternalURLs spotifyAPIExternalURLs `json:"external_urls"`
+	// Tracks is only returned when a single album is requested.
+	Tracks spotifyAPIAlbumTracksResponse `json:"tracks"`
+}
+
+// spotifyAPIAlbumTracksResponse lists the tracks of an album, they do not have their ISRC.
+type spotifyAPIAlbumTracksResponse struct {
+	Next  string `json:"next"`
+	Total int    `json:"total"`
+	Items []struct {
+		ID string `json:"id"`
+	} `json:"items"`
+}
+
+type spotifyAPIGetTracksResponse struct {
+	Tracks []spotifyAPITrack `json:"tracks"`
+}
+
+type spotifyAPISearchAlbumsResponse struct {
+	Albums struct {
+		Items []spotifyAPIAlbum `json:"items"`
+	} `json:"albums"`
+}
+
+type spotifyAPIImage struct {
+	URL string `json:"url"`
+}
+
+type spotifyAPIExternalIDs struct {
+	ISRC string `json:"isrc"`
+	UPC  string `json:"upc"`
+}
+
+type spotifyAPIExternalURLs struct {
+	Spotify string `json:"spotify"`
+}
+
 type spotifyAPICreatePlaylistResponse struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
 	URL  string `json:"uri"`
 }
 
+type spotifyAPIUserResponse struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
+}
+
 type spotifyAPIClientCredentialsResponse struct {
 	AccessToken string `json:"access_token"`
 	ExpiresIn   int    `json:"expires_in"`
@@ -85,14 +146,15 @@ type spotifyAPIClientCredentialsResponse struct {
 type spotifyAPIBearerCredentialsResponse struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
-	ExpiresAt    int    `json:"expires_at"`
+	ExpiresIn    int    `json:"expires_in"`
 }
 
 type spotifyAPIRefreshTokenResponse struct {
-	AccessToken string `json:"access_token"`
-	TokenType   string `json:"token_type"`
-	Scope       string `json:"scope"`
-	ExpiresIn   int    `json:"expires_in"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	Scope        string `json:"scope"`
+	ExpiresIn    int    `json:"expires_in"`
 }
 
 type spotifyAPIError struct {
@@ -100,8 +162,15 @@ type spotifyAPIError struct {
 		Status  uint   `json:"status"`
 		Message string `json:"message"`
 	} `json:"error"`
+	// kind is the kind of error the platform answered with, see Unwrap.
+	kind error
 }
 
 func (e *spotifyAPIError) Error() string {
 	return fmt.Sprintf("Spotify API Error: status: %v  reason: %s", e.APIError.Status, e.APIError.Message)
 }
+
+// Unwrap returns the kind of error the platform answered with, e.g. `registry.ErrNotFound`.
+func (e *spotifyAPIError) Unwrap() error {
+	return e.kind
+}
@@ -2,25 +2,51 @@ package spotify
 
 import (
 	"fmt"
+	"net/http"
 	"regexp"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/imroc/req/v3"
 
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
 	"github.com/prettyirrelevant/kilishi/utils"
 )
 
+var (
+	playlistURLRegex = regexp.MustCompile(`^(?:https://open\.spotify\.com/(?:intl-[a-z-]+/)?playlist/|spotify:playlist:)([a-zA-Z0-9]+)`)
+	albumURLRegex    = regexp.MustCompile(`^(?:https://open\.spotify\.com/(?:intl-[a-z-]+/)?album/|spotify:album:)([a-zA-Z0-9]+)`)
+	trackURLRegex    = regexp.MustCompile(`^(?:https://open\.spotify\.com/(?:intl-[a-z-]+/)?track/|spotify:track:)([a-zA-Z0-9]+)`)
+)
+
 // parsePlaylistURL validates a Spotify playlist URL and returns the playlist ID.
 func parsePlaylistURL(playlistURL string) (string, error) {
-	re := regexp.MustCompile(`^(https:\/\/open\.spotify\.com\/playlist\/)([a-zA-Z0-9]+)(\?si=[a-zA-Z0-9]+)?`)
+	matches := playlistURLRegex.FindStringSubmatch(playlistURL)
+	if len(matches) < 2 {
+		return "", registry.NewError(registry.ErrInvalidInput, "spotify: playlist url is invalid. check that it follows the format https://open.spotify.com/playlist/<id> or spotify:playlist:<id>")
+	}
+
+	return matches[1], nil
+}
+
+// parseTrackURL validates a Spotify track URL and returns the track ID.
+func parseTrackURL(trackURL string) (string, error) {
+	matches := trackURLRegex.FindStringSubmatch(trackURL)
+	if len(matches) < 2 {
+		return "", registry.NewError(registry.ErrInvalidInput, "spotify: track url is invalid. check that it follows the format https://open.spotify.com/track/<id> or spotify:track:<id>")
+	}
+
+	return matches[1], nil
+}
 
-	matches := re.FindStringSubmatch(playlistURL)
-	if len(matches) < 3 {
-		return "", fmt.Errorf("spotify: playlist url is invalid. check that it follows the format https://open.spotify.com/playlist/<id>")
+// parseAlbumURL validates a Spotify album URL and returns the album ID.
+func parseAlbumURL(albumURL string) (string, error) {
+	matches := albumURLRegex.FindStringSubmatch(albumURL)
+	if len(matches) < 2 {
+		return "", registry.NewError(registry.ErrInvalidInput, "spotify: album url is invalid. check that it follows the format https://open.spotify.com/album/<id> or spotify:album:<id>")
 	}
 
-	return matches[2], nil
+	return matches[1], nil
 }
 
 // parseGetPlaylistResponse transforms the playlist object returned from Spotify API into our internal object.
@@ -31,6 +57,9 @@ func parseGetPlaylistResponse(response *spotifyAPIGetPlaylistResponse) utils.Pla
 		Title:       response.Name,
 		Description: response.Description,
 		Tracks:      tracks,
+		Owner:       response.Owner.DisplayName,
+		ImageURL:    firstImageURL(response.Images),
+		URL:         response.ExternalURLs.Spotify,
 	}
 }
 
@@ -39,18 +68,9 @@ func parseTracksResponse(response spotifyAPITracksResponse) []utils.Track {
 	var tracks []utils.Track
 
 	for _, entry := range response.Items {
-		artistes := []string{}
-		for _, artiste := range entry.Track.Artists {
-			artistes = append(artistes, artiste.Name)
-		}
-		tracks = append(
-			tracks,
-			utils.Track{
-				ID:      entry.Track.ID,
-				Title:   utils.CleanTrackTitle(entry.Track.Name),
-				Artists: artistes,
-			},
-		)
+		track := parseTrack(entry.Track)
+		track.Title = utils.CleanTrackTitle(track.Title)
+		tracks = append(tracks, track)
 	}
 
 	return tracks
@@ -61,21 +81,85 @@ func parseSearchResponse(response spotifyAPISearchResponse) []utils.Track {
 	var tracks []utils.Track
 
 	for _, entry := range response.Tracks.Items {
-		artistes := []string{}
-		for _, artiste := range entry.Artists {
-			artistes = append(artistes, artiste.Name)
-		}
-		tracks = append(tracks, utils.Track{ID: entry.ID, Title: entry.Name, Artists: artistes})
+		tracks = append(tracks, parseTrack(entry))
 	}
 
 	return tracks
 }
 
+// parseTrack transforms a track object returned from Spotify API into our internal object.
+func parseTrack(track spotifyAPITrack) utils.Track {
+	artistes := []string{}
+	for _, artiste := range track.Artists {
+		artistes = append(artistes, artiste.Name)
+	}
+
+	return utils.Track{
+		ID:         track.ID,
+		Title:      track.Name,
+		Artists:    artistes,
+		ISRC:       track.ExternalIDs.ISRC,
+		Duration:   track.DurationMs / 1000,
+		Album:      track.Album.Name,
+		Explicit:   track.Explicit,
+		ArtworkURL: firstImageURL(track.Album.Images),
+		URL:        track.ExternalURLs.Spotify,
+	}
+}
+
+// parseAlbum transforms an album object returned from Spotify API into our internal object, without its tracks.
+func parseAlbum(album spotifyAPIAlbum) utils.Album {
+	artistes := []string{}
+	for _, artiste := range album.Artists {
+		artistes = append(artistes, artiste.Name)
+	}
+
+	return utils.Album{
+		ID:          album.ID,
+		Title:       album.Name,
+		Artists:     artistes,
+		UPC:         album.ExternalIDs.UPC,
+		ReleaseDate: album.ReleaseDate,
+		ImageURL:    firstImageURL(album.Images),
+		URL:         album.ExternalURLs.Spotify,
+		TrackCount:  album.TotalTracks,
+	}
+}
+
+// firstImageURL returns the URL of the largest image, spotify sorts images by size in descending order.
+func firstImageURL(images []spotifyAPIImage) string {
+	for _, image := range images {
+		return image.URL
+	}
+	return ""
+}
+
+// isrcToSearchQuery transforms an ISRC into a Spotify search query that only matches that recording.
+func isrcToSearchQuery(isrc string) string {
+	return "isrc:" + isrc
+}
+
 // trackIDToURI transforms a Spotify ID into URL.
 func trackIDToURI(track utils.Track) string {
 	return "spotify:track:" + track.ID
 }
 
+// upcToSearchQuery transforms a UPC into a Spotify search query that only matches that release.
+func upcToSearchQuery(upc string) string {
+	return "upc:" + upc
+}
+
+// albumToSearchQuery transforms our internal album object into a Spotify search query.
+func albumToSearchQuery(album utils.Album) string {
+	q := fmt.Sprintf("album:%s", album.Title)
+	for _, artistName := range album.Artists {
+		q += fmt.Sprintf(" artist:%s", artistName)
+		break // search with > 1 artiste fails.
+	}
+
+	return q
+}
+
 // trackToSearchQuery transforms our internal track object into a Spotify search query.
 func trackToSearchQuery(track utils.Track) string {
 	q := fmt.Sprintf("track:%s", track.Title)
@@ -92,26 +176,36 @@ func setupRequestClient(reqClient *req.Client) *req.Client {
 		EnableDumpEachRequest().
 		SetCommonErrorResult(&spotifyAPIError{}).
 		OnAfterResponse(func(client *req.Client, resp *req.Response) error {
+			// The access token has expired or the refresh token was revoked, see `registry.ErrUnauthorized`.
+			if resp.Response != nil && (resp.StatusCode == http.StatusUnauthorized || (resp.StatusCode == http.StatusBadRequest && strings.Contains(resp.String(), "invalid_grant"))) {
+				resp.Err = fmt.Errorf("%w: %s", registry.ErrUnauthorized, resp.String())
+				return nil
+			}
 			// There is an underlying error, e.g. network error or unmarshal error.
 			if resp.Err != nil {
+				// the platform could not be reached, see `registry.ErrUpstreamUnavailable`.
+				if resp.Response == nil {
+					resp.Err = registry.TransportError(resp.Err)
+				}
 				return nil
 			}
 			// Server returns an error message, convert it to human-readable Go error.
 			if apiErr, ok := resp.ErrorResult().(*spotifyAPIError); ok {
+				apiErr.kind = registry.ErrorKind(resp.Response)
 				resp.Err = apiErr
 				return nil
 			}
 			// Edge case: neither an error state response nor a success state response,
 			// dump content to help troubleshoot.
 			if !resp.IsSuccessState() {
-				resp.Err = fmt.Errorf("bad status: %s\nraw content:\n%s", resp.Status, resp.Dump())
+				resp.Err = registry.NewError(registry.ErrorKind(resp.Response), "bad status: %s\nraw content:\n%s", resp.Status, resp.Dump())
 				return nil
 			}
 			return nil
 		}).
 		SetCommonRetryCount(3).
 		AddCommonRetryCondition(func(resp *req.Response, err error) bool {
-			return resp.StatusCode == 429
+			return resp.Response != nil && resp.StatusCode == 429
 		}).
 		SetCommonRetryInterval(func(resp *req.Response, attempt int) time.Duration {
 			// https://developer.spotify.com/documentation/web-api/guides/rate-limits/
@@ -119,9 +213,8 @@ func setupRequestClient(reqClient *req.Client) *req.Client {
 				return 2 * time.Second
 			}
 
-			retryAfterHeader := resp.Header.Get("Retry-After")
-			if retryAfter, err := strconv.Atoi(retryAfterHeader); retryAfterHeader != "" && err != nil {
-				return time.Duration(retryAfter) * time.Second
+			if retryAfter := registry.RetryAfter(resp.Response); retryAfter > 0 {
+				return retryAfter
 			}
 
 			return 2 * time.Second
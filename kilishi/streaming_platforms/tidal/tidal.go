@@ -0,0 +1,356 @@
+package tidal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/matching"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+	"github.com/prettyirrelevant/kilishi/utils"
+)
+
+const (
+	basePlaylistURL              = "https://tidal.com/browse/playlist/"
+	baseTrackURL                 = "https://tidal.com/browse/track/"
+	maximumNumOfTracksPerRequest = 20
+	maximumNumOfSearchResults    = 5
+	// oauthScopes are the permissions requested from users, to read and create playlists.
+	oauthScopes = "playlists.read playlists.write user.read"
+)
+
+// errPKCERequired is returned by the authorization methods that do not take part in the PKCE flow TIDAL requires.
+var errPKCERequired = errors.New("tidal: authorization requires a PKCE code challenge")
+
+// New initializes a `Tidal` object.
+func New(opts *InitialisationOpts) *Tidal {
+	return &Tidal{
+		RequestClient: setupRequestClient(opts.RequestClient.SetTimeout(opts.RequestTimeout), opts.BaseAPIURL),
+		Config: Config{
+			BaseAPIURL:                opts.BaseAPIURL,
+			ClientID:                  opts.ClientID,
+			ClientSecret:              opts.ClientSecret,
+			CountryCode:               opts.CountryCode,
+			AuthenticationURL:         opts.AuthenticationURL,
+			AuthorizationURL:          opts.AuthorizationURL,
+			AuthenticationRedirectURL: opts.AuthenticationRedirectURL,
+			RequestTimeout:            opts.RequestTimeout,
+		},
+	}
+}
+
+// GetPlaylist returns information about a playlist along with all of its tracks.
+func (t *Tidal) GetPlaylist(ctx context.Context, playlistURL string) (utils.Playlist, error) {
+	playlistID, err := parsePlaylistURL(playlistURL)
+	if err != nil {
+		return utils.Playlist{}, err
+	}
+
+	clientAuthToken, err := t.getClientAuthenticationCredentials(ctx)
+	if err != nil {
+		return utils.Playlist{}, err
+	}
+
+	var response tidalAPIGetPlaylistResponse
+	err = t.RequestClient.
+		Get("/playlists/"+playlistID).
+		SetBearerAuthToken(clientAuthToken).
+		SetQueryParam("countryCode", t.Config.CountryCode).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return utils.Playlist{}, err
+	}
+
+	playlist, err := parsePlaylistResponse(&response)
+	if err != nil {
+		return utils.Playlist{}, err
+	}
+
+	// the playlist items are paginated with a cursor, follow `links.next` until it runs out.
+	next := "/playlists/" + playlistID + "/relationships/items?countryCode=" + t.Config.CountryCode
+	for next != "" {
+		var itemsResp tidalAPIGetRelationshipResponse
+		err := t.RequestClient.
+			Get(next).
+			SetBearerAuthToken(clientAuthToken).
+			Do(ctx).
+			Into(&itemsResp)
+
+		if err != nil {
+			// the request was cancelled, do not return a partial playlist.
+			if ctx.Err() != nil {
+				return utils.Playlist{}, ctx.Err()
+			}
+			break
+		}
+
+		var trackIDs []string
+		for _, entry := range itemsResp.Data {
+			if entry.Type == "tracks" {
+				trackIDs = append(trackIDs, entry.ID)
+			}
+		}
+
+		tracks, err := t.getTracks(ctx, trackIDs, clientAuthToken)
+		if err != nil {
+			if ctx.Err() != nil {
+				return utils.Playlist{}, ctx.Err()
+			}
+			break
+		}
+
+		playlist.Tracks = append(playlist.Tracks, tracks...)
+		next = itemsResp.Links.Next
+	}
+
+	playlist.Finalise(string(Platform))
+	return playlist, nil
+}
+
+// CreatePlaylist uses our internal playlist object to create a playlist on TIDAL.
+func (t *Tidal) CreatePlaylist(ctx context.Context, playlist utils.Playlist, accessToken string) (string, error) {
+	var response tidalAPICreatePlaylistResponse
+	err := t.RequestClient.
+		Post("/playlists").
+		SetBearerAuthToken(accessToken).
+		SetContentType(jsonAPIContentType).
+		SetQueryParam("countryCode", t.Config.CountryCode).
+		SetBodyJsonMarshal(map[string]any{
+			"data": map[string]any{
+				"type": "playlists",
+				"attributes": map[string]string{
+					"name":        playlist.Title,
+					"description": playlist.Description,
+					"accessType":  "PUBLIC",
+				},
+			},
+		}).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return "", err
+	}
+
+	var trackIDs []string
+	for _, entry := range playlist.Tracks {
+		if ok := utils.Contains(trackIDs, entry.ID); !ok {
+			trackIDs = append(trackIDs, entry.ID)
+		}
+	}
+
+	// items have to be added in order, so the batches are sent one after the other.
+	var addedTracks int
+	for _, batch := range chunkIDs(trackIDs, maximumNumOfTracksPerRequest) {
+		var items []tidalAPIResourceIdentifier
+		for _, trackID := range batch {
+			items = append(items, tidalAPIResourceIdentifier{ID: trackID, Type: "tracks"})
+		}
+
+		resp := t.RequestClient.
+			Post("/playlists/" + response.Data.ID + "/relationships/items").
+			SetBearerAuthToken(accessToken).
+			SetContentType(jsonAPIContentType).
+			SetBodyJsonMarshal(map[string]any{"data": items}).
+			Do(ctx)
+
+		if resp.Err != nil {
+			return "", resp.Err
+		}
+
+		addedTracks += len(batch)
+		registry.ReportProgress(ctx, addedTracks, len(trackIDs))
+	}
+
+	return basePlaylistURL + response.Data.ID, nil
+}
+
+// LookupTrack searches for a track on TIDAL and returns the top result.
+func (t *Tidal) LookupTrack(ctx context.Context, track utils.Track) (utils.Track, error) {
+	tracks, err := t.SearchTracks(ctx, track)
+	if err != nil {
+		return utils.Track{}, err
+	}
+
+	return tracks[0], nil
+}
+
+// SearchTracks returns the tracks on TIDAL that match the track, best first.
+// An exact ISRC lookup is tried first, the search is used when it finds nothing.
+func (t *Tidal) SearchTracks(ctx context.Context, track utils.Track) ([]utils.Track, error) {
+	clientAuthToken, err := t.getClientAuthenticationCredentials(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("tidal: %w", err)
+	}
+
+	// the filter only matches the ISRC exactly as TIDAL stores it, in upper case and without dashes.
+	if isrc := matching.NormaliseISRC(track.ISRC); isrc != "" {
+		var tracksResp tidalAPIGetTracksResponse
+		_err := t.RequestClient.
+			Get("/tracks").
+			SetBearerAuthToken(clientAuthToken).
+			SetQueryParams(map[string]string{
+				"countryCode":  t.Config.CountryCode,
+				"filter[isrc]": isrc,
+				"include":      "artists,albums",
+			}).
+			Do(ctx).
+			Into(&tracksResp)
+
+		if _err != nil {
+			return nil, fmt.Errorf("tidal: %w", _err)
+		}
+		if tracks := parseTracksResponse(&tracksResp); len(tracks) > 0 {
+			return utils.SetMatchStrategy(tracks, utils.ISRCMatch), nil
+		}
+	}
+
+	var response tidalAPIGetRelationshipResponse
+	err = t.RequestClient.
+		Get("/searchResults/"+url.PathEscape(trackToSearchQuery(track))+"/relationships/tracks").
+		SetBearerAuthToken(clientAuthToken).
+		SetQueryParam("countryCode", t.Config.CountryCode).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return nil, fmt.Errorf("tidal: %w", err)
+	}
+
+	var trackIDs []string
+	for _, entry := range response.Data {
+		if len(trackIDs) == maximumNumOfSearchResults {
+			break
+		}
+		trackIDs = append(trackIDs, entry.ID)
+	}
+
+	tracks, err := t.getTracks(ctx, trackIDs, clientAuthToken)
+	if err != nil {
+		return nil, fmt.Errorf("tidal: %w", err)
+	}
+	if len(tracks) == 0 {
+		return nil, registry.NewError(registry.ErrNotFound, "tidal: no track found that matches %s", track.Title)
+	}
+
+	return utils.SetMatchStrategy(tracks, utils.TextSearchMatch), nil
+}
+
+// GetAuthorizationCode is not supported as TIDAL requires the code verifier of the authorization, see ExchangeAuthorizationCode.
+func (*Tidal) GetAuthorizationCode(_ context.Context, _ string) (utils.OauthCredentials, error) {
+	return utils.OauthCredentials{}, errPKCERequired
+}
+
+// ExchangeAuthorizationCode exchanges an authorization code for the user's oauth credentials.
+// The code verifier is the one whose challenge was sent with the authorization link.
+func (t *Tidal) ExchangeAuthorizationCode(ctx context.Context, code, codeVerifier string) (utils.OauthCredentials, error) {
+	var response tidalAPIBearerCredentialsResponse
+	err := t.RequestClient.
+		Post(t.Config.AuthenticationURL).
+		SetFormData(map[string]string{
+			"grant_type":    "authorization_code",
+			"code":          code,
+			"code_verifier": codeVerifier,
+			"client_id":     t.Config.ClientID,
+			"redirect_uri":  t.Config.AuthenticationRedirectURL,
+		}).
+		SetBasicAuth(t.Config.ClientID, t.Config.ClientSecret).
+		SetContentType("application/x-www-form-urlencoded").
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return utils.OauthCredentials{}, err
+	}
+
+	return utils.OauthCredentials{AccessToken: response.AccessToken, RefreshToken: response.RefreshToken, ExpiresAt: utils.ExpiresAt(response.ExpiresIn)}, nil
+}
+
+// GetAuthorizationURL is not supported as TIDAL requires a code challenge, see GetPKCEAuthorizationURL.
+func (*Tidal) GetAuthorizationURL(_ string) (string, error) {
+	return "", errPKCERequired
+}
+
+// GetPKCEAuthorizationURL returns the link users follow to grant access to their account, the state is sent back to the callback.
+func (t *Tidal) GetPKCEAuthorizationURL(state, codeChallenge string) (string, error) {
+	query := url.Values{}
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+	query.Set("response_type", "code")
+	query.Set("client_id", t.Config.ClientID)
+	query.Set("redirect_uri", t.Config.AuthenticationRedirectURL)
+	query.Set("scope", oauthScopes)
+	query.Set("state", state)
+	return t.Config.AuthorizationURL + "?" + query.Encode(), nil
+}
+
+// RequiresAccessToken specifies if the streaming requires Oauth.
+func (*Tidal) RequiresAccessToken() bool {
+	return true
+}
+
+// getTracks fetches the tracks with the given IDs along with their artists.
+func (t *Tidal) getTracks(ctx context.Context, trackIDs []string, clientAuthToken string) ([]utils.Track, error) {
+	var tracks []utils.Track
+	for _, batch := range chunkIDs(trackIDs, maximumNumOfTracksPerRequest) {
+		var response tidalAPIGetTracksResponse
+		err := t.RequestClient.
+			Get("/tracks").
+			SetBearerAuthToken(clientAuthToken).
+			SetQueryParams(map[string]string{
+				"countryCode": t.Config.CountryCode,
+				"filter[id]":  strings.Join(batch, ","),
+				"include":     "artists,albums",
+			}).
+			Do(ctx).
+			Into(&response)
+
+		if err != nil {
+			return tracks, err
+		}
+
+		// the API does not guarantee the order of `data`, so restore the order the IDs were requested in.
+		byID := make(map[string]utils.Track)
+		for _, track := range parseTracksResponse(&response) {
+			byID[track.ID] = track
+		}
+		for _, trackID := range batch {
+			if track, ok := byID[trackID]; ok {
+				tracks = append(tracks, track)
+			}
+		}
+	}
+
+	return tracks, nil
+}
+
+// getClientAuthenticationCredentials fetches the client credentials needed for catalog requests.
+func (t *Tidal) getClientAuthenticationCredentials(ctx context.Context) (string, error) {
+	if token, ok := utils.GlobalCache.Get("tidalClientAuthToken"); ok {
+		if val, ok := token.(string); ok {
+			return val, nil
+		}
+
+		return "", errors.New("client authentication credentials corrupted in cache")
+	}
+
+	var response tidalAPIClientCredentialsResponse
+	err := t.RequestClient.
+		Post(t.Config.AuthenticationURL).
+		SetBasicAuth(t.Config.ClientID, t.Config.ClientSecret).
+		SetFormData(map[string]string{"grant_type": "client_credentials"}).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return "", err
+	}
+
+	utils.GlobalCache.Set("tidalClientAuthToken", response.AccessToken, time.Second*time.Duration(response.ExpiresIn))
+	return response.AccessToken, nil
+}
@@ -0,0 +1,207 @@
+package tidal
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/imroc/req/v3"
+
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+	"github.com/prettyirrelevant/kilishi/utils"
+)
+
+// recordedTidal returns a client of a server that answers with the responses recorded from the TIDAL API in testdata,
+// keyed by the request path and decoded query, or by the path alone for any other query.
+// The token endpoint issues the client credentials, and the credentials of a user for the code "code" and the verifier "verifier".
+func recordedTidal(t *testing.T, responses map[string]string) (*Tidal, *[]string) {
+	t.Helper()
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, status := "", http.StatusOK
+		switch {
+		case r.URL.Path == "/oauth2/token" && r.FormValue("grant_type") == "client_credentials":
+			name = "client_credentials.json"
+		case r.URL.Path == "/oauth2/token" && r.FormValue("code") == "code" && r.FormValue("code_verifier") == "verifier":
+			name = "user_credentials.json"
+		case r.URL.Path == "/oauth2/token":
+			name, status = "invalid_grant.json", http.StatusBadRequest
+		default:
+			if r.Header.Get("Authorization") != "Bearer tidal-client-token" {
+				t.Errorf("%s was requested without the client credentials", r.URL.Path)
+			}
+
+			query, _ := url.QueryUnescape(r.URL.Query().Encode())
+			requests = append(requests, r.URL.Path+"?"+query)
+			if name = responses[r.URL.Path+"?"+query]; name == "" {
+				name = responses[r.URL.Path]
+			}
+			if name == "" {
+				name, status = "not_found.json", http.StatusNotFound
+			}
+		}
+
+		body, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", jsonAPIContentType)
+		w.WriteHeader(status)
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+
+	// the client credentials are cached, every test authenticates against its own server.
+	utils.GlobalCache.Set("tidalClientAuthToken", nil, 0)
+
+	return New(&InitialisationOpts{
+		RequestClient:     req.C(),
+		BaseAPIURL:        server.URL,
+		ClientID:          "client-id",
+		ClientSecret:      "client-secret",
+		CountryCode:       "NG",
+		AuthenticationURL: server.URL + "/oauth2/token",
+		AuthorizationURL:  "https://login.tidal.com/authorize",
+		RequestTimeout:    5 * time.Second,
+	}), &requests
+}
+
+func TestGetPlaylist(t *testing.T) {
+	client, requests := recordedTidal(t, map[string]string{
+		"/playlists/36ea71a8-445e-41a4-82ab-6628c581535d?countryCode=NG":                                          "playlist.json",
+		"/playlists/36ea71a8-445e-41a4-82ab-6628c581535d/relationships/items?countryCode=NG":                      "playlist_items.json",
+		"/playlists/36ea71a8-445e-41a4-82ab-6628c581535d/relationships/items?countryCode=NG&page[cursor]=3nI1Esi": "playlist_items_next.json",
+		"/tracks?countryCode=NG&filter[id]=158834625,232045716&include=artists,albums":                            "tracks_playlist.json",
+		"/tracks?countryCode=NG&filter[id]=243212651&include=artists,albums":                                      "tracks_playlist_next.json",
+	})
+
+	playlist, err := client.GetPlaylist(context.Background(), "https://tidal.com/browse/playlist/36ea71a8-445e-41a4-82ab-6628c581535d")
+	if err != nil {
+		t.Fatalf("GetPlaylist() error = %v after %v", err, *requests)
+	}
+	if playlist.Title != "Afrobeats Essentials" || playlist.Description != "The songs that took Afrobeats around the world." || playlist.Platform != string(Platform) {
+		t.Errorf("GetPlaylist() = %+v, attributes of the playlist are missing", playlist)
+	}
+	if want := "https://resources.tidal.com/images/9d2a7b1c/3f5e/4a2b/8c6d/1e0f2a3b4c5d/1080x1080.jpg"; playlist.ImageURL != want {
+		t.Errorf("ImageURL = %q, want the largest image %q", playlist.ImageURL, want)
+	}
+
+	// the videos of the playlist are skipped, and the tracks keep the order of the playlist rather than the order they were fetched in.
+	var ids []string
+	for _, track := range playlist.Tracks {
+		ids = append(ids, track.ID)
+	}
+	if want := []string{"158834625", "232045716", "243212651"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("track ids = %v, want %v", ids, want)
+	}
+
+	// the artists and the album are resolved from the resources included with the tracks.
+	want := utils.Track{
+		ID:         "158834625",
+		Title:      "Essence",
+		Artists:    []string{"Wizkid", "Tems"},
+		ISRC:       "USRC12100001",
+		Duration:   248,
+		Album:      "Made In Lagos",
+		Explicit:   true,
+		ArtworkURL: "https://resources.tidal.com/images/2b0e6e33/2fdf/4b7a/9116/4da3162127b5/1280x1280.jpg",
+		URL:        "https://tidal.com/browse/track/158834625",
+		Position:   1,
+	}
+	if !reflect.DeepEqual(playlist.Tracks[0], want) {
+		t.Errorf("Tracks[0] = %+v, want %+v", playlist.Tracks[0], want)
+	}
+
+	if _, err = client.GetPlaylist(context.Background(), "https://tidal.com/browse/album/158834620"); !errors.Is(err, registry.ErrInvalidInput) {
+		t.Errorf("GetPlaylist() of an album url error = %v, want %v", err, registry.ErrInvalidInput)
+	}
+	if _, err = client.GetPlaylist(context.Background(), "https://listen.tidal.com/playlist/00000000-0000-0000-0000-000000000000"); !errors.Is(err, registry.ErrNotFound) {
+		t.Errorf("GetPlaylist() of an unknown playlist error = %v, want %v", err, registry.ErrNotFound)
+	}
+}
+
+func TestSearchTracks(t *testing.T) {
+	client, requests := recordedTidal(t, map[string]string{
+		"/tracks?countryCode=NG&filter[isrc]=USRC12100001&include=artists,albums":                                    "tracks_isrc.json",
+		"/tracks?countryCode=NG&filter[id]=158834625,189145623,171452233,199845710,199845711&include=artists,albums": "tracks_search.json",
+		"/tracks": "tracks_empty.json",
+		"/searchResults/Essence Wizkid/relationships/tracks":    "search_tracks.json",
+		"/searchResults/Unreleased Nobody/relationships/tracks": "search_empty.json",
+	})
+
+	// the filter only matches the ISRC as TIDAL stores it, so a dashed one is normalised first.
+	tracks, err := client.SearchTracks(context.Background(), utils.Track{Title: "Essence", Artists: []string{"Wizkid"}, ISRC: "us-rc1-21-00001"})
+	if err != nil {
+		t.Fatalf("SearchTracks() error = %v", err)
+	}
+	if len(tracks) != 1 || tracks[0].ID != "158834625" || tracks[0].MatchStrategy != utils.ISRCMatch || len(*requests) != 1 {
+		t.Errorf("SearchTracks() = %+v after %v, want the track with the ISRC from its lookup alone", tracks, *requests)
+	}
+
+	// no track has the ISRC, so the top five search results are fetched, and those unavailable in the country are left out.
+	*requests = nil
+	tracks, err = client.SearchTracks(context.Background(), utils.Track{Title: "Essence", Artists: []string{"Wizkid", "Tems"}, ISRC: "USRC12100009"})
+	if err != nil {
+		t.Fatalf("SearchTracks() error = %v", err)
+	}
+	var ids []string
+	for _, track := range tracks {
+		ids = append(ids, track.ID)
+		if track.MatchStrategy != utils.TextSearchMatch {
+			t.Errorf("MatchStrategy = %q, want %q", track.MatchStrategy, utils.TextSearchMatch)
+		}
+	}
+	if want := []string{"158834625", "189145623", "171452233"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("SearchTracks() ids = %v, want %v in the order of the search", ids, want)
+	}
+	if len(*requests) != 3 {
+		t.Errorf("requests = %v, want the ISRC lookup, the search and the tracks", *requests)
+	}
+	// the duration of a live recording runs over an hour.
+	if tracks[2].Duration != 3725 {
+		t.Errorf("Duration = %d, want 3725", tracks[2].Duration)
+	}
+
+	if _, err = client.SearchTracks(context.Background(), utils.Track{Title: "Unreleased", Artists: []string{"Nobody"}}); !errors.Is(err, registry.ErrNotFound) {
+		t.Errorf("SearchTracks() without results error = %v, want %v", err, registry.ErrNotFound)
+	}
+}
+
+func TestAuthorizationUsesPKCE(t *testing.T) {
+	client, _ := recordedTidal(t, nil)
+
+	link, err := client.GetPKCEAuthorizationURL("state", "challenge")
+	if err != nil {
+		t.Fatalf("GetPKCEAuthorizationURL() error = %v", err)
+	}
+	authorizationURL, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query := authorizationURL.Query(); query.Get("code_challenge") != "challenge" || query.Get("code_challenge_method") != "S256" || query.Get("state") != "state" {
+		t.Errorf("GetPKCEAuthorizationURL() = %s, want the state and the S256 challenge", link)
+	}
+
+	credentials, err := client.ExchangeAuthorizationCode(context.Background(), "code", "verifier")
+	if err != nil || credentials.AccessToken != "tidal-user-token" || credentials.RefreshToken != "tidal-refresh-token" {
+		t.Errorf("ExchangeAuthorizationCode() = %+v, %v, want the credentials of the user", credentials, err)
+	}
+	if _, err = client.ExchangeAuthorizationCode(context.Background(), "code", "another-verifier"); err == nil {
+		t.Error("ExchangeAuthorizationCode() with another verifier error = nil, want an error")
+	}
+
+	if _, err = client.GetAuthorizationURL("state"); !errors.Is(err, errPKCERequired) {
+		t.Errorf("GetAuthorizationURL() error = %v, want %v", err, errPKCERequired)
+	}
+	if _, err = client.GetAuthorizationCode(context.Background(), "code"); !errors.Is(err, errPKCERequired) {
+		t.Errorf("GetAuthorizationCode() error = %v, want %v", err, errPKCERequired)
+	}
+}
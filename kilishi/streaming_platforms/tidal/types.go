@@ -0,0 +1,152 @@
+package tidal
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/imroc/req/v3"
+)
+
+// Tidal encapsulates all methods relating to TIDAL.
+type Tidal struct {
+	RequestClient *req.Client
+	Config        Config
+}
+
+type InitialisationOpts struct {
+	RequestClient             *req.Client
+	BaseAPIURL                string
+	ClientID                  string
+	ClientSecret              string
+	CountryCode               string
+	AuthenticationURL         string
+	AuthorizationURL          string
+	AuthenticationRedirectURL string
+	RequestTimeout            time.Duration
+}
+
+type Config struct {
+	BaseAPIURL                string        `env:"TIDAL_BASE_API_URL,notEmpty"`
+	ClientID                  string        `env:"TIDAL_CLIENT_ID,notEmpty"`
+	ClientSecret              string        `env:"TIDAL_CLIENT_SECRET,notEmpty"`
+	CountryCode               string        `env:"TIDAL_COUNTRY_CODE,notEmpty"`
+	AuthenticationURL         string        `env:"TIDAL_AUTH_URL,notEmpty"`
+	AuthorizationURL          string        `env:"TIDAL_AUTHORIZATION_URL" envDefault:"https://login.tidal.com/authorize"`
+	AuthenticationRedirectURL string        `env:"TIDAL_AUTH_REDIRECT_URL,notEmpty"`
+	RequestTimeout            time.Duration `env:"TIDAL_REQUEST_TIMEOUT" envDefault:"30s"`
+	RateLimit                 float64       `env:"TIDAL_RATE_LIMIT" envDefault:"5"`
+	RateLimitBurst            int           `env:"TIDAL_RATE_LIMIT_BURST" envDefault:"5"`
+	MaxConcurrentRequests     int           `env:"TIDAL_MAX_CONCURRENT_REQUESTS" envDefault:"5"`
+}
+
+// API Types (Autogenerated).
+// TIDAL's v2 API follows the JSON:API specification, https://jsonapi.org/format/
+type tidalAPIResourceIdentifier struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+type tidalAPIRelationship struct {
+	Data  []tidalAPIResourceIdentifier `json:"data"`
+	Links struct {
+		Next string `json:"next"`
+	} `json:"links"`
+}
+
+type tidalAPIResource struct {
+	ID            string                          `json:"id"`
+	Type          string                          `json:"type"`
+	Attributes    json.RawMessage                 `json:"attributes"`
+	Relationships map[string]tidalAPIRelationship `json:"relationships"`
+}
+
+type tidalAPIPlaylistAttributes struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	ImageLinks  []tidalAPIImage `json:"imageLinks"`
+}
+
+type tidalAPITrackAttributes struct {
+	Title    string `json:"title"`
+	Version  string `json:"version"`
+	ISRC     string `json:"isrc"`
+	Duration string `json:"duration"`
+	Explicit bool   `json:"explicit"`
+}
+
+type tidalAPIAlbumAttributes struct {
+	Title      string          `json:"title"`
+	ImageLinks []tidalAPIImage `json:"imageLinks"`
+}
+
+// tidalAPIImage is a link to an image, TIDAL sorts them by size in descending order.
+type tidalAPIImage struct {
+	Href string `json:"href"`
+}
+
+type tidalAPIArtistAttributes struct {
+	Name string `json:"name"`
+}
+
+type tidalAPIGetPlaylistResponse struct {
+	Data tidalAPIResource `json:"data"`
+}
+
+type tidalAPIGetRelationshipResponse struct {
+	Data  []tidalAPIResourceIdentifier `json:"data"`
+	Links struct {
+		Next string `json:"next"`
+	} `json:"links"`
+}
+
+type tidalAPIGetTracksResponse struct {
+	Data     []tidalAPIResource `json:"data"`
+	Included []tidalAPIResource `json:"included"`
+}
+
+type tidalAPICreatePlaylistResponse struct {
+	Data tidalAPIResource `json:"data"`
+}
+
+type tidalAPIClientCredentialsResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+	TokenType   string `json:"token_type"`
+}
+
+type tidalAPIBearerCredentialsResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+type tidalAPIError struct {
+	Errors []struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+		Code   string `json:"code"`
+		Detail string `json:"detail"`
+	} `json:"errors"`
+	// kind is the kind of error the platform answered with, see Unwrap.
+	kind error
+}
+
+func (e *tidalAPIError) Error() string {
+	var reasons []string
+	for _, entry := range e.Errors {
+		reasons = append(reasons, fmt.Sprintf("%s (%s)", entry.Code, entry.Detail))
+	}
+
+	status := ""
+	if len(e.Errors) > 0 {
+		status = e.Errors[0].Status
+	}
+	return fmt.Sprintf("TIDAL API Error: status: %s  reason: %s", status, strings.Join(reasons, "; "))
+}
+
+// Unwrap returns the kind of error the platform answered with, e.g. `registry.ErrNotFound`.
+func (e *tidalAPIError) Unwrap() error {
+	return e.kind
+}
@@ -0,0 +1,209 @@
+package tidal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/imroc/req/v3"
+
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+	"github.com/prettyirrelevant/kilishi/utils"
+)
+
+const jsonAPIContentType = "application/vnd.api+json"
+
+var (
+	playlistURLRegex = regexp.MustCompile(`^https://(?:listen\.)?tidal\.com/(?:browse/)?playlist/([a-f0-9-]{36})`)
+	albumURLRegex    = regexp.MustCompile(`^https://(?:listen\.)?tidal\.com/(?:browse/)?album/(\d+)`)
+	trackURLRegex    = regexp.MustCompile(`^https://(?:listen\.)?tidal\.com/(?:browse/)?track/(\d+)`)
+
+	// TIDAL returns durations in the ISO 8601 format e.g. PT3M25S.
+	durationRegex = regexp.MustCompile(`^PT(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?$`)
+)
+
+// parsePlaylistURL validates a TIDAL playlist URL and returns the playlist ID.
+func parsePlaylistURL(playlistURL string) (string, error) {
+	matches := playlistURLRegex.FindStringSubmatch(playlistURL)
+	if len(matches) < 2 {
+		return "", registry.NewError(registry.ErrInvalidInput, "tidal: playlist url is invalid. check that it follows the format https://tidal.com/browse/playlist/<id>")
+	}
+
+	return matches[1], nil
+}
+
+// parsePlaylistResponse transforms the playlist object returned from TIDAL API into our internal object.
+func parsePlaylistResponse(response *tidalAPIGetPlaylistResponse) (utils.Playlist, error) {
+	var attributes tidalAPIPlaylistAttributes
+	if err := json.Unmarshal(response.Data.Attributes, &attributes); err != nil {
+		return utils.Playlist{}, fmt.Errorf("tidal: playlist attributes parse failed due to %s", err.Error())
+	}
+
+	return utils.Playlist{
+		ID:          response.Data.ID,
+		Title:       attributes.Name,
+		Description: attributes.Description,
+		ImageURL:    firstImageURL(attributes.ImageLinks),
+		URL:         basePlaylistURL + response.Data.ID,
+	}, nil
+}
+
+// parseTracksResponse transforms the tracks returned from TIDAL API into our internal object.
+// Artist names and album titles are resolved from the `included` section of the response.
+func parseTracksResponse(response *tidalAPIGetTracksResponse) []utils.Track {
+	artistNames := make(map[string]string)
+	albums := make(map[string]tidalAPIAlbumAttributes)
+	for _, entry := range response.Included {
+		switch entry.Type {
+		case "artists":
+			var attributes tidalAPIArtistAttributes
+			if err := json.Unmarshal(entry.Attributes, &attributes); err == nil {
+				artistNames[entry.ID] = attributes.Name
+			}
+		case "albums":
+			var attributes tidalAPIAlbumAttributes
+			if err := json.Unmarshal(entry.Attributes, &attributes); err == nil {
+				albums[entry.ID] = attributes
+			}
+		}
+	}
+
+	var tracks []utils.Track
+	for _, entry := range response.Data {
+		var attributes tidalAPITrackAttributes
+		if err := json.Unmarshal(entry.Attributes, &attributes); err != nil {
+			continue
+		}
+
+		artists := []string{}
+		for _, artist := range entry.Relationships["artists"].Data {
+			if name, ok := artistNames[artist.ID]; ok {
+				artists = append(artists, name)
+			}
+		}
+
+		var album tidalAPIAlbumAttributes
+		for _, albumEntry := range entry.Relationships["albums"].Data {
+			album = albums[albumEntry.ID]
+			break
+		}
+
+		tracks = append(tracks, utils.Track{
+			ID:         entry.ID,
+			Title:      utils.CleanTrackTitle(attributes.Title),
+			Artists:    artists,
+			ISRC:       attributes.ISRC,
+			Duration:   parseDuration(attributes.Duration),
+			Album:      album.Title,
+			Explicit:   attributes.Explicit,
+			ArtworkURL: firstImageURL(album.ImageLinks),
+			URL:        baseTrackURL + entry.ID,
+		})
+	}
+
+	return tracks
+}
+
+// firstImageURL returns the URL of the largest image.
+func firstImageURL(images []tidalAPIImage) string {
+	for _, image := range images {
+		return image.Href
+	}
+	return ""
+}
+
+// parseDuration converts an ISO 8601 duration into seconds, it returns zero if the duration is malformed.
+func parseDuration(duration string) int {
+	matches := durationRegex.FindStringSubmatch(duration)
+	if matches == nil {
+		return 0
+	}
+
+	var seconds int
+	for i, multiplier := range []int{3600, 60, 1} {
+		if value, err := strconv.Atoi(matches[i+1]); err == nil {
+			seconds += value * multiplier
+		}
+	}
+	return seconds
+}
+
+// trackToSearchQuery transforms our internal track object into a TIDAL search query.
+func trackToSearchQuery(track utils.Track) string {
+	q := track.Title
+	for _, artistName := range track.Artists {
+		q += " " + artistName
+		break
+	}
+
+	return q
+}
+
+// chunkIDs splits the IDs into batches no larger than `size`.
+func chunkIDs(ids []string, size int) [][]string {
+	// https://github.com/golang/go/wiki/SliceTricks#batching-with-minimal-allocation
+	batches := make([][]string, 0, (len(ids)+size-1)/size)
+	for size < len(ids) {
+		ids, batches = ids[size:], append(batches, ids[0:size:size])
+	}
+	if len(ids) > 0 {
+		batches = append(batches, ids)
+	}
+
+	return batches
+}
+
+func setupRequestClient(reqClient *req.Client, baseURL string) *req.Client {
+	return reqClient.
+		SetBaseURL(baseURL).
+		EnableDumpEachRequest().
+		SetCommonHeader("Accept", jsonAPIContentType).
+		SetCommonErrorResult(&tidalAPIError{}).
+		OnAfterResponse(func(client *req.Client, resp *req.Response) error {
+			// The access token has expired or was revoked, see `registry.ErrUnauthorized`.
+			if resp.Response != nil && (resp.StatusCode == http.StatusUnauthorized) {
+				resp.Err = fmt.Errorf("%w: %s", registry.ErrUnauthorized, resp.String())
+				return nil
+			}
+			// There is an underlying error, e.g. network error or unmarshal error.
+			if resp.Err != nil {
+				// the platform could not be reached, see `registry.ErrUpstreamUnavailable`.
+				if resp.Response == nil {
+					resp.Err = registry.TransportError(resp.Err)
+				}
+				return nil
+			}
+			// Server returns an error message, convert it to human-readable Go error.
+			if apiErr, ok := resp.ErrorResult().(*tidalAPIError); ok {
+				apiErr.kind = registry.ErrorKind(resp.Response)
+				resp.Err = apiErr
+				return nil
+			}
+			// Edge case: neither an error state response nor a success state response,
+			// dump content to help troubleshoot.
+			if !resp.IsSuccessState() {
+				resp.Err = registry.NewError(registry.ErrorKind(resp.Response), "bad status: %s\nraw content:\n%s", resp.Status, resp.Dump())
+				return nil
+			}
+			return nil
+		}).
+		SetCommonRetryCount(3).
+		AddCommonRetryCondition(func(resp *req.Response, err error) bool {
+			return resp.Response != nil && resp.StatusCode == 429
+		}).
+		SetCommonRetryInterval(func(resp *req.Response, attempt int) time.Duration {
+			if resp.Response == nil {
+				return 2 * time.Second
+			}
+
+			retryAfterHeader := resp.Header.Get("Retry-After")
+			if retryAfter, err := strconv.Atoi(retryAfterHeader); retryAfterHeader != "" && err == nil {
+				return time.Duration(retryAfter) * time.Second
+			}
+
+			return 2 * time.Second
+		})
+}
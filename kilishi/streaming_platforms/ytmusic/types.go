@@ -2,6 +2,7 @@ package ytmusic
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/imroc/req/v3"
 )
@@ -16,46 +17,90 @@ type InitialisationOpts struct {
 	RequestClient       *req.Client
 	BaseAPIURL          string
 	AuthenticationToken string
+	RequestTimeout      time.Duration
 }
 
 type Config struct {
-	BaseAPIURL          string
-	AuthenticationToken string
+	BaseAPIURL            string `env:"YTMUSICAPI_BASE_URL,notEmpty"`
+	AuthenticationToken   string
+	RequestTimeout        time.Duration `env:"YTMUSIC_REQUEST_TIMEOUT" envDefault:"30s"`
+	RateLimit             float64       `env:"YTMUSIC_RATE_LIMIT" envDefault:"5"`
+	RateLimitBurst        int           `env:"YTMUSIC_RATE_LIMIT_BURST" envDefault:"5"`
+	MaxConcurrentRequests int           `env:"YTMUSIC_MAX_CONCURRENT_REQUESTS" envDefault:"5"`
 }
 
 // API Types (Autogenerated).
+type ytmusicAPITrack struct {
+	Album        string   `json:"album"`
+	Artists      []string `json:"artists"`
+	Duration     int      `json:"duration_seconds"`
+	Explicit     bool     `json:"explicit"`
+	Identifier   string   `json:"identifier"`
+	ThumbnailURL string   `json:"thumbnail_url"`
+	Title        string   `json:"title"`
+}
+
 type ytmusicAPIGetPlaylistResponse struct {
 	Data struct {
-		Identifier  string `json:"identifier"`
-		Description string `json:"description"`
-		Title       string `json:"title"`
-		Tracks      []struct {
-			Artists    []string `json:"artists"`
-			Identifier string   `json:"identifier"`
-			Title      string   `json:"title"`
-		} `json:"tracks"`
+		Author       string            `json:"author"`
+		Identifier   string            `json:"identifier"`
+		Description  string            `json:"description"`
+		ThumbnailURL string            `json:"thumbnail_url"`
+		Title        string            `json:"title"`
+		Tracks       []ytmusicAPITrack `json:"tracks"`
 	} `json:"data"`
 }
 
+type ytmusicAPIGetTrackResponse struct {
+	Data ytmusicAPITrack `json:"data"`
+}
+
+type ytmusicAPIGetSavedTracksResponse struct {
+	Data []ytmusicAPITrack `json:"data"`
+}
+
+type ytmusicAPIAlbum struct {
+	Artists      []string          `json:"artists"`
+	Identifier   string            `json:"identifier"`
+	ThumbnailURL string            `json:"thumbnail_url"`
+	Title        string            `json:"title"`
+	TrackCount   int               `json:"track_count"`
+	Tracks       []ytmusicAPITrack `json:"tracks"`
+	Year         string            `json:"year"`
+}
+
+type ytmusicAPIGetAlbumResponse struct {
+	Data ytmusicAPIAlbum `json:"data"`
+}
+
+type ytmusicAPISearchAlbumResponse struct {
+	Data []ytmusicAPIAlbum `json:"data"`
+}
+
 type ytmusicAPICreatePlaylistResponse struct {
 	Data string `json:"data"`
 }
 
 type ytmusicAPISearchResponse struct {
 	Data []struct {
-		Artists    []string `json:"artists"`
-		Category   string   `json:"category"`
-		Identifier string   `json:"identifier"`
-		ResultType string   `json:"result_type"`
-		Title      string   `json:"title"`
+		ytmusicAPITrack
+		Category   string `json:"category"`
+		ResultType string `json:"result_type"`
 	} `json:"data"`
 }
 
 type ytmusiAPIError struct {
 	Errors  []string `json:"errors"`
 	Message string   `json:"message"`
+	// kind is the kind of error the platform answered with, see Unwrap.
+	kind error
 }
 
 func (e *ytmusiAPIError) Error() string {
 	return fmt.Sprintf("YTMusic API: message: %v  errors: %+v", e.Message, e.Errors)
 }
+
+// Unwrap returns the kind of error the platform answered with, e.g. `registry.ErrNotFound`.
+func (e *ytmusiAPIError) Unwrap() error {
+	return e.kind
+}
@@ -1,15 +1,28 @@
 package ytmusic
 
 import (
-	"fmt"
 	"regexp"
 	"strings"
 
 	"github.com/imroc/req/v3"
 
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
 	"github.com/prettyirrelevant/kilishi/utils"
 )
 
+// These recognise YouTube Music links, the playlist URL itself is validated by `asaro`.
+var (
+	playlistURLRegex = regexp.MustCompile(`^https://music\.youtube\.com/playlist\?list=([a-zA-Z0-9-_]+)`)
+	albumURLRegex    = regexp.MustCompile(`^https://music\.youtube\.com/browse/(MPREb_[a-zA-Z0-9-_]+)`)
+	trackURLRegex    = regexp.MustCompile(`^https://music\.youtube\.com/watch\?v=([a-zA-Z0-9-_]+)`)
+)
+
+const (
+	basePlaylistURL = "https://music.youtube.com/playlist?list="
+	baseAlbumURL    = "https://music.youtube.com/browse/"
+	baseTrackURL    = "https://music.youtube.com/watch?v="
+)
+
 // trackToSearchQuery takes a track and transforms it into a search query.
 func trackToSearchQuery(track utils.Track) string {
 	q := track.Title + " by"
@@ -20,6 +33,16 @@ func trackToSearchQuery(track utils.Track) string {
 	return q
 }
 
+// albumToSearchQuery takes an album and transforms it into a search query.
+func albumToSearchQuery(album utils.Album) string {
+	q := album.Title
+	for _, artiste := range album.Artists {
+		q += " " + artiste
+		break
+	}
+	return q
+}
+
 func cleanTrackArtist(name string) string {
 	re := regexp.MustCompile(`(?i)vevo`)
 	cleanedName := strings.TrimSpace(re.ReplaceAllString(name, ""))
@@ -28,21 +51,82 @@ func cleanTrackArtist(name string) string {
 
 // parseGetPlaylistResponse transforms the playlist object returned from `ytmusicapi` into our internal object.
 func parseGetPlaylistResponse(response ytmusicAPIGetPlaylistResponse) utils.Playlist {
+	tracks := parseTracks(response.Data.Tracks)
+	return utils.Playlist{
+		ID:          response.Data.Identifier,
+		Title:       response.Data.Title,
+		Description: response.Data.Description,
+		Owner:       response.Data.Author,
+		ImageURL:    response.Data.ThumbnailURL,
+		URL:         basePlaylistURL + response.Data.Identifier,
+		Tracks:      tracks,
+	}
+}
+
+// parseTracks transforms the tracks of a playlist or a library returned from `ytmusicapi` into our internal object.
+func parseTracks(entries []ytmusicAPITrack) []utils.Track {
 	var tracks []utils.Track
-	for _, entry := range response.Data.Tracks {
+	for _, entry := range entries {
 		var artists []string
 		for _, artist := range entry.Artists {
 			artists = append(artists, cleanTrackArtist(artist))
 		}
 
-		tracks = append(tracks, utils.Track{ID: entry.Identifier, Title: utils.CleanTrackTitle(entry.Title), Artists: artists})
+		track := parseTrack(entry)
+		track.Title = utils.CleanTrackTitle(entry.Title)
+		track.Artists = artists
+		tracks = append(tracks, track)
 	}
 
-	return utils.Playlist{
-		ID:          response.Data.Identifier,
-		Title:       response.Data.Title,
-		Description: response.Data.Description,
+	return tracks
+}
+
+// parseSearchResponse transforms the search results returned from `ytmusicapi` into our internal object.
+func parseSearchResponse(response ytmusicAPISearchResponse) []utils.Track {
+	var tracks []utils.Track
+	for _, entry := range response.Data {
+		tracks = append(tracks, parseTrack(entry.ytmusicAPITrack))
+	}
+
+	return tracks
+}
+
+// parseAlbum transforms an album returned from `ytmusicapi` into our internal object.
+func parseAlbum(album ytmusicAPIAlbum) utils.Album {
+	var tracks []utils.Track
+	for _, entry := range album.Tracks {
+		track := parseTrack(entry)
+		track.Title = utils.CleanTrackTitle(entry.Title)
+		track.Album = album.Title
+		if track.ArtworkURL == "" {
+			track.ArtworkURL = album.ThumbnailURL
+		}
+		tracks = append(tracks, track)
+	}
+
+	return utils.Album{
+		ID:          album.Identifier,
+		Title:       album.Title,
+		Artists:     album.Artists,
 		Tracks:      tracks,
+		ReleaseDate: album.Year,
+		ImageURL:    album.ThumbnailURL,
+		URL:         baseAlbumURL + album.Identifier,
+		TrackCount:  album.TrackCount,
+	}
+}
+
+// parseTrack transforms a track returned from `ytmusicapi` into our internal object.
+func parseTrack(track ytmusicAPITrack) utils.Track {
+	return utils.Track{
+		ID:         track.Identifier,
+		Title:      track.Title,
+		Artists:    track.Artists,
+		Duration:   track.Duration,
+		Album:      track.Album,
+		Explicit:   track.Explicit,
+		ArtworkURL: track.ThumbnailURL,
+		URL:        baseTrackURL + track.Identifier,
 	}
 }
 
@@ -55,17 +139,22 @@ func setupRequestClient(reqClient *req.Client, baseURL string) *req.Client {
 		OnAfterResponse(func(client *req.Client, resp *req.Response) error {
 			// There is an underlying error, e.g. network error or unmarshal error.
 			if resp.Err != nil {
+				// the platform could not be reached, see `registry.ErrUpstreamUnavailable`.
+				if resp.Response == nil {
+					resp.Err = registry.TransportError(resp.Err)
+				}
 				return nil
 			}
 			// Server returns an error message, convert it to human-readable Go error.
 			if apiErr, ok := resp.ErrorResult().(*ytmusiAPIError); ok {
+				apiErr.kind = registry.ErrorKind(resp.Response)
 				resp.Err = apiErr
 				return nil
 			}
 			// Edge case: neither an error state response nor a success state response,
 			// dump content to help troubleshoot.
 			if !resp.IsSuccessState() {
-				resp.Err = fmt.Errorf("bad status: %s\nraw content:\n%s", resp.Status, resp.Dump())
+				resp.Err = registry.NewError(registry.ErrorKind(resp.Response), "bad status: %s\nraw content:\n%s", resp.Status, resp.Dump())
 				return nil
 			}
 			return nil
@@ -1,39 +1,45 @@
 package ytmusic
 
 import (
-	"fmt"
+	"context"
+	"net/url"
 
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
 	"github.com/prettyirrelevant/kilishi/utils"
 )
 
 // New initializes a `YTMusic` object.
 func New(opts *InitialisationOpts) *YTMusic {
 	return &YTMusic{
-		RequestClient: setupRequestClient(opts.RequestClient, opts.BaseAPIURL),
+		RequestClient: setupRequestClient(opts.RequestClient.SetTimeout(opts.RequestTimeout), opts.BaseAPIURL),
 		Config: Config{
 			BaseAPIURL:          opts.BaseAPIURL,
 			AuthenticationToken: opts.AuthenticationToken,
+			RequestTimeout:      opts.RequestTimeout,
 		},
 	}
 }
 
 // GetPlaylist returns information about a playlist.
-func (y *YTMusic) GetPlaylist(playlistURL string) (utils.Playlist, error) {
+func (y *YTMusic) GetPlaylist(ctx context.Context, playlistURL string) (utils.Playlist, error) {
 	var response ytmusicAPIGetPlaylistResponse
 	err := y.RequestClient.
 		Post("/playlists").
 		SetBody(map[string]string{"url": playlistURL}).
-		Do().
+		Do(ctx).
 		Into(&response)
 
 	if err != nil {
 		return utils.Playlist{}, err
 	}
-	return parseGetPlaylistResponse(response), nil
+
+	playlist := parseGetPlaylistResponse(response)
+	playlist.Finalise(string(Platform))
+	return playlist, nil
 }
 
 // CreatePlaylist creates a new playlist using the information provided.
-func (y *YTMusic) CreatePlaylist(playlist utils.Playlist, _ string) (string, error) {
+func (y *YTMusic) CreatePlaylist(ctx context.Context, playlist utils.Playlist, _ string) (string, error) {
 	var trackIDs []string
 	for _, entry := range playlist.Tracks {
 		if ok := utils.Contains(trackIDs, entry.ID); !ok {
@@ -50,7 +56,7 @@ func (y *YTMusic) CreatePlaylist(playlist utils.Playlist, _ string) (string, err
 			"description": playlist.Description,
 			"track_ids":   trackIDs,
 		}).
-		Do().
+		Do(ctx).
 		Into(&response)
 
 	if err != nil {
@@ -59,9 +65,18 @@ func (y *YTMusic) CreatePlaylist(playlist utils.Playlist, _ string) (string, err
 	return response.Data, nil
 }
 
-// LookupTrack searches for track on YTMusic and appends the top result to a slice.
-func (y *YTMusic) LookupTrack(track utils.Track) (utils.Track, error) {
-	var foundTrack utils.Track
+// LookupTrack searches for a track on YTMusic and returns the top result.
+func (y *YTMusic) LookupTrack(ctx context.Context, track utils.Track) (utils.Track, error) {
+	tracks, err := y.SearchTracks(ctx, track)
+	if err != nil {
+		return utils.Track{}, err
+	}
+
+	return tracks[0], nil
+}
+
+// SearchTracks returns the songs on YTMusic that match the track, best first.
+func (y *YTMusic) SearchTracks(ctx context.Context, track utils.Track) ([]utils.Track, error) {
 	var response ytmusicAPISearchResponse
 	err := y.RequestClient.
 		Post("/tracks/search").
@@ -70,23 +85,134 @@ func (y *YTMusic) LookupTrack(track utils.Track) (utils.Track, error) {
 			"ignore_spelling": true,
 			"limit":           3,
 		}).
-		Do().
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Data) == 0 {
+		return nil, registry.NewError(registry.ErrNotFound, "ytmusic: no track found that matches %s", track.Title)
+	}
+
+	return utils.SetMatchStrategy(parseSearchResponse(response), utils.TextSearchMatch), nil
+}
+
+// TrackURL returns the YouTube Music link of the track with the given ID.
+func (y *YTMusic) TrackURL(trackID string) string {
+	return "https://music.youtube.com/watch?v=" + url.QueryEscape(trackID)
+}
+
+// GetTrack returns the track a YouTube Music link points at.
+// `ytmusicapi` does not return the album of a single track, so it is left empty.
+func (y *YTMusic) GetTrack(ctx context.Context, trackURL string) (utils.Track, error) {
+	var response ytmusicAPIGetTrackResponse
+	err := y.RequestClient.
+		Post("/tracks").
+		SetBody(map[string]string{"url": trackURL}).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return utils.Track{}, err
+	}
+
+	track := parseTrack(response.Data)
+	track.Title = utils.CleanTrackTitle(track.Title)
+	for i, artist := range track.Artists {
+		track.Artists[i] = cleanTrackArtist(artist)
+	}
+	return track, nil
+}
+
+// GetAlbum returns the album a YouTube Music link points at, along with its tracks.
+func (y *YTMusic) GetAlbum(ctx context.Context, albumURL string) (utils.Album, error) {
+	var response ytmusicAPIGetAlbumResponse
+	err := y.RequestClient.
+		Post("/albums").
+		SetBody(map[string]string{"url": albumURL}).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return utils.Album{}, err
+	}
+
+	album := parseAlbum(response.Data)
+	album.Finalise(string(Platform))
+	return album, nil
+}
+
+// LookupAlbum searches for an album on YTMusic and returns the top result.
+// YouTube Music does not expose UPCs, so the album is always searched by its title and artist.
+func (y *YTMusic) LookupAlbum(ctx context.Context, album utils.Album) (utils.Album, error) {
+	var response ytmusicAPISearchAlbumResponse
+	err := y.RequestClient.
+		Post("/albums/search").
+		SetBody(map[string]interface{}{
+			"q":     albumToSearchQuery(album),
+			"limit": 1,
+		}).
+		Do(ctx).
 		Into(&response)
 
 	if err != nil {
-		return foundTrack, err
+		return utils.Album{}, err
 	}
 	if len(response.Data) == 0 {
-		return foundTrack, fmt.Errorf("ytmusic: no track found that matches %s", track.Title)
+		return utils.Album{}, registry.NewError(registry.ErrNotFound, "ytmusic: no album found that matches %s", album.Title)
+	}
+
+	match := parseAlbum(response.Data[0])
+	match.Platform = string(Platform)
+	match.MatchStrategy = utils.TextSearchMatch
+	return match, nil
+}
+
+// GetSavedTracks returns the liked songs of the account `asaro` is signed in with,
+// YouTube Music has no user credentials so the access token is not used.
+func (y *YTMusic) GetSavedTracks(ctx context.Context, _ string) ([]utils.Track, error) {
+	var response ytmusicAPIGetSavedTracksResponse
+	err := y.RequestClient.
+		Get("/library/tracks").
+		SetBearerAuthToken(y.Config.AuthenticationToken).
+		Do(ctx).
+		Into(&response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTracks(response.Data), nil
+}
+
+// SaveTracks likes the tracks on the account `asaro` is signed in with.
+func (y *YTMusic) SaveTracks(ctx context.Context, tracks []utils.Track, _ string) error {
+	var trackIDs []string
+	for _, entry := range tracks {
+		if ok := utils.Contains(trackIDs, entry.ID); !ok {
+			trackIDs = append(trackIDs, entry.ID)
+		}
+	}
+
+	err := y.RequestClient.
+		Put("/library/tracks").
+		SetBearerAuthToken(y.Config.AuthenticationToken).
+		SetBody(map[string]interface{}{
+			"track_ids": trackIDs,
+		}).
+		Do(ctx).
+		Err
+
+	if err != nil {
+		return err
 	}
 
-	foundTrack.Title = response.Data[0].Title
-	foundTrack.ID = response.Data[0].Identifier
-	foundTrack.Artists = response.Data[0].Artists
-	return foundTrack, nil
+	registry.ReportProgress(ctx, len(trackIDs), len(trackIDs))
+	return nil
 }
 
-func (*YTMusic) GetAuthorizationCode(_ string) (utils.OauthCredentials, error) {
+func (*YTMusic) GetAuthorizationCode(_ context.Context, _ string) (utils.OauthCredentials, error) {
 	return utils.OauthCredentials{}, nil // no-op
 }
 
@@ -1,86 +1,252 @@
 package utils
 
 import (
-	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
 )
 
-// Encrypt takes a plaintext string, a secret key in hexadecimal format, and an
-// initialization vector in hexadecimal format, and returns the encrypted
-// ciphertext as a base64-encoded string using AES-256 in CBC mode.
-func Encrypt(plaintext, secretKeyHex, initializationVectorHex string) (string, error) {
-	secretKey, err := hex.DecodeString(secretKeyHex)
+// ciphertextVersion prefixes every ciphertext returned by Keyring.Encrypt, it changes whenever the scheme does.
+// A ciphertext takes the format of version.keyID.base64(nonce|sealed), the version and key ID are authenticated along with it.
+const ciphertextVersion = "v1"
+
+var (
+	// ErrMalformedCiphertext is returned when a ciphertext was not returned by Keyring.Encrypt or has been tampered with.
+	ErrMalformedCiphertext = errors.New("ciphertext is malformed")
+	// ErrUnversionedCiphertext is returned for ciphertexts without a version, which Keyring.Encrypt never returns.
+	ErrUnversionedCiphertext = errors.New("ciphertext is not versioned")
+	// ErrUnknownKey is returned when a ciphertext was encrypted with a key that is no longer configured.
+	ErrUnknownKey = errors.New("ciphertext was encrypted with an unknown key")
+)
+
+// Keyring encrypts with AES-256-GCM using its primary key, and decrypts with any of its keys.
+// Keys are identified by a hash of their value, so a key can be rotated by making it a previous key of the new one
+// until everything encrypted with it has been encrypted again.
+type Keyring struct {
+	primaryID string
+	keys      map[string]keyringKey
+	// ids lists the keys with the primary one first.
+	ids []string
+}
+
+type keyringKey struct {
+	secret []byte
+	aead   cipher.AEAD
+}
+
+// NewKeyring creates a keyring from 32-byte keys in hexadecimal format, the primary key encrypts and signs while the previous ones are only used to decrypt and verify.
+func NewKeyring(primaryKeyHex string, previousKeysHex ...string) (*Keyring, error) {
+	keyring := &Keyring{keys: make(map[string]keyringKey)}
+
+	for i, keyHex := range append([]string{primaryKeyHex}, previousKeysHex...) {
+		secret, err := hex.DecodeString(strings.TrimSpace(keyHex))
+		if err != nil {
+			return nil, fmt.Errorf("keyring: key %d is not hexadecimal", i)
+		}
+		if err = keyring.add(secret); err != nil {
+			return nil, fmt.Errorf("keyring: key %d %s", i, err.Error())
+		}
+	}
+
+	keyring.primaryID = keyring.ids[0]
+	return keyring, nil
+}
+
+func (k *Keyring) add(secret []byte) error {
+	if len(secret) != 32 {
+		return fmt.Errorf("must be 32 bytes, got %d", len(secret))
+	}
+
+	block, err := aes.NewCipher(secret)
 	if err != nil {
-		return "", err
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
 	}
 
-	block, err := aes.NewCipher(secretKey)
+	id := keyID(secret)
+	if _, ok := k.keys[id]; ok {
+		return nil
+	}
+	k.keys[id] = keyringKey{secret: secret, aead: aead}
+	k.ids = append(k.ids, id)
+	return nil
+}
+
+// SealEnvelope encrypts the plaintext with a random data key, and returns the data key encrypted with the primary key along with the ciphertext.
+// Rotating the keys then only requires encrypting the data keys again, see RewrapKey.
+func (k *Keyring) SealEnvelope(plaintext, associatedData []byte) (string, string, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", "", err
+	}
+
+	dataKeyring, err := newDataKeyring(dataKey)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	plaintextBytes := []byte(plaintext)
-	paddedPlaintext := pad(plaintextBytes, block.BlockSize())
+	ciphertext, err := dataKeyring.Encrypt(plaintext, associatedData)
+	if err != nil {
+		return "", "", err
+	}
+	wrappedKey, err := k.Encrypt(dataKey, associatedData)
+	if err != nil {
+		return "", "", err
+	}
 
-	ciphertext := make([]byte, len(paddedPlaintext))
+	return wrappedKey, ciphertext, nil
+}
 
-	iv, err := hex.DecodeString(initializationVectorHex)
+// OpenEnvelope returns the plaintext of a ciphertext returned by SealEnvelope, the wrapped data key is decrypted with any of the keys.
+func (k *Keyring) OpenEnvelope(wrappedKey, ciphertext string, associatedData []byte) ([]byte, error) {
+	dataKey, err := k.Decrypt(wrappedKey, associatedData)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	mode := cipher.NewCBCEncrypter(block, iv)
-	mode.CryptBlocks(ciphertext, paddedPlaintext)
+	dataKeyring, err := newDataKeyring(dataKey)
+	if err != nil {
+		return nil, ErrMalformedCiphertext
+	}
+
+	return dataKeyring.Decrypt(ciphertext, associatedData)
+}
+
+// newDataKeyring returns a keyring made of only the data key of an envelope.
+func newDataKeyring(dataKey []byte) (*Keyring, error) {
+	dataKeyring := &Keyring{keys: make(map[string]keyringKey)}
+	if err := dataKeyring.add(dataKey); err != nil {
+		return nil, err
+	}
 
-	ciphertextStr := base64.StdEncoding.EncodeToString(ciphertext)
-	return ciphertextStr, nil
+	dataKeyring.primaryID = dataKeyring.ids[0]
+	return dataKeyring, nil
 }
 
-// Decrypt decrypts the given ciphertext string using the provided secret key and initialization vector.
-func Decrypt(ciphertextStr, secretKeyHex, initializationVectorHex string) (string, error) {
-	key, err := hex.DecodeString(secretKeyHex)
+// RewrapKey encrypts a data key returned by SealEnvelope with the primary key, the data it encrypts is left as is.
+func (k *Keyring) RewrapKey(wrappedKey string, associatedData []byte) (string, error) {
+	dataKey, err := k.Decrypt(wrappedKey, associatedData)
 	if err != nil {
 		return "", err
 	}
 
-	ciphertext, err := base64.StdEncoding.DecodeString(ciphertextStr)
-	if err != nil {
+	return k.Encrypt(dataKey, associatedData)
+}
+
+// Encrypt encrypts and authenticates the plaintext with the primary key and a random nonce.
+// The associated data is authenticated but not encrypted, the same has to be passed to Decrypt e.g. to bind the ciphertext to where it is stored.
+func (k *Keyring) Encrypt(plaintext, associatedData []byte) (string, error) {
+	key := k.keys[k.primaryID]
+
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
 		return "", err
 	}
 
-	block, err := aes.NewCipher(key)
+	header := ciphertextHeader(k.primaryID)
+	sealed := key.aead.Seal(nonce, nonce, plaintext, append([]byte(header), associatedData...))
+	return header + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt returns the plaintext of a ciphertext returned by Encrypt with the same associated data.
+func (k *Keyring) Decrypt(ciphertext string, associatedData []byte) ([]byte, error) {
+	id, sealed, err := ParseCiphertext(ciphertext)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, ErrUnknownKey
 	}
 
-	plaintext := make([]byte, len(ciphertext))
+	nonceSize := key.aead.NonceSize()
+	if len(sealed) < nonceSize+key.aead.Overhead() {
+		return nil, ErrMalformedCiphertext
+	}
 
-	iv, err := hex.DecodeString(initializationVectorHex)
+	plaintext, err := key.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], append([]byte(ciphertextHeader(id)), associatedData...))
 	if err != nil {
-		return "", err
+		return nil, ErrMalformedCiphertext
 	}
+	return plaintext, nil
+}
+
+// IsCurrent reports whether the ciphertext was encrypted with the primary key using the current scheme.
+// Ciphertexts that are not should be encrypted again before their key is removed.
+func (k *Keyring) IsCurrent(ciphertext string) bool {
+	id, _, err := ParseCiphertext(ciphertext)
+	return err == nil && id == k.primaryID
+}
 
-	decrypter := cipher.NewCBCDecrypter(block, iv)
-	decrypter.CryptBlocks(plaintext, ciphertext)
+// Sign returns the HMAC-SHA256 of the message keyed with the primary key, as a URL-safe base64-encoded string.
+func (k *Keyring) Sign(message string) string {
+	return sign(message, k.keys[k.primaryID].secret)
+}
+
+// VerifySignature reports whether the signature was returned by Sign for the message with any of the keys.
+// The comparison takes constant time.
+func (k *Keyring) VerifySignature(message, signature string) bool {
+	for _, id := range k.ids {
+		if hmac.Equal([]byte(sign(message, k.keys[id].secret)), []byte(signature)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseCiphertext splits a ciphertext returned by Keyring.Encrypt into the ID of the key it was encrypted with and the sealed nonce and data.
+// It only checks the format, the ciphertext is authenticated when it is decrypted.
+func ParseCiphertext(ciphertext string) (string, []byte, error) {
+	version, rest, found := strings.Cut(ciphertext, ".")
+	if !found {
+		return "", nil, ErrUnversionedCiphertext
+	}
+	if version != ciphertextVersion {
+		return "", nil, fmt.Errorf("%w: unsupported version %q", ErrMalformedCiphertext, version)
+	}
+
+	id, encoded, found := strings.Cut(rest, ".")
+	if !found || id == "" || encoded == "" {
+		return "", nil, ErrMalformedCiphertext
+	}
+
+	// the decoder skips line breaks and the strict encoding rejects unused bits that are set,
+	// so that every ciphertext has a single valid encoding.
+	if strings.ContainsAny(encoded, "\r\n") {
+		return "", nil, ErrMalformedCiphertext
+	}
+	sealed, err := base64.RawURLEncoding.Strict().DecodeString(encoded)
+	if err != nil || len(sealed) == 0 {
+		return "", nil, ErrMalformedCiphertext
+	}
+
+	return id, sealed, nil
+}
 
-	unpaddedPlaintext := unpad(plaintext)
-	return string(unpaddedPlaintext), nil
+// keyID identifies a key without revealing it, it is the first 4 bytes of its SHA-256 in hexadecimal format.
+func keyID(secret []byte) string {
+	sum := sha256.Sum256(secret)
+	return hex.EncodeToString(sum[:4])
 }
 
-// pad adds PKCS7 padding to the message byte slice, such that the length
-// of the padded message is a multiple of the specified block size.
-func pad(message []byte, blockSize int) []byte {
-	padding := blockSize - len(message)%blockSize
-	padtext := bytes.Repeat([]byte{byte(padding)}, padding)
-	return append(message, padtext...)
+func ciphertextHeader(id string) string {
+	return ciphertextVersion + "." + id + "."
 }
 
-// unpad removes PKCS7 padding from the message byte slice, returning the original
-// message with the padding removed.
-func unpad(message []byte) []byte {
-	padding := int(message[len(message)-1])
-	return message[:len(message)-padding]
+func sign(message string, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(message))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
 }
@@ -0,0 +1,316 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const (
+	testPrimaryKey  = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+	testPreviousKey = "1f1e1d1c1b1a191817161514131211100f0e0d0c0b0a09080706050403020100"
+	testOtherKey    = "a0a1a2a3a4a5a6a7a8a9aaabacadaeafb0b1b2b3b4b5b6b7b8b9babbbcbdbebf"
+)
+
+func mustKeyring(t testing.TB, primary string, previous ...string) *Keyring {
+	t.Helper()
+
+	keyring, err := NewKeyring(primary, previous...)
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+	return keyring
+}
+
+func TestNewKeyring(t *testing.T) {
+	tests := []struct {
+		name     string
+		primary  string
+		previous []string
+		wantErr  bool
+	}{
+		{name: "primary only", primary: testPrimaryKey},
+		{name: "with previous keys", primary: testPrimaryKey, previous: []string{testPreviousKey, testOtherKey}},
+		{name: "duplicate previous key", primary: testPrimaryKey, previous: []string{testPrimaryKey}},
+		{name: "not hexadecimal", primary: strings.Repeat("zz", 32), wantErr: true},
+		{name: "too short", primary: testPrimaryKey[:62], wantErr: true},
+		{name: "invalid previous key", primary: testPrimaryKey, previous: []string{"abcd"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKeyring(tt.primary, tt.previous...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewKeyring() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyringEncryptDecrypt(t *testing.T) {
+	keyring := mustKeyring(t, testPrimaryKey)
+	plaintext := []byte(`{"access_token":"token"}`)
+	associatedData := []byte("oauth_cred:user:spotify")
+
+	ciphertext, err := keyring.Encrypt(plaintext, associatedData)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if !keyring.IsCurrent(ciphertext) {
+		t.Errorf("IsCurrent(%q) = false, want true", ciphertext)
+	}
+
+	tests := []struct {
+		name           string
+		keyring        *Keyring
+		ciphertext     string
+		associatedData []byte
+		wantErr        error
+	}{
+		{name: "same key", keyring: keyring, ciphertext: ciphertext, associatedData: associatedData},
+		{name: "rotated to a new primary key", keyring: mustKeyring(t, testOtherKey, testPrimaryKey), ciphertext: ciphertext, associatedData: associatedData},
+		{name: "wrong key", keyring: mustKeyring(t, testOtherKey), ciphertext: ciphertext, associatedData: associatedData, wantErr: ErrUnknownKey},
+		{name: "tampered associated data", keyring: keyring, ciphertext: ciphertext, associatedData: []byte("oauth_cred:attacker:spotify"), wantErr: ErrMalformedCiphertext},
+		{name: "tampered ciphertext", keyring: keyring, ciphertext: flipLastCharacter(ciphertext), associatedData: associatedData, wantErr: ErrMalformedCiphertext},
+		{name: "line break in the encoding", keyring: keyring, ciphertext: ciphertext[:20] + "\n" + ciphertext[20:], associatedData: associatedData, wantErr: ErrMalformedCiphertext},
+		{name: "unversioned", keyring: keyring, ciphertext: base64.StdEncoding.EncodeToString(plaintext), associatedData: associatedData, wantErr: ErrUnversionedCiphertext},
+		{name: "unsupported version", keyring: keyring, ciphertext: "v2" + strings.TrimPrefix(ciphertext, ciphertextVersion), associatedData: associatedData, wantErr: ErrMalformedCiphertext},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.keyring.Decrypt(tt.ciphertext, tt.associatedData)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Decrypt() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && !bytes.Equal(got, plaintext) {
+				t.Errorf("Decrypt() = %q, want %q", got, plaintext)
+			}
+		})
+	}
+}
+
+func TestKeyringIsCurrent(t *testing.T) {
+	previous := mustKeyring(t, testPreviousKey)
+	ciphertext, err := previous.Encrypt([]byte("secret"), nil)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+
+	rotated := mustKeyring(t, testPrimaryKey, testPreviousKey)
+	if rotated.IsCurrent(ciphertext) {
+		t.Errorf("IsCurrent() = true for a ciphertext of a previous key")
+	}
+	if rotated.IsCurrent("not a ciphertext") {
+		t.Errorf("IsCurrent() = true for an unversioned ciphertext")
+	}
+}
+
+func TestKeyringSignatures(t *testing.T) {
+	previous := mustKeyring(t, testPreviousKey)
+	signature := previous.Sign("spotify:nonce:1700000000")
+
+	tests := []struct {
+		name      string
+		keyring   *Keyring
+		message   string
+		signature string
+		want      bool
+	}{
+		{name: "same key", keyring: previous, message: "spotify:nonce:1700000000", signature: signature, want: true},
+		{name: "previous key", keyring: mustKeyring(t, testPrimaryKey, testPreviousKey), message: "spotify:nonce:1700000000", signature: signature, want: true},
+		{name: "removed key", keyring: mustKeyring(t, testPrimaryKey), message: "spotify:nonce:1700000000", signature: signature},
+		{name: "other message", keyring: previous, message: "deezer:nonce:1700000000", signature: signature},
+		{name: "empty signature", keyring: previous, message: "spotify:nonce:1700000000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.keyring.VerifySignature(tt.message, tt.signature); got != tt.want {
+				t.Errorf("VerifySignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyringEnvelope(t *testing.T) {
+	keyring := mustKeyring(t, testPrimaryKey)
+	plaintext := []byte(`{"access_token":"token","refresh_token":"refresh"}`)
+	associatedData := []byte("oauth_cred:user:deezer")
+
+	wrappedKey, ciphertext, err := keyring.SealEnvelope(plaintext, associatedData)
+	if err != nil {
+		t.Fatalf("SealEnvelope() error = %v", err)
+	}
+
+	otherWrappedKey, otherCiphertext, err := keyring.SealEnvelope(plaintext, associatedData)
+	if err != nil {
+		t.Fatalf("SealEnvelope() error = %v", err)
+	}
+	if wrappedKey == otherWrappedKey || ciphertext == otherCiphertext {
+		t.Errorf("SealEnvelope() reused a data key or nonce")
+	}
+
+	tests := []struct {
+		name           string
+		keyring        *Keyring
+		wrappedKey     string
+		ciphertext     string
+		associatedData []byte
+		wantErr        error
+	}{
+		{name: "round trip", keyring: keyring, wrappedKey: wrappedKey, ciphertext: ciphertext, associatedData: associatedData},
+		{name: "previous key", keyring: mustKeyring(t, testOtherKey, testPrimaryKey), wrappedKey: wrappedKey, ciphertext: ciphertext, associatedData: associatedData},
+		{name: "wrong key", keyring: mustKeyring(t, testOtherKey), wrappedKey: wrappedKey, ciphertext: ciphertext, associatedData: associatedData, wantErr: ErrUnknownKey},
+		{name: "tampered associated data", keyring: keyring, wrappedKey: wrappedKey, ciphertext: ciphertext, associatedData: []byte("oauth_cred:attacker:deezer"), wantErr: ErrMalformedCiphertext},
+		{name: "data key of another envelope", keyring: keyring, wrappedKey: otherWrappedKey, ciphertext: ciphertext, associatedData: associatedData, wantErr: ErrUnknownKey},
+		{name: "tampered ciphertext", keyring: keyring, wrappedKey: wrappedKey, ciphertext: flipLastCharacter(ciphertext), associatedData: associatedData, wantErr: ErrMalformedCiphertext},
+		{name: "tampered data key", keyring: keyring, wrappedKey: flipLastCharacter(wrappedKey), ciphertext: ciphertext, associatedData: associatedData, wantErr: ErrMalformedCiphertext},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.keyring.OpenEnvelope(tt.wrappedKey, tt.ciphertext, tt.associatedData)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("OpenEnvelope() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && !bytes.Equal(got, plaintext) {
+				t.Errorf("OpenEnvelope() = %q, want %q", got, plaintext)
+			}
+		})
+	}
+}
+
+func TestKeyringRewrapKey(t *testing.T) {
+	previous := mustKeyring(t, testPreviousKey)
+	plaintext := []byte(`{"access_token":"token"}`)
+	associatedData := []byte("oauth_cred:user:spotify")
+
+	wrappedKey, ciphertext, err := previous.SealEnvelope(plaintext, associatedData)
+	if err != nil {
+		t.Fatalf("SealEnvelope() error = %v", err)
+	}
+
+	rotated := mustKeyring(t, testPrimaryKey, testPreviousKey)
+	rewrappedKey, err := rotated.RewrapKey(wrappedKey, associatedData)
+	if err != nil {
+		t.Fatalf("RewrapKey() error = %v", err)
+	}
+	if !rotated.IsCurrent(rewrappedKey) {
+		t.Errorf("RewrapKey() did not encrypt the data key with the primary key")
+	}
+
+	// once rewrapped, the previous key can be removed without touching the ciphertext.
+	got, err := mustKeyring(t, testPrimaryKey).OpenEnvelope(rewrappedKey, ciphertext, associatedData)
+	if err != nil {
+		t.Fatalf("OpenEnvelope() error = %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("OpenEnvelope() = %q, want %q", got, plaintext)
+	}
+
+	if _, err = rotated.RewrapKey(wrappedKey, []byte("oauth_cred:attacker:spotify")); !errors.Is(err, ErrMalformedCiphertext) {
+		t.Errorf("RewrapKey() with tampered associated data error = %v, want %v", err, ErrMalformedCiphertext)
+	}
+	if _, err = mustKeyring(t, testOtherKey).RewrapKey(wrappedKey, associatedData); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("RewrapKey() with a wrong key error = %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func FuzzParseCiphertext(f *testing.F) {
+	for _, seed := range []string{testCiphertext, testEnvelope, "v1.0.\n", testCiphertext + "\n", flipLastCharacter(testCiphertext), "", ".", "v1.", "v1..", "v1.id.", "v1.id.!!!", "v2.id.AAAA", "plain text", base64.StdEncoding.EncodeToString([]byte("legacy"))} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, ciphertext string) {
+		id, sealed, err := ParseCiphertext(ciphertext)
+		if err != nil {
+			if id != "" || sealed != nil {
+				t.Errorf("ParseCiphertext(%q) returned a key ID or data along with error %v", ciphertext, err)
+			}
+			if !errors.Is(err, ErrMalformedCiphertext) && !errors.Is(err, ErrUnversionedCiphertext) {
+				t.Errorf("ParseCiphertext(%q) error = %v, want a malformed or unversioned ciphertext", ciphertext, err)
+			}
+			return
+		}
+
+		if id == "" || len(sealed) == 0 {
+			t.Errorf("ParseCiphertext(%q) = %q, %x, want a key ID and data", ciphertext, id, sealed)
+		}
+		if !strings.HasPrefix(ciphertext, ciphertextHeader(id)) {
+			t.Errorf("ParseCiphertext(%q) key ID %q does not match the header", ciphertext, id)
+		}
+	})
+}
+
+// The fuzzing workers run in their own processes, so the genuine ciphertexts are fixed rather than encrypted with a random nonce by each of them.
+// They were returned by Encrypt and SealEnvelope with testPrimaryKey for testPlaintext and testAssociatedData.
+const (
+	testPlaintext      = `{"access_token":"token"}`
+	testAssociatedData = "oauth_cred:user:spotify"
+	testCiphertext     = "v1.630dcd29.YsoeKxyZPPfjmR75iCnDwJcIAenzq707OuO78o5KL7KdSZZuoihX4-Z6RoIbE3TYwKo-4w"
+	testWrappedKey     = "v1.630dcd29.j0OpkrVx_V82E0XpxglEy2Qs-qHQ0Y3FMY2iwoM92x6rWC4_cbE0spfwP-hBG353W1blmAcxWo-kf5ow"
+	testEnvelope       = "v1.f6f835dc.29Yz-KlDWiF29DeTt5yUAqWyCidifyBdJq1c129g5ZuPhb2A2EYGvLJrdkawfCZ0lQkaSQ"
+)
+
+func TestKeyringFixedCiphertexts(t *testing.T) {
+	keyring := mustKeyring(t, testPrimaryKey)
+
+	got, err := keyring.Decrypt(testCiphertext, []byte(testAssociatedData))
+	if err != nil || string(got) != testPlaintext {
+		t.Errorf("Decrypt() = %q, %v, want %q", got, err, testPlaintext)
+	}
+	got, err = keyring.OpenEnvelope(testWrappedKey, testEnvelope, []byte(testAssociatedData))
+	if err != nil || string(got) != testPlaintext {
+		t.Errorf("OpenEnvelope() = %q, %v, want %q", got, err, testPlaintext)
+	}
+}
+
+func FuzzDecrypt(f *testing.F) {
+	keyring := mustKeyring(f, testPrimaryKey, testPreviousKey)
+
+	f.Add(testCiphertext, []byte(testAssociatedData))
+	f.Add(testWrappedKey, []byte(testAssociatedData))
+	f.Add(testEnvelope, []byte(testAssociatedData))
+	f.Add(flipLastCharacter(testCiphertext), []byte(testAssociatedData))
+	f.Add(testCiphertext, []byte{})
+	f.Add("v1."+keyID(mustHexKey(f, testPrimaryKey))+".AAAA", []byte(testAssociatedData))
+	f.Add("", []byte(nil))
+
+	f.Fuzz(func(t *testing.T, candidate string, associatedData []byte) {
+		got, err := keyring.Decrypt(candidate, associatedData)
+		if err != nil {
+			if got != nil {
+				t.Errorf("Decrypt(%q) returned plaintext along with error %v", candidate, err)
+			}
+			return
+		}
+
+		// only the ciphertexts returned by Encrypt with their associated data can be decrypted, anything else is a forgery.
+		if (candidate != testCiphertext && candidate != testWrappedKey) || string(associatedData) != testAssociatedData {
+			t.Fatalf("Decrypt(%q, %q) accepted a ciphertext that was not returned by Encrypt", candidate, associatedData)
+		}
+		if candidate == testCiphertext && string(got) != testPlaintext {
+			t.Errorf("Decrypt() = %q, want %q", got, testPlaintext)
+		}
+	})
+}
+
+// flipLastCharacter tampers with a ciphertext while keeping it valid base64.
+func flipLastCharacter(ciphertext string) string {
+	last := ciphertext[len(ciphertext)-1]
+	replacement := byte('A')
+	if last == 'A' {
+		replacement = 'B'
+	}
+	return ciphertext[:len(ciphertext)-1] + string(replacement)
+}
+
+func mustHexKey(t testing.TB, keyHex string) []byte {
+	t.Helper()
+
+	keyring := mustKeyring(t, keyHex)
+	return keyring.keys[keyring.primaryID].secret
+}
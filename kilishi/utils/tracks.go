@@ -18,6 +18,15 @@ func CleanTrackTitle(title string) string {
 	return cleanedTitle
 }
 
+// SetMatchStrategy records how the tracks were found on a streaming platform.
+func SetMatchStrategy(tracks []Track, strategy MatchStrategy) []Track {
+	for i := range tracks {
+		tracks[i].MatchStrategy = strategy
+	}
+
+	return tracks
+}
+
 func Contains(arr []string, val string) bool {
 	for _, i := range arr {
 		if val == i {
@@ -1,28 +1,132 @@
 package utils
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 const ApplicationJSON = "application/json"
 
 // Playlist represents a playlist entry from any of the supported streaming platform internally.
+// The fields tagged with `omitempty` are left empty when the platform does not expose them.
 type Playlist struct {
 	ID          string  `json:"id"`
 	Title       string  `json:"title"`
 	Description string  `json:"description"`
 	Tracks      []Track `json:"tracks"`
+	Owner       string  `json:"owner,omitempty"`
+	ImageURL    string  `json:"image_url,omitempty"`
+	URL         string  `json:"url,omitempty"`
+	TrackCount  int     `json:"track_count,omitempty"`
+	// Platform is the streaming platform the playlist was retrieved from.
+	Platform string `json:"platform,omitempty"`
+}
+
+// Finalise records the streaming platform the playlist was retrieved from and numbers its tracks.
+// It should be called once every track of the playlist has been retrieved.
+func (p *Playlist) Finalise(platform string) {
+	p.Platform = platform
+	p.TrackCount = len(p.Tracks)
+	for i := range p.Tracks {
+		p.Tracks[i].Position = i + 1
+	}
+}
+
+// Album represents an album from any of the supported streaming platform internally.
+// The fields tagged with `omitempty` are left empty when the platform does not expose them.
+type Album struct {
+	ID      string   `json:"id"`
+	Title   string   `json:"title"`
+	Artists []string `json:"artists"`
+	Tracks  []Track  `json:"tracks"`
+	// UPC is the Universal Product Code of the album.
+	UPC         string `json:"upc,omitempty"`
+	ReleaseDate string `json:"release_date,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+	URL         string `json:"url,omitempty"`
+	TrackCount  int    `json:"track_count,omitempty"`
+	// Platform is the streaming platform the album was retrieved from.
+	Platform string `json:"platform,omitempty"`
+	// MatchStrategy and Confidence are set on albums returned by `LookupAlbum`, see `Track`.
+	MatchStrategy MatchStrategy `json:"match_strategy,omitempty"`
+	Confidence    float64       `json:"confidence,omitempty"`
+}
+
+// Finalise records the streaming platform the album was retrieved from and numbers its tracks.
+// It should be called once every track of the album has been retrieved.
+func (a *Album) Finalise(platform string) {
+	a.Platform = platform
+	if a.TrackCount == 0 {
+		a.TrackCount = len(a.Tracks)
+	}
+	for i := range a.Tracks {
+		a.Tracks[i].Position = i + 1
+	}
 }
 
 // Track represents a song entry in a playlist from any of the supported streaming platform internally.
+// The fields tagged with `omitempty` are left empty when the platform does not expose them.
 type Track struct {
 	ID      string   `json:"id"`
 	Title   string   `json:"title"`
 	Artists []string `json:"artists"`
+	// Duration is the length of the track in seconds.
+	Duration   int    `json:"duration,omitempty"`
+	Album      string `json:"album,omitempty"`
+	Explicit   bool   `json:"explicit,omitempty"`
+	ArtworkURL string `json:"artwork_url,omitempty"`
+	URL        string `json:"url,omitempty"`
+	// Position is the 1-based position of the track in its playlist.
+	Position int `json:"position,omitempty"`
+	// ISRC is the International Standard Recording Code of the track, it is empty when the platform does not expose it.
+	ISRC string `json:"isrc,omitempty"`
+	// MatchStrategy is set on tracks returned by `LookupTrack` and reports how the track was found.
+	MatchStrategy MatchStrategy `json:"match_strategy,omitempty"`
+	// Confidence is set on ranked search results and ranges from 0 to 1, see `matching.Rank`.
+	Confidence float64 `json:"confidence,omitempty"`
+}
+
+// MatchStrategy describes how a track was found on a streaming platform.
+type MatchStrategy string
+
+const (
+	// ISRCMatch means the track was found by an exact lookup of its ISRC.
+	ISRCMatch MatchStrategy = "isrc"
+	// UPCMatch means the album was found by an exact lookup of its UPC.
+	UPCMatch MatchStrategy = "upc"
+	// TextSearchMatch means the track was found by searching its title and artist.
+	TextSearchMatch MatchStrategy = "text_search"
+	// AlbumMatch means the track was found among the tracks of the album its own album was matched to.
+	AlbumMatch MatchStrategy = "album"
+	// OverrideMatch means the track was chosen by a user as the match of the source track, see `database.MatchOverride`.
+	OverrideMatch MatchStrategy = "override"
+)
+
+// Account is the account on a streaming platform that an access token belongs to.
+type Account struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
 }
 
 type OauthCredentials struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
-	ExpiresAt    int    `json:"expires_at"`
+	// ExpiresAt is the unix time the access token expires at, it is 0 when the access token does not expire.
+	ExpiresAt int `json:"expires_at"`
+}
+
+// ExpiresAt converts the lifetime in seconds of an access token, as returned by the platforms, to the unix time it expires at.
+func ExpiresAt(expiresIn int) int {
+	if expiresIn <= 0 {
+		return 0
+	}
+
+	return int(time.Now().Unix()) + expiresIn
+}
+
+// ExpiresWithin reports whether the access token expires before the duration elapses.
+func (o *OauthCredentials) ExpiresWithin(d time.Duration) bool {
+	return o.ExpiresAt != 0 && int64(o.ExpiresAt) <= time.Now().Add(d).Unix()
 }
 
 func (o *OauthCredentials) ToBytes() ([]byte, error) {
@@ -6,8 +6,7 @@ const cors = require("cors");
 const basicAuth = require("express-basic-auth");
 const config = require("./config/config");
 const {
-  generateSpotifyAuthenticationURL,
-  generateDeezerAuthenticationURL,
+  fetchAuthenticationURL,
   handleMusicServiceAuthentication,
 } = require("./utils");
 const morgan = require("./middlewares/morgan");
@@ -44,15 +43,17 @@ app.get("/ping", (req, res) => {
 });
 
 app.get("/api/oauth/:platform/link", async (req, res) => {
-  if (req.params.platform === "spotify") {
-    return res.status(200).json({ data: generateSpotifyAuthenticationURL() });
+  if (!["spotify", "deezer"].includes(req.params.platform)) {
+    return res.status(400).json({ message: "Invalid platform provided" });
   }
 
-  if (req.params.platform === "deezer") {
-    return res.status(200).json({ data: generateDeezerAuthenticationURL() });
+  try {
+    const link = await fetchAuthenticationURL(req.params.platform);
+    return res.status(200).json({ data: link });
+  } catch (error) {
+    logger.error(error);
+    return res.status(502).json({ message: error.message });
   }
-
-  return res.status(400).json({ message: "Invalid platform provided" });
 });
 
 app.post("/api/oauth/spotify", async (req, res) => {
@@ -64,7 +65,6 @@ app.post("/api/oauth/spotify", async (req, res) => {
     submitButtonSelector: "#login-button",
     emailSelector: "#login-username",
     passwordSelector: "#login-password",
-    authUrl: generateSpotifyAuthenticationURL(),
   });
   const statusCode = isSuccessful ? 200 : 500;
   return res.status(statusCode).json({ message: statusMsg });
@@ -79,7 +79,6 @@ app.post("/api/oauth/deezer", async (req, res) => {
     submitButtonSelector: "#login_form_submit",
     emailSelector: "#login_mail",
     passwordSelector: "#login_password",
-    authUrl: generateDeezerAuthenticationURL(),
   });
   const statusCode = isSuccessful ? 200 : 500;
   return res.status(statusCode).json({ message: statusMsg });
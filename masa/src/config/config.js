@@ -14,12 +14,6 @@ const envVarsSchema = Joi.object().keys({
   DEEZER_AUTH_PASSWORD: Joi.string()
     .required()
     .description("password to authenticate the deezer account."),
-  DEEZER_APP_ID: Joi.string()
-    .required()
-    .description("Deezer App ID gotten from the dashboard"),
-  DEEZER_AUTH_REDIRECT_URI: Joi.string()
-    .required()
-    .description("The redirect URI gotten from your Deezer Dashboard"),
   SPOTIFY_AUTH_EMAIL: Joi.string()
     .email()
     .required()
@@ -27,12 +21,6 @@ const envVarsSchema = Joi.object().keys({
   SPOTIFY_AUTH_PASSWORD: Joi.string()
     .required()
     .description("password to authenticate the spotify account"),
-  SPOTIFY_CLIENT_ID: Joi.string()
-    .required()
-    .description("Spotify Client ID gotten from the dashboard"),
-  SPOTIFY_AUTH_REDIRECT_URI: Joi.string()
-    .required()
-    .description("The redirect URI gotten from your Spotify Dashboard"),
   BROWSER_EXECUTABLE_PATH: Joi.string()
     .default(
       "/opt/homebrew/Caskroom/google-chrome/113.0.5672.126/Google Chrome.app/Contents/MacOS/Google Chrome"
@@ -41,11 +29,11 @@ const envVarsSchema = Joi.object().keys({
   SECRET_KEY: Joi.string()
     .hex()
     .required()
-    .description("Secret Key used for encryption and authentication"),
-  INITIALIZATION_VECTOR: Joi.string()
-    .hex()
+    .description("Secret Key used for authentication"),
+  KILISHI_BASE_URL: Joi.string()
+    .uri()
     .required()
-    .description("Initialization Vector used for the encryption"),
+    .description("Base URL of kilishi, it issues the authorization links"),
 });
 const { value: envVars, error } = envVarsSchema
   .prefs({ errors: { label: "key" } })
@@ -1,31 +1,9 @@
-const crypto = require("crypto");
 const chromium = require("@sparticuz/chromium");
 const puppeteer = require("puppeteer-extra");
 const StealthPlugin = require("puppeteer-extra-plugin-stealth");
 const config = require("./config/config");
 const logger = require("./config/logger");
 
-/**
- * Encrypts a plain text using AES-256-CBC encryption with the given secret key and IV
- * @param secretKeyHex - A hex-encoded string representing the secret key to use for encryption
- * @param ivHex - A hex-encoded string representing the initialization vector to use for encryption
- * @param plainText - The plain text to encrypt
- * @returns A base64-encoded string representing the encrypted text
- */
-const encryptWithAES256CBC = (secretKeyHex, ivHex, plainText) => {
-  const key = Buffer.from(secretKeyHex, "hex");
-  const iv = Buffer.from(ivHex, "hex");
-  const cipher = crypto.createCipheriv("aes-256-cbc", key, iv);
-
-  let encryptedText = cipher.update(plainText, "utf8", "hex");
-  encryptedText += cipher.final("hex");
-
-  const encryptedTextBuffer = Buffer.from(encryptedText, "hex");
-  const encryptedTextBase64 = encryptedTextBuffer.toString("base64");
-
-  return encryptedTextBase64;
-};
-
 /**
  * Sets up a new Puppeteer browser and page with anti-detection measures enabled.
  * @returns A Promise that resolves to an object containing the new page and browser instances.
@@ -81,8 +59,11 @@ const handleMusicServiceAuthentication = async (authenticationParams) => {
   try {
     let isSuccessful = false;
     let statusMsg = "";
-    await page.goto(authenticationParams.authUrl);
-    logger.info(`Navigated to ${authenticationParams.authUrl}...`);
+    const authUrl = await fetchAuthenticationURL(
+      authenticationParams.serviceName
+    );
+    await page.goto(authUrl);
+    logger.info(`Navigated to ${authUrl}...`);
 
     await page.type(
       authenticationParams.emailSelector,
@@ -117,49 +98,27 @@ const handleMusicServiceAuthentication = async (authenticationParams) => {
 };
 
 /**
- * Generates an authentication URL with query string parameters.
- * @param url The base URL to append the query string parameters to.
- * @param queryParams An object containing the query string parameters to be appended to the URL.
- * @returns The URL with the appended query string parameters.
+ * Fetches the authorization link of a music streaming service from kilishi.
+ * The link carries a signed, single-use state, so a new one must be fetched for every login.
+ * @param platform - The name of the music streaming service e.g. spotify, deezer.
+ * @returns A Promise that resolves to the authorization link.
  */
-const generateAuthenticationURL = (url, queryParams) => {
-  const searchParams = new URLSearchParams();
-  for (const [key, value] of Object.entries(queryParams)) {
-    searchParams.set(key, value);
+const fetchAuthenticationURL = async (platform) => {
+  const response = await fetch(
+    `${config.KILISHI_BASE_URL}/api/v1/auth/${platform}/login?redirect=false`
+  );
+  const body = await response.json();
+  if (!response.ok) {
+    throw new Error(
+      `Unable to fetch ${platform} authorization link: ${body.message}`
+    );
   }
 
-  return `${url}?${searchParams.toString()}`;
-};
-
-const generateSpotifyAuthenticationURL = () => {
-  return generateAuthenticationURL("https://accounts.spotify.com/authorize", {
-    response_type: "code",
-    client_id: config.SPOTIFY_CLIENT_ID,
-    redirect_uri: config.SPOTIFY_AUTH_REDIRECT_URI,
-    scope: "playlist-modify-public",
-    state: encryptWithAES256CBC(
-      config.SECRET_KEY,
-      config.INITIALIZATION_VECTOR,
-      `${Date.now()}:spotify`
-    ),
-  });
-};
-
-const generateDeezerAuthenticationURL = () => {
-  return generateAuthenticationURL(
-    "https://connect.deezer.com/oauth/auth.php",
-    {
-      app_id: config.DEEZER_APP_ID,
-      redirect_uri: config.DEEZER_AUTH_REDIRECT_URI,
-      perms: "manage_library,offline_access",
-    }
-  );
+  return body.data;
 };
 
 module.exports = {
   getPuppeteerSetup,
-  encryptWithAES256CBC,
-  generateDeezerAuthenticationURL,
-  generateSpotifyAuthenticationURL,
+  fetchAuthenticationURL,
   handleMusicServiceAuthentication,
 };
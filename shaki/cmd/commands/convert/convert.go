@@ -1,12 +1,11 @@
 package convert
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
-	"regexp"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/briandowns/spinner"
@@ -15,6 +14,8 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/prettyirrelevant/kilishi/streaming_platforms/aggregator"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+	"github.com/prettyirrelevant/kilishi/utils"
 	"github.com/prettyirrelevant/shaki/cmd/services"
 )
 
@@ -22,96 +23,230 @@ var (
 	ErrInvalidPlaylistURL               = errors.New("link provided does not match any of the supported streaming platforms")
 	ErrPlaylistURLRequired              = errors.New("please provide a link to the playlist")
 	ErrPlaylistSourceAndDestinationSame = errors.New("source and destination cannot be the same")
+	ErrInvalidMinConfidence             = errors.New("min-confidence must be between 0 and 1")
 )
 
+var (
+	// minConfidence is the confidence (0 to 1) below which a track is reported as not found instead of using a poor match.
+	minConfidence float64
+	// fromLibrary converts the liked songs on the source instead of a playlist.
+	fromLibrary bool
+	// toLibrary saves the tracks to the liked songs on the destination instead of creating a playlist.
+	toLibrary bool
+)
+
+func init() {
+	ConvertCmd.Flags().Float64Var(&minConfidence, "min-confidence", 0, "tracks matched with a lower confidence (0 to 1) are reported as not found")
+	ConvertCmd.Flags().BoolVar(&fromLibrary, "from-library", false, "convert your liked songs on the source instead of a playlist")
+	ConvertCmd.Flags().BoolVar(&toLibrary, "to-library", false, "save the tracks to your liked songs on the destination instead of creating a playlist")
+}
+
 var ConvertCmd = &cobra.Command{
 	Use:   "convert",
-	Short: "Convert a playlist",
+	Short: "Convert a playlist or your liked songs",
 	Run: func(cmd *cobra.Command, args []string) {
-		url := getPlaylistURLInput()
-		source := getStreamingPlatformInput("Source")
-		destination := getStreamingPlatformInput("Destination")
-		if source == destination {
+		if minConfidence < 0 || minConfidence > 1 {
+			log.Error(ErrInvalidMinConfidence)
+			return
+		}
+
+		opts := services.CreateConversionOpts{Target: "playlist", MinConfidence: minConfidence}
+		if fromLibrary {
+			opts.SourceType = "library"
+			opts.SourcePlatform = getStreamingPlatformInput("Source", func(c registry.Capabilities) bool { return c.Library })
+		} else {
+			opts.SourceURL = getPlaylistURLInput()
+			opts.SourcePlatform = detectStreamingPlatform(opts.SourceURL)
+			if opts.SourcePlatform == "" {
+				opts.SourcePlatform = getStreamingPlatformInput("Source", func(c registry.Capabilities) bool { return c.GetPlaylist })
+			} else {
+				log.Info("Detected source platform from the link", "platform", opts.SourcePlatform)
+			}
+		}
+		if toLibrary {
+			opts.Target = "library"
+		}
+		opts.DestinationPlatform = getStreamingPlatformInput("Destination", func(c registry.Capabilities) bool {
+			if toLibrary {
+				return c.Library
+			}
+			return c.CreatePlaylist
+		})
+		if opts.SourcePlatform == opts.DestinationPlatform {
 			log.Error(ErrPlaylistSourceAndDestinationSame)
 			return
 		}
 
-		// get the playlist
+		// the server converts the tracks without asking again, so the user confirms before the job starts.
+		getConfirmationInput("Do you want to continue")
+
 		s := spinner.New(spinner.CharSets[11], 10*time.Millisecond)
-		s.Suffix = fmt.Sprintf(" Fetching playlist %s on %s...\n", url, source)
+		s.Suffix = " Starting the conversion...\n"
 		setColorErr := s.Color("green", "bold")
 		if setColorErr != nil {
 			log.Warn("Unable to set color for spinner", "err", setColorErr)
 		}
 		s.Start()
 
-		playlist, err := services.GetPlaylist(url, source)
-		s.Stop()
+		createConversionResp, err := services.CreateConversion(opts)
 		if err != nil {
-			log.Error("An error occurred while fetching the playlist", "err", err)
+			s.Stop()
+			log.Error("An error occurred while starting the conversion", "err", err)
 			return
 		}
 
-		// now search for each tracks in the playlist
-		var wg sync.WaitGroup
-		var result sync.Map
-
-		s.Suffix = fmt.Sprintf(" Searching for %d tracks on %s...\n", len(playlist.Data.Tracks), destination)
-		s.Restart()
-		for index, track := range playlist.Data.Tracks {
-			wg.Add(1)
-			go func(i int, entry services.TrackResponse) {
-				defer wg.Done()
-				resp, _err := services.FindTrack(entry.Title, destination, entry.Artists)
-				if _err != nil {
-					result.Store(i, TrackResult{Success: false})
-				} else {
-					result.Store(i, TrackResult{Success: true, Result: resp.Data})
-				}
-			}(index, track)
-		}
-		wg.Wait()
+		// render the progress of the job as it happens, the stream ends once the job completes or fails.
+		progress := &conversionProgress{spinner: s, destination: opts.DestinationPlatform, toLibrary: toLibrary}
+		err = services.StreamConversionEvents(context.Background(), createConversionResp.Data.ID, progress.onEvent)
 		s.Stop()
+		if err != nil {
+			log.Error("An error occurred while following the conversion", "err", err, "Conversion", createConversionResp.Data.ID)
+			return
+		}
 
-		// show the summary of the playlist search.
-		var successfulSearches []services.TrackResponse
-		var failedSearchesIndex []int
-		for i := 0; i < len(playlist.Data.Tracks); i++ {
-			value, _ := result.Load(i)
-			result, _ := value.(TrackResult)
+		conversionResp, err := services.GetConversion(createConversionResp.Data.ID)
+		if err != nil {
+			log.Error("An error occurred while fetching the result of the conversion", "err", err)
+			return
+		}
 
-			if result.Success {
-				successfulSearches = append(successfulSearches, result.Result)
-			} else {
-				failedSearchesIndex = append(failedSearchesIndex, i)
-			}
+		// show the summary of the conversion, then let signed in users correct any of the matches for future conversions.
+		logSearchSummary(conversionResp.Data.Tracks)
+		for services.IsSignedIn() && len(conversionResp.Data.Tracks) > 0 && isConfirmed("Do you want to correct any of the matches") {
+			correctMatch(conversionResp.Data.Tracks, opts.SourcePlatform, opts.DestinationPlatform)
 		}
+	},
+}
 
-		log.Info("Playlist tracks conversion info:", "Tracks found", len(successfulSearches), "Total number of tracks", len(playlist.Data.Tracks))
-		if len(playlist.Data.Tracks)-len(successfulSearches) > 0 {
-			for _, v := range failedSearchesIndex {
-				log.Info("Track not found info:", "Track", v, "Title", playlist.Data.Tracks[v].Title, "Artists", playlist.Data.Tracks[v].Artists)
-			}
+// conversionProgress renders the events of a conversion job, the spinner shows the overall progress
+// while every track is logged above it as soon as it has been matched.
+type conversionProgress struct {
+	spinner     *spinner.Spinner
+	destination string
+	toLibrary   bool
+	// total is the number of tracks to match, and matched the number of tracks processed so far.
+	total   int
+	matched int
+}
+
+func (p *conversionProgress) onEvent(event services.ConversionEvent) {
+	// the spinner is stopped while logging so the lines are not mixed up with it.
+	p.spinner.Stop()
+	defer func() {
+		if !event.IsFinal() {
+			p.spinner.Start()
 		}
+	}()
 
-		// show a prompt asking if user wants to proceed and create the playlist
-		getConfirmationInput("Do you want to continue")
-		s.Suffix = fmt.Sprintf(" Creating playlist on %s with %d tracks...\n", destination, len(successfulSearches))
-		s.Restart()
-		createPlaylistResp, err := services.CreatePlaylist(playlist.Data.Title, playlist.Data.Description, destination, successfulSearches)
-		s.Stop()
-		if err != nil {
-			log.Error("An error occurred during playlist creation", "err", err)
+	switch event.Type {
+	case "playlist_fetched":
+		p.total = event.TrackCount
+		log.Info("Fetched the tracks to convert", "Title", event.Title, "Tracks", event.TrackCount)
+		p.spinner.Suffix = fmt.Sprintf(" Matching tracks on %s %s\n", p.destination, progressBar(0, p.total))
+	case "album_matched":
+		if event.Album != nil {
+			log.Info("Album found", "Title", event.Album.Title, "Artists", strings.Join(event.Album.Artists, ", "), "URL", event.Album.URL)
+		}
+	case "track_matched", "track_unmatched":
+		p.matched++
+		p.spinner.Suffix = fmt.Sprintf(" Matching tracks on %s %s\n", p.destination, progressBar(p.matched, p.total))
+		if event.Track == nil {
 			return
 		}
 
-		log.Info("Playlist created successfully ;)", "URL", createPlaylistResp.Data)
-	},
+		source := event.Track.Source
+		if event.Track.Match == nil {
+			log.Warn("Track not found", "Track", event.Position, "Title", source.Title, "Artists", strings.Join(source.Artists, ", "), "err", event.Track.Error)
+			return
+		}
+		match := event.Track.Match
+		log.Info("Track matched", "Track", event.Position, "Title", source.Title, "Match", fmt.Sprintf("%s by %s", match.Title, strings.Join(match.Artists, ", ")), "Confidence", match.Confidence)
+	case "tracks_added":
+		if p.toLibrary {
+			p.spinner.Suffix = fmt.Sprintf(" Saving tracks to your liked songs on %s %s\n", p.destination, progressBar(event.Added, event.Total))
+		} else {
+			p.spinner.Suffix = fmt.Sprintf(" Adding tracks to the playlist on %s %s\n", p.destination, progressBar(event.Added, event.Total))
+		}
+	case "completed":
+		if p.toLibrary {
+			log.Info("Tracks saved successfully ;)", "Tracks", event.MatchedTracks)
+			return
+		}
+		log.Info("Playlist created successfully ;)", "Tracks", event.MatchedTracks, "URL", event.PlaylistURL)
+	case "failed":
+		log.Error("The conversion failed", "err", event.Error)
+	}
+}
+
+// progressBar renders how many of the total items are done, e.g. `[#####---------------] 10/40`.
+func progressBar(done, total int) string {
+	const width = 20
+
+	filled := width
+	if total > 0 && done < total {
+		filled = done * width / total
+	}
+	return fmt.Sprintf("[%s%s] %d/%d", strings.Repeat("#", filled), strings.Repeat("-", width-filled), done, total)
 }
 
-type TrackResult struct {
-	Success bool
-	Result  services.TrackResponse
+// logSearchSummary shows how many of the tracks were found and lists the ones that were not.
+func logSearchSummary(tracks []services.ConversionTrackResult) {
+	var tracksFound int
+	var isrcMatches int
+	var failedSearchesIndex []int
+	for i, track := range tracks {
+		if track.Match == nil {
+			failedSearchesIndex = append(failedSearchesIndex, i)
+			continue
+		}
+
+		tracksFound++
+		if track.Match.MatchStrategy == string(utils.ISRCMatch) {
+			isrcMatches++
+		}
+	}
+
+	log.Info("Playlist tracks conversion info:", "Tracks found", tracksFound, "Matched by ISRC", isrcMatches, "Total number of tracks", len(tracks))
+	for _, v := range failedSearchesIndex {
+		log.Info("Track not found info:", "Track", v+1, "Title", tracks[v].Source.Title, "Artists", tracks[v].Source.Artists)
+	}
+}
+
+// correctMatch asks the user for a track of the conversion and the ID of its correct match on the destination.
+// The correction is submitted as a match override, so the later conversions of the track by the user use it.
+func correctMatch(tracks []services.ConversionTrackResult, source, destination string) {
+	items := make([]string, 0, len(tracks))
+	for i, track := range tracks {
+		match := "not found"
+		if track.Match != nil {
+			match = fmt.Sprintf("%s by %s", track.Match.Title, strings.Join(track.Match.Artists, ", "))
+		}
+		items = append(items, fmt.Sprintf("%d. %s by %s -> %s", i+1, track.Source.Title, strings.Join(track.Source.Artists, ", "), match))
+	}
+
+	prompt := promptui.Select{
+		Label:    "Track to correct",
+		Items:    items,
+		HideHelp: true,
+		Searcher: func(input string, index int) bool {
+			return strings.Contains(strings.ToLower(items[index]), strings.ToLower(input))
+		},
+	}
+	index, _, err := prompt.Run()
+	if err != nil {
+		return
+	}
+
+	destinationID := getTextInput(fmt.Sprintf("ID of the correct track on %s", destination))
+	note := getTextInput("Why is the match wrong (optional)")
+	resp, err := services.CreateMatchOverride(tracks[index].Source, source, destination, destinationID, note)
+	if err != nil {
+		log.Error("An error occurred while submitting the correction", "err", err)
+		return
+	}
+
+	tracks[index].Match = &services.TrackResponse{ID: resp.Data.DestinationID, Title: resp.Data.Title, Artists: resp.Data.Artists}
+	log.Info("Correction submitted, it will be used for your future conversions of the track.", "Track", tracks[index].Source.Title)
 }
 
 func getPlaylistURLInput() string {
@@ -120,7 +255,7 @@ func getPlaylistURLInput() string {
 			return ErrPlaylistURLRequired
 		}
 
-		if ok, _ := regexp.MatchString(`^https:\/\/(open\.spotify\.com|deezer\.com|music\.youtube\.com)`, input); !ok {
+		if _, _, ok := aggregator.DetectPlatform(input); !ok {
 			return ErrInvalidPlaylistURL
 		}
 
@@ -140,6 +275,40 @@ func getPlaylistURLInput() string {
 	return result
 }
 
+// detectStreamingPlatform returns the platform the playlist link belongs to, or an empty string if it cannot be detected.
+func detectStreamingPlatform(url string) string {
+	platform, kind, ok := aggregator.DetectPlatform(url)
+	if !ok || kind != registry.PlaylistURL {
+		return ""
+	}
+
+	return string(platform)
+}
+
+// isConfirmed asks the user a yes/no question, unlike getConfirmationInput declining does not exit.
+func isConfirmed(label string) bool {
+	prompt := promptui.Prompt{
+		Label:     label,
+		IsConfirm: true,
+	}
+
+	_, err := prompt.Run()
+	return err == nil
+}
+
+func getTextInput(label string) string {
+	prompt := promptui.Prompt{
+		Label: label,
+	}
+
+	result, err := prompt.Run()
+	if err != nil {
+		os.Exit(1)
+	}
+
+	return strings.TrimSpace(result)
+}
+
 func getConfirmationInput(label string) string {
 	prompt := promptui.Prompt{
 		Label:     label,
@@ -154,10 +323,10 @@ func getConfirmationInput(label string) string {
 	return result
 }
 
-func getStreamingPlatformInput(label string) string {
+func getStreamingPlatformInput(label string, supports func(registry.Capabilities) bool) string {
 	prompt := promptui.Select{
 		Label:    label,
-		Items:    allMusicStreamingPlatforms(),
+		Items:    allMusicStreamingPlatforms(supports),
 		HideHelp: true,
 	}
 
@@ -169,10 +338,13 @@ func getStreamingPlatformInput(label string) string {
 	return result
 }
 
-func allMusicStreamingPlatforms() []string {
+// allMusicStreamingPlatforms returns the names of the registered platforms that have the required capability.
+func allMusicStreamingPlatforms(supports func(registry.Capabilities) bool) []string {
 	var platforms []string
-	for i := range aggregator.AllMusicStreamingPlatforms {
-		platforms = append(platforms, string(i))
+	for _, factory := range registry.All() {
+		if supports(factory.Capabilities) {
+			platforms = append(platforms, string(factory.Name))
+		}
 	}
 
 	return platforms
@@ -0,0 +1,104 @@
+package link
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/aggregator"
+	"github.com/prettyirrelevant/kilishi/streaming_platforms/registry"
+	"github.com/prettyirrelevant/shaki/cmd/services"
+)
+
+var (
+	ErrInvalidLinkURL       = errors.New("link provided is not a track or album on any of the supported streaming platforms")
+	ErrInvalidMinConfidence = errors.New("min-confidence must be between 0 and 1")
+)
+
+// minConfidence is the confidence (0 to 1) below which a link is reported as not found instead of using a poor match.
+var minConfidence float64
+
+func init() {
+	LinkCmd.Flags().Float64Var(&minConfidence, "min-confidence", 0, "links matched with a lower confidence (0 to 1) are reported as not found")
+}
+
+var LinkCmd = &cobra.Command{
+	Use:   "link <url>",
+	Short: "Find a track or an album on every other streaming platform",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if minConfidence < 0 || minConfidence > 1 {
+			log.Error(ErrInvalidMinConfidence)
+			return
+		}
+
+		url := strings.TrimSpace(args[0])
+		platform, kind, ok := aggregator.DetectPlatform(url)
+		if !ok || (kind != registry.TrackURL && kind != registry.AlbumURL) {
+			log.Error(ErrInvalidLinkURL)
+			return
+		}
+
+		s := spinner.New(spinner.CharSets[11], 10*time.Millisecond)
+		s.Suffix = fmt.Sprintf(" Finding %s on the other platforms...\n", url)
+		setColorErr := s.Color("green", "bold")
+		if setColorErr != nil {
+			log.Warn("Unable to set color for spinner", "err", setColorErr)
+		}
+		s.Start()
+
+		if kind == registry.TrackURL {
+			response, err := services.ResolveTrack(url, minConfidence)
+			s.Stop()
+			if err != nil {
+				log.Error("An error occurred while resolving the track", "err", err)
+				return
+			}
+
+			log.Info("Resolved track", "platform", platform, "title", response.Data.Source.Title, "artists", strings.Join(response.Data.Source.Artists, ", "))
+			for _, name := range sortedKeys(response.Data.Links) {
+				link := response.Data.Links[name]
+				if link.Track == nil {
+					log.Warn("Not found", "platform", name, "err", link.Error)
+					continue
+				}
+				log.Info("Found", "platform", name, "url", link.Track.URL, "confidence", fmt.Sprintf("%.2f", link.Track.Confidence))
+			}
+			return
+		}
+
+		response, err := services.ResolveAlbum(url, minConfidence)
+		s.Stop()
+		if err != nil {
+			log.Error("An error occurred while resolving the album", "err", err)
+			return
+		}
+
+		log.Info("Resolved album", "platform", platform, "title", response.Data.Source.Title, "artists", strings.Join(response.Data.Source.Artists, ", "), "tracks", response.Data.Source.TrackCount)
+		for _, name := range sortedKeys(response.Data.Links) {
+			link := response.Data.Links[name]
+			if link.Album == nil {
+				log.Warn("Not found", "platform", name, "err", link.Error)
+				continue
+			}
+			log.Info("Found", "platform", name, "url", link.Album.URL, "confidence", fmt.Sprintf("%.2f", link.Album.Confidence))
+		}
+	},
+}
+
+// sortedKeys returns the platforms of the links in alphabetical order, so they are printed in the same order every time.
+func sortedKeys[T any](links map[string]T) []string {
+	keys := make([]string, 0, len(links))
+	for key := range links {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
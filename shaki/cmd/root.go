@@ -6,14 +6,24 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/prettyirrelevant/shaki/cmd/commands/convert"
+	"github.com/prettyirrelevant/shaki/cmd/commands/link"
+	"github.com/prettyirrelevant/shaki/cmd/services"
 )
 
 const Version = "0.0.4"
 
+// sessionToken is the token returned when signing in to waakye, it defaults to the WAAKYE_TOKEN environment variable.
+var sessionToken string
+
 var rootCmd = &cobra.Command{
 	Use:     "waakye",
 	Short:   "This is the CLI application for the playlist converter, waakye.",
 	Version: Version,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if sessionToken != "" {
+			services.SetSessionToken(sessionToken)
+		}
+	},
 }
 
 func Execute() {
@@ -24,5 +34,7 @@ func Execute() {
 }
 
 func init() {
+	rootCmd.PersistentFlags().StringVar(&sessionToken, "token", os.Getenv("WAAKYE_TOKEN"), "session token used to create playlists and read liked songs in your own accounts")
 	rootCmd.AddCommand(convert.ConvertCmd)
+	rootCmd.AddCommand(link.LinkCmd)
 }
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/imroc/req/v3"
+)
+
+// maxEventStreamReconnects is how many times the event stream of a conversion is resumed after the connection drops.
+const maxEventStreamReconnects = 3
+
+// CreateConversionOpts describes what a conversion job converts and where to.
+type CreateConversionOpts struct {
+	// SourceType is `playlist`, `album` or `library`, it is detected from SourceURL when empty.
+	SourceType          string
+	SourceURL           string
+	SourcePlatform      string
+	DestinationPlatform string
+	// Target is `playlist` or `library`.
+	Target        string
+	MinConfidence float64
+}
+
+// CreateConversion starts a conversion job on the server, it runs in the background and reports its progress as events.
+func CreateConversion(opts CreateConversionOpts) (APIConversionResponse, error) {
+	var response APIConversionResponse
+
+	err := reqClient.
+		Post("/conversions").
+		// creating a job is not idempotent, a retry would start the conversion twice.
+		SetRetryCount(0).
+		SetBodyJsonMarshal(map[string]any{
+			"source_type":          opts.SourceType,
+			"source_url":           opts.SourceURL,
+			"source_platform":      opts.SourcePlatform,
+			"destination_platform": opts.DestinationPlatform,
+			"target":               opts.Target,
+			"min_confidence":       opts.MinConfidence,
+		}).
+		Do().
+		Into(&response)
+
+	if err != nil {
+		return response, err
+	}
+
+	return response, nil
+}
+
+// GetConversion returns the conversion job, including the result of every track matched so far.
+func GetConversion(id string) (APIConversionResponse, error) {
+	var response APIConversionResponse
+	err := reqClient.
+		Get("/conversions/{id}").
+		SetPathParam("id", id).
+		Do().
+		Into(&response)
+
+	if err != nil {
+		return response, err
+	}
+
+	return response, nil
+}
+
+// StreamConversionEvents calls onEvent with every event of the conversion job as it happens, until the job completes or fails.
+// The stream is resumed from the last event received when the connection drops.
+func StreamConversionEvents(ctx context.Context, id string, onEvent func(ConversionEvent)) error {
+	lastEventID := ""
+	for reconnects := 0; ; reconnects++ {
+		done, err := streamConversionEvents(ctx, id, &lastEventID, onEvent)
+		if done {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if reconnects == maxEventStreamReconnects {
+			if err == nil {
+				err = io.ErrUnexpectedEOF
+			}
+			return fmt.Errorf("the progress of the conversion could not be followed: %w", err)
+		}
+	}
+}
+
+// streamConversionEvents reads the `text/event-stream` of the job until it ends, lastEventID is kept up to date to resume from.
+// It reports whether the final event of the job was received.
+func streamConversionEvents(ctx context.Context, id string, lastEventID *string, onEvent func(ConversionEvent)) (bool, error) {
+	request := reqClient.
+		Get("/conversions/{id}/events").
+		SetPathParam("id", id).
+		SetContext(ctx).
+		SetHeader("Accept", "text/event-stream").
+		// the stream is read as it arrives, retrying would only read it from the start again.
+		SetRetryCount(0).
+		DisableAutoReadResponse().
+		SetDumpOptions(&req.DumpOptions{RequestHeader: true, RequestBody: true, ResponseHeader: true})
+	if *lastEventID != "" {
+		request.SetHeader("Last-Event-ID", *lastEventID)
+	}
+
+	resp := request.Do()
+	if resp.Err != nil {
+		if resp.Response != nil && resp.Body != nil {
+			_ = resp.Body.Close()
+		}
+		return false, resp.Err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var eventID string
+	var data strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// a blank line dispatches the event, lines starting with a colon are comments such as keep-alives.
+		if line == "" {
+			if data.Len() == 0 {
+				continue
+			}
+
+			var event ConversionEvent
+			if err := json.Unmarshal([]byte(data.String()), &event); err != nil {
+				return false, fmt.Errorf("conversion event is malformed: %w", err)
+			}
+			data.Reset()
+			*lastEventID = eventID
+
+			onEvent(event)
+			if event.IsFinal() {
+				return true, nil
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			eventID = value
+		case "data":
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return false, err
+	}
+	return false, errors.New("the event stream ended before the conversion finished")
+}
+
+type APIConversionResponse struct {
+	Data    ConversionJob `json:"data"`
+	Message string        `json:"message"`
+}
+
+// ConversionJob is a conversion running on the server, see CreateConversion.
+type ConversionJob struct {
+	ID                  string                  `json:"id"`
+	Status              string                  `json:"status"`
+	SourceType          string                  `json:"source_type"`
+	SourceURL           string                  `json:"source_url"`
+	SourcePlatform      string                  `json:"source_platform"`
+	DestinationPlatform string                  `json:"destination_platform"`
+	Target              string                  `json:"target"`
+	Title               string                  `json:"title"`
+	Description         string                  `json:"description"`
+	Tracks              []ConversionTrackResult `json:"tracks"`
+	MatchedTracks       int                     `json:"matched_tracks"`
+	PlaylistURL         string                  `json:"playlist_url"`
+	Error               string                  `json:"error"`
+}
+
+// ConversionTrackResult is the outcome of matching one track of the source on the destination platform.
+type ConversionTrackResult struct {
+	Source TrackResponse `json:"source"`
+	// Match is nil when the track could not be found, Error then holds the reason.
+	Match *TrackResponse `json:"match"`
+	Error string         `json:"error"`
+}
+
+// ConversionEvent is a step in the progress of a conversion job, only the fields relevant to its type are set.
+type ConversionEvent struct {
+	Type          string                 `json:"type"`
+	Title         string                 `json:"title"`
+	TrackCount    int                    `json:"track_count"`
+	Album         *AlbumResponse         `json:"album"`
+	Position      int                    `json:"position"`
+	Track         *ConversionTrackResult `json:"track"`
+	Added         int                    `json:"added"`
+	Total         int                    `json:"total"`
+	MatchedTracks int                    `json:"matched_tracks"`
+	PlaylistURL   string                 `json:"playlist_url"`
+	Error         string                 `json:"error"`
+}
+
+// IsFinal reports whether the event ends the conversion, no event follows it.
+func (c ConversionEvent) IsFinal() bool {
+	return c.Type == "completed" || c.Type == "failed"
+}
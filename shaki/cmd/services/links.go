@@ -0,0 +1,79 @@
+package services
+
+import "strconv"
+
+// ResolveTrack fetches the track the link points at and searches for it on every other platform.
+// Matches with a confidence below minConfidence are reported as not found.
+func ResolveTrack(url string, minConfidence float64) (APIResolveTrackResponse, error) {
+	var response APIResolveTrackResponse
+	err := reqClient.
+		Get("/tracks/resolve").
+		SetQueryParams(map[string]string{
+			"url":            url,
+			"min_confidence": strconv.FormatFloat(minConfidence, 'f', -1, 64),
+		}).
+		Do().
+		Into(&response)
+
+	if err != nil {
+		return response, err
+	}
+
+	return response, nil
+}
+
+// ResolveAlbum fetches the album the link points at and searches for it on every other platform.
+// Matches with a confidence below minConfidence are reported as not found.
+func ResolveAlbum(url string, minConfidence float64) (APIResolveAlbumResponse, error) {
+	var response APIResolveAlbumResponse
+	err := reqClient.
+		Get("/albums/resolve").
+		SetQueryParams(map[string]string{
+			"url":            url,
+			"min_confidence": strconv.FormatFloat(minConfidence, 'f', -1, 64),
+		}).
+		Do().
+		Into(&response)
+
+	if err != nil {
+		return response, err
+	}
+
+	return response, nil
+}
+
+type APIResolveTrackResponse struct {
+	Data struct {
+		Source TrackResponse `json:"source"`
+		Links  map[string]struct {
+			// Track is nil when the track could not be found.
+			Track *TrackResponse `json:"track"`
+			Error string         `json:"error"`
+		} `json:"links"`
+	} `json:"data"`
+	Message string `json:"message"`
+}
+
+type APIResolveAlbumResponse struct {
+	Data struct {
+		Source AlbumResponse `json:"source"`
+		Links  map[string]struct {
+			// Album is nil when the album could not be found.
+			Album *AlbumResponse `json:"album"`
+			Error string         `json:"error"`
+		} `json:"links"`
+	} `json:"data"`
+	Message string `json:"message"`
+}
+
+type AlbumResponse struct {
+	ID            string          `json:"id"`
+	Title         string          `json:"title"`
+	Artists       []string        `json:"artists"`
+	Tracks        []TrackResponse `json:"tracks"`
+	UPC           string          `json:"upc"`
+	URL           string          `json:"url"`
+	TrackCount    int             `json:"track_count"`
+	MatchStrategy string          `json:"match_strategy"`
+	Confidence    float64         `json:"confidence"`
+}
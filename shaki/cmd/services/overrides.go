@@ -0,0 +1,37 @@
+package services
+
+// CreateMatchOverride records the track on the destination platform as the match of the source track,
+// it is used instead of searching whenever the signed in user converts the source track to the destination again.
+func CreateMatchOverride(track TrackResponse, source, destination, destinationID, note string) (APICreateMatchOverrideResponse, error) {
+	var response APICreateMatchOverrideResponse
+
+	err := reqClient.
+		Post("/overrides").
+		SetBodyJsonMarshal(map[string]any{
+			"source_platform":      source,
+			"source_id":            track.ID,
+			"destination_platform": destination,
+			"destination_id":       destinationID,
+			"note":                 note,
+		}).
+		Do().
+		Into(&response)
+
+	if err != nil {
+		return response, err
+	}
+
+	return response, nil
+}
+
+type APICreateMatchOverrideResponse struct {
+	Data struct {
+		SourcePlatform      string   `json:"source_platform"`
+		SourceID            string   `json:"source_id"`
+		DestinationPlatform string   `json:"destination_platform"`
+		DestinationID       string   `json:"destination_id"`
+		Title               string   `json:"title"`
+		Artists             []string `json:"artists"`
+	} `json:"data"`
+	Message string `json:"message"`
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/imroc/req/v3"
 )
@@ -28,6 +29,21 @@ var reqClient = req.C().
 		return resp.StatusCode < 500
 	})
 
+// signedIn is true once a session token has been set.
+var signedIn bool
+
+// SetSessionToken signs the requests in as the user the session token belongs to,
+// so playlists are created and liked songs are read in their own accounts.
+func SetSessionToken(token string) {
+	reqClient.SetCommonBearerAuthToken(token)
+	signedIn = true
+}
+
+// IsSignedIn reports whether the requests are signed in, which submitting corrections requires.
+func IsSignedIn() bool {
+	return signedIn
+}
+
 func GetPlaylist(url, platform string) (APIGetPlaylistResponse, error) {
 	var response APIGetPlaylistResponse
 	err := reqClient.
@@ -43,17 +59,25 @@ func GetPlaylist(url, platform string) (APIGetPlaylistResponse, error) {
 	return response, nil
 }
 
-func FindTrack(title, platform string, artists []string) (APIFindTrackResponse, error) {
+// FindTrack searches for the track on the platform, matches with a confidence below minConfidence are treated as not found.
+func FindTrack(track TrackResponse, platform string, minConfidence float64) (APIFindTrackResponse, error) {
 	var response APIFindTrackResponse
 
 	artistsMap := make(map[string]string)
-	for _, artist := range artists {
+	for _, artist := range track.Artists {
 		artistsMap["artists"] = artist
 	}
 
 	err := reqClient.
 		Get("/playlists/tracks").
-		SetQueryParams(map[string]string{"platform": platform, "title": title}).
+		SetQueryParams(map[string]string{
+			"platform":       platform,
+			"title":          track.Title,
+			"isrc":           track.ISRC,
+			"album":          track.Album,
+			"duration":       strconv.Itoa(track.Duration),
+			"min_confidence": strconv.FormatFloat(minConfidence, 'f', -1, 64),
+		}).
 		SetQueryParams(artistsMap).
 		Do().
 		Into(&response)
@@ -70,7 +94,7 @@ func CreatePlaylist(title, description, platform string, tracks []TrackResponse)
 
 	var tracksMap []map[string]any
 	for _, track := range tracks {
-		tracksMap = append(tracksMap, map[string]any{"id": track.ID, "title": track.Title, "artists": track.Artists})
+		tracksMap = append(tracksMap, map[string]any{"id": track.ID, "title": track.Title, "artists": track.Artists, "isrc": track.ISRC})
 	}
 
 	payload := make(map[string]any)
@@ -99,6 +123,8 @@ type APIGetPlaylistResponse struct {
 		ID          string          `json:"id"`
 		Title       string          `json:"title"`
 		Description string          `json:"description"`
+		Owner       string          `json:"owner"`
+		TrackCount  int             `json:"track_count"`
 		Tracks      []TrackResponse `json:"tracks"`
 	} `json:"data"`
 	Message string `json:"message"`
@@ -110,9 +136,17 @@ type APIFindTrackResponse struct {
 }
 
 type TrackResponse struct {
-	ID      string   `json:"id"`
-	Title   string   `json:"title"`
-	Artists []string `json:"artists"`
+	ID            string   `json:"id"`
+	Title         string   `json:"title"`
+	Artists       []string `json:"artists"`
+	ISRC          string   `json:"isrc"`
+	Duration      int      `json:"duration"`
+	Album         string   `json:"album"`
+	Explicit      bool     `json:"explicit"`
+	URL           string   `json:"url"`
+	Position      int      `json:"position"`
+	MatchStrategy string   `json:"match_strategy"`
+	Confidence    float64  `json:"confidence"`
 }
 
 type APICreatePlaylistResponse struct {